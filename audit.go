@@ -0,0 +1,446 @@
+package gopherdb
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditOperation is an operation recorded by the audit log.
+type AuditOperation string
+
+const (
+	// AuditInsert records inserted documents.
+	AuditInsert AuditOperation = "insert"
+	// AuditUpdate records updated, replaced or upserted documents.
+	AuditUpdate AuditOperation = "update"
+	// AuditDelete records deleted documents.
+	AuditDelete AuditOperation = "delete"
+	// AuditCreateIndexes records created indexes.
+	AuditCreateIndexes AuditOperation = "createIndexes"
+	// AuditDropCollection records dropped collections.
+	AuditDropCollection AuditOperation = "dropCollection"
+	// AuditRenameCollection records renamed collections.
+	AuditRenameCollection AuditOperation = "renameCollection"
+)
+
+// AuditOutcome is the outcome of an audited operation.
+type AuditOutcome string
+
+const (
+	// AuditSuccess is the outcome of an operation that was applied.
+	AuditSuccess AuditOutcome = "success"
+	// AuditFailure is the outcome of an operation that failed.
+	AuditFailure AuditOutcome = "failure"
+)
+
+// AuditRecord is an entry of the audit log. The filter is a summary: its fields and operators are
+// kept and the values are replaced by their type, except document IDs, so the log does not copy
+// the data it protects.
+type AuditRecord struct {
+	Time      time.Time      `bson:"ts"`
+	Principal string         `bson:"principal,omitempty"`
+	Operation AuditOperation `bson:"op"`
+	Namespace Namespace      `bson:"ns"`
+	Target    *Namespace     `bson:"to,omitempty"`
+	Filter    map[string]any `bson:"filter,omitempty"`
+	IDs       []any          `bson:"ids,omitempty"`
+	Indexes   []string       `bson:"indexes,omitempty"`
+	Outcome   AuditOutcome   `bson:"outcome"`
+	Error     string         `bson:"error,omitempty"`
+}
+
+// auditLog writes the audit records of a database to an NDJSON file, an internal collection, or
+// both.
+type auditLog struct {
+	db         *Database
+	principal  string
+	file       *auditFile
+	collection string
+	logger     options.Logger
+
+	mu   sync.Mutex
+	coll *Collection
+}
+
+// newAuditLog opens the audit log of a database.
+func newAuditLog(db *Database, opt *options.AuditOptions, logger options.Logger) (*auditLog, error) {
+	l := &auditLog{db: db, logger: logger}
+
+	if opt.Principal != nil {
+		l.principal = *opt.Principal
+	}
+
+	if opt.Collection != nil {
+		l.collection = *opt.Collection
+	}
+
+	if opt.Path != nil && *opt.Path != "" {
+		f, err := openAuditFile(*opt.Path, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		l.file = f
+	}
+
+	return l, nil
+}
+
+// close releases the audit file.
+func (l *auditLog) close() {
+	if l != nil && l.file != nil {
+		l.file.release()
+	}
+}
+
+// write writes a record. Audit failures do not fail the audited operation, which has already
+// been applied; they are reported to the logger of the database.
+func (l *auditLog) write(rec AuditRecord) {
+	if l.file != nil {
+		if err := l.file.write(rec); err != nil {
+			l.report(err)
+		}
+	}
+
+	if l.collection != "" && rec.Namespace.Collection != l.collection {
+		if err := l.insert(rec); err != nil {
+			l.report(err)
+		}
+	}
+}
+
+// insert stores a record in the audit collection. The record bypasses the public API so it is not
+// audited itself.
+func (l *auditLog) insert(rec AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.coll == nil {
+		coll, err := newCollection(l.db, l.collection)
+		if err != nil {
+			return err
+		}
+
+		l.coll = coll
+	}
+
+	txn, err := l.db.beginWrite()
+	if err != nil {
+		return err
+	}
+
+	if result := l.coll.insertOne(txn, rec); result.Err != nil {
+		txn.Rollback()
+
+		return result.Err
+	}
+
+	return txn.Commit()
+}
+
+// report reports an audit failure.
+func (l *auditLog) report(err error) {
+	if l.logger != nil {
+		l.logger.Errorf("audit log of database %s: %v", l.db.name, err)
+	}
+}
+
+// audit records an operation of the collection when its database has an audit log.
+func (c *Collection) audit(op AuditOperation, filter any, ids []any, indexes []string, err error) {
+	c.auditRecord(op, err, func(rec *AuditRecord) {
+		rec.Indexes = indexes
+
+		if filter != nil {
+			if doc, err := bson.ToDocument(filter); err == nil {
+				rec.Filter, _ = summarizeFilter(doc).(map[string]any)
+			}
+		}
+
+		for _, id := range ids {
+			if id != nil {
+				rec.IDs = append(rec.IDs, id)
+			}
+		}
+	})
+}
+
+// auditRename records the rename of the collection to another one of its database.
+func (c *Collection) auditRename(to string, err error) {
+	c.auditRecord(AuditRenameCollection, err, func(rec *AuditRecord) {
+		rec.Target = &Namespace{Database: c.dbname, Collection: to}
+	})
+}
+
+// auditRecord writes a record of an operation of the collection, filled in by fill, when its
+// database has an audit log.
+func (c *Collection) auditRecord(op AuditOperation, err error, fill func(rec *AuditRecord)) {
+	l := c.db.audit
+	if l == nil {
+		return
+	}
+
+	rec := AuditRecord{
+		Time:      time.Now().UTC(),
+		Principal: cmp.Or(c.principal, l.principal),
+		Operation: op,
+		Namespace: Namespace{Database: c.dbname, Collection: c.collname},
+		Outcome:   AuditSuccess,
+	}
+
+	fill(&rec)
+
+	if err != nil {
+		rec.Outcome = AuditFailure
+		rec.Error = err.Error()
+	}
+
+	l.write(rec)
+}
+
+// WithPrincipal returns a handle of the collection whose operations are audited as done by the
+// principal, such as the authenticated user of a server or a tag of the embedding caller. The
+// handle shares the indexes and state of c.
+func (c *Collection) WithPrincipal(principal string) *Collection {
+	cp := *c
+	cp.principal = principal
+
+	return &cp
+}
+
+// summarizeFilter returns the shape of a filter value: documents keep their fields and operators,
+// arrays their elements, and other values are replaced by their type. Document IDs are kept.
+func summarizeFilter(v any) any {
+	switch t := bson.Normalize(v).(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, fv := range t {
+			if k == consts.DocumentFieldID {
+				out[k] = fv
+
+				continue
+			}
+
+			out[k] = summarizeFilter(fv)
+		}
+
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, item := range t {
+			out[i] = summarizeFilter(item)
+		}
+
+		return out
+	default:
+		return bsonTypeName(t)
+	}
+}
+
+// bsonTypeName returns the BSON type name of a value.
+func bsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int32, int:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case time.Time, primitive.DateTime:
+		return "date"
+	case primitive.ObjectID:
+		return "objectId"
+	case primitive.Regex:
+		return "regex"
+	case primitive.Binary, []byte:
+		return "binData"
+	case primitive.Decimal128:
+		return "decimal"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+var (
+	// auditFilesMu guards auditFiles.
+	auditFilesMu sync.Mutex
+	// auditFiles are the open audit files by path. Database handles that share a data directory and
+	// audit options share the file, so rotation sees every write.
+	auditFiles = make(map[string]*auditFile)
+)
+
+// auditFile is an NDJSON audit file rotated by size.
+type auditFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	refs     int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// openAuditFile opens the audit file at path, or shares the already open one.
+func openAuditFile(path string, opt *options.AuditOptions) (*auditFile, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	auditFilesMu.Lock()
+	defer auditFilesMu.Unlock()
+
+	if af, ok := auditFiles[path]; ok {
+		af.refs++
+
+		return af, nil
+	}
+
+	af := &auditFile{path: path, refs: 1}
+
+	if opt.MaxSize != nil {
+		af.maxSize = *opt.MaxSize
+	}
+
+	if opt.MaxFiles != nil {
+		af.maxFiles = *opt.MaxFiles
+	}
+
+	if err := af.open(); err != nil {
+		return nil, err
+	}
+
+	auditFiles[path] = af
+
+	return af, nil
+}
+
+// open opens the file for appending.
+func (af *auditFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(af.path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(af.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	af.f = f
+	af.size = info.Size()
+
+	return nil
+}
+
+// write appends a record as a line of relaxed Extended JSON, rotating the file first when the
+// line would make it larger than the maximum size.
+func (af *auditFile) write(rec AuditRecord) error {
+	data, err := bson.MarshalExtJSON(rec, false)
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
+	if af.f == nil {
+		return os.ErrClosed
+	}
+
+	if af.maxSize > 0 && af.size > 0 && af.size+int64(len(data)) > af.maxSize {
+		if err := af.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := af.f.Write(data)
+	af.size += int64(n)
+
+	return err
+}
+
+// rotate renames the current file with a timestamp suffix, opens a new one and removes the oldest
+// rotated files beyond the maximum count.
+func (af *auditFile) rotate() error {
+	if err := af.f.Close(); err != nil {
+		return err
+	}
+
+	rotated := af.path + "." + time.Now().UTC().Format("20060102T150405.000000000")
+	if err := os.Rename(af.path, rotated); err != nil {
+		return err
+	}
+
+	if err := af.open(); err != nil {
+		af.f = nil
+
+		return err
+	}
+
+	if af.maxFiles <= 0 {
+		return nil
+	}
+
+	old, err := filepath.Glob(af.path + ".*")
+	if err != nil {
+		return err
+	}
+
+	slices.Sort(old)
+
+	for len(old) > af.maxFiles {
+		if err := os.Remove(old[0]); err != nil {
+			return err
+		}
+
+		old = old[1:]
+	}
+
+	return nil
+}
+
+// release drops a reference to the file and closes it with the last one.
+func (af *auditFile) release() {
+	auditFilesMu.Lock()
+	defer auditFilesMu.Unlock()
+
+	af.refs--
+	if af.refs > 0 {
+		return
+	}
+
+	delete(auditFiles, af.path)
+
+	af.mu.Lock()
+	defer af.mu.Unlock()
+
+	if af.f != nil {
+		af.f.Close()
+		af.f = nil
+	}
+}
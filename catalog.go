@@ -0,0 +1,370 @@
+package gopherdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// catalog opens the databases of a data directory on demand for the servers that expose the whole
+// directory. Every database shares the same storage engine.
+type catalog struct {
+	storage storage.Storage
+	dbOpt   *options.DatabaseOptions
+
+	mu          sync.Mutex
+	dbs         map[string]*Database
+	collections map[string]*servedCollection
+}
+
+// servedCollection is a collection exposed by a server. Requests on the same collection are
+// serialized.
+type servedCollection struct {
+	mu   sync.Mutex
+	coll *Collection
+}
+
+// openCatalog opens the storage engine of the data directory at path.
+func openCatalog(path string, opt *options.DatabaseOptions) (*catalog, error) {
+	engine, err := openStorage(path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &catalog{
+		storage:     engine,
+		dbOpt:       opt,
+		dbs:         make(map[string]*Database),
+		collections: make(map[string]*servedCollection),
+	}, nil
+}
+
+// database returns the handle of a database, opening it the first time.
+func (c *catalog) database(name string) (*Database, error) {
+	if name == "" || strings.ContainsAny(name, "/. $") {
+		return nil, fmt.Errorf("%w: invalid database name '%s'", ErrInvalidNamespace, name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if db, ok := c.dbs[name]; ok {
+		return db, nil
+	}
+
+	db, err := newDatabase(name, c.storage, c.dbOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	c.dbs[name] = db
+
+	return db, nil
+}
+
+// collection returns a collection of a database.
+func (c *catalog) collection(dbname, collname string) (*servedCollection, error) {
+	if collname == "" || strings.ContainsAny(collname, "/$") {
+		return nil, fmt.Errorf("%w: invalid collection name '%s'", ErrInvalidNamespace, collname)
+	}
+
+	db, err := c.database(dbname)
+	if err != nil {
+		return nil, err
+	}
+
+	key := dbname + "." + collname
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if sc, ok := c.collections[key]; ok {
+		return sc, nil
+	}
+
+	coll, err := db.Collection(collname)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &servedCollection{coll: coll}
+	c.collections[key] = sc
+
+	return sc, nil
+}
+
+// forget drops the cached handle of a collection.
+func (c *catalog) forget(dbname, collname string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.collections, dbname+"."+collname)
+}
+
+// databaseNames returns the names of the databases of the data directory.
+func (c *catalog) databaseNames() ([]string, error) {
+	return databaseNames(c.storage)
+}
+
+// dropDatabase drops every collection of a database as the user, each one under its lock, and
+// then the database. forget is called with the name of every dropped collection. Nothing is
+// dropped unless the user may manage every collection, so a database that holds system.*
+// collections can only be dropped by root.
+func (c *catalog) dropDatabase(dbname string, user *User, forget func(collname string)) error {
+	db, err := c.database(dbname)
+	if err != nil {
+		return err
+	}
+
+	names, err := db.collectionNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if user != nil && !user.can(actionManage, dbname, name) {
+			return fmt.Errorf("%w: %s on %s.%s", ErrUnauthorized, user.Name, dbname, name)
+		}
+	}
+
+	for _, name := range names {
+		sc, err := c.collection(dbname, name)
+		if err != nil {
+			return err
+		}
+
+		sc.mu.Lock()
+		err = sc.as(user).Drop()
+		sc.mu.Unlock()
+
+		forget(name)
+
+		if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+			return err
+		}
+	}
+
+	return db.Drop()
+}
+
+// renameCollection renames a collection of a database as the user, under the locks of both
+// collections. forget is called with both names.
+func (c *catalog) renameCollection(dbname, from, to string, user *User, dropTarget bool, forget func(collname string)) error {
+	if from == to {
+		return fmt.Errorf("%w: cannot rename %s to itself", ErrInvalidNamespace, from)
+	}
+
+	source, err := c.collection(dbname, from)
+	if err != nil {
+		return err
+	}
+
+	target, err := c.collection(dbname, to)
+	if err != nil {
+		return err
+	}
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	target.mu.Lock()
+	defer target.mu.Unlock()
+
+	err = source.as(user).Rename(to, options.RenameCollection().SetDropTarget(dropTarget))
+	if err != nil {
+		return err
+	}
+
+	forget(from)
+	forget(to)
+
+	return nil
+}
+
+// close closes the databases and the storage engine.
+func (c *catalog) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, db := range c.dbs {
+		db.changes.closeAll()
+		db.audit.close()
+	}
+
+	c.dbs = make(map[string]*Database)
+	c.collections = make(map[string]*servedCollection)
+
+	return c.storage.Close()
+}
+
+// as returns the handle of the collection whose operations are audited as done by the user, or
+// the shared handle when the server has no authentication.
+func (sc *servedCollection) as(user *User) *Collection {
+	if user == nil {
+		return sc.coll
+	}
+
+	return sc.coll.WithPrincipal(user.Name)
+}
+
+// createIndexes creates indexes and builds them before returning. Indexes that already exist with
+// the same name and fields are skipped.
+func createIndexes(ctx context.Context, coll *Collection, models []IndexModel) (err error) {
+	defer func() { coll.audit(AuditCreateIndexes, nil, nil, indexNames(models), err) }()
+
+	if err := coll.db.checkWritable(); err != nil {
+		return err
+	}
+
+	m := coll.IndexManager
+	existing := visibleIndexes(m.List())
+
+	models = slices.DeleteFunc(slices.Clone(models), func(model IndexModel) bool {
+		return slices.ContainsFunc(existing, func(idx IndexModel) bool {
+			return idx.Options.Name == model.Options.Name && slices.Equal(idx.Fields, model.Fields)
+		})
+	})
+
+	if len(models) == 0 {
+		return nil
+	}
+
+	if err := m.createMany(models); err != nil {
+		return err
+	}
+
+	return m.rebuildIndexes(ctx)
+}
+
+// visibleIndexes returns the indexes created by clients, leaving out the autogenerated ones.
+func visibleIndexes(indexes []IndexModel) []IndexModel {
+	out := make([]IndexModel, 0, len(indexes))
+
+	for _, idx := range indexes {
+		if !idx.isAutogenerated() {
+			out = append(out, idx)
+		}
+	}
+
+	return out
+}
+
+// indexSpec returns the specification of an index in the MongoDB format, the inverse of
+// parseIndexSpec.
+func indexSpec(idx IndexModel) primitive.D {
+	keys := make(primitive.D, 0, len(idx.Fields))
+	for _, f := range idx.Fields {
+		keys = append(keys, primitive.E{Key: f.Name, Value: int32(f.Order)})
+	}
+
+	spec := primitive.D{
+		{Key: "key", Value: keys},
+		{Key: "name", Value: idx.Options.Name},
+	}
+
+	if idx.isUnique() && idx.Options.Name != "_id_" {
+		spec = append(spec, primitive.E{Key: "unique", Value: true})
+	}
+
+	return spec
+}
+
+// collStatsDocument returns the statistics of a collection in the format of the collStats command.
+func collStatsDocument(cs CollectionStats) primitive.D {
+	sizes := make(primitive.D, 0, len(cs.Indexes))
+	keys := make(primitive.D, 0, len(cs.Indexes))
+
+	for _, idx := range cs.Indexes {
+		sizes = append(sizes, primitive.E{Key: idx.Index.Options.Name, Value: idx.Size})
+		keys = append(keys, primitive.E{Key: idx.Index.Options.Name, Value: idx.Keys})
+	}
+
+	return primitive.D{
+		{Key: "ns", Value: cs.Namespace.Database + "." + cs.Namespace.Collection},
+		{Key: "count", Value: cs.Count},
+		{Key: "size", Value: cs.Size},
+		{Key: "avgObjSize", Value: cs.AvgObjSize},
+		{Key: "storageSize", Value: cs.Size},
+		{Key: "nindexes", Value: int32(len(cs.Indexes))},
+		{Key: "totalIndexSize", Value: cs.TotalIndexSize},
+		{Key: "totalSize", Value: cs.Size + cs.TotalIndexSize},
+		{Key: "indexSizes", Value: sizes},
+		{Key: "indexKeys", Value: keys},
+		{Key: "keys", Value: cs.Keys},
+	}
+}
+
+// dbStatsDocument returns the statistics of a database in the format of the dbStats command.
+func dbStatsDocument(ds DatabaseStats) primitive.D {
+	indexes := 0
+	for _, cs := range ds.Collections {
+		indexes += len(cs.Indexes)
+	}
+
+	avg := int64(0)
+	if ds.Objects > 0 {
+		avg = ds.DataSize / ds.Objects
+	}
+
+	return primitive.D{
+		{Key: "db", Value: ds.Name},
+		{Key: "collections", Value: int32(len(ds.Collections))},
+		{Key: "objects", Value: ds.Objects},
+		{Key: "avgObjSize", Value: avg},
+		{Key: "dataSize", Value: ds.DataSize},
+		{Key: "storageSize", Value: ds.DataSize},
+		{Key: "indexes", Value: int32(indexes)},
+		{Key: "indexSize", Value: ds.IndexSize},
+		{Key: "totalSize", Value: ds.DataSize + ds.IndexSize},
+		{Key: "keys", Value: ds.Keys},
+		{Key: "lsmSize", Value: ds.LSMSize},
+		{Key: "vlogSize", Value: ds.VlogSize},
+	}
+}
+
+// parseIndexSpec parses an index specification in the MongoDB format:
+// {key: {field: 1 | -1, ...}, name: string, unique: bool}.
+func parseIndexSpec(spec primitive.D) (*IndexModel, error) {
+	model := NewIndexModel()
+
+	for _, e := range spec {
+		switch e.Key {
+		case "key":
+			keys, ok := e.Value.(primitive.D)
+			if !ok || len(keys) == 0 {
+				return nil, fmt.Errorf("%w: key must be a non-empty document", ErrInvalidIndexSpec)
+			}
+
+			for _, k := range keys {
+				order, ok := wireInt(k.Value)
+				if !ok || (order != 1 && order != -1) {
+					return nil, fmt.Errorf("%w: unsupported index type %v on field %s", ErrInvalidIndexSpec, k.Value, k.Key)
+				}
+
+				model.AddField(k.Key, int(order))
+			}
+		case "name":
+			name, ok := e.Value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: name must be a string", ErrInvalidIndexSpec)
+			}
+
+			model.SetName(name)
+		case "unique":
+			model.SetUnique(wireTruthy(e.Value))
+		}
+	}
+
+	if len(model.Fields) == 0 {
+		return nil, fmt.Errorf("%w: key is required", ErrInvalidIndexSpec)
+	}
+
+	return model, nil
+}
@@ -0,0 +1,759 @@
+package gopherdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/queryengine"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// OperationType is the type of operation of a change event.
+type OperationType string
+
+const (
+	// OperationInsert is emitted when a document is inserted.
+	OperationInsert OperationType = "insert"
+	// OperationUpdate is emitted when a document is updated.
+	OperationUpdate OperationType = "update"
+	// OperationReplace is emitted when a document is replaced.
+	OperationReplace OperationType = "replace"
+	// OperationDelete is emitted when a document is deleted.
+	OperationDelete OperationType = "delete"
+	// OperationDrop is emitted when a collection is dropped.
+	OperationDrop OperationType = "drop"
+	// OperationRename is emitted when a collection is renamed.
+	OperationRename OperationType = "rename"
+)
+
+// changeStreamQueueSize is the number of events a change stream buffers before it has to catch
+// up from the change history.
+const changeStreamQueueSize = 4096
+
+// Namespace identifies a collection.
+type Namespace struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"coll"`
+}
+
+// UpdateDescription describes the fields modified by an update.
+type UpdateDescription struct {
+	UpdatedFields map[string]any `bson:"updatedFields"`
+	RemovedFields []string       `bson:"removedFields"`
+}
+
+// ChangeEvent is a change made to a document, or to a whole collection for drops and renames.
+type ChangeEvent struct {
+	// ID is the resume token of the event.
+	ID                       string             `bson:"_id"`
+	OperationType            OperationType      `bson:"operationType"`
+	ClusterTime              time.Time          `bson:"clusterTime"`
+	Namespace                Namespace          `bson:"ns"`
+	DocumentKey              map[string]any     `bson:"documentKey"`
+	FullDocument             map[string]any     `bson:"fullDocument,omitempty"`
+	FullDocumentBeforeChange map[string]any     `bson:"fullDocumentBeforeChange,omitempty"`
+	UpdateDescription        *UpdateDescription `bson:"updateDescription,omitempty"`
+	// To is the new namespace of a renamed collection.
+	To *Namespace `bson:"to,omitempty"`
+}
+
+// changeRecord is a change event with its sequence number.
+type changeRecord struct {
+	seq   uint64
+	event ChangeEvent
+}
+
+// formatResumeToken formats a sequence number as a resume token.
+func formatResumeToken(seq uint64) string {
+	return fmt.Sprintf("%016x", seq)
+}
+
+// parseResumeToken parses a resume token into its sequence number.
+func parseResumeToken(token string) (uint64, error) {
+	seq, err := strconv.ParseUint(token, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidResumeToken, token)
+	}
+
+	return seq, nil
+}
+
+// newUpdateDescription describes the differences between two versions of a document.
+func newUpdateDescription(before, after map[string]any) *UpdateDescription {
+	desc := &UpdateDescription{
+		UpdatedFields: make(map[string]any),
+		RemovedFields: make([]string, 0),
+	}
+
+	for k, v := range after {
+		if old, ok := before[k]; !ok || !reflect.DeepEqual(old, v) {
+			desc.UpdatedFields[k] = v
+		}
+	}
+
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			desc.RemovedFields = append(desc.RemovedFields, k)
+		}
+	}
+
+	return desc
+}
+
+// changeHub orders the changes of a database, journals them and publishes them to its change streams.
+type changeHub struct {
+	// mu serializes the commits that record changes, so sequence numbers follow the commit order.
+	mu       sync.Mutex
+	dbname   string
+	storage  storage.Storage
+	seq      uint64
+	history  uint64
+	oplog    *oplog
+	watchers map[*ChangeStream]struct{}
+}
+
+// newChangeHub creates the change hub of a database, restoring its sequence from the journal.
+func newChangeHub(engine storage.Storage, dbname string, opt *options.DatabaseOptions) (*changeHub, error) {
+	h := &changeHub{
+		dbname:   dbname,
+		storage:  engine,
+		watchers: make(map[*ChangeStream]struct{}),
+	}
+
+	if opt.ChangeHistory != nil {
+		h.history = uint64(max(*opt.ChangeHistory, 0))
+	}
+
+	readOnly := opt.ReadOnly != nil && *opt.ReadOnly
+
+	if opt.Oplog != nil {
+		l, err := newOplog(engine, dbname, opt.Oplog, readOnly)
+		if err != nil {
+			return nil, err
+		}
+
+		h.oplog = l
+	}
+
+	keys, err := engine.ScanKeys(fmt.Sprintf(consts.ChangesKeyStringFormat, dbname))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return h, nil
+	}
+
+	h.seq, err = h.parseKey(keys[len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	if readOnly {
+		return h, nil
+	}
+
+	for _, key := range keys {
+		seq, err := h.parseKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if seq+h.history > h.seq {
+			break
+		}
+
+		if err := engine.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// buildKey builds the journal key of a change.
+func (h *changeHub) buildKey(seq uint64) string {
+	return fmt.Sprintf(consts.ChangeKeyStringFormat, h.dbname, seq)
+}
+
+// parseKey returns the sequence number of a journal key.
+func (h *changeHub) parseKey(key string) (uint64, error) {
+	match, err := consts.ChangeKeyPathmatcher.Match(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(match["seq"], 10, 64)
+}
+
+// commit journals the changes in the transaction, commits it and publishes the changes.
+func (h *changeHub) commit(txn storage.Transaction, changes []ChangeEvent, counts, sequences map[string]int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := adjustCollectionMetadata(h.storage, txn, h.dbname, counts, sequences); err != nil {
+		txn.Rollback()
+
+		return fmt.Errorf("collection metadata failed: %w", err)
+	}
+
+	now := time.Now().UTC()
+	records := make([]changeRecord, 0, len(changes))
+
+	for i, event := range changes {
+		seq := h.seq + uint64(i) + 1
+		event.ID = formatResumeToken(seq)
+		event.ClusterTime = now
+
+		if h.history > 0 {
+			data, err := bson.Marshal(event)
+			if err != nil {
+				txn.Rollback()
+
+				return fmt.Errorf("change marshal failed: %w", err)
+			}
+
+			if err := txn.Put(h.buildKey(seq), data); err != nil {
+				txn.Rollback()
+
+				return fmt.Errorf("change journal failed: %w", err)
+			}
+
+			if seq > h.history {
+				if err := txn.Delete(h.buildKey(seq - h.history)); err != nil {
+					txn.Rollback()
+
+					return fmt.Errorf("change journal failed: %w", err)
+				}
+			}
+		}
+
+		records = append(records, changeRecord{seq: seq, event: event})
+	}
+
+	var (
+		written []oplogSlot
+		trimmed int
+	)
+
+	if h.oplog != nil {
+		var err error
+
+		written, trimmed, err = h.oplog.write(txn, changes, now)
+		if err != nil {
+			txn.Rollback()
+
+			return err
+		}
+	}
+
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+
+	h.seq += uint64(len(records))
+
+	if h.oplog != nil {
+		h.oplog.advance(written, trimmed)
+	}
+
+	for w := range h.watchers {
+		w.push(records)
+	}
+
+	return nil
+}
+
+// readHistory reads the journaled changes after the given sequence number.
+func (h *changeHub) readHistory(after uint64) ([]changeRecord, error) {
+	kvs, err := h.storage.Scan(fmt.Sprintf(consts.ChangesKeyStringFormat, h.dbname))
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]changeRecord, 0, len(kvs))
+
+	for _, kv := range kvs {
+		seq, err := h.parseKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		if seq <= after {
+			continue
+		}
+
+		var event ChangeEvent
+		if err := bson.Unmarshal(kv.Value, &event); err != nil {
+			return nil, fmt.Errorf("change unmarshal failed: %w", err)
+		}
+
+		records = append(records, changeRecord{seq: seq, event: event})
+	}
+
+	return records, nil
+}
+
+// register registers a change stream. When after is set the stream resumes after that sequence
+// number, otherwise it only receives the changes committed from now on.
+func (h *changeHub) register(cs *ChangeStream, after *uint64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if after == nil {
+		cs.lastSeq = h.seq
+	} else {
+		if *after > h.seq {
+			return fmt.Errorf("%w: %s", ErrResumeTokenNotFound, formatResumeToken(*after))
+		}
+
+		if *after < h.seq && h.history == 0 {
+			return fmt.Errorf("%w: change history is disabled", ErrResumeTokenNotFound)
+		}
+
+		cs.lastSeq = *after
+		cs.lost = *after < h.seq
+	}
+
+	h.watchers[cs] = struct{}{}
+
+	return nil
+}
+
+// unregister unregisters a change stream.
+func (h *changeHub) unregister(cs *ChangeStream) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.watchers, cs)
+}
+
+// closeAll closes every change stream and oplog cursor of the hub.
+func (h *changeHub) closeAll() {
+	h.mu.Lock()
+	watchers := make([]*ChangeStream, 0, len(h.watchers))
+
+	for w := range h.watchers {
+		watchers = append(watchers, w)
+	}
+	h.mu.Unlock()
+
+	for _, w := range watchers {
+		w.Close()
+	}
+
+	if h.oplog != nil {
+		h.oplog.closeAll()
+	}
+}
+
+// ChangeStream is a stream of change events.
+type ChangeStream struct {
+	hub        *changeHub
+	collection string
+	match      queryengine.Expr
+	opt        *options.ChangeStreamOptions
+	encryption *fieldEncryptor
+
+	mu      sync.Mutex
+	queue   []changeRecord
+	lost    bool
+	lastSeq uint64
+	notify  chan struct{}
+	done    chan struct{}
+	closed  bool
+
+	current ChangeEvent
+	err     error
+}
+
+// Watch returns a change stream of the changes made to the collection.
+// The pipeline may only contain $match stages, which are evaluated against the change events.
+func (c *Collection) Watch(ctx context.Context, pipeline []map[string]any, opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
+	enc, err := c.encryptor()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.db.watch(ctx, c.collname, enc, pipeline, opts...)
+}
+
+// Watch returns a change stream of the changes made to every collection of the database.
+// The pipeline may only contain $match stages, which are evaluated against the change events.
+func (db *Database) Watch(ctx context.Context, pipeline []map[string]any, opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
+	return db.watch(ctx, "", nil, pipeline, opts...)
+}
+
+// watch creates a change stream.
+func (db *Database) watch(
+	ctx context.Context,
+	collection string,
+	enc *fieldEncryptor,
+	pipeline []map[string]any,
+	opts ...*options.ChangeStreamOptions,
+) (*ChangeStream, error) {
+	opt := options.ChangeStream()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	match, err := parseChangePipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *uint64
+
+	if opt.ResumeAfter != nil {
+		seq, err := parseResumeToken(*opt.ResumeAfter)
+		if err != nil {
+			return nil, err
+		}
+
+		after = &seq
+	}
+
+	cs := &ChangeStream{
+		hub:        db.changes,
+		collection: collection,
+		match:      match,
+		opt:        opt,
+		encryption: enc,
+		notify:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+
+	if err := db.changes.register(cs, after); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cs.Close()
+		case <-cs.done:
+		}
+	}()
+
+	return cs, nil
+}
+
+// parseChangePipeline parses the $match stages of a change stream pipeline.
+func parseChangePipeline(pipeline []map[string]any) (queryengine.Expr, error) {
+	clauses := make([]queryengine.Expr, 0, len(pipeline))
+
+	for _, stage := range pipeline {
+		for name, spec := range stage {
+			if name != "$match" {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedPipelineStage, name)
+			}
+
+			filter, ok := spec.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: $match must be a document", ErrUnsupportedPipelineStage)
+			}
+
+			expr, err := queryengine.ParseFilter(filter)
+			if err != nil {
+				return nil, err
+			}
+
+			clauses = append(clauses, expr)
+		}
+	}
+
+	if len(clauses) == 0 {
+		return nil, nil
+	}
+
+	return queryengine.AndExpr{Clauses: clauses}, nil
+}
+
+// push enqueues published changes. When the queue overflows the stream is marked as lost and
+// catches up from the change history.
+func (cs *ChangeStream) push(records []changeRecord) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.closed || cs.lost {
+		return
+	}
+
+	if len(cs.queue)+len(records) > changeStreamQueueSize {
+		cs.lost = true
+		cs.queue = nil
+	} else {
+		cs.queue = append(cs.queue, records...)
+	}
+
+	select {
+	case cs.notify <- struct{}{}:
+	default:
+	}
+}
+
+// catchUp reloads the queue from the change history after the stream fell behind.
+func (cs *ChangeStream) catchUp() error {
+	cs.mu.Lock()
+	cs.lost = false
+	cs.queue = nil
+	after := cs.lastSeq
+	cs.mu.Unlock()
+
+	if cs.hub.history == 0 {
+		return ErrChangeStreamLagged
+	}
+
+	records, err := cs.hub.readHistory(after)
+	if err != nil {
+		return err
+	}
+
+	if len(records) > 0 && records[0].seq != after+1 {
+		return fmt.Errorf("%w: %s", ErrResumeTokenNotFound, formatResumeToken(after))
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	last := after
+	if len(records) > 0 {
+		last = records[len(records)-1].seq
+	}
+
+	for _, r := range cs.queue {
+		if r.seq > last {
+			records = append(records, r)
+		}
+	}
+
+	cs.queue = records
+
+	return nil
+}
+
+// pop returns the next queued change, if any.
+func (cs *ChangeStream) pop() (changeRecord, bool, error) {
+	cs.mu.Lock()
+	lost := cs.lost
+	cs.mu.Unlock()
+
+	if lost {
+		if err := cs.catchUp(); err != nil {
+			return changeRecord{}, false, err
+		}
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for len(cs.queue) > 0 {
+		r := cs.queue[0]
+		cs.queue = cs.queue[1:]
+
+		if r.seq <= cs.lastSeq {
+			continue
+		}
+
+		cs.lastSeq = r.seq
+
+		return r, true, nil
+	}
+
+	return changeRecord{}, false, nil
+}
+
+// matches reports whether a change belongs to the stream and matches its pipeline.
+func (cs *ChangeStream) matches(event ChangeEvent) bool {
+	if cs.collection != "" && event.Namespace.Collection != cs.collection {
+		return false
+	}
+
+	if cs.match == nil {
+		return true
+	}
+
+	doc, err := bson.ConvertToMap(event)
+	if err != nil {
+		return false
+	}
+
+	return cs.match.Evaluate(doc)
+}
+
+// shape applies the full document options of the stream to a change event.
+func (cs *ChangeStream) shape(event ChangeEvent) ChangeEvent {
+	fullDocument := options.FullDocumentDefault
+	if cs.opt.FullDocument != nil {
+		fullDocument = *cs.opt.FullDocument
+	}
+
+	if event.OperationType == OperationUpdate {
+		switch fullDocument {
+		case options.FullDocumentUpdateLookup, options.FullDocumentWhenAvailable, options.FullDocumentRequired:
+		default:
+			event.FullDocument = nil
+		}
+	}
+
+	beforeChange := options.FullDocumentOff
+	if cs.opt.FullDocumentBeforeChange != nil {
+		beforeChange = *cs.opt.FullDocumentBeforeChange
+	}
+
+	if beforeChange != options.FullDocumentWhenAvailable && beforeChange != options.FullDocumentRequired {
+		event.FullDocumentBeforeChange = nil
+	}
+
+	if cs.encryption != nil {
+		event.FullDocument = cs.decrypt(event.FullDocument)
+		event.FullDocumentBeforeChange = cs.decrypt(event.FullDocumentBeforeChange)
+
+		if event.UpdateDescription != nil {
+			event.UpdateDescription = &UpdateDescription{
+				UpdatedFields: cs.decrypt(event.UpdateDescription.UpdatedFields),
+				RemovedFields: event.UpdateDescription.RemovedFields,
+			}
+		}
+	}
+
+	return event
+}
+
+// decrypt decrypts a document of a change event, leaving it as stored if it cannot be decrypted.
+func (cs *ChangeStream) decrypt(doc map[string]any) map[string]any {
+	if doc == nil {
+		return nil
+	}
+
+	decrypted, err := cs.encryption.decryptDocument(doc)
+	if err != nil {
+		return doc
+	}
+
+	return decrypted
+}
+
+// Next blocks until the next change event is available and reports whether one was found.
+// It returns false when the context is done, the stream is closed or an error occurs.
+func (cs *ChangeStream) Next(ctx context.Context) bool {
+	return cs.next(ctx, true)
+}
+
+// TryNext reports whether a change event is available without blocking.
+func (cs *ChangeStream) TryNext(ctx context.Context) bool {
+	return cs.next(ctx, false)
+}
+
+// next returns the next matching change event.
+func (cs *ChangeStream) next(ctx context.Context, wait bool) bool {
+	for {
+		if cs.err != nil || cs.isClosed() {
+			return false
+		}
+
+		r, ok, err := cs.pop()
+		if err != nil {
+			cs.err = err
+
+			return false
+		}
+
+		if ok {
+			if !cs.matches(r.event) {
+				continue
+			}
+
+			cs.current = cs.shape(r.event)
+
+			return true
+		}
+
+		if !wait {
+			return false
+		}
+
+		select {
+		case <-ctx.Done():
+			cs.err = ctx.Err()
+
+			return false
+		case <-cs.done:
+			return false
+		case <-cs.notify:
+		}
+	}
+}
+
+// isClosed reports whether the stream is closed.
+func (cs *ChangeStream) isClosed() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.closed
+}
+
+// Event returns the current change event.
+func (cs *ChangeStream) Event() ChangeEvent {
+	return cs.current
+}
+
+// Decode unmarshals the current change event into v.
+func (cs *ChangeStream) Decode(v any) error {
+	data, err := bson.Marshal(cs.current)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(data, v)
+}
+
+// ResumeToken returns the token to resume the stream after the last change it has seen.
+func (cs *ChangeStream) ResumeToken() string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return formatResumeToken(cs.lastSeq)
+}
+
+// Err returns the error that stopped the stream, if any.
+func (cs *ChangeStream) Err() error {
+	return cs.err
+}
+
+// Close closes the stream.
+func (cs *ChangeStream) Close() error {
+	cs.mu.Lock()
+	if cs.closed {
+		cs.mu.Unlock()
+
+		return nil
+	}
+
+	cs.closed = true
+	close(cs.done)
+	cs.mu.Unlock()
+
+	cs.hub.unregister(cs)
+
+	return nil
+}
+
+// newChangeEvent creates a change event of the collection.
+func (c *Collection) newChangeEvent(op OperationType, id any) ChangeEvent {
+	return ChangeEvent{
+		OperationType: op,
+		Namespace: Namespace{
+			Database:   c.dbname,
+			Collection: c.collname,
+		},
+		DocumentKey: map[string]any{consts.DocumentFieldID: id},
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/wirvii/gopherdb/options"
+)
+
+// auditFlags are the audit log flags of the server commands.
+type auditFlags struct {
+	file       *string
+	maxSize    *int64
+	maxFiles   *int
+	collection *string
+}
+
+// newAuditFlags adds the audit log flags to a flag set.
+func newAuditFlags(fs *flag.FlagSet) *auditFlags {
+	return &auditFlags{
+		file:       fs.String("audit-file", "", "NDJSON file of the audit log of writes, index changes and drops"),
+		maxSize:    fs.Int64("audit-max-size", 100<<20, "size in bytes at which the audit file is rotated; 0 disables rotation"),
+		maxFiles:   fs.Int("audit-max-files", 10, "rotated audit files to keep; 0 keeps all"),
+		collection: fs.String("audit-collection", "", "collection of each database the audit log is also written to"),
+	}
+}
+
+// apply enables the audit log on the database options when a file or collection is given.
+func (f *auditFlags) apply(opt *options.DatabaseOptions) {
+	if *f.file == "" && *f.collection == "" {
+		return
+	}
+
+	audit := options.Audit()
+	if *f.file != "" {
+		audit.SetPath(*f.file).SetMaxSize(*f.maxSize).SetMaxFiles(*f.maxFiles)
+	}
+
+	if *f.collection != "" {
+		audit.SetCollection(*f.collection)
+	}
+
+	opt.SetAudit(audit)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/wirvii/gopherdb"
+)
+
+// errEngineCheckFailed is returned when an engine does not pass the conformance suite.
+var errEngineCheckFailed = errors.New("conformance check failed")
+
+// runEngine runs the engine command.
+func runEngine(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopherdb engine <list|check> [flags]")
+	}
+
+	switch args[0] {
+	case "list":
+		for _, name := range gopherdb.Engines() {
+			fmt.Println(name)
+		}
+
+		return nil
+	case "check":
+		return runEngineCheck(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// runEngineCheck runs the storage conformance suite against an engine.
+func runEngineCheck(args []string) error {
+	fs := flag.NewFlagSet("engine check", flag.ContinueOnError)
+	name := fs.String("engine", "badger", "name of the storage engine to check")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	results, err := gopherdb.CheckEngine(*name)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Printf("ok    %s\n", r.Name)
+
+			continue
+		}
+
+		failed++
+
+		fmt.Printf("FAIL  %s: %v\n", r.Name, r.Err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d cases failed", errEngineCheckFailed, failed, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// runHTTP serves a data directory over the HTTP/JSON REST API until interrupted.
+func runHTTP(args []string) error {
+	fs := flag.NewFlagSet("http", flag.ContinueOnError)
+	path := fs.String("path", "./data", "database directory")
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	engine := fs.String("engine", options.EngineBadger, "storage engine")
+	keyFile := fs.String("key-file", "", "file with the encryption key; empty for a plaintext database")
+	auth := fs.Bool("auth", false, "require clients to authenticate as a user of the users command")
+	canonical := fs.Bool("canonical", false, "write canonical instead of relaxed Extended JSON")
+	audit := newAuditFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := readKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	dbOpt := options.Database().SetEngine(*engine)
+	if key != nil {
+		dbOpt.SetEncryptionKey(key)
+	}
+
+	audit.apply(dbOpt)
+
+	server, err := gopherdb.NewHTTPServer(*path, options.HTTPServer().
+		SetDatabase(dbOpt).
+		SetCanonical(*canonical).
+		SetAuth(*auth))
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("serving %s on http://%s\n", *path, ln.Addr())
+
+	return server.Serve(ctx, ln)
+}
@@ -1,4 +1,59 @@
 package main
 
+import (
+	"fmt"
+	"os"
+	"slices"
+)
+
+// command is a gopherdb CLI command.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// commands returns every CLI command.
+func commands() []command {
+	return []command{
+		{name: "engine", summary: "list storage engines or run the conformance suite", run: runEngine},
+		{name: "keys", summary: "generate, rotate and verify encryption keys", run: runKeys},
+		{name: "oplog", summary: "print the oplog of a database", run: runOplog},
+		{name: "http", summary: "serve a data directory over an HTTP/JSON REST API", run: runHTTP},
+		{name: "replica", summary: "serve, follow or promote a replicated database", run: runReplica},
+		{name: "serve", summary: "serve a data directory over the MongoDB wire protocol", run: runServe},
+		{name: "users", summary: "manage the users and roles of the server modes", run: runUsers},
+	}
+}
+
 func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmds := commands()
+
+	idx := slices.IndexFunc(cmds, func(c command) bool { return c.name == os.Args[1] })
+	if idx < 0 {
+		fmt.Fprintf(os.Stderr, "gopherdb: unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmds[idx].run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "gopherdb %s: %v\n", cmds[idx].name, err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the CLI usage.
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gopherdb <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+
+	for _, c := range commands() {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
 }
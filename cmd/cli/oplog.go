@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// runOplog runs the oplog command.
+func runOplog(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopherdb oplog <tail> [flags]")
+	}
+
+	switch args[0] {
+	case "tail":
+		return runOplogTail(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// runOplogTail prints the oplog entries of a database as extended JSON, one per line.
+// The database is opened read-only, so it must not be held open by another process; with -follow
+// it is reopened every interval to pick up new entries.
+func runOplogTail(args []string) error {
+	fs := flag.NewFlagSet("oplog tail", flag.ContinueOnError)
+	path := fs.String("path", "./data", "database directory")
+	dbname := fs.String("db", "", "database name")
+	keyFile := fs.String("key-file", "", "file with the encryption key; empty for a plaintext database")
+	since := fs.Uint64("since", 0, "print the entries after this timestamp")
+	follow := fs.Bool("follow", false, "keep polling for new entries")
+	interval := fs.Duration("interval", time.Second, "polling interval with -follow")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dbname == "" {
+		return errors.New("-db is required")
+	}
+
+	key, err := readKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opt := options.Database().
+		SetReadOnly(true).
+		SetOplog(options.Oplog())
+
+	if key != nil {
+		opt.SetEncryptionKey(key)
+	}
+
+	last := *since
+
+	for {
+		last, err = printOplog(ctx, *path, *dbname, opt, last)
+		if err != nil {
+			return err
+		}
+
+		if !*follow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// printOplog prints the oplog entries after a timestamp and returns the timestamp of the last one.
+func printOplog(ctx context.Context, path, dbname string, opt *options.DatabaseOptions, after uint64) (uint64, error) {
+	db, err := gopherdb.NewDatabase(dbname, path, opt)
+	if err != nil {
+		return after, err
+	}
+	defer db.Close()
+
+	cursor, err := db.TailOplog(ctx, options.OplogTail().SetStartAfter(after))
+	if err != nil {
+		return after, err
+	}
+	defer cursor.Close()
+
+	for cursor.Next(ctx) {
+		line, err := bson.MarshalExtJSON(cursor.Entry(), false, false)
+		if err != nil {
+			return after, err
+		}
+
+		fmt.Println(string(line))
+
+		after = cursor.Timestamp()
+	}
+
+	if err := cursor.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return after, err
+	}
+
+	return after, nil
+}
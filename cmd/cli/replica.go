@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// runReplica runs the replica command.
+func runReplica(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopherdb replica <serve|follow|promote> [flags]")
+	}
+
+	switch args[0] {
+	case "serve":
+		return runReplicaServe(args[1:])
+	case "follow":
+		return runReplicaFollow(args[1:])
+	case "promote":
+		return runReplicaPromote(args[1:])
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// replicaFlags are the flags shared by the replica subcommands.
+type replicaFlags struct {
+	path   *string
+	dbname *string
+}
+
+// newReplicaFlags registers the shared flags.
+func newReplicaFlags(fs *flag.FlagSet) replicaFlags {
+	return replicaFlags{
+		path:   fs.String("path", "./data", "database directory"),
+		dbname: fs.String("db", "", "database name"),
+	}
+}
+
+// open opens the database selected by the flags.
+func (f replicaFlags) open(opts ...*options.DatabaseOptions) (*gopherdb.Database, error) {
+	if *f.dbname == "" {
+		return nil, errors.New("-db is required")
+	}
+
+	return gopherdb.NewDatabase(*f.dbname, *f.path, opts...)
+}
+
+// runReplicaServe serves the oplog of a database to its followers until interrupted.
+func runReplicaServe(args []string) error {
+	fs := flag.NewFlagSet("replica serve", flag.ContinueOnError)
+	db := newReplicaFlags(fs)
+	addr := fs.String("addr", "127.0.0.1:27018", "address to listen on")
+	heartbeat := fs.Duration("heartbeat", time.Second, "heartbeat interval")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := db.open(options.Database().SetOplog(options.Oplog()))
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("serving replication of %s on %s\n", *db.dbname, ln.Addr())
+
+	return database.ServeReplication(ctx, ln, options.Replication().SetHeartbeatInterval(*heartbeat))
+}
+
+// runReplicaFollow follows a primary and prints the replication status until interrupted.
+func runReplicaFollow(args []string) error {
+	fs := flag.NewFlagSet("replica follow", flag.ContinueOnError)
+	db := newReplicaFlags(fs)
+	primary := fs.String("primary", "127.0.0.1:27018", "address of the primary")
+	interval := fs.Duration("status-interval", 5*time.Second, "how often the status is printed")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := db.open(options.Database().SetOplog(options.Oplog()))
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	follower, err := database.Follow(ctx, *primary)
+	if err != nil {
+		return err
+	}
+	defer follower.Close()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		status := follower.Status()
+
+		switch {
+		case status.Connected:
+			fmt.Printf("connected to %s, applied %d, lag %s\n", status.Primary, status.AppliedTimestamp, status.Lag())
+		case status.Err != nil:
+			fmt.Printf("disconnected from %s: %v\n", status.Primary, status.Err)
+		default:
+			fmt.Printf("connecting to %s\n", status.Primary)
+		}
+	}
+}
+
+// runReplicaPromote makes a stopped follower a writable primary.
+func runReplicaPromote(args []string) error {
+	fs := flag.NewFlagSet("replica promote", flag.ContinueOnError)
+	db := newReplicaFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	database, err := db.open()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := database.Promote(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s promoted to primary\n", *db.dbname)
+
+	return nil
+}
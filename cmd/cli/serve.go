@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// runServe serves a data directory over the MongoDB wire protocol until interrupted.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	path := fs.String("path", "./data", "database directory")
+	addr := fs.String("addr", "127.0.0.1:27017", "address to listen on")
+	engine := fs.String("engine", options.EngineBadger, "storage engine")
+	keyFile := fs.String("key-file", "", "file with the encryption key; empty for a plaintext database")
+	auth := fs.Bool("auth", false, "require clients to authenticate as a user of the users command")
+	cursorTimeout := fs.Duration("cursor-timeout", 10*time.Minute, "how long an idle cursor is kept")
+	audit := newAuditFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := readKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	dbOpt := options.Database().SetEngine(*engine)
+	if key != nil {
+		dbOpt.SetEncryptionKey(key)
+	}
+
+	audit.apply(dbOpt)
+
+	server, err := gopherdb.NewWireServer(*path, options.WireServer().
+		SetDatabase(dbOpt).
+		SetCursorTimeout(*cursorTimeout).
+		SetAuth(*auth))
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("serving %s on mongodb://%s\n", *path, ln.Addr())
+
+	return server.Serve(ctx, ln)
+}
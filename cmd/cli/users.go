@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// usersFlags are the flags shared by the users subcommands.
+type usersFlags struct {
+	fs       *flag.FlagSet
+	path     *string
+	engine   *string
+	keyFile  *string
+	name     *string
+	password *string
+	roles    []gopherdb.RoleGrant
+}
+
+// newUsersFlags creates the flag set of a users subcommand.
+func newUsersFlags(name string) *usersFlags {
+	f := &usersFlags{fs: flag.NewFlagSet("users "+name, flag.ContinueOnError)}
+	f.path = f.fs.String("path", "./data", "database directory")
+	f.engine = f.fs.String("engine", options.EngineBadger, "storage engine")
+	f.keyFile = f.fs.String("key-file", "", "file with the encryption key; empty for a plaintext database")
+	f.name = f.fs.String("name", "", "user name")
+	f.password = f.fs.String("password", "", "password; read from stdin when empty")
+	f.fs.Func("role", "role as role@db or role@db.coll, or root; repeatable", func(s string) error {
+		g, err := gopherdb.ParseRoleGrant(s)
+		if err != nil {
+			return err
+		}
+
+		f.roles = append(f.roles, g)
+
+		return nil
+	})
+
+	return f
+}
+
+// parse parses the arguments and checks the user name when it is required.
+func (f *usersFlags) parse(args []string, needName bool) error {
+	if err := f.fs.Parse(args); err != nil {
+		return err
+	}
+
+	if needName && *f.name == "" {
+		return errors.New("-name is required")
+	}
+
+	return nil
+}
+
+// open opens the users of the data directory.
+func (f *usersFlags) open() (*gopherdb.Users, error) {
+	key, err := readKeyFile(*f.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := options.Database().SetEngine(*f.engine)
+	if key != nil {
+		opt.SetEncryptionKey(key)
+	}
+
+	return gopherdb.OpenUsers(*f.path, opt)
+}
+
+// readPassword returns the -password flag, or the first line of stdin.
+func (f *usersFlags) readPassword() (string, error) {
+	if *f.password != "" {
+		return *f.password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "password: ")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no password given")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// runUsers runs the users command. The data directory must not be held open by a server.
+func runUsers(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: gopherdb users <add|remove|list|passwd|grant|revoke|token|revoke-token> [flags]")
+	}
+
+	f := newUsersFlags(args[0])
+
+	switch args[0] {
+	case "add":
+		return f.run(args[1:], true, func(users *gopherdb.Users) error {
+			password, err := f.readPassword()
+			if err != nil {
+				return err
+			}
+
+			return users.Create(*f.name, password, f.roles...)
+		})
+	case "remove":
+		return f.run(args[1:], true, func(users *gopherdb.Users) error {
+			return users.Drop(*f.name)
+		})
+	case "list":
+		return f.run(args[1:], false, func(users *gopherdb.Users) error {
+			list, err := users.List()
+			if err != nil {
+				return err
+			}
+
+			for _, u := range list {
+				roles := make([]string, 0, len(u.Roles))
+				for _, g := range u.Roles {
+					roles = append(roles, g.String())
+				}
+
+				fmt.Printf("%s\troles=%s\ttokens=%s\n", u.Name, strings.Join(roles, ","), strings.Join(u.Tokens, ","))
+			}
+
+			return nil
+		})
+	case "passwd":
+		return f.run(args[1:], true, func(users *gopherdb.Users) error {
+			password, err := f.readPassword()
+			if err != nil {
+				return err
+			}
+
+			return users.SetPassword(*f.name, password)
+		})
+	case "grant":
+		return f.run(args[1:], true, func(users *gopherdb.Users) error {
+			return users.Grant(*f.name, f.roles...)
+		})
+	case "revoke":
+		return f.run(args[1:], true, func(users *gopherdb.Users) error {
+			return users.Revoke(*f.name, f.roles...)
+		})
+	case "token":
+		return f.run(args[1:], true, func(users *gopherdb.Users) error {
+			token, err := users.CreateToken(*f.name)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(token)
+
+			return nil
+		})
+	case "revoke-token":
+		id := f.fs.String("id", "", "ID of the token, the part before the dot")
+
+		return f.run(args[1:], true, func(users *gopherdb.Users) error {
+			if *id == "" {
+				return errors.New("-id is required")
+			}
+
+			return users.RevokeToken(*f.name, *id)
+		})
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// run parses the arguments and runs fn on the users of the data directory.
+func (f *usersFlags) run(args []string, needName bool, fn func(users *gopherdb.Users) error) error {
+	if err := f.parse(args, needName); err != nil {
+		return err
+	}
+
+	users, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+
+	return fn(users)
+}
@@ -2,6 +2,8 @@ package gopherdb
 
 import (
 	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"reflect"
@@ -9,117 +11,246 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/wirvii/gopherdb/internal/bson"
 	"github.com/wirvii/gopherdb/internal/consts"
 	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/internal/update"
 	"github.com/wirvii/gopherdb/options"
 )
 
 // Collection is a collection of documents.
 type Collection struct {
+	db           *Database
 	dbname       string
 	collname     string
 	storage      storage.Storage
-	initialized  bool
+	encryption   *fieldEncryptor
+	principal    string
 	IndexManager *IndexManager
 }
 
 // newCollection creates a new collection.
-func newCollection(storage storage.Storage, dbname, collname string) (*Collection, error) {
-	idxMgr := newIndexManager(storage, dbname, collname)
-	if err := idxMgr.loadMetadata(); err != nil {
+func newCollection(db *Database, collname string) (*Collection, error) {
+	idxMgr := newIndexManager(db.storage, db.name, collname)
+	if _, err := idxMgr.loadMetadata(); err != nil {
 		return nil, err
 	}
 
 	return &Collection{
-		dbname:       dbname,
+		db:           db,
+		dbname:       db.name,
 		collname:     collname,
-		storage:      storage,
-		initialized:  (idxMgr.metadata.DocumentCount > 0),
+		storage:      db.storage,
 		IndexManager: idxMgr,
 	}, nil
 }
 
-// ensureInitialized ensures that the collection is initialized.
-func (c *Collection) ensureInitialized() error {
-	if c.initialized {
-		return nil
+// ensureInitialized persists the metadata of the collection with the write transaction, unless it
+// is already stored when the transaction commits.
+func (c *Collection) ensureInitialized(txn *writeTxn) {
+	txn.create(c.collname)
+}
+
+// Drop deletes the documents, indexes and metadata of the collection. It returns
+// ErrCollectionNotFound when the collection does not exist. The handle stays usable and the next
+// write creates the collection again.
+func (c *Collection) Drop() error {
+	return c.DropContext(context.Background())
+}
+
+// DropContext is like Drop, but fails with the error of the context when it is done before the
+// drop starts. The keys of the collection are deleted with a prefix drop, which cannot be stopped.
+func (c *Collection) DropContext(ctx context.Context) (err error) {
+	defer func() { c.audit(AuditDropCollection, nil, nil, nil, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	if c.IndexManager.metadata.DocumentCount > 0 {
-		if err := c.IndexManager.saveMetadata(); err != nil {
-			return err
-		}
+	existed, err := c.db.dropCollection(c.collname)
+	if err != nil {
+		return err
+	}
 
-		c.initialized = true
+	if !existed {
+		return fmt.Errorf("%w: %s.%s", ErrCollectionNotFound, c.dbname, c.collname)
 	}
 
 	return nil
 }
 
-// buildDocumentKey builds the key for a document.
-func (c *Collection) buildDocumentKey(docID string) string {
-	return fmt.Sprintf(consts.DocumentKeyStringFormat, c.dbname, c.collname, docID)
+// Rename renames the collection within its database. Documents, index entries and metadata are
+// moved to the new name in a single write transaction, which records the rename, so readers see
+// either the old or the new collection; the collection must fit in one transaction of the storage
+// engine. The handle keeps naming the old collection.
+func (c *Collection) Rename(to string, opts ...*options.RenameCollectionOptions) error {
+	return c.RenameContext(context.Background(), to, opts...)
 }
 
-// ensureDocumentID ensures that the document has an ID.
-func (c *Collection) ensureDocumentID(doc map[string]any) (map[string]any, string) {
-	copyDoc := make(map[string]any)
-	maps.Copy(copyDoc, doc)
-	val, ok := copyDoc[consts.DocumentFieldID]
+// RenameContext is like Rename, but stops when the context is done before the rename commits,
+// returning its error. A target dropped by DropTarget is not restored.
+func (c *Collection) RenameContext(
+	ctx context.Context,
+	to string,
+	opts ...*options.RenameCollectionOptions,
+) (err error) {
+	defer func() { c.auditRename(to, err) }()
 
-	if !ok {
-		copyDoc[consts.DocumentFieldID] = uuid.NewString()
-	} else {
-		tval := reflect.ValueOf(val)
-		if tval.IsZero() && tval.Kind() == reflect.String {
-			copyDoc[consts.DocumentFieldID] = uuid.NewString()
+	opt := options.RenameCollection()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	db, from := c.db, c.collname
+
+	if to == "" || strings.ContainsAny(to, "/$") {
+		return fmt.Errorf("%w: invalid collection name '%s'", ErrInvalidNamespace, to)
+	}
+
+	if from == to {
+		return fmt.Errorf("%w: cannot rename %s to itself", ErrInvalidNamespace, from)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
+
+	fromMeta := fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, db.name, from)
+	toMeta := fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, db.name, to)
+
+	if _, err := db.storage.Get(fromMeta); errors.Is(err, storage.ErrKeyNotFound) {
+		return fmt.Errorf("%w: %s.%s", ErrCollectionNotFound, db.name, from)
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := db.storage.Get(toMeta); err == nil {
+		if opt.DropTarget == nil || !*opt.DropTarget {
+			return fmt.Errorf("%w: %s.%s", ErrCollectionExists, db.name, to)
 		}
+
+		if err := db.DropCollectionContext(ctx, to); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		return err
 	}
 
-	return copyDoc, fmt.Sprintf("%v", copyDoc[consts.DocumentFieldID])
+	txn := db.newWriteTxn()
+	txn.ctx = ctx
+
+	if err := db.moveCollection(txn, from, to); err != nil {
+		txn.Rollback()
+
+		return err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+
+	db.resetCollections(from, to)
+
+	return nil
 }
 
-// updateOne updates a single document by a filter.
-func (c *Collection) updateOne(
-	txn storage.Transaction,
-	filter map[string]any,
-	doc any,
-	opts ...*options.UpdateOptions,
-) UpdateOneResult {
-	c.IndexManager.loadMetadata()
+// moveCollection moves the documents, index entries and metadata of a collection to a new name in
+// a write transaction and records the rename. It fails when the target collection exists, and
+// stops when the context of the transaction is done.
+func (db *Database) moveCollection(txn *writeTxn, from, to string) error {
+	fromMeta := fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, db.name, from)
+	toMeta := fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, db.name, to)
 
-	if doc == nil {
-		return UpdateOneResult{
-			Err: ErrDocumentIsNil,
-		}
+	data, err := txn.Get(fromMeta)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return fmt.Errorf("%w: %s.%s", ErrCollectionNotFound, db.name, from)
+	} else if err != nil {
+		return err
 	}
 
-	docVal := reflect.ValueOf(doc)
-	if docVal.Kind() == reflect.Ptr {
-		if docVal.IsNil() {
-			return UpdateOneResult{
-				Err: ErrDocumentPointerIsNil,
-			}
+	if _, err := txn.Get(toMeta); err == nil {
+		return fmt.Errorf("%w: %s.%s", ErrCollectionExists, db.name, to)
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		return err
+	}
+
+	var meta CollectionMetadata
+	if err := bson.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+
+	meta.Name = fmt.Sprintf(consts.CollectionKeyStringFormat, db.name, to)
+
+	data, err = bson.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	oldPrefix := fmt.Sprintf(consts.CollectionKeyStringFormat, db.name, from) + "/"
+	newPrefix := fmt.Sprintf(consts.CollectionKeyStringFormat, db.name, to) + "/"
+
+	kvs, err := txn.ScanContext(txn.ctx, oldPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, kv := range kvs {
+		if err := txn.ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := txn.Put(newPrefix+strings.TrimPrefix(kv.Key, oldPrefix), kv.Value); err != nil {
+			return err
 		}
 
-		docVal = docVal.Elem()
+		if err := txn.Delete(kv.Key); err != nil {
+			return err
+		}
+	}
+
+	if err := txn.Put(toMeta, data); err != nil {
+		return err
 	}
 
-	docKind := docVal.Kind()
-	if docKind != reflect.Map && docKind != reflect.Struct {
+	if err := txn.Delete(fromMeta); err != nil {
+		return err
+	}
+
+	txn.record(ChangeEvent{
+		OperationType: OperationRename,
+		Namespace:     Namespace{Database: db.name, Collection: from},
+		To:            &Namespace{Database: db.name, Collection: to},
+	})
+
+	return nil
+}
+
+// buildDocumentKey builds the key for a document.
+func (c *Collection) buildDocumentKey(docID string) string {
+	return fmt.Sprintf(consts.DocumentKeyStringFormat, c.dbname, c.collname, docID)
+}
+
+// updateOne updates the first document that matches a filter, in the order of sort.
+func (c *Collection) updateOne(
+	txn *writeTxn,
+	filter map[string]any,
+	sort []options.SortField,
+	doc any,
+	opts ...*options.UpdateOptions,
+) UpdateOneResult {
+	if _, err := c.IndexManager.loadMetadata(); err != nil {
 		return UpdateOneResult{
-			Err: ErrDocumentTypeInvalid,
+			Err: err,
 		}
 	}
 
-	if docKind == reflect.Map {
-		mapType := docVal.Type()
-		if mapType.Key().Kind() != reflect.String || mapType.Elem().Kind() != reflect.Interface {
-			return UpdateOneResult{
-				Err: ErrDocumentTypeInvalid,
-			}
+	if _, err := validateDocumentType(doc); err != nil {
+		return UpdateOneResult{
+			Err: err,
 		}
 	}
 
@@ -128,11 +259,25 @@ func (c *Collection) updateOne(
 		opt = opt.Merge(opts...)
 	}
 
-	result := c.FindOne(filter)
+	docMap, err := bson.ConvertToMap(doc)
+	if err != nil {
+		return UpdateOneResult{
+			Err: fmt.Errorf("error converting document to map: %w", err),
+		}
+	}
+
+	result := c.findForWrite(txn, filter, sort)
 
 	if result.Err != nil {
 		if result.Err == ErrDocumentNotFound && opt.Upsert != nil && *opt.Upsert {
-			insertResult := c.insertOne(txn, doc)
+			newDoc, err := update.NewDocument(filter, docMap)
+			if err != nil {
+				return UpdateOneResult{
+					Err: err,
+				}
+			}
+
+			insertResult := c.insertOne(txn, newDoc)
 			if insertResult.Err != nil {
 				return UpdateOneResult{
 					Err: insertResult.Err,
@@ -141,6 +286,7 @@ func (c *Collection) updateOne(
 
 			return UpdateOneResult{
 				UpsertedID: insertResult.InsertedID,
+				updated:    insertResult.raw,
 			}
 		}
 
@@ -149,7 +295,26 @@ func (c *Collection) updateOne(
 		}
 	}
 
-	match, err := consts.DocumentKeyPathmatcher.Match(result.raw.Key)
+	return c.updateDocument(txn, result.raw, docMap, opt)
+}
+
+// updateDocument applies an update document, or with the set option a replacement, to a stored
+// document.
+func (c *Collection) updateDocument(
+	txn *writeTxn,
+	kv storage.KV,
+	docMap map[string]any,
+	opt *options.UpdateOptions,
+) UpdateOneResult {
+	if err := checkVersion(kv.Document(), opt.Version); err != nil {
+		return UpdateOneResult{
+			Err: err,
+		}
+	}
+
+	operators := update.IsOperatorDocument(docMap)
+
+	match, err := consts.DocumentKeyPathmatcher.Match(kv.Key)
 	if err != nil {
 		return UpdateOneResult{
 			Err: fmt.Errorf("match failed: %w", err),
@@ -158,33 +323,91 @@ func (c *Collection) updateOne(
 
 	docID := match["docId"]
 
-	docMap, err := bson.ConvertToMap(doc)
+	enc, err := c.encryptor()
 	if err != nil {
 		return UpdateOneResult{
-			Err: fmt.Errorf("error converting document to map: %w", err),
+			Err: err,
+		}
+	}
+
+	// Los operadores y el validador ven los campos cifrados descifrados
+	previous := kv.Document()
+	plain := previous
+
+	if enc != nil {
+		plain, err = enc.decryptDocument(previous)
+		if err != nil {
+			return UpdateOneResult{
+				Err: err,
+			}
+		}
+	}
+
+	if operators {
+		docMap, err = update.Apply(plain, docMap, false)
+		if err != nil {
+			return UpdateOneResult{
+				Err: err,
+			}
+		}
+	}
+
+	replace := opt.Set != nil && *opt.Set
+
+	candidate := docMap
+	if !replace && !operators {
+		candidate = maps.Clone(plain)
+		maps.Copy(candidate, docMap)
+	}
+
+	if err := c.validateDocument(candidate, plain); err != nil {
+		return UpdateOneResult{
+			Err: err,
+		}
+	}
+
+	if enc != nil {
+		docMap, err = enc.encryptDocument(enc.keepCiphertexts(docMap, plain, previous))
+		if err != nil {
+			return UpdateOneResult{
+				Err: err,
+			}
 		}
 	}
 
 	docMapID, docMapIDOk := docMap[consts.DocumentFieldID]
 	if !docMapIDOk {
-		docMap[consts.DocumentFieldID] = docID
-		docMapID = docID
+		docMapID = kv.Document()[consts.DocumentFieldID]
+		docMap[consts.DocumentFieldID] = docMapID
 	}
 
-	if docID != docMapID {
+	if docID != encodeDocumentID(docMapID) {
 		return UpdateOneResult{
 			Err: ErrDocumentIDNoEditable,
 		}
 	}
 
 	docUpdate := make(map[string]any)
-	if opt.Set != nil && *opt.Set {
+	if replace || operators {
 		maps.Copy(docUpdate, docMap)
 	} else {
-		maps.Copy(docUpdate, result.Document())
+		maps.Copy(docUpdate, kv.Document())
 		maps.Copy(docUpdate, docMap)
 	}
 
+	versioned := c.IndexManager.current().Versioned
+	if version, ok := previous[consts.DocumentFieldVersion]; versioned && ok {
+		docUpdate[consts.DocumentFieldVersion] = version
+	} else if versioned {
+		delete(docUpdate, consts.DocumentFieldVersion)
+	}
+
+	if err := c.IndexManager.checkUniqueness(txn, docUpdate, previous); err != nil {
+		return UpdateOneResult{
+			Err: err,
+		}
+	}
+
 	bdoc, err := bson.Marshal(docUpdate)
 	if err != nil {
 		return UpdateOneResult{
@@ -192,12 +415,38 @@ func (c *Collection) updateOne(
 		}
 	}
 
-	if err := txn.Put(result.raw.Key, bdoc); err != nil {
+	// Con la versión guardada, el documento cambia si difiere de él; solo entonces sube la versión
+	stored := storage.KV{Key: kv.Key, Value: bdoc}
+	modified := int64(0)
+
+	if !reflect.DeepEqual(stored.Document(), previous) {
+		modified = 1
+	}
+
+	if versioned && modified == 1 {
+		docUpdate[consts.DocumentFieldVersion] = documentVersion(previous) + 1
+
+		stored.Value, err = bson.Marshal(docUpdate)
+		if err != nil {
+			return UpdateOneResult{
+				Err: fmt.Errorf("bson marshal failed: %w", err),
+			}
+		}
+	}
+
+	if err := txn.Put(stored.Key, stored.Value); err != nil {
 		return UpdateOneResult{
 			Err: fmt.Errorf("update failed: %w", err),
 		}
 	}
 
+	err = c.IndexManager.deleteDocumentIndexes(txn, kv.Document())
+	if err != nil {
+		return UpdateOneResult{
+			Err: fmt.Errorf("delete document indexes failed: %w", err),
+		}
+	}
+
 	err = c.IndexManager.indexDocument(txn, docUpdate)
 	if err != nil {
 		return UpdateOneResult{
@@ -205,14 +454,35 @@ func (c *Collection) updateOne(
 		}
 	}
 
+	event := c.newChangeEvent(OperationUpdate, docUpdate[consts.DocumentFieldID])
+	event.FullDocument = docUpdate
+	event.FullDocumentBeforeChange = kv.Document()
+
+	if replace {
+		event.OperationType = OperationReplace
+	} else {
+		event.UpdateDescription = newUpdateDescription(event.FullDocumentBeforeChange, docUpdate)
+	}
+
+	txn.record(event)
+
 	return UpdateOneResult{
-		UpsertedID: docID,
+		UpsertedID:    docMapID,
+		MatchedCount:  1,
+		ModifiedCount: modified,
+		matched:       kv,
+		updated:       stored,
 	}
 }
 
 // insertOne inserts a single document into the collection.
-func (c *Collection) insertOne(txn storage.Transaction, doc any) InsertOneResult {
-	c.IndexManager.loadMetadata()
+func (c *Collection) insertOne(txn *writeTxn, doc any) InsertOneResult {
+	meta, err := c.IndexManager.loadMetadata()
+	if err != nil {
+		return InsertOneResult{
+			Err: err,
+		}
+	}
 
 	// 1. Convertimos a BSON (map[string]interface{})
 	parsed, err := bson.ConvertToMap(doc)
@@ -222,17 +492,50 @@ func (c *Collection) insertOne(txn storage.Transaction, doc any) InsertOneResult
 		}
 	}
 
-	// 2. Generamos ID único
-	mDoc, docID := c.ensureDocumentID(parsed)
+	// 2. Generamos ID único con la estrategia de la colección
+	mDoc, docID, err := c.ensureDocumentID(txn, parsed)
+	if err != nil {
+		return InsertOneResult{
+			Err: err,
+		}
+	}
+
+	// 3. Validamos el documento contra el validador de la colección
+	if err := c.validateDocument(mDoc, nil); err != nil {
+		return InsertOneResult{
+			Err: err,
+		}
+	}
+
+	if meta.Versioned {
+		mDoc[consts.DocumentFieldVersion] = int64(1)
+	}
 
-	// 3. Verificamos unicidad en índices
-	if err := c.IndexManager.checkUniqueness(mDoc); err != nil {
+	// 4. Ciframos los campos protegidos antes de indexarlos
+	enc, err := c.encryptor()
+	if err != nil {
 		return InsertOneResult{
 			Err: err,
 		}
 	}
 
-	// 4. Serializamos a JSON
+	if enc != nil {
+		mDoc, err = enc.encryptDocument(mDoc)
+		if err != nil {
+			return InsertOneResult{
+				Err: err,
+			}
+		}
+	}
+
+	// 5. Verificamos unicidad en índices
+	if err := c.IndexManager.checkUniqueness(txn, mDoc, nil); err != nil {
+		return InsertOneResult{
+			Err: err,
+		}
+	}
+
+	// 6. Serializamos a JSON
 	data, err := bson.Marshal(mDoc)
 	if err != nil {
 		return InsertOneResult{
@@ -246,17 +549,27 @@ func (c *Collection) insertOne(txn storage.Transaction, doc any) InsertOneResult
 		}
 	}
 
-	// 5. Guardamos el documento
-	key := c.buildDocumentKey(docID)
+	// 7. Guardamos el documento
+	key := c.buildDocumentKey(encodeDocumentID(docID))
+	if _, err := txn.Get(key); err == nil {
+		return InsertOneResult{
+			Err: fmt.Errorf("%w: duplicate _id %v", ErrUniqueIndexViolation, docID),
+		}
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		return InsertOneResult{
+			Err: fmt.Errorf("storage get failed: %w", err),
+		}
+	}
+
 	if err := txn.Put(key, data); err != nil {
 		return InsertOneResult{
 			Err: fmt.Errorf("storage put failed: %w", err),
 		}
 	}
 
-	c.IndexManager.metadata.DocumentCount++
+	txn.count(c.collname, 1)
 
-	// 6. Registramos índices secundarios
+	// 8. Registramos índices secundarios
 	err = c.IndexManager.indexDocument(txn, mDoc)
 	if err != nil {
 		return InsertOneResult{
@@ -264,23 +577,28 @@ func (c *Collection) insertOne(txn storage.Transaction, doc any) InsertOneResult
 		}
 	}
 
-	// 7. Persistimos metadata si es la primera vez
-	if err := c.ensureInitialized(); err != nil {
-		return InsertOneResult{
-			Err: fmt.Errorf("metadata initialization failed: %w", err),
-		}
-	}
+	event := c.newChangeEvent(OperationInsert, mDoc[consts.DocumentFieldID])
+	event.FullDocument = mDoc
+	txn.record(event)
+
+	// 9. Persistimos metadata si es la primera vez
+	c.ensureInitialized(txn)
 
 	return InsertOneResult{
 		InsertedID: docID,
+		raw:        storage.KV{Key: key, Value: data},
 	}
 }
 
-// deleteOne deletes a single document by a filter.
-func (c *Collection) deleteOne(txn storage.Transaction, filter map[string]any) DeleteOneResult {
-	c.IndexManager.loadMetadata()
+// deleteOne deletes the first document that matches a filter, in the order of sort.
+func (c *Collection) deleteOne(txn *writeTxn, filter map[string]any, sort []options.SortField) DeleteOneResult {
+	if _, err := c.IndexManager.loadMetadata(); err != nil {
+		return DeleteOneResult{
+			Err: err,
+		}
+	}
 
-	result := c.FindOne(filter)
+	result := c.findForWrite(txn, filter, sort)
 	if result.Err != nil {
 		return DeleteOneResult{
 			Err: result.Err,
@@ -303,19 +621,22 @@ func (c *Collection) deleteOne(txn storage.Transaction, filter map[string]any) D
 		}
 	}
 
-	c.IndexManager.metadata.DocumentCount--
+	txn.count(c.collname, -1)
 
-	err = c.IndexManager.deleteDocumentIndexes(result.Document())
+	err = c.IndexManager.deleteDocumentIndexes(txn, result.raw.Document())
 	if err != nil {
 		return DeleteOneResult{
 			Err: fmt.Errorf("delete document indexes failed: %w", err),
 		}
 	}
 
-	c.IndexManager.saveMetadata()
+	event := c.newChangeEvent(OperationDelete, result.raw.Document()[consts.DocumentFieldID])
+	event.FullDocumentBeforeChange = result.raw.Document()
+	txn.record(event)
 
 	return DeleteOneResult{
-		DeletedID: docID,
+		DeletedID: result.raw.Document()[consts.DocumentFieldID],
+		raw:       result.raw,
 	}
 }
 
@@ -0,0 +1,368 @@
+package gopherdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/wirvii/gopherdb/options"
+)
+
+// bulkWriteBatchSize is the number of operations BulkWrite runs per transaction.
+const bulkWriteBatchSize = 100
+
+// WriteModel is an operation of a BulkWrite: an InsertOneModel, UpdateOneModel, UpdateManyModel,
+// ReplaceOneModel, DeleteOneModel or DeleteManyModel.
+type WriteModel interface {
+	// apply runs the operation in a write transaction.
+	apply(c *Collection, txn *writeTxn) (bulkOutcome, error)
+	// audited returns the audit operation and the filter of the operation.
+	audited() (AuditOperation, any)
+}
+
+// InsertOneModel inserts a document.
+type InsertOneModel struct {
+	Document any
+}
+
+// UpdateOneModel updates the first document that matches the filter, like Collection.UpdateOne.
+type UpdateOneModel struct {
+	Filter any
+	Update any
+	Upsert bool
+}
+
+// UpdateManyModel applies update operators to every document that matches the filter, like
+// Collection.UpdateMany.
+type UpdateManyModel struct {
+	Filter any
+	Update any
+	Upsert bool
+}
+
+// ReplaceOneModel replaces the first document that matches the filter, like
+// Collection.ReplaceOne.
+type ReplaceOneModel struct {
+	Filter      any
+	Replacement any
+	Upsert      bool
+}
+
+// DeleteOneModel deletes the first document that matches the filter.
+type DeleteOneModel struct {
+	Filter any
+}
+
+// DeleteManyModel deletes every document that matches the filter.
+type DeleteManyModel struct {
+	Filter any
+}
+
+// bulkOutcome is the outcome of an operation of a bulk write.
+type bulkOutcome struct {
+	inserted int64
+	matched  int64
+	modified int64
+	deleted  int64
+	upserted int64
+	// id is the ID of the inserted or upserted document.
+	id any
+	// ids are the IDs of the written documents, for the audit log.
+	ids []any
+}
+
+// bulkOperationError is the error of an operation of a batch, which rolls back its transaction.
+type bulkOperationError struct {
+	err error
+}
+
+// Error implements error.
+func (e *bulkOperationError) Error() string {
+	return e.err.Error()
+}
+
+// BulkWrite runs inserts, updates and deletes. The operations run in transactions of up to
+// bulkWriteBatchSize operations; an operation that fails is left out of its transaction, and the
+// ones after it run in the next one. Ordered bulk writes, the default, stop at the first failed
+// operation; unordered ones run every operation. The result counts the operations that were
+// committed and lists the errors of those that failed, with Err wrapping ErrBulkWrite and the
+// first of them. The context is checked before each transaction and by the scans of its
+// operations.
+func (c *Collection) BulkWrite(
+	ctx context.Context,
+	models []WriteModel,
+	opts ...*options.BulkWriteOptions,
+) (result BulkWriteResult) {
+	opt := options.BulkWrite().Merge(opts...)
+	ordered := opt.Ordered == nil || *opt.Ordered
+
+	result = BulkWriteResult{
+		InsertedIDs: make(map[int]any),
+		UpsertedIDs: make(map[int]any),
+		WriteErrors: make([]BulkWriteError, 0),
+	}
+
+	if err := c.db.checkWritable(); err != nil {
+		result.Err = err
+
+		return result
+	}
+
+	for pos := 0; pos < len(models); {
+		if err := ctx.Err(); err != nil {
+			result.Err = err
+
+			return result
+		}
+
+		end := min(pos+bulkWriteBatchSize, len(models))
+
+		outcomes, opErr, err := c.bulkWriteBatch(ctx, models[pos:end])
+		if err != nil {
+			result.Err = err
+
+			return result
+		}
+
+		for _, outcome := range outcomes {
+			result.add(pos, outcome)
+			c.auditBulk(models[pos], outcome, nil)
+			pos++
+		}
+
+		if opErr == nil {
+			continue
+		}
+
+		result.WriteErrors = append(result.WriteErrors, BulkWriteError{Index: pos, Err: opErr})
+		c.auditBulk(models[pos], bulkOutcome{}, opErr)
+
+		if ordered {
+			break
+		}
+
+		pos++
+	}
+
+	if len(result.WriteErrors) > 0 {
+		result.Err = fmt.Errorf("%w: %d of %d operations failed: %w",
+			ErrBulkWrite, len(result.WriteErrors), len(models), result.WriteErrors[0])
+	}
+
+	return result
+}
+
+// bulkWriteBatch runs operations in a transaction. When one fails, the transaction is rolled back
+// and the operations before it run again in a new one; the outcomes of the committed operations
+// are returned with the error of the failed one.
+func (c *Collection) bulkWriteBatch(ctx context.Context, models []WriteModel) ([]bulkOutcome, error, error) {
+	var outcomes []bulkOutcome
+
+	err := c.db.runWrite(ctx, func(txn *writeTxn) error {
+		outcomes = make([]bulkOutcome, 0, len(models))
+
+		for _, model := range models {
+			outcome, err := model.apply(c, txn)
+			if err != nil {
+				return &bulkOperationError{err: err}
+			}
+
+			outcomes = append(outcomes, outcome)
+		}
+
+		return nil
+	})
+
+	var failed *bulkOperationError
+	if !errors.As(err, &failed) {
+		return outcomes, nil, err
+	}
+
+	if len(outcomes) == 0 {
+		return nil, failed.err, nil
+	}
+
+	// Las operaciones anteriores a la fallida se repiten sin ella; si ahora falla una anterior, es
+	// esa la que se informa
+	committed, opErr, err := c.bulkWriteBatch(ctx, models[:len(outcomes)])
+	if opErr != nil || err != nil {
+		return committed, opErr, err
+	}
+
+	return committed, failed.err, nil
+}
+
+// add adds the outcome of the operation at index to the result.
+func (r *BulkWriteResult) add(index int, outcome bulkOutcome) {
+	r.InsertedCount += outcome.inserted
+	r.MatchedCount += outcome.matched
+	r.ModifiedCount += outcome.modified
+	r.DeletedCount += outcome.deleted
+	r.UpsertedCount += outcome.upserted
+
+	switch {
+	case outcome.inserted > 0:
+		r.InsertedIDs[index] = outcome.id
+	case outcome.upserted > 0:
+		r.UpsertedIDs[index] = outcome.id
+	}
+}
+
+// auditBulk records an operation of a bulk write.
+func (c *Collection) auditBulk(model WriteModel, outcome bulkOutcome, err error) {
+	op, filter := model.audited()
+	c.audit(op, filter, outcome.ids, nil, err)
+}
+
+// apply inserts the document.
+func (m InsertOneModel) apply(c *Collection, txn *writeTxn) (bulkOutcome, error) {
+	if _, err := validateDocumentType(m.Document); err != nil {
+		return bulkOutcome{}, err
+	}
+
+	one := c.insertOne(txn, m.Document)
+	if one.Err != nil {
+		return bulkOutcome{}, one.Err
+	}
+
+	return bulkOutcome{inserted: 1, id: one.InsertedID, ids: []any{one.InsertedID}}, nil
+}
+
+// audited implements WriteModel.
+func (m InsertOneModel) audited() (AuditOperation, any) {
+	return AuditInsert, nil
+}
+
+// apply updates the first matching document.
+func (m UpdateOneModel) apply(c *Collection, txn *writeTxn) (bulkOutcome, error) {
+	query, err := filterDocument(m.Filter)
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	return bulkUpdateOutcome(c.updateOne(txn, query, nil, m.Update, options.Update().SetUpsert(m.Upsert)))
+}
+
+// audited implements WriteModel.
+func (m UpdateOneModel) audited() (AuditOperation, any) {
+	return AuditUpdate, m.Filter
+}
+
+// apply updates every matching document.
+func (m UpdateManyModel) apply(c *Collection, txn *writeTxn) (bulkOutcome, error) {
+	query, err := filterDocument(m.Filter)
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	docMap, err := updateManyDocument(m.Update)
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	enc, err := c.encryptor()
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	_, expr, err := c.compileFilter(enc, query)
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	result, ids, _ := c.updateMany(txn, query, expr, docMap, options.Update().SetUpsert(m.Upsert), math.MaxInt)
+	if result.Err != nil {
+		return bulkOutcome{}, result.Err
+	}
+
+	if result.UpsertedID != nil {
+		return bulkOutcome{upserted: 1, id: result.UpsertedID, ids: []any{result.UpsertedID}}, nil
+	}
+
+	return bulkOutcome{matched: result.MatchedCount, modified: result.ModifiedCount, ids: ids}, nil
+}
+
+// audited implements WriteModel.
+func (m UpdateManyModel) audited() (AuditOperation, any) {
+	return AuditUpdate, m.Filter
+}
+
+// apply replaces the first matching document.
+func (m ReplaceOneModel) apply(c *Collection, txn *writeTxn) (bulkOutcome, error) {
+	query, err := filterDocument(m.Filter)
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	docMap, err := c.replacementDocument(m.Replacement)
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	return bulkUpdateOutcome(c.updateOne(txn, query, nil, docMap, options.Update().SetSet(true).SetUpsert(m.Upsert)))
+}
+
+// audited implements WriteModel.
+func (m ReplaceOneModel) audited() (AuditOperation, any) {
+	return AuditUpdate, m.Filter
+}
+
+// apply deletes the first matching document.
+func (m DeleteOneModel) apply(c *Collection, txn *writeTxn) (bulkOutcome, error) {
+	query, err := filterDocument(m.Filter)
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	one := c.deleteOne(txn, query, nil)
+	if errors.Is(one.Err, ErrDocumentNotFound) {
+		return bulkOutcome{}, nil
+	}
+
+	if one.Err != nil {
+		return bulkOutcome{}, one.Err
+	}
+
+	return bulkOutcome{deleted: 1, ids: []any{one.DeletedID}}, nil
+}
+
+// audited implements WriteModel.
+func (m DeleteOneModel) audited() (AuditOperation, any) {
+	return AuditDelete, m.Filter
+}
+
+// apply deletes every matching document.
+func (m DeleteManyModel) apply(c *Collection, txn *writeTxn) (bulkOutcome, error) {
+	query, err := filterDocument(m.Filter)
+	if err != nil {
+		return bulkOutcome{}, err
+	}
+
+	result := c.deleteMany(txn, query)
+	if result.Err != nil {
+		return bulkOutcome{}, result.Err
+	}
+
+	return bulkOutcome{deleted: int64(len(result.DeletedIDs)), ids: result.DeletedIDs}, nil
+}
+
+// audited implements WriteModel.
+func (m DeleteManyModel) audited() (AuditOperation, any) {
+	return AuditDelete, m.Filter
+}
+
+// bulkUpdateOutcome returns the outcome of an update or replacement of a single document. An
+// update that matches nothing is not an error of a bulk write.
+func bulkUpdateOutcome(one UpdateOneResult) (bulkOutcome, error) {
+	switch {
+	case errors.Is(one.Err, ErrDocumentNotFound):
+		return bulkOutcome{}, nil
+	case one.Err != nil:
+		return bulkOutcome{}, one.Err
+	case one.MatchedCount == 0:
+		return bulkOutcome{upserted: 1, id: one.UpsertedID, ids: []any{one.UpsertedID}}, nil
+	}
+
+	return bulkOutcome{matched: 1, modified: one.ModifiedCount, ids: []any{one.UpsertedID}}, nil
+}
@@ -0,0 +1,45 @@
+package gopherdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+)
+
+func TestBulkWriteDuplicateUniqueKey(t *testing.T) {
+	for _, ordered := range []bool{true, false} {
+		t.Run(map[bool]string{true: "ordered", false: "unordered"}[ordered], func(t *testing.T) {
+			coll := newUniqueEmailCollection(t)
+
+			r := coll.BulkWrite(context.Background(), []gopherdb.WriteModel{
+				gopherdb.InsertOneModel{Document: account{ID: 1, Email: "a@example.com"}},
+				gopherdb.InsertOneModel{Document: account{ID: 2, Email: "a@example.com"}},
+				gopherdb.InsertOneModel{Document: account{ID: 3, Email: "c@example.com"}},
+			}, options.BulkWrite().SetOrdered(ordered))
+
+			if !errors.Is(r.Err, gopherdb.ErrUniqueIndexViolation) {
+				t.Fatalf("got %v, want %v", r.Err, gopherdb.ErrUniqueIndexViolation)
+			}
+
+			if len(r.WriteErrors) != 1 || r.WriteErrors[0].Index != 1 {
+				t.Fatalf("write errors %v, want the second insert", r.WriteErrors)
+			}
+
+			want := int64(2)
+			if ordered {
+				want = 1
+			}
+
+			if r.InsertedCount != want {
+				t.Fatalf("inserted %d, want %d", r.InsertedCount, want)
+			}
+
+			if n, err := coll.CountDocuments(map[string]any{"email": "a@example.com"}); err != nil || n != 1 {
+				t.Fatalf("count of the duplicate email %d, %v, want 1", n, err)
+			}
+		})
+	}
+}
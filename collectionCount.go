@@ -0,0 +1,146 @@
+package gopherdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/wirvii/gopherdb/internal/consts"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CountDocuments counts the documents that match a filter. When an index resolves every field of
+// the filter by equality, or the filter is empty, the entries of the index are counted without
+// reading the documents; otherwise the matching documents are counted as Find finds them.
+func (c *Collection) CountDocuments(filter any) (int64, error) {
+	return c.CountDocumentsContext(context.Background(), filter)
+}
+
+// CountDocumentsContext is like CountDocuments, but stops when the context is done, returning its
+// error.
+func (c *Collection) CountDocumentsContext(ctx context.Context, filter any) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	query, err := filterDocument(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	meta, err := c.IndexManager.loadMetadata()
+	if err != nil {
+		return 0, err
+	}
+
+	enc, err := c.encryptor()
+	if err != nil {
+		return 0, err
+	}
+
+	planned := query
+	if enc != nil {
+		planned, err = enc.encryptFilter(query)
+		if err != nil {
+			return 0, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	if prefix, ok := c.countPrefix(meta.Indexes, planned); ok {
+		keys, err := c.storage.ScanKeys(prefix)
+		if err != nil {
+			return 0, fmt.Errorf("scan index keys failed: %w", err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		return int64(len(keys)), nil
+	}
+
+	result := c.FindContext(ctx, query)
+	if result.Err != nil {
+		return 0, result.Err
+	}
+
+	return result.TotalCount, nil
+}
+
+// EstimatedDocumentCount returns the number of documents of the collection kept in its metadata,
+// without scanning it. The count is updated by the transactions that insert and delete documents.
+func (c *Collection) EstimatedDocumentCount() (int64, error) {
+	return c.EstimatedDocumentCountContext(context.Background())
+}
+
+// EstimatedDocumentCountContext is like EstimatedDocumentCount, but fails with the error of the
+// context when it is done.
+func (c *Collection) EstimatedDocumentCountContext(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	meta, err := c.IndexManager.loadMetadata()
+	if err != nil {
+		return 0, err
+	}
+
+	return meta.DocumentCount, nil
+}
+
+// countPrefix returns the prefix of the index keys of the documents that match a filter, when
+// the planner picks one of the indexes that resolves the whole filter by equality.
+func (c *Collection) countPrefix(indexes []IndexModel, filter map[string]any) (string, bool) {
+	m := c.IndexManager
+
+	if len(filter) == 0 {
+		for _, idx := range indexes {
+			if idx.Options.Name == "_id_" {
+				return m.buildIndexFieldsKey(idx), true
+			}
+		}
+
+		return "", false
+	}
+
+	plan := NewQueryPlanner(indexes).Plan(filter, nil)
+	if !plan.IsExact || len(plan.IndexFilter) != len(filter) {
+		return "", false
+	}
+
+	for field, v := range plan.IndexFilter {
+		if op, ok := filter[field].(map[string]any); ok && len(op) != 1 {
+			return "", false
+		}
+
+		if !indexCountable(v) {
+			return "", false
+		}
+	}
+
+	key, err := m.buildDocumentIndexKey(*plan.IndexUsed, plan.IndexFilter, true)
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimSuffix(key, consts.RemoverWildcard), true
+}
+
+// indexCountable reports whether equality with a value can be resolved by its index key alone.
+// Documents, arrays, regular expressions and operators are evaluated against the documents.
+func indexCountable(v any) bool {
+	switch v.(type) {
+	case nil, primitive.Regex:
+		return false
+	case primitive.ObjectID:
+		return true
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return false
+	default:
+		return true
+	}
+}
@@ -1,25 +1,37 @@
 package gopherdb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/wirvii/gopherdb/internal/consts"
 )
 
 // DeleteOne deletes a single document by a filter.
-func (c *Collection) DeleteOne(filter map[string]any) DeleteOneResult {
-	txn := c.storage.BeginTx()
-	result := c.deleteOne(txn, filter)
+func (c *Collection) DeleteOne(filter any) DeleteOneResult {
+	return c.DeleteOneContext(context.Background(), filter)
+}
 
-	if result.Err != nil {
-		txn.Rollback()
+// DeleteOneContext is like DeleteOne, but fails with the error of the context when it is done
+// before the delete commits.
+func (c *Collection) DeleteOneContext(ctx context.Context, filter any) (result DeleteOneResult) {
+	defer func() { c.audit(AuditDelete, filter, []any{result.DeletedID}, nil, result.Err) }()
 
-		return result
+	query, err := filterDocument(filter)
+	if err != nil {
+		return DeleteOneResult{
+			Err: err,
+		}
 	}
 
-	if err := txn.Commit(); err != nil {
+	err = c.db.runWrite(ctx, func(txn *writeTxn) error {
+		result = c.deleteOne(txn, query, nil)
+
+		return result.Err
+	})
+	if err != nil {
 		return DeleteOneResult{
-			Err: fmt.Errorf("commit failed: %w", err),
+			Err: err,
 		}
 	}
 
@@ -28,18 +40,24 @@ func (c *Collection) DeleteOne(filter map[string]any) DeleteOneResult {
 
 // DeleteByID deletes a single document by its ID.
 func (c *Collection) DeleteByID(id any) DeleteOneResult {
-	txn := c.storage.BeginTx()
-	result := c.deleteOne(txn, map[string]any{"_id": id})
+	return c.DeleteByIDContext(context.Background(), id)
+}
 
-	if result.Err != nil {
-		txn.Rollback()
+// DeleteByIDContext is like DeleteByID, but fails with the error of the context when it is done
+// before the delete commits.
+func (c *Collection) DeleteByIDContext(ctx context.Context, id any) (result DeleteOneResult) {
+	defer func() {
+		c.audit(AuditDelete, map[string]any{consts.DocumentFieldID: id}, []any{result.DeletedID}, nil, result.Err)
+	}()
 
-		return result
-	}
+	err := c.db.runWrite(ctx, func(txn *writeTxn) error {
+		result = c.deleteOne(txn, map[string]any{consts.DocumentFieldID: id}, nil)
 
-	if err := txn.Commit(); err != nil {
+		return result.Err
+	})
+	if err != nil {
 		return DeleteOneResult{
-			Err: fmt.Errorf("commit failed: %w", err),
+			Err: err,
 		}
 	}
 
@@ -47,8 +65,39 @@ func (c *Collection) DeleteByID(id any) DeleteOneResult {
 }
 
 // Delete deletes multiple documents by a filter.
-func (c *Collection) Delete(filter map[string]any) DeleteManyResult {
-	results := c.Find(filter)
+func (c *Collection) Delete(filter any) DeleteManyResult {
+	return c.DeleteContext(context.Background(), filter)
+}
+
+// DeleteContext is like Delete, but fails with the error of the context when it is done before
+// the delete commits, without deleting any document.
+func (c *Collection) DeleteContext(ctx context.Context, filter any) (result DeleteManyResult) {
+	defer func() { c.audit(AuditDelete, filter, result.DeletedIDs, nil, result.Err) }()
+
+	query, err := filterDocument(filter)
+	if err != nil {
+		return DeleteManyResult{
+			Err: err,
+		}
+	}
+
+	err = c.db.runWrite(ctx, func(txn *writeTxn) error {
+		result = c.deleteMany(txn, query)
+
+		return result.Err
+	})
+	if err != nil {
+		return DeleteManyResult{
+			Err: err,
+		}
+	}
+
+	return result
+}
+
+// deleteMany deletes the documents that match a query, finding them through the transaction.
+func (c *Collection) deleteMany(txn *writeTxn, query map[string]any) DeleteManyResult {
+	results := c.find(txn.ctx, txn, query)
 
 	if results.Err != nil {
 		return DeleteManyResult{
@@ -56,35 +105,34 @@ func (c *Collection) Delete(filter map[string]any) DeleteManyResult {
 		}
 	}
 
-	txn := c.storage.BeginTx()
-
 	deletedIDs := make([]any, 0)
 
 	for _, kv := range results.raw {
-		if err := txn.Delete(kv.Key); err != nil {
-			txn.Rollback()
+		if err := txn.ctx.Err(); err != nil {
+			return DeleteManyResult{
+				Err: err,
+			}
+		}
 
+		if err := txn.Delete(kv.Key); err != nil {
 			return DeleteManyResult{
 				Err: fmt.Errorf("delete failed: %w", err),
 			}
 		}
 
-		match, err := consts.DocumentKeyPathmatcher.Match(kv.Key)
-		if err != nil {
-			txn.Rollback()
-
+		if err := c.IndexManager.deleteDocumentIndexes(txn, kv.Document()); err != nil {
 			return DeleteManyResult{
-				Err: fmt.Errorf("match failed: %w", err),
+				Err: fmt.Errorf("delete document indexes failed: %w", err),
 			}
 		}
 
-		deletedIDs = append(deletedIDs, match["docId"])
-	}
+		txn.count(c.collname, -1)
 
-	if err := txn.Commit(); err != nil {
-		return DeleteManyResult{
-			Err: fmt.Errorf("commit failed: %w", err),
-		}
+		event := c.newChangeEvent(OperationDelete, kv.Document()[consts.DocumentFieldID])
+		event.FullDocumentBeforeChange = kv.Document()
+		txn.record(event)
+
+		deletedIDs = append(deletedIDs, kv.Document()[consts.DocumentFieldID])
 	}
 
 	return DeleteManyResult{
@@ -1,21 +1,54 @@
 package gopherdb
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/wirvii/gopherdb/internal/bson"
 	"github.com/wirvii/gopherdb/internal/queryengine"
 	"github.com/wirvii/gopherdb/internal/storage"
 	"github.com/wirvii/gopherdb/options"
 )
 
-// FindByID finds a document by its ID.
+// FindByID finds a document by its ID. The type of the ID matters: FindByID(int64(5)) does not
+// find the document whose ID is "5".
 func (c *Collection) FindByID(id any) FindOneResult {
-	key := c.buildDocumentKey(fmt.Sprintf("%v", id))
-	data, err := c.storage.Get(key)
+	return c.FindByIDContext(context.Background(), id)
+}
+
+// FindByIDContext is like FindByID, but fails with the error of the context when it is done.
+func (c *Collection) FindByIDContext(ctx context.Context, id any) FindOneResult {
+	if err := ctx.Err(); err != nil {
+		return FindOneResult{
+			Err: err,
+		}
+	}
+
+	return c.findByKey(c.storage, c.buildDocumentKey(encodeDocumentID(id)))
+}
 
+// findByKey finds a document by its key.
+func (c *Collection) findByKey(r storageReader, key string) FindOneResult {
+	enc, err := c.encryptor()
 	if err != nil {
 		return FindOneResult{
-			Err: fmt.Errorf("document not found: %w", err),
+			Err: err,
+		}
+	}
+
+	data, err := r.Get(key)
+
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return FindOneResult{
+			Err: ErrDocumentNotFound,
+		}
+	}
+
+	if err != nil {
+		return FindOneResult{
+			Err: fmt.Errorf("get document failed: %w", err),
 		}
 	}
 
@@ -24,16 +57,27 @@ func (c *Collection) FindByID(id any) FindOneResult {
 			Key:   key,
 			Value: data,
 		},
+		encryption: enc,
 	}
 }
 
 // FindOne finds a single document by a filter.
 func (c *Collection) FindOne(
-	filter map[string]any,
+	filter any,
 ) FindOneResult {
-	opts := options.Find().SetLimit(1)
+	return c.FindOneContext(context.Background(), filter)
+}
+
+// FindOneContext is like FindOne, but stops when the context is done. See FindContext.
+func (c *Collection) FindOneContext(ctx context.Context, filter any) FindOneResult {
+	return c.findOne(ctx, filter)
+}
 
-	result := c.Find(filter, opts)
+// findOne finds the first document that Find finds with the options.
+func (c *Collection) findOne(ctx context.Context, filter any, opts ...*options.FindOptions) FindOneResult {
+	opts = append(opts, options.Find().SetLimit(1))
+
+	result := c.FindContext(ctx, filter, opts...)
 	if result.Err != nil {
 		return FindOneResult{
 			Err: result.Err,
@@ -46,7 +90,10 @@ func (c *Collection) FindOne(
 		}
 	}
 
-	resultFind := FindOneResult{}
+	resultFind := FindOneResult{
+		IndexUsed:  result.IndexUsed,
+		encryption: result.encryption,
+	}
 
 	if len(result.raw) > 0 {
 		resultFind.raw = result.raw[0]
@@ -55,28 +102,126 @@ func (c *Collection) FindOne(
 	return resultFind
 }
 
-// Find finds documents by a filter.
+// Find finds documents by a filter. The filter is a map[string]any, a bson.M, a bson.D or a
+// type whose underlying type is bson.D, such as filter.Filter; a nil filter matches every
+// document. With the ReadAt option, the documents are found as they were after a past commit,
+// like with Database.SnapshotAt.
 func (c *Collection) Find(
-	filter map[string]any,
+	filter any,
 	opts ...*options.FindOptions,
 ) FindResult {
-	c.IndexManager.loadMetadata()
+	return c.FindContext(context.Background(), filter, opts...)
+}
+
+// FindContext is like Find, but stops when the context is done, returning its error. The context
+// is checked while the documents or the entries of an index are read. With the MaxTime option,
+// a query that runs longer fails with ErrMaxTimeExceeded.
+func (c *Collection) FindContext(
+	ctx context.Context,
+	filter any,
+	opts ...*options.FindOptions,
+) (result FindResult) {
+	opt := options.Find().Merge(opts...)
+
+	ctx, done := withMaxTime(ctx, opt.MaxTime)
+	defer func() { result.Err = done(result.Err) }()
+
+	if opt.ReadAt == nil {
+		return c.find(ctx, c.storage, filter, opt)
+	}
+
+	r, err := c.db.readerAt(*opt.ReadAt)
+	if err != nil {
+		return FindResult{
+			Err: err,
+		}
+	}
+
+	return c.find(ctx, r, filter, opt)
+}
+
+// compileFilter returns the filter as it matches the stored documents, with the values of the
+// encrypted fields encrypted, and the expression that evaluates it.
+func (c *Collection) compileFilter(
+	enc *fieldEncryptor,
+	query map[string]any,
+) (map[string]any, queryengine.Expr, error) {
+	if enc != nil {
+		var err error
+
+		query, err = enc.encryptFilter(query)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	expr, err := queryengine.ParseFilter(query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	return query, expr, nil
+}
+
+// find finds documents by a filter, reading them through r: the storage, or a write transaction,
+// which sees its own writes and fails to commit when another transaction changes what it read.
+// It stops when the context is done.
+func (c *Collection) find(
+	ctx context.Context,
+	r storageReader,
+	filter any,
+	opts ...*options.FindOptions,
+) FindResult {
+	meta, err := c.IndexManager.loadMetadata()
+	if err != nil {
+		return FindResult{
+			Err: err,
+		}
+	}
 
 	opt := options.Find()
 	if len(opts) > 0 {
 		opt = opt.Merge(opts...)
 	}
 
-	planner := NewQueryPlanner(c.IndexManager.metadata.Indexes)
-	plan := planner.Plan(filter, opt.Sort)
+	query, err := filterDocument(filter)
+	if err != nil {
+		return FindResult{
+			Err: err,
+		}
+	}
 
-	expr, err := queryengine.ParseFilter(filter)
+	if err := opt.Validate(); err != nil {
+		return FindResult{
+			Err: fmt.Errorf("%w: %v", ErrInvalidQuery, err),
+		}
+	}
+
+	enc, err := c.encryptor()
 	if err != nil {
 		return FindResult{
-			Err: fmt.Errorf("invalid filter: %w", err),
+			Err: err,
 		}
 	}
 
+	query, expr, err := c.compileFilter(enc, query)
+	if err != nil {
+		return FindResult{
+			Err: err,
+		}
+	}
+
+	indexes := meta.Indexes
+	if opt.ReadAt != nil {
+		// Un índice creado después del commit leído no tiene entradas para sus documentos
+		indexes = nil
+	}
+
+	planner := NewQueryPlanner(indexes)
+	plan := planner.Plan(query, opt.Sort)
+
+	// Sin filtro, el índice de orden permite paginar antes de leer los documentos
+	paged := plan.UsedForSort && len(query) == 0
 	raw := make([]storage.KV, 0)
 	totalCount := int64(0)
 
@@ -84,7 +229,7 @@ func (c *Collection) Find(
 		docKeys := make([]string, 0)
 
 		if plan.IndexFilter != nil && len(plan.IndexFilter) > 0 {
-			docKeys, err = c.IndexManager.getDocumentIndexKeysByIndexAndFilter(*plan.IndexUsed, plan.IndexFilter)
+			docKeys, err = c.IndexManager.getDocumentIndexKeysByIndexAndFilter(r, *plan.IndexUsed, plan.IndexFilter)
 
 			if err != nil {
 				return FindResult{
@@ -92,7 +237,7 @@ func (c *Collection) Find(
 				}
 			}
 		} else {
-			docKeys, err = c.IndexManager.getDocumentIndexKeysByIndex(*plan.IndexUsed)
+			docKeys, err = c.IndexManager.getDocumentIndexKeysByIndex(r, *plan.IndexUsed)
 			if err != nil {
 				return FindResult{
 					Err: fmt.Errorf("get all document keys by index failed: %w", err),
@@ -100,7 +245,7 @@ func (c *Collection) Find(
 			}
 		}
 
-		if plan.UsedForSort {
+		if paged {
 			// ✅ Aplica paginación directamente
 			start := 0
 			end := len(docKeys)
@@ -121,6 +266,12 @@ func (c *Collection) Find(
 		}
 
 		for _, docKey := range docKeys {
+			if err := ctx.Err(); err != nil {
+				return FindResult{
+					Err: err,
+				}
+			}
+
 			k, err := c.IndexManager.getDocumentIdFromIndexKey(docKey)
 			if err != nil {
 				return FindResult{
@@ -128,14 +279,18 @@ func (c *Collection) Find(
 				}
 			}
 
-			result := c.FindByID(k)
-			if result.Err != nil && result.Err != ErrDocumentNotFound {
+			result := c.findByKey(r, c.buildDocumentKey(k))
+			if result.Err == ErrDocumentNotFound {
+				continue
+			}
+
+			if result.Err != nil {
 				return FindResult{
 					Err: fmt.Errorf("get document by key failed: %w", result.Err),
 				}
 			}
 
-			if expr.Evaluate(result.Document()) {
+			if expr.Evaluate(result.raw.Document()) {
 				raw = append(raw, result.raw)
 				totalCount++
 			}
@@ -143,7 +298,7 @@ func (c *Collection) Find(
 	} else {
 		documentsKey := c.IndexManager.buildDocumentsKey()
 
-		docs, err := c.storage.Scan(documentsKey)
+		docs, err := scanContext(ctx, r, documentsKey)
 		if err != nil {
 			return FindResult{
 				Err: fmt.Errorf("scan keys failed: %w", err),
@@ -162,12 +317,17 @@ func (c *Collection) Find(
 		raw:        raw,
 		IndexUsed:  plan.IndexUsed,
 		TotalCount: totalCount,
+		encryption: enc,
 	}
 
 	if opt.Sort != nil && !plan.UsedForSort {
 		c.sortDocuments(result.raw, opt)
 	}
 
+	if paged {
+		return result
+	}
+
 	if opt.Skip != nil {
 		if int(*opt.Skip) < len(result.raw) {
 			result.raw = result.raw[*opt.Skip:]
@@ -184,3 +344,35 @@ func (c *Collection) Find(
 
 	return result
 }
+
+// filterDocument converts a filter given as a map[string]any, a bson.M, a bson.D or a type whose
+// underlying type is bson.D to the map taken by the query engine.
+func filterDocument(filter any) (map[string]any, error) {
+	doc, err := bson.ToDocument(filter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid filter: %v", ErrInvalidQuery, err)
+	}
+
+	return doc, nil
+}
+
+// withMaxTime bounds the context of a query by its max time, if any. The returned function
+// releases the context and turns the error of a query stopped by the max time into
+// ErrMaxTimeExceeded.
+func withMaxTime(ctx context.Context, maxTime *time.Duration) (context.Context, func(err error) error) {
+	if maxTime == nil {
+		return ctx, func(err error) error { return err }
+	}
+
+	bounded, cancel := context.WithTimeoutCause(ctx, *maxTime, ErrMaxTimeExceeded)
+
+	return bounded, func(err error) error {
+		defer cancel()
+
+		if errors.Is(err, context.DeadlineExceeded) && errors.Is(context.Cause(bounded), ErrMaxTimeExceeded) {
+			return fmt.Errorf("%w of %s: %w", ErrMaxTimeExceeded, *maxTime, context.DeadlineExceeded)
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,281 @@
+package gopherdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// FindOneAndUpdate updates the first document that matches the filter, in the order of the sort
+// option, and returns it as it was before the update or, with ReturnDocumentAfter, as it is after
+// it. The document is found and updated in a single transaction, so two concurrent calls never
+// update the same version of a document: the one that commits second is retried and sees the
+// update of the first. It returns ErrDocumentNotFound when no document matches, or when the
+// document is upserted and the version before the update is asked for.
+func (c *Collection) FindOneAndUpdate(
+	filter any,
+	doc any,
+	opts ...*options.FindOneAndUpdateOptions,
+) FindOneResult {
+	return c.FindOneAndUpdateContext(context.Background(), filter, doc, opts...)
+}
+
+// FindOneAndUpdateContext is like FindOneAndUpdate, but fails with the error of the context when
+// it is done before the update commits.
+func (c *Collection) FindOneAndUpdateContext(
+	ctx context.Context,
+	filter any,
+	doc any,
+	opts ...*options.FindOneAndUpdateOptions,
+) FindOneResult {
+	opt := options.FindOneAndUpdate().Merge(opts...)
+	if err := opt.Validate(); err != nil {
+		return FindOneResult{
+			Err: fmt.Errorf("%w: %v", ErrInvalidQuery, err),
+		}
+	}
+
+	updateOpt := options.Update()
+	if opt.Upsert != nil {
+		updateOpt.SetUpsert(*opt.Upsert)
+	}
+
+	after := opt.ReturnDocument != nil && *opt.ReturnDocument == options.ReturnDocumentAfter
+	result, _ := c.findOneAndUpdate(ctx, filter, doc, opt.Sort, opt.Projection, updateOpt, after)
+
+	return result
+}
+
+// FindOneAndReplace replaces the first document that matches the filter, in the order of the sort
+// option, and returns it as it was before or, with ReturnDocumentAfter, as it is after the
+// replacement. The replacement keeps the _id of the document and cannot have update operators.
+// Like FindOneAndUpdate, the document is found and replaced in a single transaction.
+func (c *Collection) FindOneAndReplace(
+	filter any,
+	replacement any,
+	opts ...*options.FindOneAndReplaceOptions,
+) FindOneResult {
+	return c.FindOneAndReplaceContext(context.Background(), filter, replacement, opts...)
+}
+
+// FindOneAndReplaceContext is like FindOneAndReplace, but fails with the error of the context
+// when it is done before the replacement commits.
+func (c *Collection) FindOneAndReplaceContext(
+	ctx context.Context,
+	filter any,
+	replacement any,
+	opts ...*options.FindOneAndReplaceOptions,
+) FindOneResult {
+	opt := options.FindOneAndReplace().Merge(opts...)
+	if err := opt.Validate(); err != nil {
+		return FindOneResult{
+			Err: fmt.Errorf("%w: %v", ErrInvalidQuery, err),
+		}
+	}
+
+	updateOpt := options.Update().SetSet(true)
+	if opt.Upsert != nil {
+		updateOpt.SetUpsert(*opt.Upsert)
+	}
+
+	after := opt.ReturnDocument != nil && *opt.ReturnDocument == options.ReturnDocumentAfter
+	result, _ := c.findOneAndUpdate(ctx, filter, replacement, opt.Sort, opt.Projection, updateOpt, after)
+
+	return result
+}
+
+// findOneAndUpdate updates or, with the set option, replaces the first document that matches the
+// filter in the order of sort, and returns the version of it that after selects along with the
+// result of the update, whose matched document is empty when the document was upserted.
+func (c *Collection) findOneAndUpdate(
+	ctx context.Context,
+	filter any,
+	doc any,
+	sort []options.SortField,
+	projection map[string]any,
+	updateOpt *options.UpdateOptions,
+	after bool,
+) (result FindOneResult, one UpdateOneResult) {
+	defer func() { c.audit(AuditUpdate, filter, []any{one.UpsertedID}, nil, result.Err) }()
+
+	if _, err := validateDocumentType(doc); err != nil {
+		return FindOneResult{
+			Err: err,
+		}, one
+	}
+
+	if updateOpt.Set != nil && *updateOpt.Set {
+		docMap, err := c.replacementDocument(doc)
+		if err != nil {
+			return FindOneResult{
+				Err: err,
+			}, one
+		}
+
+		doc = docMap
+	}
+
+	result = c.findOneAndModify(ctx, filter, projection, func(txn *writeTxn, query map[string]any) (storage.KV, error) {
+		one = c.updateOne(txn, query, sort, doc, updateOpt)
+		if one.Err != nil {
+			return storage.KV{}, one.Err
+		}
+
+		if after {
+			return one.updated, nil
+		}
+
+		return one.matched, nil
+	})
+
+	return result, one
+}
+
+// FindOneAndDelete deletes the first document that matches the filter, in the order of the sort
+// option, and returns it. Like FindOneAndUpdate, the document is found and deleted in a single
+// transaction, so two concurrent calls never return the same document.
+func (c *Collection) FindOneAndDelete(
+	filter any,
+	opts ...*options.FindOneAndDeleteOptions,
+) FindOneResult {
+	return c.FindOneAndDeleteContext(context.Background(), filter, opts...)
+}
+
+// FindOneAndDeleteContext is like FindOneAndDelete, but fails with the error of the context when
+// it is done before the delete commits.
+func (c *Collection) FindOneAndDeleteContext(
+	ctx context.Context,
+	filter any,
+	opts ...*options.FindOneAndDeleteOptions,
+) (result FindOneResult) {
+	var id any
+
+	defer func() { c.audit(AuditDelete, filter, []any{id}, nil, result.Err) }()
+
+	opt := options.FindOneAndDelete().Merge(opts...)
+	if err := opt.Validate(); err != nil {
+		return FindOneResult{
+			Err: fmt.Errorf("%w: %v", ErrInvalidQuery, err),
+		}
+	}
+
+	return c.findOneAndModify(ctx, filter, opt.Projection, func(txn *writeTxn, query map[string]any) (storage.KV, error) {
+		one := c.deleteOne(txn, query, opt.Sort)
+		if one.Err != nil {
+			return storage.KV{}, one.Err
+		}
+
+		id = one.DeletedID
+
+		return one.raw, nil
+	})
+}
+
+// findOneAndModify runs modify in a write transaction, retried when it conflicts with another
+// one, and returns the document modify selects, projected. An empty document is returned as
+// ErrDocumentNotFound.
+func (c *Collection) findOneAndModify(
+	ctx context.Context,
+	filter any,
+	projection map[string]any,
+	modify func(txn *writeTxn, query map[string]any) (storage.KV, error),
+) FindOneResult {
+	query, err := filterDocument(filter)
+	if err != nil {
+		return FindOneResult{
+			Err: err,
+		}
+	}
+
+	proj, err := parseOptionsProjection(projection)
+	if err != nil {
+		return FindOneResult{
+			Err: err,
+		}
+	}
+
+	enc, err := c.encryptor()
+	if err != nil {
+		return FindOneResult{
+			Err: err,
+		}
+	}
+
+	var kv storage.KV
+
+	err = c.db.runWrite(ctx, func(txn *writeTxn) error {
+		kv, err = modify(txn, query)
+
+		return err
+	})
+	if err != nil {
+		return FindOneResult{
+			Err: err,
+		}
+	}
+
+	if kv.Key == "" {
+		return FindOneResult{
+			Err: ErrDocumentNotFound,
+		}
+	}
+
+	docs, err := proj.applyRaw([]storage.KV{kv})
+	if err != nil {
+		return FindOneResult{
+			Err: fmt.Errorf("bson marshal failed: %w", err),
+		}
+	}
+
+	kv.Value = docs[0]
+
+	return FindOneResult{
+		raw:        kv,
+		encryption: enc,
+	}
+}
+
+// findForWrite finds the first document that matches a filter, in the order of sort, through a
+// write transaction: it sees the writes of the transaction, and the transaction fails to commit
+// with a conflict when another one changes what the query read first.
+func (c *Collection) findForWrite(txn *writeTxn, filter map[string]any, sort []options.SortField) FindOneResult {
+	opt := options.Find().SetLimit(1)
+	opt.Sort = sort
+
+	result := c.find(txn.ctx, txn, filter, opt)
+	if result.Err != nil {
+		return FindOneResult{
+			Err: result.Err,
+		}
+	}
+
+	if len(result.raw) == 0 {
+		return FindOneResult{
+			Err: ErrDocumentNotFound,
+		}
+	}
+
+	return FindOneResult{
+		raw:        result.raw[0],
+		IndexUsed:  result.IndexUsed,
+		encryption: result.encryption,
+	}
+}
+
+// parseOptionsProjection parses the projection of the options of a collection method, whose
+// numbers may be Go ints.
+func parseOptionsProjection(projection map[string]any) (*queryProjection, error) {
+	if len(projection) == 0 {
+		return nil, nil
+	}
+
+	spec, err := bson.ConvertToMap(projection)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid projection: %v", ErrInvalidQuery, err)
+	}
+
+	return parseProjection(spec, false)
+}
@@ -1,13 +1,45 @@
 package gopherdb
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
 // CreateIndex crea un nuevo índice en la colección.
-func (c *Collection) CreateIndex(ctx context.Context, index IndexModel) error {
+func (c *Collection) CreateIndex(ctx context.Context, index IndexModel) (err error) {
+	defer func() { c.audit(AuditCreateIndexes, nil, nil, indexNames([]IndexModel{index}), err) }()
+
+	if err := c.db.checkWritable(); err != nil {
+		return err
+	}
+
 	return c.IndexManager.CreateMany(ctx, []IndexModel{index})
 }
 
 // CreateManyIndexes crea múltiples índices en la colección.
-func (c *Collection) CreateManyIndexes(ctx context.Context, indexes []IndexModel) error {
+func (c *Collection) CreateManyIndexes(ctx context.Context, indexes []IndexModel) (err error) {
+	defer func() { c.audit(AuditCreateIndexes, nil, nil, indexNames(indexes), err) }()
+
+	if err := c.db.checkWritable(); err != nil {
+		return err
+	}
+
 	return c.IndexManager.CreateMany(ctx, indexes)
 }
+
+// indexNames returns the names of indexes, with the default name of those created without one.
+func indexNames(indexes []IndexModel) []string {
+	names := make([]string, 0, len(indexes))
+
+	for _, idx := range indexes {
+		if strings.TrimSpace(idx.Options.Name) == "" {
+			names = append(names, idx.defaultName())
+
+			continue
+		}
+
+		names = append(names, idx.Options.Name)
+	}
+
+	return names
+}
@@ -1,11 +1,20 @@
 package gopherdb
 
 import (
+	"context"
 	"fmt"
 )
 
 // InsertOne inserts a single document into the collection.
 func (c *Collection) InsertOne(doc any) InsertOneResult {
+	return c.InsertOneContext(context.Background(), doc)
+}
+
+// InsertOneContext is like InsertOne, but fails with the error of the context when it is done
+// before the insert commits.
+func (c *Collection) InsertOneContext(ctx context.Context, doc any) (result InsertOneResult) {
+	defer func() { c.audit(AuditInsert, nil, []any{result.InsertedID}, nil, result.Err) }()
+
 	_, err := validateDocumentType(doc)
 	if err != nil {
 		return InsertOneResult{
@@ -13,8 +22,20 @@ func (c *Collection) InsertOne(doc any) InsertOneResult {
 		}
 	}
 
-	txn := c.storage.BeginTx()
-	result := c.insertOne(txn, doc)
+	if err := ctx.Err(); err != nil {
+		return InsertOneResult{
+			Err: err,
+		}
+	}
+
+	txn, err := c.db.beginWrite()
+	if err != nil {
+		return InsertOneResult{
+			Err: err,
+		}
+	}
+
+	result = c.insertOne(txn, doc)
 
 	if result.Err != nil {
 		txn.Rollback()
@@ -33,6 +54,16 @@ func (c *Collection) InsertOne(doc any) InsertOneResult {
 
 // Insert inserts multiple documents into the collection.
 func (c *Collection) Insert(docs any) InsertManyResult {
+	return c.InsertContext(context.Background(), docs)
+}
+
+// InsertContext is like Insert, but stops when the context is done. The documents are inserted in
+// batches of 100, each one in its own transaction: the batch being inserted is rolled back, and
+// the result lists the IDs of the batches committed before it along with the error of the
+// context.
+func (c *Collection) InsertContext(ctx context.Context, docs any) (result InsertManyResult) {
+	defer func() { c.audit(AuditInsert, nil, result.InsertedIDs, nil, result.Err) }()
+
 	resultsVal, err := validateDocumentSliceType(docs)
 	if err != nil {
 		return InsertManyResult{
@@ -47,21 +78,42 @@ func (c *Collection) Insert(docs any) InsertManyResult {
 	for i := 0; i < totalDocs; i += batchSize {
 		end := min(i+batchSize, totalDocs)
 
-		txn := c.storage.BeginTx()
+		if err := ctx.Err(); err != nil {
+			return InsertManyResult{
+				InsertedIDs: insertedIDs,
+				Err:         err,
+			}
+		}
+
+		txn, err := c.db.beginWrite()
+		if err != nil {
+			return InsertManyResult{
+				Err: err,
+			}
+		}
 
 		for j := i; j < end; j++ {
+			if err := ctx.Err(); err != nil {
+				txn.Rollback()
+
+				return InsertManyResult{
+					InsertedIDs: insertedIDs,
+					Err:         err,
+				}
+			}
+
 			doc := resultsVal.Index(j).Interface()
-			result := c.insertOne(txn, doc)
+			one := c.insertOne(txn, doc)
 
-			if result.Err != nil {
+			if one.Err != nil {
 				txn.Rollback()
 
 				return InsertManyResult{
-					Err: result.Err,
+					Err: one.Err,
 				}
 			}
 
-			insertedIDs = append(insertedIDs, result.InsertedID)
+			insertedIDs = append(insertedIDs, one.InsertedID)
 		}
 
 		if err := txn.Commit(); err != nil {
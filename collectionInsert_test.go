@@ -0,0 +1,44 @@
+package gopherdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wirvii/gopherdb"
+)
+
+// newUniqueEmailCollection opens a collection with a unique index on email.
+func newUniqueEmailCollection(t *testing.T) *gopherdb.Collection {
+	t.Helper()
+
+	coll := newTestCollection(t, "accounts")
+
+	index := gopherdb.NewIndexModel().AddField("email", 1).SetName("email_1").SetUnique(true).Value()
+	if err := coll.CreateIndex(context.Background(), index); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+
+	return coll
+}
+
+func TestInsertManyDuplicateUniqueKey(t *testing.T) {
+	coll := newUniqueEmailCollection(t)
+
+	r := coll.Insert([]account{{ID: 1, Email: "b@example.com"}, {ID: 2, Email: "b@example.com"}})
+	if !errors.Is(r.Err, gopherdb.ErrUniqueIndexViolation) {
+		t.Fatalf("insert of a duplicate email in the same batch: got %v, want %v", r.Err, gopherdb.ErrUniqueIndexViolation)
+	}
+
+	if n, err := coll.CountDocuments(nil); err != nil || n != 0 {
+		t.Fatalf("count %d, %v, want the failed batch rolled back", n, err)
+	}
+
+	if r := coll.Insert([]account{{ID: 1, Email: "a@example.com"}, {ID: 2, Email: "b@example.com"}}); r.Err != nil {
+		t.Fatalf("insert of distinct emails: %v", r.Err)
+	}
+
+	if r := coll.InsertOne(account{ID: 3, Email: "a@example.com"}); !errors.Is(r.Err, gopherdb.ErrUniqueIndexViolation) {
+		t.Fatalf("insert of a committed email: got %v, want %v", r.Err, gopherdb.ErrUniqueIndexViolation)
+	}
+}
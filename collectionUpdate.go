@@ -1,79 +1,420 @@
 package gopherdb
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/queryengine"
+	"github.com/wirvii/gopherdb/internal/update"
 	"github.com/wirvii/gopherdb/options"
 )
 
+// updateBatchSize is the number of documents UpdateMany updates per transaction.
+const updateBatchSize = 1000
+
 // UpdateOne updates a single document by a filter.
 func (c *Collection) UpdateOne(
-	filter map[string]any,
+	filter any,
+	doc any,
+	opts ...*options.UpdateOptions,
+) UpdateOneResult {
+	return c.UpdateOneContext(context.Background(), filter, doc, opts...)
+}
+
+// UpdateOneContext is like UpdateOne, but fails with the error of the context when it is done
+// before the update commits.
+func (c *Collection) UpdateOneContext(
+	ctx context.Context,
+	filter any,
+	doc any,
+	opts ...*options.UpdateOptions,
+) (result UpdateOneResult) {
+	defer func() { c.audit(AuditUpdate, filter, []any{result.UpsertedID}, nil, result.Err) }()
+
+	query, err := filterDocument(filter)
+	if err != nil {
+		return UpdateOneResult{
+			Err: err,
+		}
+	}
+
+	if _, err := validateDocumentType(doc); err != nil {
+		return UpdateOneResult{
+			Err: err,
+		}
+	}
+
+	err = c.db.runWrite(ctx, func(txn *writeTxn) error {
+		result = c.updateOne(txn, query, nil, doc, opts...)
+
+		return result.Err
+	})
+	if err != nil {
+		return UpdateOneResult{
+			Err: err,
+		}
+	}
+
+	return result
+}
+
+// UpdateMany applies the update operators of an update document to every document that matches
+// the filter. When none matches and the upsert option is set, it inserts the equality conditions
+// of the filter with the update applied. Up to updateBatchSize documents are updated in a single
+// transaction; larger match sets are committed in batches, each one checking its documents
+// against the filter again, so an error leaves the batches committed before it applied.
+func (c *Collection) UpdateMany(
+	filter any,
 	doc any,
 	opts ...*options.UpdateOptions,
+) UpdateManyResult {
+	return c.UpdateManyContext(context.Background(), filter, doc, opts...)
+}
+
+// UpdateManyContext is like UpdateMany, but stops when the context is done: the batch being
+// updated is rolled back, and the result counts the batches committed before it along with the
+// error of the context.
+func (c *Collection) UpdateManyContext(
+	ctx context.Context,
+	filter any,
+	doc any,
+	opts ...*options.UpdateOptions,
+) (result UpdateManyResult) {
+	ids := make([]any, 0)
+
+	defer func() { c.audit(AuditUpdate, filter, append(ids, result.UpsertedIDs...), nil, result.Err) }()
+
+	query, err := filterDocument(filter)
+	if err != nil {
+		return UpdateManyResult{
+			Err: err,
+		}
+	}
+
+	docMap, err := updateManyDocument(doc)
+	if err != nil {
+		return UpdateManyResult{
+			Err: err,
+		}
+	}
+
+	opt := options.Update().Merge(opts...).SetSet(false)
+
+	enc, err := c.encryptor()
+	if err != nil {
+		return UpdateManyResult{
+			Err: err,
+		}
+	}
+
+	_, expr, err := c.compileFilter(enc, query)
+	if err != nil {
+		return UpdateManyResult{
+			Err: err,
+		}
+	}
+
+	var (
+		keys     []string
+		batch    UpdateManyResult
+		batchIDs []any
+	)
+
+	// La primera transacción busca los documentos y actualiza el primer lote
+	err = c.db.runWrite(ctx, func(txn *writeTxn) error {
+		batch, batchIDs, keys = c.updateMany(txn, query, expr, docMap, opt, updateBatchSize)
+
+		return batch.Err
+	})
+
+	for err == nil {
+		result.MatchedCount += batch.MatchedCount
+		result.ModifiedCount += batch.ModifiedCount
+		ids = append(ids, batchIDs...)
+
+		if batch.UpsertedID != nil {
+			result.UpsertedID = batch.UpsertedID
+			result.UpsertedIDs = []any{batch.UpsertedID}
+		}
+
+		if len(keys) == 0 {
+			return result
+		}
+
+		batchKeys := keys[:min(len(keys), updateBatchSize)]
+		keys = keys[len(batchKeys):]
+
+		err = c.db.runWrite(ctx, func(txn *writeTxn) error {
+			batch, batchIDs = c.updateBatch(txn, batchKeys, expr, docMap, opt)
+
+			return batch.Err
+		})
+	}
+
+	result.Err = err
+
+	return result
+}
+
+// updateMany finds the documents that match a query through a transaction and updates up to limit
+// of them, or upserts one when none matches and the upsert option is set. It returns the IDs of
+// the documents that matched in the batch and the keys of the matched documents left to update.
+func (c *Collection) updateMany(
+	txn *writeTxn,
+	query map[string]any,
+	expr queryengine.Expr,
+	docMap map[string]any,
+	opt *options.UpdateOptions,
+	limit int,
+) (UpdateManyResult, []any, []string) {
+	found := c.find(txn.ctx, txn, query)
+	if found.Err != nil {
+		return UpdateManyResult{
+			Err: found.Err,
+		}, nil, nil
+	}
+
+	if len(found.raw) == 0 {
+		if opt.Upsert == nil || !*opt.Upsert {
+			return UpdateManyResult{}, nil, nil
+		}
+
+		one := c.updateOne(txn, query, nil, docMap, opt)
+
+		return UpdateManyResult{
+			UpsertedID: one.UpsertedID,
+			Err:        one.Err,
+		}, nil, nil
+	}
+
+	keys := make([]string, 0, len(found.raw))
+	for _, kv := range found.raw {
+		keys = append(keys, kv.Key)
+	}
+
+	n := min(len(keys), limit)
+	batch, ids := c.updateBatch(txn, keys[:n], expr, docMap, opt)
+
+	return batch, ids, keys[n:]
+}
+
+// updateBatch applies an update to the documents of a batch of keys that still match the filter
+// of expr, and returns the IDs of the documents that matched.
+func (c *Collection) updateBatch(
+	txn *writeTxn,
+	keys []string,
+	expr queryengine.Expr,
+	docMap map[string]any,
+	opt *options.UpdateOptions,
+) (UpdateManyResult, []any) {
+	batch := UpdateManyResult{}
+	ids := make([]any, 0, len(keys))
+
+	for _, key := range keys {
+		if err := txn.ctx.Err(); err != nil {
+			return UpdateManyResult{
+				Err: err,
+			}, nil
+		}
+
+		found := c.findByKey(txn, key)
+		if errors.Is(found.Err, ErrDocumentNotFound) {
+			continue
+		}
+
+		if found.Err != nil {
+			return UpdateManyResult{
+				Err: found.Err,
+			}, nil
+		}
+
+		doc := found.raw.Document()
+		if !expr.Evaluate(doc) {
+			continue
+		}
+
+		one := c.updateDocument(txn, found.raw, docMap, opt)
+		if one.Err != nil {
+			return UpdateManyResult{
+				Err: one.Err,
+			}, nil
+		}
+
+		batch.MatchedCount += one.MatchedCount
+		batch.ModifiedCount += one.ModifiedCount
+		ids = append(ids, doc[consts.DocumentFieldID])
+	}
+
+	return batch, ids
+}
+
+// ReplaceOne replaces the first document that matches the filter with a document without update
+// operators. The document keeps its _id: the replacement may omit it, but not change it. When
+// none matches and the upsert option is set, the replacement is inserted with the _id of the
+// filter, if it has one.
+func (c *Collection) ReplaceOne(
+	filter any,
+	replacement any,
+	opts ...*options.ReplaceOptions,
 ) UpdateOneResult {
-	_, err := validateDocumentType(doc)
+	return c.ReplaceOneContext(context.Background(), filter, replacement, opts...)
+}
+
+// ReplaceOneContext is like ReplaceOne, but fails with the error of the context when it is done
+// before the replacement commits.
+func (c *Collection) ReplaceOneContext(
+	ctx context.Context,
+	filter any,
+	replacement any,
+	opts ...*options.ReplaceOptions,
+) (result UpdateOneResult) {
+	defer func() { c.audit(AuditUpdate, filter, []any{result.UpsertedID}, nil, result.Err) }()
+
+	query, err := filterDocument(filter)
 	if err != nil {
 		return UpdateOneResult{
 			Err: err,
 		}
 	}
 
-	txn := c.storage.BeginTx()
-	result := c.updateOne(txn, filter, doc, opts...)
+	docMap, err := c.replacementDocument(replacement)
+	if err != nil {
+		return UpdateOneResult{
+			Err: err,
+		}
+	}
 
-	if result.Err != nil {
-		txn.Rollback()
+	opt := options.Replace().Merge(opts...)
 
-		return result
+	updateOpt := options.Update().SetSet(true)
+	if opt.Upsert != nil {
+		updateOpt.SetUpsert(*opt.Upsert)
 	}
 
-	if err := txn.Commit(); err != nil {
+	updateOpt.Version = opt.Version
+
+	err = c.db.runWrite(ctx, func(txn *writeTxn) error {
+		result = c.updateOne(txn, query, nil, docMap, updateOpt)
+
+		return result.Err
+	})
+	if err != nil {
 		return UpdateOneResult{
-			Err: fmt.Errorf("commit failed: %w", err),
+			Err: err,
 		}
 	}
 
 	return result
 }
 
-// Update updates multiple documents by a filter.
+// updateManyDocument converts the update document of an update of many documents to a map,
+// failing when it has no update operators.
+func updateManyDocument(doc any) (map[string]any, error) {
+	if _, err := validateDocumentType(doc); err != nil {
+		return nil, err
+	}
+
+	docMap, err := bson.ConvertToMap(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error converting document to map: %w", err)
+	}
+
+	if !update.IsOperatorDocument(docMap) {
+		return nil, fmt.Errorf("%w: update document must have update operators", ErrInvalidUpdate)
+	}
+
+	return docMap, nil
+}
+
+// replacementDocument converts a replacement document to a map, failing when it has update
+// operators.
+func (c *Collection) replacementDocument(replacement any) (map[string]any, error) {
+	if _, err := validateDocumentType(replacement); err != nil {
+		return nil, err
+	}
+
+	docMap, err := bson.ConvertToMap(replacement)
+	if err != nil {
+		return nil, fmt.Errorf("error converting document to map: %w", err)
+	}
+
+	if update.IsOperatorDocument(docMap) {
+		return nil, fmt.Errorf("%w: replacement document cannot have update operators", ErrInvalidUpdate)
+	}
+
+	// Un _id vacío, como el de un struct sin ID, se trata como ausente igual que al insertar
+	if id, ok := docMap[consts.DocumentFieldID]; ok && c.emptyDocumentID(id) {
+		delete(docMap, consts.DocumentFieldID)
+	}
+
+	return docMap, nil
+}
+
+// Update applies each of the documents to the first document that matches the filter.
+//
+// Deprecated: Update does not update every matching document; use UpdateMany, or UpdateOne and
+// ReplaceOne.
 func (c *Collection) Update(
-	filter map[string]any,
+	filter any,
 	docs any,
 	opts ...*options.UpdateOptions,
 ) UpdateManyResult {
-	resultsVal, err := validateDocumentSliceType(docs)
+	return c.UpdateContext(context.Background(), filter, docs, opts...)
+}
+
+// UpdateContext is like Update, but fails with the error of the context when it is done before the
+// updates commit.
+//
+// Deprecated: use UpdateManyContext, or UpdateOneContext and ReplaceOneContext.
+func (c *Collection) UpdateContext(
+	ctx context.Context,
+	filter any,
+	docs any,
+	opts ...*options.UpdateOptions,
+) (result UpdateManyResult) {
+	defer func() { c.audit(AuditUpdate, filter, result.UpsertedIDs, nil, result.Err) }()
+
+	query, err := filterDocument(filter)
 	if err != nil {
 		return UpdateManyResult{
 			Err: err,
 		}
 	}
 
-	upsertedIDs := make([]any, 0)
+	resultsVal, err := validateDocumentSliceType(docs)
+	if err != nil {
+		return UpdateManyResult{
+			Err: err,
+		}
+	}
 
-	txn := c.storage.BeginTx()
+	var upsertedIDs []any
 
-	for i := range resultsVal.Len() {
-		doc := resultsVal.Index(i).Interface()
-		result := c.updateOne(txn, filter, doc, opts...)
+	err = c.db.runWrite(ctx, func(txn *writeTxn) error {
+		upsertedIDs = make([]any, 0)
 
-		if result.Err != nil {
-			txn.Rollback()
+		for i := range resultsVal.Len() {
+			doc := resultsVal.Index(i).Interface()
+			one := c.updateOne(txn, query, nil, doc, opts...)
 
-			return UpdateManyResult{
-				Err: fmt.Errorf("update one failed: %w", result.Err),
+			if one.Err != nil {
+				return fmt.Errorf("update one failed: %w", one.Err)
 			}
-		}
 
-		if result.UpsertedID != nil {
-			upsertedIDs = append(upsertedIDs, result.UpsertedID)
+			// UpsertedID también lleva el ID del documento actualizado cuando alguno coincidió
+			if one.UpsertedID != nil && one.MatchedCount == 0 {
+				upsertedIDs = append(upsertedIDs, one.UpsertedID)
+			}
 		}
-	}
 
-	if err := txn.Commit(); err != nil {
+		return nil
+	})
+	if err != nil {
 		return UpdateManyResult{
-			Err: fmt.Errorf("commit failed: %w", err),
+			Err: err,
 		}
 	}
 
@@ -0,0 +1,212 @@
+package gopherdb_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// newTestCollection opens a collection of a new memory database.
+func newTestCollection(t *testing.T, name string, opts ...*options.CollectionOptions) *gopherdb.Collection {
+	t.Helper()
+
+	db, err := gopherdb.NewMemoryDatabase("test")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	coll, err := db.Collection(name, opts...)
+	if err != nil {
+		t.Fatalf("open collection: %v", err)
+	}
+
+	return coll
+}
+
+type account struct {
+	ID    int      `bson:"_id"`
+	Email string   `bson:"email,omitempty"`
+	Name  string   `bson:"name,omitempty"`
+	Score int      `bson:"score"`
+	Tags  []string `bson:"tags,omitempty"`
+}
+
+// findAccount returns the account with the ID.
+func findAccount(t *testing.T, coll *gopherdb.Collection, id int) account {
+	t.Helper()
+
+	result := coll.FindByID(id)
+
+	var got account
+	if err := result.Unmarshal(&got); err != nil {
+		t.Fatalf("find %d: %v", id, err)
+	}
+
+	return got
+}
+
+func TestUpdateOneOperators(t *testing.T) {
+	coll := newTestCollection(t, "accounts")
+
+	if r := coll.InsertOne(account{ID: 1, Name: "ana", Score: 1, Tags: []string{"a"}}); r.Err != nil {
+		t.Fatalf("insert: %v", r.Err)
+	}
+
+	r := coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{
+		"$inc":  map[string]any{"score": 4},
+		"$set":  map[string]any{"email": "ana@example.com"},
+		"$push": map[string]any{"tags": "b"},
+	})
+	if r.Err != nil {
+		t.Fatalf("update: %v", r.Err)
+	}
+
+	if r.MatchedCount != 1 || r.ModifiedCount != 1 {
+		t.Fatalf("matched %d, modified %d, want 1 and 1", r.MatchedCount, r.ModifiedCount)
+	}
+
+	want := account{ID: 1, Email: "ana@example.com", Name: "ana", Score: 5, Tags: []string{"a", "b"}}
+	if got := findAccount(t, coll, 1); got.Email != want.Email || got.Name != want.Name ||
+		got.Score != want.Score || !slices.Equal(got.Tags, want.Tags) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	r = coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$unset": map[string]any{"name": ""}})
+	if r.Err != nil {
+		t.Fatalf("unset: %v", r.Err)
+	}
+
+	if got := findAccount(t, coll, 1); got.Name != "" || got.Score != 5 {
+		t.Fatalf("after $unset got %+v, want no name and the other fields kept", got)
+	}
+}
+
+func TestUpdateOneUpsertOperators(t *testing.T) {
+	coll := newTestCollection(t, "accounts")
+
+	r := coll.UpdateOne(
+		map[string]any{"_id": 7, "name": "eva"},
+		map[string]any{"$inc": map[string]any{"score": 2}},
+		options.Update().SetUpsert(true),
+	)
+	if r.Err != nil {
+		t.Fatalf("upsert: %v", r.Err)
+	}
+
+	if r.MatchedCount != 0 {
+		t.Fatalf("matched %d, want 0", r.MatchedCount)
+	}
+
+	if got := findAccount(t, coll, 7); got.Name != "eva" || got.Score != 2 {
+		t.Fatalf("got %+v, want the filter equalities and the applied operators", got)
+	}
+}
+
+func TestUpdateOneRemovesOldIndexEntries(t *testing.T) {
+	coll := newTestCollection(t, "accounts")
+
+	index := gopherdb.NewIndexModel().AddField("email", 1).SetName("email_1").Value()
+	if err := coll.CreateIndex(context.Background(), index); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+
+	if r := coll.InsertOne(account{ID: 1, Email: "old@example.com"}); r.Err != nil {
+		t.Fatalf("insert: %v", r.Err)
+	}
+
+	count := func(email string) int64 {
+		t.Helper()
+
+		n, err := coll.CountDocuments(map[string]any{"email": email})
+		if err != nil {
+			t.Fatalf("count %s: %v", email, err)
+		}
+
+		return n
+	}
+
+	r := coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$set": map[string]any{"email": "new@example.com"}})
+	if r.Err != nil {
+		t.Fatalf("update: %v", r.Err)
+	}
+
+	if n := count("old@example.com"); n != 0 {
+		t.Fatalf("count of the old email = %d, want 0", n)
+	}
+
+	if n := count("new@example.com"); n != 1 {
+		t.Fatalf("count of the new email = %d, want 1", n)
+	}
+
+	replaced := coll.ReplaceOne(map[string]any{"_id": 1}, account{ID: 1, Email: "other@example.com"})
+	if replaced.Err != nil {
+		t.Fatalf("replace: %v", replaced.Err)
+	}
+
+	if n := count("new@example.com"); n != 0 {
+		t.Fatalf("count of the replaced email = %d, want 0", n)
+	}
+
+	if n := count("other@example.com"); n != 1 {
+		t.Fatalf("count of the replacement email = %d, want 1", n)
+	}
+
+	// Los campos indexados son obligatorios: quitar uno falla sin tocar su entrada
+	r = coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$unset": map[string]any{"email": ""}})
+	if !errors.Is(r.Err, gopherdb.ErrMissingFieldForIndex) {
+		t.Fatalf("unset of an indexed field: got %v, want %v", r.Err, gopherdb.ErrMissingFieldForIndex)
+	}
+
+	if n := count("other@example.com"); n != 1 {
+		t.Fatalf("count after the failed unset = %d, want 1", n)
+	}
+}
+
+func TestUpdateDuplicateUniqueKey(t *testing.T) {
+	coll := newUniqueEmailCollection(t)
+
+	if r := coll.Insert([]account{{ID: 1, Email: "a@example.com"}, {ID: 2, Email: "b@example.com"}}); r.Err != nil {
+		t.Fatalf("insert: %v", r.Err)
+	}
+
+	r := coll.UpdateOne(map[string]any{"_id": 2}, map[string]any{"$set": map[string]any{"email": "a@example.com"}})
+	if !errors.Is(r.Err, gopherdb.ErrUniqueIndexViolation) {
+		t.Fatalf("$set of a taken email: got %v, want %v", r.Err, gopherdb.ErrUniqueIndexViolation)
+	}
+
+	replaced := coll.ReplaceOne(map[string]any{"_id": 2}, account{ID: 2, Email: "a@example.com"})
+	if !errors.Is(replaced.Err, gopherdb.ErrUniqueIndexViolation) {
+		t.Fatalf("replace with a taken email: got %v, want %v", replaced.Err, gopherdb.ErrUniqueIndexViolation)
+	}
+
+	many := coll.UpdateMany(map[string]any{}, map[string]any{"$set": map[string]any{"email": "z@example.com"}})
+	if !errors.Is(many.Err, gopherdb.ErrUniqueIndexViolation) {
+		t.Fatalf("$set of one email on every document: got %v, want %v", many.Err, gopherdb.ErrUniqueIndexViolation)
+	}
+
+	if n, err := coll.CountDocuments(map[string]any{"email": "a@example.com"}); err != nil || n != 1 {
+		t.Fatalf("count of the taken email %d, %v, want 1", n, err)
+	}
+
+	// Un documento que conserva su email, o lo cambia por uno libre, no choca con su propia entrada
+	r = coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$set": map[string]any{"name": "ana"}})
+	if r.Err != nil || r.ModifiedCount != 1 {
+		t.Fatalf("update keeping the email: modified %d, err %v", r.ModifiedCount, r.Err)
+	}
+
+	replaced = coll.ReplaceOne(map[string]any{"_id": 2}, account{ID: 2, Email: "b@example.com", Name: "eva"})
+	if replaced.Err != nil {
+		t.Fatalf("replace keeping the email: %v", replaced.Err)
+	}
+
+	r = coll.UpdateOne(map[string]any{"_id": 2}, map[string]any{"$set": map[string]any{"email": "c@example.com"}})
+	if r.Err != nil {
+		t.Fatalf("$set of a free email: %v", r.Err)
+	}
+}
@@ -1,40 +1,498 @@
 package gopherdb
 
-import "github.com/wirvii/gopherdb/internal/storage"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+)
 
 // Database es una base de datos.
 type Database struct {
 	name    string
+	mu      sync.Mutex
 	colls   []*Collection
 	storage storage.Storage
+	changes *changeHub
+	audit   *auditLog
+	logger  options.Logger
+	// seqMu serializes the allocation of the IDs of the collections with a sequence strategy.
+	seqMu sync.Mutex
+	// sequences holds the highest ID allocated by the sequence of each collection.
+	sequences map[string]int64
+	// follower is set while the database is a read-only follower.
+	follower atomic.Bool
+	// following is the follower replicating a primary into the database, if any.
+	following atomic.Pointer[Follower]
 }
 
 // NewDatabase crea una nueva instancia de Database.
-func NewDatabase(name, path string) (*Database, error) {
-	engine, err := storage.NewStorage(path)
+func NewDatabase(name, path string, opts ...*options.DatabaseOptions) (*Database, error) {
+	opt := options.Database()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	engine, err := openStorage(path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := newDatabase(name, engine, opt)
+	if err != nil {
+		engine.Close()
+
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// newDatabase creates a database handle on an open storage engine. Several handles may share the
+// same engine, each one scoped to its own database name.
+func newDatabase(name string, engine storage.Storage, opt *options.DatabaseOptions) (*Database, error) {
+	changes, err := newChangeHub(engine, name, opt)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Database{
+	db := &Database{
 		name:    name,
 		storage: engine,
-	}, nil
+		changes: changes,
+		logger:  opt.Logger,
+	}
+
+	if db.logger == nil {
+		db.logger = NewStdLogger()
+	}
+
+	if opt.Audit != nil {
+		db.audit, err = newAuditLog(db, opt.Audit, opt.Logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	st, err := db.loadReplicationState()
+	if err != nil {
+		return nil, err
+	}
+
+	db.follower.Store(st.Role == replicationRoleFollower)
+
+	if opt.ReadOnly == nil || !*opt.ReadOnly {
+		if err := db.migrateDocumentKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// NewMemoryDatabase crea una nueva instancia de Database cuyos datos viven solo en memoria.
+func NewMemoryDatabase(name string, opts ...*options.DatabaseOptions) (*Database, error) {
+	opts = append(opts, options.Database().SetEngine(options.EngineMemory))
+
+	return NewDatabase(name, "", opts...)
+}
+
+// openStorage opens the storage engine selected by the options.
+func openStorage(path string, opt *options.DatabaseOptions) (storage.Storage, error) {
+	engine := options.EngineBadger
+	if opt.Engine != nil {
+		engine = *opt.Engine
+	}
+
+	factory, ok := storage.Lookup(engine)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStorageEngine, engine)
+	}
+
+	return factory(storage.Config{
+		Path:    path,
+		Options: engineOptions(opt),
+	})
+}
+
+// engineOptions returns the engine-specific options, including the typed storage options.
+func engineOptions(opt *options.DatabaseOptions) map[string]any {
+	out := make(map[string]any, len(opt.EngineOptions))
+	maps.Copy(out, opt.EngineOptions)
+
+	if opt.InMemory != nil {
+		out[storage.OptionInMemory] = *opt.InMemory
+	}
+
+	if opt.SyncWrites != nil {
+		out[storage.OptionSyncWrites] = *opt.SyncWrites
+	}
+
+	if opt.Compression != nil {
+		out[storage.OptionCompression] = string(*opt.Compression)
+	}
+
+	if opt.BlockCacheSize != nil {
+		out[storage.OptionBlockCacheSize] = *opt.BlockCacheSize
+	}
+
+	if opt.IndexCacheSize != nil {
+		out[storage.OptionIndexCacheSize] = *opt.IndexCacheSize
+	}
+
+	if opt.ValueThreshold != nil {
+		out[storage.OptionValueThreshold] = *opt.ValueThreshold
+	}
+
+	if opt.ValueLogFileSize != nil {
+		out[storage.OptionValueLogFileSize] = *opt.ValueLogFileSize
+	}
+
+	if opt.MemTableSize != nil {
+		out[storage.OptionMemTableSize] = *opt.MemTableSize
+	}
+
+	if opt.ReadOnly != nil {
+		out[storage.OptionReadOnly] = *opt.ReadOnly
+	}
+
+	if opt.EncryptionKey != nil {
+		out[storage.OptionEncryptionKey] = opt.EncryptionKey
+	}
+
+	if opt.KeyRotation != nil {
+		out[storage.OptionEncryptionKeyRotation] = *opt.KeyRotation
+	}
+
+	if opt.Logger != nil {
+		out[storage.OptionLogger] = opt.Logger
+	}
+
+	if opt.NumVersionsToKeep != nil {
+		out[storage.OptionNumVersionsToKeep] = *opt.NumVersionsToKeep
+	}
+
+	return out
 }
 
-// Collection devuelve una instancia de Collection para la base de datos
-func (db *Database) Collection(name string) (*Collection, error) {
-	col, err := newCollection(db.storage, db.name, name)
+// Collection devuelve una instancia de Collection para la base de datos. Las opciones, si se
+// indican, establecen la validación de los documentos de la colección (ver SetValidation), la
+// estrategia de sus _id (ver SetIDStrategy) y si tienen versiones (ver SetVersioned).
+func (db *Database) Collection(name string, opts ...*options.CollectionOptions) (*Collection, error) {
+	col, err := newCollection(db, name)
 	if err != nil {
 		return nil, err
 	}
 
+	opt := options.Collection().Merge(opts...)
+
+	if opt.IDStrategy != nil {
+		if err := col.SetIDStrategy(*opt.IDStrategy); err != nil {
+			return nil, err
+		}
+	}
+
+	if opt.Validator != nil || opt.ValidationLevel != nil || opt.ValidationAction != nil {
+		if err := col.SetValidation(opt); err != nil {
+			return nil, err
+		}
+	}
+
+	if opt.Versioned != nil {
+		if err := col.SetVersioned(*opt.Versioned); err != nil {
+			return nil, err
+		}
+	}
+
+	db.mu.Lock()
 	db.colls = append(db.colls, col)
+	db.mu.Unlock()
 
 	return col, nil
 }
 
 // Close cierra la base de datos
 func (db *Database) Close() error {
+	if f := db.following.Load(); f != nil {
+		f.Close()
+	}
+
+	db.changes.closeAll()
+	db.audit.close()
+
 	return db.storage.Close()
 }
+
+// ListDatabases returns the names of the databases stored in the data directory at path. The
+// directory must not be open by another handle.
+func ListDatabases(path string, opts ...*options.DatabaseOptions) ([]string, error) {
+	opt := options.Database()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	engine, err := openStorage(path, opt)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.Close()
+
+	return databaseNames(engine)
+}
+
+// ListDatabases returns the names of the databases that share the storage of the database,
+// including its own once it has collections.
+func (db *Database) ListDatabases() ([]string, error) {
+	return databaseNames(db.storage)
+}
+
+// ListCollections returns the names of the collections of the database, sorted.
+func (db *Database) ListCollections() ([]string, error) {
+	return db.ListCollectionsContext(context.Background())
+}
+
+// ListCollectionsContext is like ListCollections, but fails with the error of the context when it
+// is done.
+func (db *Database) ListCollectionsContext(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	names, err := db.collectionNames()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// DropCollection deletes the documents, indexes and metadata of a collection. It returns
+// ErrCollectionNotFound when the collection does not exist. The drop is recorded in the change
+// history and in the oplog.
+func (db *Database) DropCollection(name string) error {
+	return db.DropCollectionContext(context.Background(), name)
+}
+
+// DropCollectionContext is like DropCollection. See Collection.DropContext.
+func (db *Database) DropCollectionContext(ctx context.Context, name string) error {
+	coll, err := newCollection(db, name)
+	if err != nil {
+		return err
+	}
+
+	return coll.DropContext(ctx)
+}
+
+// RenameCollection renames a collection of the database. See Collection.Rename.
+func (db *Database) RenameCollection(from, to string, opts ...*options.RenameCollectionOptions) error {
+	return db.RenameCollectionContext(context.Background(), from, to, opts...)
+}
+
+// RenameCollectionContext is like RenameCollection. See Collection.RenameContext.
+func (db *Database) RenameCollectionContext(
+	ctx context.Context,
+	from, to string,
+	opts ...*options.RenameCollectionOptions,
+) error {
+	coll, err := newCollection(db, from)
+	if err != nil {
+		return err
+	}
+
+	return coll.RenameContext(ctx, to, opts...)
+}
+
+// Drop drops every collection of the database and its metadata. The change history and the oplog
+// of the database are kept, and record the drop of each collection.
+func (db *Database) Drop() error {
+	return db.DropContext(context.Background())
+}
+
+// DropContext is like Drop, but stops when the context is done, returning its error. The
+// collections dropped before it are not restored.
+func (db *Database) DropContext(ctx context.Context) error {
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
+
+	names, err := db.collectionNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := db.DropCollectionContext(ctx, name); err != nil && !errors.Is(err, ErrCollectionNotFound) {
+			return err
+		}
+	}
+
+	if err := db.storage.DropPrefix(fmt.Sprintf(consts.CollectionKeyStringFormat, db.name, "")); err != nil {
+		return err
+	}
+
+	return db.storage.Delete(fmt.Sprintf(consts.MetadataDatabaseKeyStringFormat, db.name))
+}
+
+// dropCollection deletes the documents and index entries of a collection with a prefix drop, and
+// then its metadata in a write transaction that records the drop. It reports whether the
+// collection existed.
+func (db *Database) dropCollection(name string) (bool, error) {
+	if err := db.checkWritable(); err != nil {
+		return false, err
+	}
+
+	existed, err := db.dropCollectionKeys(name)
+	if err != nil || !existed {
+		return existed, err
+	}
+
+	txn := db.newWriteTxn()
+
+	if err := db.deleteCollectionMetadata(txn, name); err != nil {
+		txn.Rollback()
+
+		return false, err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return false, err
+	}
+
+	db.resetCollections(name)
+
+	return true, nil
+}
+
+// dropCollectionKeys deletes the documents and index entries of a collection with a prefix drop.
+// It reports whether the collection has metadata.
+func (db *Database) dropCollectionKeys(name string) (bool, error) {
+	_, err := db.storage.Get(fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, db.name, name))
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return false, err
+	}
+
+	existed := err == nil
+
+	if err := db.storage.DropPrefix(fmt.Sprintf(consts.CollectionKeyStringFormat, db.name, name) + "/"); err != nil {
+		return false, err
+	}
+
+	return existed, nil
+}
+
+// deleteCollectionMetadata deletes the metadata of a dropped collection in a write transaction and
+// records the drop.
+func (db *Database) deleteCollectionMetadata(txn *writeTxn, name string) error {
+	if err := txn.Delete(fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, db.name, name)); err != nil {
+		return err
+	}
+
+	txn.record(ChangeEvent{
+		OperationType: OperationDrop,
+		Namespace:     Namespace{Database: db.name, Collection: name},
+	})
+
+	return nil
+}
+
+// resetCollections reloads the metadata of the open handles of the named collections after their
+// keys were dropped or moved.
+func (db *Database) resetCollections(names ...string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, c := range db.colls {
+		if slices.Contains(names, c.collname) {
+			c.IndexManager.loadMetadata()
+		}
+	}
+
+	db.seqMu.Lock()
+	defer db.seqMu.Unlock()
+
+	for _, name := range names {
+		delete(db.sequences, name)
+	}
+}
+
+// collectionNames returns the names of the collections of the database.
+func (db *Database) collectionNames() ([]string, error) {
+	prefix := fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, db.name, "")
+
+	keys, err := db.storage.ScanKeys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, prefix)
+		if name != "" && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// saveDatabaseMetadata persists the metadata of a database with put the first time one of its
+// collections is persisted.
+func saveDatabaseMetadata(engine storage.Storage, put func(key string, value []byte) error, dbname string) error {
+	key := fmt.Sprintf(consts.MetadataDatabaseKeyStringFormat, dbname)
+
+	if _, err := engine.Get(key); err == nil {
+		return nil
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		return err
+	}
+
+	data, err := bson.Marshal(DatabaseMetadata{Name: dbname, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	return put(key, data)
+}
+
+// databaseNames returns the names of the databases of a storage engine: the ones with persisted
+// metadata and, for data written before the metadata was persisted, the ones with collections.
+func databaseNames(engine storage.Storage) ([]string, error) {
+	prefix := fmt.Sprintf(consts.MetadataDatabaseKeyStringFormat, "")
+
+	keys, err := engine.ScanKeys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+
+	for _, key := range keys {
+		name, rest, nested := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+		if (!nested || strings.HasPrefix(rest, "colls/")) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
@@ -0,0 +1,371 @@
+package gopherdb
+
+import (
+	"cmp"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/internal/ulid"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// documentIDTypePrefix starts the keys of the document IDs that are not plain strings. Plain
+// strings are stored as they are, so they cannot start with it.
+const documentIDTypePrefix = "$"
+
+// documentKeyFormat is the format of the document keys, stored in the metadata of the collections.
+// Before format 1 the keys ended with the ID formatted with %v, which lost its type.
+const documentKeyFormat = 1
+
+// SetIDStrategy sets how the IDs of the documents inserted without an _id are generated: one of
+// the options.IDStrategy constants. The strategy is persisted with the collection; documents
+// inserted before keep their IDs.
+func (c *Collection) SetIDStrategy(strategy string) error {
+	switch strategy {
+	case options.IDStrategyUUID, options.IDStrategyUUIDv7, options.IDStrategyObjectID, options.IDStrategyULID,
+		options.IDStrategySequence, options.IDStrategyProvided:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidIDStrategy, strategy)
+	}
+
+	if err := c.db.checkWritable(); err != nil {
+		return err
+	}
+
+	return c.IndexManager.updateMetadata(func(meta *CollectionMetadata) error {
+		meta.IDStrategy = strategy
+
+		return nil
+	})
+}
+
+// IDStrategy returns the strategy that generates the IDs of the documents of the collection.
+func (c *Collection) IDStrategy() (string, error) {
+	meta, err := c.IndexManager.loadMetadata()
+	if err != nil {
+		return "", err
+	}
+
+	return cmp.Or(meta.IDStrategy, options.IDStrategyUUID), nil
+}
+
+// ensureDocumentID returns a copy of the document with an _id, generated by the strategy of the
+// collection when the document has none or an empty one (see emptyDocumentID), and the ID.
+// Integer IDs given by the caller advance the sequence of the collection past them.
+func (c *Collection) ensureDocumentID(txn *writeTxn, doc map[string]any) (map[string]any, any, error) {
+	copyDoc := make(map[string]any, len(doc)+1)
+	maps.Copy(copyDoc, doc)
+
+	id, ok := copyDoc[consts.DocumentFieldID]
+	if ok && !c.emptyDocumentID(id) {
+		if n, ok := integerDocumentID(id); ok {
+			txn.sequence(c.collname, n)
+		}
+
+		return copyDoc, id, nil
+	}
+
+	id, err := c.newDocumentID(txn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	copyDoc[consts.DocumentFieldID] = id
+
+	return copyDoc, id, nil
+}
+
+// emptyDocumentID reports whether an ID is the zero value of the IDs of the strategy of the
+// collection, as the _id field of a struct left unset: "" for every strategy, 0 for
+// IDStrategySequence and the NilObjectID for IDStrategyObjectID. Empty IDs are treated as missing,
+// so TypedCollection does not need omitempty on the _id field.
+func (c *Collection) emptyDocumentID(id any) bool {
+	if s, ok := id.(string); ok {
+		return s == ""
+	}
+
+	switch c.IndexManager.current().IDStrategy {
+	case options.IDStrategySequence:
+		n, ok := integerDocumentID(id)
+
+		return ok && n == 0
+	case options.IDStrategyObjectID:
+		oid, ok := id.(primitive.ObjectID)
+
+		return ok && oid.IsZero()
+	default:
+		return false
+	}
+}
+
+// newDocumentID generates an ID with the strategy of the collection.
+func (c *Collection) newDocumentID(txn *writeTxn) (any, error) {
+	switch c.IndexManager.current().IDStrategy {
+	case options.IDStrategyUUIDv7:
+		id, err := uuid.NewV7()
+		if err != nil {
+			return nil, err
+		}
+
+		return id.String(), nil
+	case options.IDStrategyObjectID:
+		return primitive.NewObjectID(), nil
+	case options.IDStrategyULID:
+		return ulid.New(), nil
+	case options.IDStrategySequence:
+		n, err := c.db.nextSequence(c.collname)
+		if err != nil {
+			return nil, err
+		}
+
+		txn.sequence(c.collname, n)
+
+		return n, nil
+	case options.IDStrategyProvided:
+		return nil, ErrDocumentIDNotFound
+	default:
+		return uuid.NewString(), nil
+	}
+}
+
+// nextSequence allocates the next ID of the sequence of a collection: one more than the highest
+// ID stored in its metadata or allocated by a transaction not committed yet. IDs allocated by
+// transactions that roll back are not reused.
+func (db *Database) nextSequence(collname string) (int64, error) {
+	db.seqMu.Lock()
+	defer db.seqMu.Unlock()
+
+	meta := CollectionMetadata{}
+
+	data, err := db.storage.Get(fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, db.name, collname))
+	if err == nil {
+		if err := bson.Unmarshal(data, &meta); err != nil {
+			return 0, err
+		}
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, err
+	}
+
+	if db.sequences == nil {
+		db.sequences = make(map[string]int64)
+	}
+
+	n := max(meta.Sequence, db.sequences[collname]) + 1
+	db.sequences[collname] = n
+
+	return n, nil
+}
+
+// integerDocumentID returns the value of an ID that is an integer, which the sequence of its
+// collection must not generate again.
+func integerDocumentID(id any) (int64, bool) {
+	switch v := id.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// encodeDocumentID encodes a document ID as the last segment of the key of the document, keeping
+// its type: the IDs 5 and "5" are different documents. Plain strings are encoded as they are;
+// other IDs are prefixed by their type. Numbers are equal when their values are, as in MongoDB,
+// so 5, int64(5) and 5.0 are the same ID.
+func encodeDocumentID(id any) string {
+	switch v := id.(type) {
+	case string:
+		if v != "" && !strings.HasPrefix(v, documentIDTypePrefix) && !strings.Contains(v, "/") {
+			return v
+		}
+
+		return documentIDTypePrefix + "s:" + url.PathEscape(v)
+	case int, int8, int16, int32, int64:
+		return documentIDTypePrefix + "n:" + fmt.Sprintf("%d", v)
+	case uint, uint8, uint16, uint32, uint64:
+		return documentIDTypePrefix + "n:" + fmt.Sprintf("%d", v)
+	case float32:
+		return encodeDocumentID(float64(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return encodeDocumentID(int64(v))
+		}
+
+		return documentIDTypePrefix + "f:" + strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return documentIDTypePrefix + "b:" + strconv.FormatBool(v)
+	case primitive.ObjectID:
+		return documentIDTypePrefix + "o:" + v.Hex()
+	case time.Time:
+		return encodeDocumentID(primitive.NewDateTimeFromTime(v))
+	case primitive.DateTime:
+		return documentIDTypePrefix + "t:" + strconv.FormatInt(int64(v), 10)
+	}
+
+	// Cualquier otro valor (documentos, arrays, binarios) se codifica por su BSON
+	data, err := bson.Marshal(primitive.D{{Key: consts.DocumentFieldID, Value: id}})
+	if err != nil {
+		return documentIDTypePrefix + "v:" + url.PathEscape(fmt.Sprintf("%v", id))
+	}
+
+	return documentIDTypePrefix + "x:" + hex.EncodeToString(data)
+}
+
+// migrateDocumentKeys moves the documents of the collections with an older key format to the keys
+// of encodeDocumentID. See migrateCollectionKeys.
+func (db *Database) migrateDocumentKeys() error {
+	names, err := db.collectionNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := db.migrateCollectionKeys(name); err != nil {
+			return fmt.Errorf("migrate document keys of %s.%s: %w", db.name, name, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateCollectionKeys moves the documents of a collection whose key ends with their ID formatted
+// with %v, as written before keys kept the type of the ID, to the key of encodeDocumentID, along
+// with their index entries. Documents are moved in batches of updateBatchSize, and the key format
+// is stored with the last one, so an interrupted migration runs again on the next open.
+func (db *Database) migrateCollectionKeys(name string) error {
+	m := newIndexManager(db.storage, db.name, name)
+
+	meta, err := m.loadMetadata()
+	if err != nil {
+		return err
+	}
+
+	if meta.KeyFormat >= documentKeyFormat {
+		return nil
+	}
+
+	prefix := m.buildDocumentsKey()
+
+	kvs, err := db.storage.Scan(prefix)
+	if err != nil {
+		return err
+	}
+
+	txn := db.storage.BeginTx()
+	moved := 0
+
+	for _, kv := range kvs {
+		var doc map[string]any
+		if err := bson.Unmarshal(kv.Value, &doc); err != nil {
+			txn.Rollback()
+
+			return err
+		}
+
+		legacyID := strings.TrimPrefix(kv.Key, prefix)
+		docID := encodeDocumentID(doc[consts.DocumentFieldID])
+
+		if docID == legacyID {
+			continue
+		}
+
+		if err := m.moveDocumentKeys(txn, meta.Indexes, doc, kv, legacyID, docID); err != nil {
+			txn.Rollback()
+
+			return err
+		}
+
+		if moved++; moved%updateBatchSize == 0 {
+			if err := txn.Commit(); err != nil {
+				return err
+			}
+
+			txn = db.storage.BeginTx()
+		}
+	}
+
+	if err := m.saveKeyFormat(txn); err != nil {
+		txn.Rollback()
+
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// saveKeyFormat stores the current key format in the metadata of the collection with a
+// transaction, keeping the rest of the stored metadata.
+func (m *IndexManager) saveKeyFormat(txn storage.Transaction) error {
+	data, err := txn.Get(m.buildMetadataKey())
+	if err != nil {
+		return err
+	}
+
+	var meta CollectionMetadata
+	if err := bson.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+
+	meta.KeyFormat = documentKeyFormat
+
+	data, err = bson.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return txn.Put(m.buildMetadataKey(), data)
+}
+
+// moveDocumentKeys moves a document and its entries in the indexes from the key of its legacy ID
+// to the key of its encoded ID.
+func (m *IndexManager) moveDocumentKeys(
+	txn storage.Transaction,
+	indexes []IndexModel,
+	doc map[string]any,
+	kv storage.KV,
+	legacyID, docID string,
+) error {
+	if err := txn.Put(m.buildDocumentKey(docID), kv.Value); err != nil {
+		return err
+	}
+
+	if err := txn.Delete(kv.Key); err != nil {
+		return err
+	}
+
+	for _, idx := range indexes {
+		idxKey, err := m.buildDocumentIndexKey(idx, doc, false)
+		if errors.Is(err, ErrMissingFieldForIndex) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Delete(strings.TrimSuffix(idxKey, docID) + legacyID); err != nil {
+			return err
+		}
+
+		if err := txn.Put(idxKey, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package gopherdb
+
+import "github.com/wirvii/gopherdb/internal/storage"
+
+// ErrEncryptionKeyMismatch is returned when a database cannot be decrypted with the given key.
+var ErrEncryptionKeyMismatch = storage.ErrEncryptionKeyMismatch
+
+// RotateEncryptionKey replaces the master key of the encrypted database stored at path.
+//
+// Documents are encrypted with data keys that are themselves encrypted with the master key, so only
+// the data keys are re-encrypted and the rotation does not rewrite the database. An empty oldKey
+// starts encrypting a plaintext database; data written before stays in plaintext until it is
+// compacted. The database must be closed while its key is rotated.
+func RotateEncryptionKey(path string, oldKey, newKey []byte) error {
+	return storage.RotateBadgerEncryptionKey(path, oldKey, newKey)
+}
+
+// VerifyEncryption reads every key and value of the database stored at path, checking that all of
+// them can be decrypted with the given key. It returns the number of keys verified.
+func VerifyEncryption(path string, key []byte) (int64, error) {
+	return storage.VerifyBadgerEncryption(path, key)
+}
@@ -0,0 +1,94 @@
+package gopherdb
+
+import (
+	"os"
+
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/internal/storage/storagetest"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// StorageEngine is the ordered key-value store that backs a Database.
+type StorageEngine = storage.Storage
+
+// Transaction is a snapshot-isolated transaction of a StorageEngine.
+type Transaction = storage.Transaction
+
+// KV is a key-value pair returned by a StorageEngine scan.
+type KV = storage.KV
+
+// EngineConfig is the configuration passed to an EngineFactory.
+type EngineConfig = storage.Config
+
+// EngineFactory creates a StorageEngine from its configuration.
+type EngineFactory = storage.Factory
+
+// EngineCheckResult is the outcome of a single storage engine conformance case.
+type EngineCheckResult = storagetest.Result
+
+var (
+	// ErrEngineKeyNotFound must be returned by engines when a key does not exist.
+	ErrEngineKeyNotFound = storage.ErrKeyNotFound
+	// ErrEngineConflict must be returned by engines when a transaction conflicts on commit.
+	ErrEngineConflict = storage.ErrConflict
+	// ErrEngineTransactionDiscarded must be returned by engines when a finished transaction is used.
+	ErrEngineTransactionDiscarded = storage.ErrTransactionDiscarded
+	// ErrEngineEmptyKey must be returned by engines when an empty key is written.
+	ErrEngineEmptyKey = storage.ErrEmptyKey
+)
+
+// RegisterEngine makes a storage engine available to NewDatabase under the given name.
+// It panics if the factory is nil or the name is already registered.
+func RegisterEngine(name string, factory EngineFactory) {
+	storage.Register(name, factory)
+}
+
+// Engines returns the sorted names of the registered storage engines.
+func Engines() []string {
+	return storage.Engines()
+}
+
+// tempEngine is a storage engine whose data directory is removed when it is closed.
+type tempEngine struct {
+	storage.Storage
+	dir string
+}
+
+// Close closes the engine and removes its data directory.
+func (e *tempEngine) Close() error {
+	err := e.Storage.Close()
+	if rerr := os.RemoveAll(e.dir); rerr != nil && err == nil {
+		err = rerr
+	}
+
+	return err
+}
+
+// CheckEngine runs the storage conformance suite against the named engine.
+// Every case runs on a fresh engine opened in a temporary directory with the given options.
+func CheckEngine(name string, opts ...*options.DatabaseOptions) ([]EngineCheckResult, error) {
+	opt := options.Database().SetEngine(name)
+	opt = opt.Merge(opts...)
+
+	if _, ok := storage.Lookup(name); !ok {
+		return nil, ErrUnknownStorageEngine
+	}
+
+	results := storagetest.Run(func() (storage.Storage, error) {
+		dir, err := os.MkdirTemp("", "gopherdb-check-")
+		if err != nil {
+			return nil, err
+		}
+
+		engine, err := openStorage(dir, opt)
+		if err != nil {
+			os.RemoveAll(dir)
+
+			return nil, err
+		}
+
+		return &tempEngine{Storage: engine, dir: dir}, nil
+	})
+
+	return results, nil
+}
@@ -1,6 +1,12 @@
 package gopherdb
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/wirvii/gopherdb/internal/jsonschema"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/internal/update"
+)
 
 var (
 	// ErrMissingFieldForIndex is returned when a field is missing for an index.
@@ -43,4 +49,82 @@ var (
 	ErrDocumentIDNotFound = errors.New("document ID not found")
 	// ErrDocumentIDNoEditable is returned when a document ID is not editable.
 	ErrDocumentIDNoEditable = errors.New("document ID no editable")
+	// ErrUnknownStorageEngine is returned when a storage engine is not known.
+	ErrUnknownStorageEngine = errors.New("unknown storage engine")
+	// ErrInvalidEncryptionSchema is returned when a field encryption schema is invalid.
+	ErrInvalidEncryptionSchema = errors.New("invalid encryption schema")
+	// ErrEncryptionKeyNotFound is returned when a key provider does not know a key.
+	ErrEncryptionKeyNotFound = errors.New("encryption key not found")
+	// ErrKeyProviderRequired is returned when a collection with encrypted fields has no key provider.
+	ErrKeyProviderRequired = errors.New("key provider required for encrypted fields")
+	// ErrFieldNotQueryable is returned when a filter uses an encrypted field in an unsupported way.
+	ErrFieldNotQueryable = errors.New("encrypted field is not queryable")
+	// ErrInvalidResumeToken is returned when a change stream resume token is malformed.
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+	// ErrResumeTokenNotFound is returned when a change stream cannot resume from a token.
+	ErrResumeTokenNotFound = errors.New("resume token not found in change history")
+	// ErrChangeStreamLagged is returned when a change stream falls behind and cannot catch up.
+	ErrChangeStreamLagged = errors.New("change stream fell behind and change history is disabled")
+	// ErrUnsupportedPipelineStage is returned when a change stream pipeline has an unsupported stage.
+	ErrUnsupportedPipelineStage = errors.New("unsupported pipeline stage")
+	// ErrOplogDisabled is returned when the oplog is read on a database opened without it.
+	ErrOplogDisabled = errors.New("oplog is disabled")
+	// ErrNotPrimary is returned when a write is made on a follower.
+	ErrNotPrimary = errors.New("database is a read-only follower")
+	// ErrReplicationProtocol is returned when a replication peer sends an unexpected message.
+	ErrReplicationProtocol = errors.New("replication protocol error")
+	// ErrReplicaDiverged is returned when a follower cannot apply an oplog entry because its data no
+	// longer matches the primary. The follower copies a new snapshot when it reconnects.
+	ErrReplicaDiverged = errors.New("follower diverged from the primary")
+	// ErrAlreadyFollowing is returned when a database already follows a primary.
+	ErrAlreadyFollowing = errors.New("database already follows a primary")
+	// ErrInvalidNamespace is returned when a database or collection name is not valid.
+	ErrInvalidNamespace = errors.New("invalid namespace")
+	// ErrInvalidIndexSpec is returned when an index specification is not valid.
+	ErrInvalidIndexSpec = errors.New("invalid index specification")
+	// ErrInvalidQuery is returned when the filter, sort or projection of a query is not valid.
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrUserNotFound is returned when a user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when a user is created with the name of an existing one.
+	ErrUserExists = errors.New("user already exists")
+	// ErrInvalidUser is returned when a user has no name or password.
+	ErrInvalidUser = errors.New("invalid user")
+	// ErrInvalidRole is returned when a role is unknown or granted on an invalid scope.
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrTokenNotFound is returned when an API token does not exist.
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrAuthenticationFailed is returned when credentials do not match a user.
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	// ErrAuthenticationRequired is returned when a server with authentication gets an anonymous request.
+	ErrAuthenticationRequired = errors.New("authentication required")
+	// ErrUnauthorized is returned when a user has no role that allows a request.
+	ErrUnauthorized = errors.New("not authorized")
+	// ErrCollectionNotFound is returned when a collection does not exist.
+	ErrCollectionNotFound = errors.New("collection not found")
+	// ErrCollectionExists is returned when a collection is renamed to one that already exists.
+	ErrCollectionExists = errors.New("collection already exists")
+	// ErrBulkWrite is returned when operations of a bulk write fail.
+	ErrBulkWrite = errors.New("bulk write failed")
+	// ErrDocumentValidation is returned when a document fails the validator of its collection.
+	ErrDocumentValidation = errors.New("document failed validation")
+	// ErrInvalidIDStrategy is returned when a collection is given an unknown _id strategy.
+	ErrInvalidIDStrategy = errors.New("invalid id strategy")
+	// ErrInvalidSchema is returned when a collection validator is not a valid $jsonSchema.
+	ErrInvalidSchema = jsonschema.ErrInvalidSchema
+	// ErrInvalidUpdate is returned when an update document has invalid update operators.
+	ErrInvalidUpdate = update.ErrInvalidUpdate
+	// ErrVersionConflict is returned when a document to update does not have the expected version.
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrHistoryUnsupported is returned when past commits are read on a storage engine that does
+	// not keep past versions.
+	ErrHistoryUnsupported = errors.New("storage engine does not keep history")
+	// ErrInvalidTimestamp is returned when a commit timestamp is after the latest commit.
+	ErrInvalidTimestamp = errors.New("invalid commit timestamp")
+	// ErrSnapshotTooOld is returned when reading a past commit whose versions the storage engine
+	// may no longer keep.
+	ErrSnapshotTooOld = storage.ErrSnapshotTooOld
+	// ErrMaxTimeExceeded is returned, along with context.DeadlineExceeded, when a query runs longer
+	// than its MaxTime option.
+	ErrMaxTimeExceeded = errors.New("operation exceeded time limit")
 )
@@ -0,0 +1,415 @@
+package gopherdb
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"sync"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/fieldcrypt"
+	"github.com/wirvii/gopherdb/internal/queryengine"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EncryptionAlgorithm is the algorithm used to encrypt a field.
+type EncryptionAlgorithm string
+
+const (
+	// EncryptionDeterministic encrypts equal values to equal ciphertexts, so the field can be
+	// queried by equality and indexed.
+	EncryptionDeterministic EncryptionAlgorithm = "deterministic"
+	// EncryptionRandom encrypts every value with a random nonce. The field cannot be queried.
+	EncryptionRandom EncryptionAlgorithm = "random"
+)
+
+// EncryptedField describes an encrypted field of a collection.
+type EncryptedField struct {
+	Field     string              `json:"field"`
+	KeyName   string              `json:"key_name"`
+	Algorithm EncryptionAlgorithm `json:"algorithm"`
+}
+
+// EncryptionSchema is the field level encryption schema of a collection.
+type EncryptionSchema struct {
+	Fields []EncryptedField `json:"fields"`
+}
+
+// KeyProvider provides the 32 byte keys used to encrypt fields.
+type KeyProvider interface {
+	// Key returns the key with the given name.
+	Key(name string) ([]byte, error)
+}
+
+// localKeyProvider is a KeyProvider backed by keys held in memory.
+type localKeyProvider struct {
+	keys map[string][]byte
+}
+
+// NewLocalKeyProvider creates a KeyProvider that serves the given keys.
+func NewLocalKeyProvider(keys map[string][]byte) KeyProvider {
+	copied := make(map[string][]byte, len(keys))
+	for name, key := range keys {
+		copied[name] = append([]byte(nil), key...)
+	}
+
+	return &localKeyProvider{keys: copied}
+}
+
+// Key returns the key with the given name.
+func (p *localKeyProvider) Key(name string) ([]byte, error) {
+	key, ok := p.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEncryptionKeyNotFound, name)
+	}
+
+	return key, nil
+}
+
+// fieldEncryptor encrypts and decrypts the fields of a collection.
+type fieldEncryptor struct {
+	fields   map[string]EncryptedField
+	provider KeyProvider
+	mu       sync.Mutex
+	ciphers  map[string]*fieldcrypt.Cipher
+}
+
+// newFieldEncryptor validates the schema and creates a fieldEncryptor.
+func newFieldEncryptor(schema EncryptionSchema, provider KeyProvider) (*fieldEncryptor, error) {
+	if provider == nil {
+		return nil, ErrKeyProviderRequired
+	}
+
+	e := &fieldEncryptor{
+		fields:   make(map[string]EncryptedField, len(schema.Fields)),
+		provider: provider,
+		ciphers:  make(map[string]*fieldcrypt.Cipher),
+	}
+
+	for _, f := range schema.Fields {
+		if f.Field == "" || f.Field == consts.DocumentFieldID {
+			return nil, fmt.Errorf("%w: field %q cannot be encrypted", ErrInvalidEncryptionSchema, f.Field)
+		}
+
+		if _, dup := e.fields[f.Field]; dup {
+			return nil, fmt.Errorf("%w: field %q is declared twice", ErrInvalidEncryptionSchema, f.Field)
+		}
+
+		if _, err := f.Algorithm.fieldcrypt(); err != nil {
+			return nil, err
+		}
+
+		if _, err := e.cipher(f.KeyName); err != nil {
+			return nil, err
+		}
+
+		e.fields[f.Field] = f
+	}
+
+	return e, nil
+}
+
+// fieldcrypt returns the fieldcrypt algorithm of the encryption algorithm.
+func (a EncryptionAlgorithm) fieldcrypt() (fieldcrypt.Algorithm, error) {
+	switch a {
+	case EncryptionDeterministic:
+		return fieldcrypt.Deterministic, nil
+	case EncryptionRandom:
+		return fieldcrypt.Random, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidEncryptionSchema, a)
+	}
+}
+
+// cipher returns the cipher for the named key.
+func (e *fieldEncryptor) cipher(keyName string) (*fieldcrypt.Cipher, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if c, ok := e.ciphers[keyName]; ok {
+		return c, nil
+	}
+
+	key, err := e.provider.Key(keyName)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := fieldcrypt.NewCipher(keyName, key)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", keyName, err)
+	}
+
+	e.ciphers[keyName] = c
+
+	return c, nil
+}
+
+// encryptValue encrypts the value of an encrypted field.
+func (e *fieldEncryptor) encryptValue(f EncryptedField, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	if _, ok := fieldcrypt.IsEncrypted(value); ok {
+		return value, nil
+	}
+
+	c, err := e.cipher(f.KeyName)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, err := f.Algorithm.fieldcrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Encrypt(f.Field, algorithm, value)
+}
+
+// encryptDocument returns a copy of the document with its encrypted fields encrypted.
+func (e *fieldEncryptor) encryptDocument(doc map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(doc))
+	maps.Copy(out, doc)
+
+	for name, f := range e.fields {
+		value, ok := out[name]
+		if !ok {
+			continue
+		}
+
+		encrypted, err := e.encryptValue(f, value)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt field %s: %w", name, err)
+		}
+
+		out[name] = encrypted
+	}
+
+	return out, nil
+}
+
+// keepCiphertexts returns a copy of an updated document whose encrypted fields that keep their
+// decrypted value take their stored ciphertext, so an update does not encrypt again, with a new
+// nonce, the fields it does not change.
+func (e *fieldEncryptor) keepCiphertexts(doc, decrypted, stored map[string]any) map[string]any {
+	out := maps.Clone(doc)
+
+	for name := range e.fields {
+		value, ok := out[name]
+		if !ok {
+			continue
+		}
+
+		if old, ok := decrypted[name]; ok && reflect.DeepEqual(value, old) {
+			out[name] = stored[name]
+		}
+	}
+
+	return out
+}
+
+// decryptDocument returns a copy of the document with every encrypted value decrypted.
+func (e *fieldEncryptor) decryptDocument(doc map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(doc))
+	maps.Copy(out, doc)
+
+	for name, value := range out {
+		decrypted, err := e.decryptValue(name, value)
+		if err != nil {
+			return nil, err
+		}
+
+		out[name] = decrypted
+	}
+
+	return out, nil
+}
+
+// decryptRaw returns a copy of a stored document with every encrypted value decrypted, keeping the
+// order of its fields.
+func (e *fieldEncryptor) decryptRaw(data []byte) (bson.Raw, error) {
+	var doc primitive.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	for i, elem := range doc {
+		decrypted, err := e.decryptValue(elem.Key, elem.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		doc[i].Value = decrypted
+	}
+
+	return bson.Marshal(doc)
+}
+
+// decryptValue decrypts the value of a field when it is encrypted, and returns it as is otherwise.
+func (e *fieldEncryptor) decryptValue(name string, value any) (any, error) {
+	bin, ok := fieldcrypt.IsEncrypted(value)
+	if !ok {
+		return value, nil
+	}
+
+	keyName, err := fieldcrypt.KeyName(bin)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt field %s: %w", name, err)
+	}
+
+	c, err := e.cipher(keyName)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt field %s: %w", name, err)
+	}
+
+	return c.Decrypt(name, bin)
+}
+
+// encryptFilter returns a copy of the filter whose values on encrypted fields are encrypted.
+// Only equality operators can be used on deterministically encrypted fields.
+func (e *fieldEncryptor) encryptFilter(filter map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(filter))
+
+	for key, value := range filter {
+		switch key {
+		case queryengine.OperatorAnd.String(), queryengine.OperatorOr.String():
+			clauses, ok := value.([]any)
+			if !ok {
+				out[key] = value
+
+				continue
+			}
+
+			encrypted := make([]any, 0, len(clauses))
+
+			for _, clause := range clauses {
+				sub, ok := clause.(map[string]any)
+				if !ok {
+					encrypted = append(encrypted, clause)
+
+					continue
+				}
+
+				sub, err := e.encryptFilter(sub)
+				if err != nil {
+					return nil, err
+				}
+
+				encrypted = append(encrypted, sub)
+			}
+
+			out[key] = encrypted
+
+			continue
+		}
+
+		f, ok := e.fields[key]
+		if !ok {
+			out[key] = value
+
+			continue
+		}
+
+		encrypted, err := e.encryptCondition(f, value)
+		if err != nil {
+			return nil, err
+		}
+
+		out[key] = encrypted
+	}
+
+	return out, nil
+}
+
+// encryptCondition encrypts the condition of a filter on an encrypted field.
+func (e *fieldEncryptor) encryptCondition(f EncryptedField, cond any) (any, error) {
+	if f.Algorithm != EncryptionDeterministic {
+		return nil, fmt.Errorf("%w: %s", ErrFieldNotQueryable, f.Field)
+	}
+
+	ops, ok := cond.(map[string]any)
+	if !ok {
+		return e.encryptValue(f, cond)
+	}
+
+	out := make(map[string]any, len(ops))
+
+	for op, value := range ops {
+		switch queryengine.Operator(op) {
+		case queryengine.OperatorEqual, queryengine.OperatorNotEqual:
+			encrypted, err := e.encryptValue(f, value)
+			if err != nil {
+				return nil, err
+			}
+
+			out[op] = encrypted
+		case queryengine.OperatorIn, queryengine.OperatorNotIn:
+			list, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s requires an array", ErrFieldNotQueryable, op)
+			}
+
+			encrypted := make([]any, 0, len(list))
+
+			for _, item := range list {
+				v, err := e.encryptValue(f, item)
+				if err != nil {
+					return nil, err
+				}
+
+				encrypted = append(encrypted, v)
+			}
+
+			out[op] = encrypted
+		case queryengine.OperatorExists:
+			out[op] = value
+		default:
+			return nil, fmt.Errorf("%w: operator %s on field %s", ErrFieldNotQueryable, op, f.Field)
+		}
+	}
+
+	return out, nil
+}
+
+// EnableFieldEncryption encrypts the fields described by the schema with keys served by the
+// provider. The schema is persisted with the collection; the provider must be supplied again every
+// time the collection is opened. Documents written before are not re-encrypted.
+func (c *Collection) EnableFieldEncryption(schema EncryptionSchema, provider KeyProvider) error {
+	if err := c.db.checkWritable(); err != nil {
+		return err
+	}
+
+	enc, err := newFieldEncryptor(schema, provider)
+	if err != nil {
+		return err
+	}
+
+	err = c.IndexManager.updateMetadata(func(meta *CollectionMetadata) error {
+		meta.Encryption = &schema
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.encryption = enc
+
+	return nil
+}
+
+// encryptor returns the field encryptor of the collection, or nil if no field is encrypted.
+func (c *Collection) encryptor() (*fieldEncryptor, error) {
+	if c.IndexManager.current().Encryption == nil {
+		return nil, nil
+	}
+
+	if c.encryption == nil {
+		return nil, ErrKeyProviderRequired
+	}
+
+	return c.encryption, nil
+}
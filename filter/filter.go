@@ -0,0 +1,181 @@
+// Package filter builds query filters for the collections of gopherdb:
+//
+//	filter.And(filter.Gte("age", 18), filter.In("status", "active", "pending"))
+//
+// A Filter is an ordered document, so the order of its fields is kept when it is encoded. It can be
+// passed to the queries of a collection as it is.
+package filter
+
+import (
+	"strings"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/queryengine"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Filter is a query filter document whose fields keep the order in which they were added.
+type Filter primitive.D
+
+// Empty returns the filter that matches every document.
+func Empty() Filter {
+	return Filter{}
+}
+
+// Eq matches the documents whose field is equal to the value.
+func Eq(field string, value any) Filter {
+	return where(field, queryengine.OperatorEqual, value)
+}
+
+// Ne matches the documents whose field is not equal to the value or is missing.
+func Ne(field string, value any) Filter {
+	return where(field, queryengine.OperatorNotEqual, value)
+}
+
+// Gt matches the documents whose field is greater than the value.
+func Gt(field string, value any) Filter {
+	return where(field, queryengine.OperatorGreaterThan, value)
+}
+
+// Gte matches the documents whose field is greater than or equal to the value.
+func Gte(field string, value any) Filter {
+	return where(field, queryengine.OperatorGreaterThanOrEqual, value)
+}
+
+// Lt matches the documents whose field is less than the value.
+func Lt(field string, value any) Filter {
+	return where(field, queryengine.OperatorLessThan, value)
+}
+
+// Lte matches the documents whose field is less than or equal to the value.
+func Lte(field string, value any) Filter {
+	return where(field, queryengine.OperatorLessThanOrEqual, value)
+}
+
+// In matches the documents whose field is equal to one of the values.
+func In(field string, values ...any) Filter {
+	return where(field, queryengine.OperatorIn, primitive.A(values))
+}
+
+// Nin matches the documents whose field is not equal to any of the values.
+func Nin(field string, values ...any) Filter {
+	return where(field, queryengine.OperatorNotIn, primitive.A(values))
+}
+
+// Exists matches the documents that have the field, or that do not have it when exists is false.
+func Exists(field string, exists bool) Filter {
+	return where(field, queryengine.OperatorExists, exists)
+}
+
+// And matches the documents that match every filter. The conditions of the filters are merged
+// into a single document when no field is repeated with the same operator, so an index can serve
+// them; otherwise the filters are combined with $and.
+func And(filters ...Filter) Filter {
+	out := Filter{}
+
+	for _, f := range filters {
+		for _, e := range f {
+			i := out.index(e.Key)
+			if i < 0 {
+				out = append(out, e)
+
+				continue
+			}
+
+			merged, ok := mergeConditions(out[i].Value, e.Value)
+			if !ok {
+				return Filter{{Key: queryengine.OperatorAnd.String(), Value: clauses(filters)}}
+			}
+
+			out[i].Value = merged
+		}
+	}
+
+	return out
+}
+
+// Or matches the documents that match at least one of the filters.
+func Or(filters ...Filter) Filter {
+	return Filter{{Key: queryengine.OperatorOr.String(), Value: clauses(filters)}}
+}
+
+// D returns the filter as an ordered document.
+func (f Filter) D() primitive.D {
+	return primitive.D(f)
+}
+
+// Map returns the filter as the map taken by the queries of a collection.
+func (f Filter) Map() map[string]any {
+	doc, _ := bson.Normalize(primitive.D(f)).(map[string]any)
+
+	return doc
+}
+
+// MarshalBSON encodes the filter as a BSON document.
+func (f Filter) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(primitive.D(f))
+}
+
+// index returns the position of a field of the filter, or -1.
+func (f Filter) index(key string) int {
+	for i, e := range f {
+		if e.Key == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// where returns the filter with a condition on a field.
+func where(field string, op queryengine.Operator, value any) Filter {
+	return Filter{{Key: field, Value: primitive.D{{Key: op.String(), Value: value}}}}
+}
+
+// clauses returns the filters as the array of a $and or $or clause.
+func clauses(filters []Filter) primitive.A {
+	out := make(primitive.A, 0, len(filters))
+
+	for _, f := range filters {
+		out = append(out, primitive.D(f))
+	}
+
+	return out
+}
+
+// mergeConditions merges two operator documents on the same field, unless they share an operator
+// or one of them is not an operator document.
+func mergeConditions(a, b any) (primitive.D, bool) {
+	da, ok := a.(primitive.D)
+	if !ok || !isOperatorDocument(da) {
+		return nil, false
+	}
+
+	db, ok := b.(primitive.D)
+	if !ok || !isOperatorDocument(db) {
+		return nil, false
+	}
+
+	merged := append(primitive.D{}, da...)
+
+	for _, e := range db {
+		if Filter(merged).index(e.Key) >= 0 {
+			return nil, false
+		}
+
+		merged = append(merged, e)
+	}
+
+	return merged, true
+}
+
+// isOperatorDocument reports whether every field of a document is an operator.
+func isOperatorDocument(doc primitive.D) bool {
+	for _, e := range doc {
+		if !strings.HasPrefix(e.Key, "$") {
+			return false
+		}
+	}
+
+	return len(doc) > 0
+}
@@ -0,0 +1,182 @@
+package gopherdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// Snapshot reads the collections of a database as they were after a past commit.
+type Snapshot struct {
+	db *Database
+	ts uint64
+}
+
+// SnapshotCollection reads a collection as it was after the commit of its snapshot. Its queries
+// scan the documents, since the indexes may not cover the commit read.
+type SnapshotCollection struct {
+	coll *Collection
+	ts   uint64
+}
+
+// CommitTimestamp returns the timestamp of the latest commit of the storage engine, to read the
+// database as it is now with SnapshotAt or FindOptions.SetReadAt later on. The engines share the
+// timestamps between the databases stored in them.
+func (db *Database) CommitTimestamp() (uint64, error) {
+	historian, ok := db.storage.(storage.Historian)
+	if !ok {
+		return 0, ErrHistoryUnsupported
+	}
+
+	return historian.CommitTs(), nil
+}
+
+// SnapshotAt returns a snapshot of the database as it was after the commit with timestamp ts.
+// The storage engine keeps the past versions of the documents as set by
+// DatabaseOptions.SetNumVersionsToKeep: it fails with ErrSnapshotTooOld when a document has changed
+// since more times than that, and so do the reads of the snapshot once that happens.
+func (db *Database) SnapshotAt(ts uint64) (*Snapshot, error) {
+	if _, err := db.readerAt(ts); err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{db: db, ts: ts}, nil
+}
+
+// readerAt returns a reader of the storage as it was after the commit with timestamp ts.
+func (db *Database) readerAt(ts uint64) (storageReader, error) {
+	historian, ok := db.storage.(storage.Historian)
+	if !ok {
+		return nil, ErrHistoryUnsupported
+	}
+
+	if latest := historian.CommitTs(); ts > latest {
+		return nil, fmt.Errorf("%w: %d is after the latest commit %d", ErrInvalidTimestamp, ts, latest)
+	}
+
+	return historian.ReadAt(ts)
+}
+
+// Timestamp returns the commit timestamp of the snapshot.
+func (s *Snapshot) Timestamp() uint64 {
+	return s.ts
+}
+
+// Collection returns a collection of the snapshot.
+func (s *Snapshot) Collection(name string) (*SnapshotCollection, error) {
+	coll, err := newCollection(s.db, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SnapshotCollection{coll: coll, ts: s.ts}, nil
+}
+
+// Find finds documents by a filter. See Collection.Find.
+func (c *SnapshotCollection) Find(filter any, opts ...*options.FindOptions) FindResult {
+	return c.FindContext(context.Background(), filter, opts...)
+}
+
+// FindContext is like Find, but stops when the context is done. See Collection.FindContext.
+func (c *SnapshotCollection) FindContext(ctx context.Context, filter any, opts ...*options.FindOptions) FindResult {
+	return c.coll.FindContext(ctx, filter, append(opts, options.Find().SetReadAt(c.ts))...)
+}
+
+// FindOne finds a single document by a filter.
+func (c *SnapshotCollection) FindOne(filter any) FindOneResult {
+	return c.FindOneContext(context.Background(), filter)
+}
+
+// FindOneContext is like FindOne, but stops when the context is done.
+func (c *SnapshotCollection) FindOneContext(ctx context.Context, filter any) FindOneResult {
+	return c.coll.findOne(ctx, filter, options.Find().SetReadAt(c.ts))
+}
+
+// FindByID finds a document by its ID. See Collection.FindByID.
+func (c *SnapshotCollection) FindByID(id any) FindOneResult {
+	return c.FindByIDContext(context.Background(), id)
+}
+
+// FindByIDContext is like FindByID, but fails with the error of the context when it is done.
+func (c *SnapshotCollection) FindByIDContext(ctx context.Context, id any) FindOneResult {
+	if err := ctx.Err(); err != nil {
+		return FindOneResult{
+			Err: err,
+		}
+	}
+
+	r, err := c.coll.db.readerAt(c.ts)
+	if err != nil {
+		return FindOneResult{
+			Err: err,
+		}
+	}
+
+	return c.coll.findByKey(r, c.coll.buildDocumentKey(encodeDocumentID(id)))
+}
+
+// CountDocuments counts the documents that match a filter.
+func (c *SnapshotCollection) CountDocuments(filter any) (int64, error) {
+	return c.CountDocumentsContext(context.Background(), filter)
+}
+
+// CountDocumentsContext is like CountDocuments, but stops when the context is done.
+func (c *SnapshotCollection) CountDocumentsContext(ctx context.Context, filter any) (int64, error) {
+	result := c.FindContext(ctx, filter)
+	if result.Err != nil {
+		return 0, result.Err
+	}
+
+	return result.TotalCount, nil
+}
+
+// History returns the versions of a document that the storage engine keeps, newest first, with
+// the timestamps of the commits that wrote them; deletions are versions too. How many versions are
+// kept is set by DatabaseOptions.SetNumVersionsToKeep. A document that never existed has no
+// versions.
+func (c *Collection) History(id any) ([]DocumentVersion, error) {
+	return c.HistoryContext(context.Background(), id)
+}
+
+// HistoryContext is like History, but fails with the error of the context when it is done.
+func (c *Collection) HistoryContext(ctx context.Context, id any) ([]DocumentVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	historian, ok := c.storage.(storage.Historian)
+	if !ok {
+		return nil, ErrHistoryUnsupported
+	}
+
+	enc, err := c.encryptor()
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.buildDocumentKey(encodeDocumentID(id))
+
+	versions, err := historian.History(key)
+	if err != nil {
+		return nil, fmt.Errorf("get document history failed: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	history := make([]DocumentVersion, 0, len(versions))
+
+	for _, v := range versions {
+		history = append(history, DocumentVersion{
+			Timestamp:  v.Ts,
+			Deleted:    v.Deleted,
+			raw:        storage.KV{Key: key, Value: v.Value},
+			encryption: enc,
+		})
+	}
+
+	return history, nil
+}
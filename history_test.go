@@ -0,0 +1,69 @@
+package gopherdb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+)
+
+func TestSnapshotAtPastRetention(t *testing.T) {
+	db, err := gopherdb.NewMemoryDatabase("test", options.Database().SetNumVersionsToKeep(2))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	coll, err := db.Collection("accounts")
+	if err != nil {
+		t.Fatalf("open collection: %v", err)
+	}
+
+	if r := coll.InsertOne(account{ID: 1, Name: "ana"}); r.Err != nil {
+		t.Fatalf("insert: %v", r.Err)
+	}
+
+	first, err := db.CommitTimestamp()
+	if err != nil {
+		t.Fatalf("commit timestamp: %v", err)
+	}
+
+	if r := coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$set": map[string]any{"name": "eva"}}); r.Err != nil {
+		t.Fatalf("update: %v", r.Err)
+	}
+
+	snapshot, err := db.SnapshotAt(first)
+	if err != nil {
+		t.Fatalf("snapshot within the kept versions: %v", err)
+	}
+
+	old, err := snapshot.Collection("accounts")
+	if err != nil {
+		t.Fatalf("snapshot collection: %v", err)
+	}
+
+	var got account
+
+	result := old.FindByID(1)
+	if err := result.Unmarshal(&got); err != nil || got.Name != "ana" {
+		t.Fatalf("find in the snapshot: got %+v, %v, want the inserted account", got, err)
+	}
+
+	if r := coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$set": map[string]any{"name": "ida"}}); r.Err != nil {
+		t.Fatalf("update: %v", r.Err)
+	}
+
+	if _, err := db.SnapshotAt(first); !errors.Is(err, gopherdb.ErrSnapshotTooOld) {
+		t.Fatalf("snapshot past the kept versions: got %v, want %v", err, gopherdb.ErrSnapshotTooOld)
+	}
+
+	if r := old.Find(nil); !errors.Is(r.Err, gopherdb.ErrSnapshotTooOld) {
+		t.Fatalf("find in a snapshot past the kept versions: got %v, want %v", r.Err, gopherdb.ErrSnapshotTooOld)
+	}
+
+	if r := coll.Find(nil, options.Find().SetReadAt(first)); !errors.Is(r.Err, gopherdb.ErrSnapshotTooOld) {
+		t.Fatalf("find read at a commit past the kept versions: got %v, want %v", r.Err, gopherdb.ErrSnapshotTooOld)
+	}
+}
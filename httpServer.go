@@ -0,0 +1,1251 @@
+package gopherdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/update"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// defaultMaxBodySize is the largest request body accepted by default.
+	defaultMaxBodySize = 48 * 1000 * 1000
+	// httpFlushEvery is the number of documents written between flushes of a streamed response.
+	httpFlushEvery = 100
+	// contentTypeJSON is the content type of JSON responses.
+	contentTypeJSON = "application/json"
+	// contentTypeNDJSON is the content type of newline delimited JSON responses.
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+// httpStatuses maps the errors of the package to HTTP status codes. The first match wins.
+var httpStatuses = []struct {
+	err    error
+	status int
+}{
+	{ErrAuthenticationRequired, http.StatusUnauthorized},
+	{ErrAuthenticationFailed, http.StatusUnauthorized},
+	{ErrUnauthorized, http.StatusForbidden},
+	{ErrDocumentNotFound, http.StatusNotFound},
+	{ErrCollectionNotFound, http.StatusNotFound},
+	{ErrCollectionExists, http.StatusConflict},
+	{ErrUniqueIndexViolation, http.StatusConflict},
+	{ErrIndexAlreadyExists, http.StatusConflict},
+	{ErrDocumentIDNoEditable, http.StatusConflict},
+	{ErrVersionConflict, http.StatusPreconditionFailed},
+	{ErrNotPrimary, http.StatusServiceUnavailable},
+	{ErrKeyProviderRequired, http.StatusServiceUnavailable},
+	{ErrEncryptionKeyNotFound, http.StatusServiceUnavailable},
+	{ErrMaxTimeExceeded, http.StatusGatewayTimeout},
+	{ErrMissingFieldForIndex, http.StatusUnprocessableEntity},
+	{ErrDocumentValidation, http.StatusUnprocessableEntity},
+	{ErrInvalidSchema, http.StatusBadRequest},
+	{ErrInvalidEncryptionSchema, http.StatusBadRequest},
+	{ErrInvalidIDStrategy, http.StatusBadRequest},
+	{ErrDocumentIDNotFound, http.StatusBadRequest},
+	{ErrFieldNotQueryable, http.StatusBadRequest},
+	{ErrInvalidQuery, http.StatusBadRequest},
+	{ErrInvalidUpdate, http.StatusBadRequest},
+	{ErrInvalidNamespace, http.StatusBadRequest},
+	{ErrInvalidIndexSpec, http.StatusBadRequest},
+	{ErrEmptyIndexFields, http.StatusBadRequest},
+	{ErrDuplicateIndexField, http.StatusBadRequest},
+	{ErrUnsupportedPipelineStage, http.StatusBadRequest},
+	{ErrDocumentIsNil, http.StatusBadRequest},
+	{ErrDocumentTypeInvalid, http.StatusBadRequest},
+	{ErrDocumentSliceEmpty, http.StatusBadRequest},
+	{ErrDocumentSliceElementTypeInvalid, http.StatusBadRequest},
+}
+
+// HTTPServer serves the databases of a data directory over a REST API that speaks Extended JSON,
+// for services that have no Go or MongoDB driver. The routes mirror the key layout of the
+// storage:
+//
+//	GET    /dbs                              list the databases
+//	DELETE /dbs/{db}                         drop a database
+//	GET    /dbs/{db}/stats                   storage statistics of a database
+//	GET    /dbs/{db}/colls                   list the collections of a database
+//	DELETE /dbs/{db}/colls/{coll}            drop a collection
+//	POST   /dbs/{db}/colls/{coll}/rename     rename a collection with a {to, dropTarget} body
+//	GET    /dbs/{db}/colls/{coll}/stats      storage statistics of a collection
+//	GET    /dbs/{db}/colls/{coll}/count      count the documents matching the filter query parameter, or
+//	                                         estimate the count of the collection with estimated=true
+//	GET    /dbs/{db}/colls/{coll}/docs       find with the filter, sort, skip, limit and projection query parameters
+//	POST   /dbs/{db}/colls/{coll}/docs       insert a document, or an array of documents
+//	GET    /dbs/{db}/colls/{coll}/docs/{id}  get a document
+//	PUT    /dbs/{db}/colls/{coll}/docs/{id}  replace or create a document
+//	PATCH  /dbs/{db}/colls/{coll}/docs/{id}  update a document with update operators or a partial document
+//	DELETE /dbs/{db}/colls/{coll}/docs/{id}  delete a document
+//	POST   /dbs/{db}/colls/{coll}/query      find with a {filter, sort, skip, limit, projection} body
+//	GET    /dbs/{db}/colls/{coll}/indexes    list the indexes
+//	POST   /dbs/{db}/colls/{coll}/indexes    create an index, or several with {indexes: [...]}
+//
+// Results are written as {"documents": [...]}, or one document per line when the client accepts
+// application/x-ndjson, and are flushed while they are written so large results stream.
+//
+// With authentication, requests carry the password of a user with HTTP Basic authentication or
+// one of its API tokens as a Bearer token, and may only do what the roles of the user allow.
+type HTTPServer struct {
+	catalog     *catalog
+	users       *Users
+	mux         *http.ServeMux
+	maxBodySize int64
+	canonical   bool
+}
+
+// NewHTTPServer opens the data directory at path to serve it over HTTP.
+func NewHTTPServer(path string, opts ...*options.HTTPServerOptions) (*HTTPServer, error) {
+	opt := options.HTTPServer()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	dbOpt := options.Database()
+	if opt.Database != nil {
+		dbOpt = dbOpt.Merge(opt.Database)
+	}
+
+	cat, err := openCatalog(path, dbOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &HTTPServer{
+		catalog:     cat,
+		mux:         http.NewServeMux(),
+		maxBodySize: defaultMaxBodySize,
+	}
+
+	if opt.MaxBodySize != nil && *opt.MaxBodySize > 0 {
+		s.maxBodySize = *opt.MaxBodySize
+	}
+
+	if opt.Canonical != nil {
+		s.canonical = *opt.Canonical
+	}
+
+	if opt.Auth != nil && *opt.Auth {
+		s.users = &Users{catalog: cat}
+	}
+
+	const coll = "/dbs/{db}/colls/{coll}"
+
+	s.route("GET /dbs", actionInspect, s.handleListDatabases)
+	s.route("DELETE /dbs/{db}", actionManage, s.handleDropDatabase)
+	s.route("GET /dbs/{db}/stats", actionInspect, s.handleDatabaseStats)
+	s.route("GET /dbs/{db}/colls", actionInspect, s.handleListCollections)
+	s.route("DELETE "+coll, actionManage, s.handleDropCollection)
+	s.route("POST "+coll+"/rename", actionManage, s.handleRenameCollection)
+	s.route("GET "+coll+"/stats", actionInspect, s.handleCollectionStats)
+	s.route("GET "+coll+"/count", actionRead, s.handleCount)
+	s.route("GET "+coll+"/docs", actionRead, s.handleFind)
+	s.route("POST "+coll+"/docs", actionWrite, s.handleInsert)
+	s.route("GET "+coll+"/docs/{id}", actionRead, s.handleGet)
+	s.route("PUT "+coll+"/docs/{id}", actionWrite, s.handleReplace)
+	s.route("PATCH "+coll+"/docs/{id}", actionWrite, s.handleUpdate)
+	s.route("DELETE "+coll+"/docs/{id}", actionWrite, s.handleDelete)
+	s.route("POST "+coll+"/query", actionRead, s.handleQuery)
+	s.route("GET "+coll+"/indexes", actionInspect, s.handleListIndexes)
+	s.route("POST "+coll+"/indexes", actionManage, s.handleCreateIndexes)
+
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler, so the server can be mounted in another mux.
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// route registers the handler of a route whose requests run an action on the collection of their
+// path.
+func (s *HTTPServer) route(pattern string, act action, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if s.users != nil {
+			user, err := s.authenticate(r)
+			if err != nil {
+				s.writeError(w, r, err)
+
+				return
+			}
+
+			// Requests that do not name a database only need an authenticated user.
+			db, coll := r.PathValue("db"), r.PathValue("coll")
+			if db != "" && !user.can(act, db, coll) {
+				s.writeError(w, r, fmt.Errorf("%w: %s on %s.%s", ErrUnauthorized, user.Name, db, coll))
+
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), httpUserKey{}, user))
+		}
+
+		handler(w, r)
+	})
+}
+
+// httpUserKey is the context key of the authenticated user of a request.
+type httpUserKey struct{}
+
+// user returns the authenticated user of the request, or nil without authentication.
+func (s *HTTPServer) user(r *http.Request) *User {
+	user, _ := r.Context().Value(httpUserKey{}).(*User)
+
+	return user
+}
+
+// writer returns the handle of the collection whose writes are audited as done by the
+// authenticated user of the request.
+func (s *HTTPServer) writer(r *http.Request, sc *servedCollection) *Collection {
+	return sc.as(s.user(r))
+}
+
+// authenticate returns the user of the credentials of a request.
+func (s *HTTPServer) authenticate(r *http.Request) (*User, error) {
+	if name, password, ok := r.BasicAuth(); ok {
+		return s.users.authenticatePassword(name, password)
+	}
+
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if strings.EqualFold(scheme, "Bearer") && token != "" {
+		return s.users.authenticateToken(strings.TrimSpace(token))
+	}
+
+	return nil, ErrAuthenticationRequired
+}
+
+// Serve accepts HTTP connections from the listener. It blocks until the context is done or the
+// listener fails; in-flight requests are given a few seconds to finish.
+func (s *HTTPServer) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	err := srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Close closes the databases and the storage engine. It must be called after Serve returns.
+func (s *HTTPServer) Close() error {
+	return s.catalog.close()
+}
+
+// httpQuery is a parsed find request.
+type httpQuery struct {
+	filter     map[string]any
+	opt        *options.FindOptions
+	projection *queryProjection
+}
+
+// handleFind finds documents with the query parameters of the URL.
+func (s *HTTPServer) handleFind(w http.ResponseWriter, r *http.Request) {
+	spec := primitive.D{}
+	params := r.URL.Query()
+
+	for _, name := range []string{"filter", "sort", "projection"} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+
+		var doc primitive.D
+		if err := bson.UnmarshalExtJSON([]byte(v), &doc); err != nil {
+			s.writeError(w, r, fmt.Errorf("%w: %s is not an Extended JSON document: %v", ErrInvalidQuery, name, err))
+
+			return
+		}
+
+		spec = append(spec, primitive.E{Key: name, Value: doc})
+	}
+
+	for _, name := range []string{"skip", "limit"} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.writeError(w, r, fmt.Errorf("%w: %s must be an integer", ErrInvalidQuery, name))
+
+			return
+		}
+
+		spec = append(spec, primitive.E{Key: name, Value: n})
+	}
+
+	s.find(w, r, spec)
+}
+
+// handleQuery finds documents with the query of the request body.
+func (s *HTTPServer) handleQuery(w http.ResponseWriter, r *http.Request) {
+	body, err := s.readBody(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	spec, ok := body.(primitive.D)
+	if !ok {
+		s.writeError(w, r, fmt.Errorf("%w: the query must be a document", ErrInvalidQuery))
+
+		return
+	}
+
+	s.find(w, r, spec)
+}
+
+// find runs a query and streams its results.
+func (s *HTTPServer) find(w http.ResponseWriter, r *http.Request, spec primitive.D) {
+	q, err := parseHTTPQuery(spec)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc.mu.Lock()
+	result := sc.coll.FindContext(r.Context(), q.filter, q.opt)
+	sc.mu.Unlock()
+
+	if result.Err != nil {
+		s.writeError(w, r, result.Err)
+
+		return
+	}
+
+	kvs, err := result.decrypted()
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	docs, err := q.projection.applyRaw(kvs)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	s.writeDocuments(w, r, docs)
+}
+
+// parseHTTPQuery parses a {filter, sort, skip, limit, projection, maxTimeMS} query.
+func parseHTTPQuery(spec primitive.D) (*httpQuery, error) {
+	q := &httpQuery{
+		filter: map[string]any{},
+		opt:    options.Find(),
+	}
+
+	for _, e := range spec {
+		switch e.Key {
+		case "filter", "projection":
+			doc, ok := bson.Normalize(e.Value).(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s must be a document", ErrInvalidQuery, e.Key)
+			}
+
+			if e.Key == "filter" {
+				q.filter = doc
+
+				continue
+			}
+
+			projection, err := parseProjection(doc, false)
+			if err != nil {
+				return nil, err
+			}
+
+			q.projection = projection
+		case "sort":
+			doc, ok := e.Value.(primitive.D)
+			if !ok {
+				return nil, fmt.Errorf("%w: sort must be a document", ErrInvalidQuery)
+			}
+
+			sort, err := parseSortSpec(doc)
+			if err != nil {
+				return nil, err
+			}
+
+			q.opt.Sort = sort
+		case "skip", "limit":
+			n, ok := wireInt(e.Value)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("%w: %s must be a non-negative integer", ErrInvalidQuery, e.Key)
+			}
+
+			if n == 0 {
+				continue
+			}
+
+			if e.Key == "skip" {
+				q.opt.SetSkip(n)
+			} else {
+				q.opt.SetLimit(n)
+			}
+		case "maxTimeMS":
+			n, ok := wireInt(e.Value)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("%w: maxTimeMS must be a non-negative integer", ErrInvalidQuery)
+			}
+
+			if n > 0 {
+				q.opt.SetMaxTime(time.Duration(n) * time.Millisecond)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown query field %s", ErrInvalidQuery, e.Key)
+		}
+	}
+
+	return q, nil
+}
+
+// handleInsert inserts the document of the request body, or every document of an array.
+func (s *HTTPServer) handleInsert(w http.ResponseWriter, r *http.Request) {
+	body, err := s.readBody(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	if arr, ok := body.(primitive.A); ok {
+		docs := make([]map[string]any, 0, len(arr))
+
+		for _, v := range arr {
+			doc, ok := bson.Normalize(v).(map[string]any)
+			if !ok {
+				s.writeError(w, r, ErrDocumentSliceElementTypeInvalid)
+
+				return
+			}
+
+			docs = append(docs, doc)
+		}
+
+		sc.mu.Lock()
+		result := s.writer(r, sc).InsertContext(r.Context(), docs)
+		sc.mu.Unlock()
+
+		if result.Err != nil {
+			s.writeError(w, r, result.Err)
+
+			return
+		}
+
+		ids := make(primitive.A, len(docs))
+		for i, doc := range docs {
+			ids[i] = insertedID(doc, result.InsertedIDs[i])
+		}
+
+		s.writeJSON(w, r, http.StatusCreated, primitive.D{{Key: "insertedIds", Value: ids}})
+
+		return
+	}
+
+	doc, _ := bson.Normalize(body).(map[string]any)
+
+	sc.mu.Lock()
+	result := s.writer(r, sc).InsertOne(doc)
+	sc.mu.Unlock()
+
+	if result.Err != nil {
+		s.writeError(w, r, result.Err)
+
+		return
+	}
+
+	id := insertedID(doc, result.InsertedID)
+
+	w.Header().Set("Location", r.URL.Path+"/"+url.PathEscape(httpPathID(id)))
+	s.writeJSON(w, r, http.StatusCreated, primitive.D{{Key: "insertedId", Value: id}})
+}
+
+// handleGet returns a document.
+func (s *HTTPServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc.mu.Lock()
+	result, _ := findByPathID(sc.coll, r.PathValue("id"))
+	sc.mu.Unlock()
+
+	if result.Err != nil {
+		s.writeError(w, r, result.Err)
+
+		return
+	}
+
+	doc, err := result.decrypted()
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	setETag(w, &result)
+	s.writeJSON(w, r, http.StatusOK, doc)
+}
+
+// handleReplace replaces a document with the request body, creating it when it does not exist.
+func (s *HTTPServer) handleReplace(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.readDocument(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	if update.IsOperatorDocument(doc) {
+		s.writeError(w, r, fmt.Errorf("%w: a replacement document cannot contain update operators", ErrInvalidUpdate))
+
+		return
+	}
+
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	pathID := r.PathValue("id")
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	found, id := findByPathID(sc.coll, pathID)
+	if found.Err != nil && !errors.Is(found.Err, ErrDocumentNotFound) {
+		s.writeError(w, r, found.Err)
+
+		return
+	}
+
+	if docID, ok := doc[consts.DocumentFieldID]; ok {
+		if fmt.Sprintf("%v", docID) != fmt.Sprintf("%v", id) {
+			s.writeError(w, r, ErrDocumentIDNoEditable)
+
+			return
+		}
+
+		// Un documento nuevo conserva el tipo del _id del cuerpo
+		if found.Err != nil {
+			id = docID
+		}
+	}
+
+	doc[consts.DocumentFieldID] = id
+
+	status := http.StatusOK
+	version := ifMatchVersion(r)
+
+	if found.Err != nil && version != nil {
+		s.writeError(w, r, fmt.Errorf("%w: document %v does not exist", ErrVersionConflict, id))
+
+		return
+	}
+
+	if found.Err != nil {
+		if result := s.writer(r, sc).InsertOne(doc); result.Err != nil {
+			s.writeError(w, r, result.Err)
+
+			return
+		}
+
+		status = http.StatusCreated
+		w.Header().Set("Location", r.URL.Path)
+	} else {
+		filter := map[string]any{consts.DocumentFieldID: id}
+		opt := options.Update().SetSet(true)
+		opt.Version = version
+
+		if result := s.writer(r, sc).UpdateOne(filter, doc, opt); result.Err != nil {
+			s.writeError(w, r, result.Err)
+
+			return
+		}
+	}
+
+	s.writeStored(w, r, sc.coll, id, status)
+}
+
+// handleUpdate updates a document with the update operators or the fields of the request body.
+func (s *HTTPServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.readDocument(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	found, id := findByPathID(sc.coll, r.PathValue("id"))
+	if found.Err != nil {
+		s.writeError(w, r, found.Err)
+
+		return
+	}
+
+	opt := options.Update()
+	opt.Version = ifMatchVersion(r)
+
+	if result := s.writer(r, sc).UpdateOne(map[string]any{consts.DocumentFieldID: id}, doc, opt); result.Err != nil {
+		s.writeError(w, r, result.Err)
+
+		return
+	}
+
+	s.writeStored(w, r, sc.coll, id, http.StatusOK)
+}
+
+// handleDelete deletes a document.
+func (s *HTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	found, id := findByPathID(sc.coll, r.PathValue("id"))
+	if found.Err != nil {
+		s.writeError(w, r, found.Err)
+
+		return
+	}
+
+	if result := s.writer(r, sc).DeleteByIDContext(r.Context(), id); result.Err != nil {
+		s.writeError(w, r, result.Err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListDatabases lists the databases the user may see.
+func (s *HTTPServer) handleListDatabases(w http.ResponseWriter, r *http.Request) {
+	names, err := s.catalog.databaseNames()
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	user := s.user(r)
+	dbs := make(primitive.A, 0, len(names))
+
+	for _, name := range names {
+		if user == nil || user.canSee(name) {
+			dbs = append(dbs, name)
+		}
+	}
+
+	s.writeJSON(w, r, http.StatusOK, primitive.D{{Key: "databases", Value: dbs}})
+}
+
+// handleDropDatabase drops every collection of a database and its metadata.
+func (s *HTTPServer) handleDropDatabase(w http.ResponseWriter, r *http.Request) {
+	user := s.user(r)
+	dbname := r.PathValue("db")
+
+	err := s.catalog.dropDatabase(dbname, user, func(collname string) {
+		s.catalog.forget(dbname, collname)
+	})
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListCollections lists the collections of a database.
+func (s *HTTPServer) handleListCollections(w http.ResponseWriter, r *http.Request) {
+	db, err := s.catalog.database(r.PathValue("db"))
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	names, err := db.ListCollectionsContext(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	colls := make(primitive.A, 0, len(names))
+	for _, name := range names {
+		colls = append(colls, name)
+	}
+
+	s.writeJSON(w, r, http.StatusOK, primitive.D{{Key: "collections", Value: colls}})
+}
+
+// handleDropCollection drops a collection.
+func (s *HTTPServer) handleDropCollection(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc.mu.Lock()
+	err = s.writer(r, sc).DropContext(r.Context())
+	sc.mu.Unlock()
+
+	s.catalog.forget(r.PathValue("db"), r.PathValue("coll"))
+
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRenameCollection renames a collection to the to field of the request body. The user also
+// needs to manage the target collection.
+func (s *HTTPServer) handleRenameCollection(w http.ResponseWriter, r *http.Request) {
+	body, err := s.readDocument(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	to, ok := body["to"].(string)
+	if !ok {
+		s.writeError(w, r, fmt.Errorf("%w: to must be a collection name", ErrInvalidNamespace))
+
+		return
+	}
+
+	dropTarget, _ := body["dropTarget"].(bool)
+	dbname, from := r.PathValue("db"), r.PathValue("coll")
+
+	user := s.user(r)
+	if user != nil && !user.can(actionManage, dbname, to) {
+		s.writeError(w, r, fmt.Errorf("%w: %s on %s.%s", ErrUnauthorized, user.Name, dbname, to))
+
+		return
+	}
+
+	err = s.catalog.renameCollection(dbname, from, to, user, dropTarget, func(collname string) {
+		s.catalog.forget(dbname, collname)
+	})
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, primitive.D{{Key: "ns", Value: dbname + "." + to}})
+}
+
+// handleDatabaseStats writes the storage statistics of a database.
+func (s *HTTPServer) handleDatabaseStats(w http.ResponseWriter, r *http.Request) {
+	db, err := s.catalog.database(r.PathValue("db"))
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	stats, err := db.StatsContext(r.Context())
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, dbStatsDocument(stats))
+}
+
+// handleCollectionStats writes the storage statistics of a collection.
+func (s *HTTPServer) handleCollectionStats(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc.mu.Lock()
+	stats, err := sc.coll.StatsContext(r.Context())
+	sc.mu.Unlock()
+
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, collStatsDocument(stats))
+}
+
+// handleCount counts the documents matching the filter query parameter.
+func (s *HTTPServer) handleCount(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	spec := primitive.D{}
+
+	if v := params.Get("filter"); v != "" {
+		var doc primitive.D
+		if err := bson.UnmarshalExtJSON([]byte(v), &doc); err != nil {
+			s.writeError(w, r, fmt.Errorf("%w: filter is not an Extended JSON document: %v", ErrInvalidQuery, err))
+
+			return
+		}
+
+		spec = append(spec, primitive.E{Key: "filter", Value: doc})
+	}
+
+	q, err := parseHTTPQuery(spec)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	var n int64
+
+	sc.mu.Lock()
+	if params.Get("estimated") == "true" {
+		n, err = sc.coll.EstimatedDocumentCountContext(r.Context())
+	} else {
+		n, err = sc.coll.CountDocumentsContext(r.Context(), q.filter)
+	}
+	sc.mu.Unlock()
+
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, primitive.D{{Key: "count", Value: n}})
+}
+
+// handleListIndexes lists the indexes of a collection.
+func (s *HTTPServer) handleListIndexes(w http.ResponseWriter, r *http.Request) {
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc.mu.Lock()
+	indexes := visibleIndexes(sc.coll.IndexManager.List())
+	sc.mu.Unlock()
+
+	specs := make(primitive.A, 0, len(indexes))
+	for _, idx := range indexes {
+		specs = append(specs, indexSpec(idx))
+	}
+
+	s.writeJSON(w, r, http.StatusOK, primitive.D{{Key: "indexes", Value: specs}})
+}
+
+// handleCreateIndexes creates the index of the request body, or every index of its indexes field.
+func (s *HTTPServer) handleCreateIndexes(w http.ResponseWriter, r *http.Request) {
+	body, err := s.readBody(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	spec, ok := body.(primitive.D)
+	if !ok {
+		s.writeError(w, r, fmt.Errorf("%w: the request must be a document", ErrInvalidIndexSpec))
+
+		return
+	}
+
+	specs := primitive.A{spec}
+
+	if len(spec) > 0 && spec[0].Key == "indexes" {
+		specs, ok = spec[0].Value.(primitive.A)
+		if !ok || len(specs) == 0 {
+			s.writeError(w, r, fmt.Errorf("%w: indexes must be a non-empty array", ErrInvalidIndexSpec))
+
+			return
+		}
+	}
+
+	models := make([]IndexModel, 0, len(specs))
+	names := make(primitive.A, 0, len(specs))
+
+	for _, v := range specs {
+		doc, ok := v.(primitive.D)
+		if !ok {
+			s.writeError(w, r, fmt.Errorf("%w: each index must be a document", ErrInvalidIndexSpec))
+
+			return
+		}
+
+		model, err := parseIndexSpec(doc)
+		if err != nil {
+			s.writeError(w, r, err)
+
+			return
+		}
+
+		models = append(models, *model)
+		names = append(names, model.Options.Name)
+	}
+
+	sc, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	sc.mu.Lock()
+	err = createIndexes(r.Context(), s.writer(r, sc), models)
+	sc.mu.Unlock()
+
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusCreated, primitive.D{{Key: "created", Value: names}})
+}
+
+// collection returns the collection named by the path of the request.
+func (s *HTTPServer) collection(r *http.Request) (*servedCollection, error) {
+	return s.catalog.collection(r.PathValue("db"), r.PathValue("coll"))
+}
+
+// readBody reads the Extended JSON document or array of the request body.
+func (s *HTTPServer) readBody(w http.ResponseWriter, r *http.Request) (any, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, &httpError{status: http.StatusRequestEntityTooLarge, err: err}
+		}
+
+		return nil, &httpError{status: http.StatusBadRequest, err: err}
+	}
+
+	// The Extended JSON decoder only reads documents, so the body is wrapped in one to accept
+	// arrays as well.
+	var wrapped struct {
+		Body any `bson:"body"`
+	}
+
+	data = bytes.Join([][]byte{[]byte(`{"body":`), data, []byte("}")}, nil)
+	if err := bson.UnmarshalExtJSON(data, &wrapped); err != nil {
+		return nil, &httpError{status: http.StatusBadRequest, err: fmt.Errorf("invalid Extended JSON body: %w", err)}
+	}
+
+	switch wrapped.Body.(type) {
+	case primitive.D, primitive.A:
+		return wrapped.Body, nil
+	default:
+		return nil, &httpError{status: http.StatusBadRequest, err: errors.New("the body must be a document or an array")}
+	}
+}
+
+// readDocument reads the Extended JSON document of the request body.
+func (s *HTTPServer) readDocument(w http.ResponseWriter, r *http.Request) (map[string]any, error) {
+	body, err := s.readBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, ok := bson.Normalize(body).(map[string]any)
+	if !ok {
+		return nil, ErrDocumentTypeInvalid
+	}
+
+	return doc, nil
+}
+
+// writeStored writes the stored version of a document.
+func (s *HTTPServer) writeStored(w http.ResponseWriter, r *http.Request, coll *Collection, id any, status int) {
+	result := coll.FindByID(id)
+	if result.Err != nil {
+		s.writeError(w, r, result.Err)
+
+		return
+	}
+
+	doc, err := result.decrypted()
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	setETag(w, &result)
+	s.writeJSON(w, r, status, doc)
+}
+
+// setETag sets the ETag of a response to the version of a document of a versioned collection.
+func setETag(w http.ResponseWriter, result *FindOneResult) {
+	if version := result.Version(); version > 0 {
+		w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+	}
+}
+
+// ifMatchVersion returns the version of the If-Match header of a request, which an update must
+// find the document at, or nil when the request has none or matches any version. A tag that is
+// not a version matches no document.
+func ifMatchVersion(r *http.Request) *int64 {
+	tag := strings.TrimSpace(r.Header.Get("If-Match"))
+	if tag == "" || tag == "*" {
+		return nil
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(tag, "W/"), `"`), 10, 64)
+	if err != nil {
+		version = -1
+	}
+
+	return &version
+}
+
+// writeDocuments writes the documents of a result, flushing them while they are written.
+func (s *HTTPServer) writeDocuments(w http.ResponseWriter, r *http.Request, docs []bson.Raw) {
+	canonical := s.isCanonical(r)
+	ndjson := acceptsNDJSON(r)
+	rc := http.NewResponseController(w)
+
+	if ndjson {
+		w.Header().Set("Content-Type", contentTypeNDJSON)
+	} else {
+		w.Header().Set("Content-Type", contentTypeJSON)
+		io.WriteString(w, `{"documents":[`)
+	}
+
+	for i, doc := range docs {
+		data, err := bson.MarshalExtJSON(doc, canonical)
+		if err != nil {
+			// The status is already sent, so the stream is cut short instead.
+			return
+		}
+
+		if ndjson {
+			data = append(data, '\n')
+		} else if i > 0 {
+			data = append([]byte{','}, data...)
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+
+		if (i+1)%httpFlushEvery == 0 {
+			rc.Flush()
+		}
+	}
+
+	if !ndjson {
+		io.WriteString(w, "]}")
+	}
+}
+
+// writeJSON writes a document as Extended JSON.
+func (s *HTTPServer) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	data, err := bson.MarshalExtJSON(v, s.isCanonical(r))
+	if err != nil {
+		s.writeError(w, r, err)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// writeError writes an error with the HTTP status it maps to.
+func (s *HTTPServer) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := httpStatus(err)
+
+	data, _ := bson.MarshalExtJSON(primitive.D{
+		{Key: "error", Value: err.Error()},
+		{Key: "status", Value: int32(status)},
+	}, false)
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="gopherdb", Bearer realm="gopherdb"`)
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// isCanonical reports whether the response of a request uses canonical Extended JSON.
+func (s *HTTPServer) isCanonical(r *http.Request) bool {
+	if v := r.URL.Query().Get("canonical"); v != "" {
+		canonical, err := strconv.ParseBool(v)
+
+		return err == nil && canonical
+	}
+
+	return s.canonical
+}
+
+// httpError is an error with an explicit HTTP status.
+type httpError struct {
+	status int
+	err    error
+}
+
+// Error implements error.
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *httpError) Unwrap() error {
+	return e.err
+}
+
+// httpStatus returns the HTTP status code of an error.
+func httpStatus(err error) int {
+	var he *httpError
+	if errors.As(err, &he) {
+		return he.status
+	}
+
+	for _, s := range httpStatuses {
+		if errors.Is(err, s.err) {
+			return s.status
+		}
+	}
+
+	return http.StatusInternalServerError
+}
+
+// acceptsNDJSON reports whether the client asked for newline delimited JSON.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && mediaType == contentTypeNDJSON {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findByPathID finds a document by the ID of a URL path. An ID that is a valid ObjectID hex string
+// is looked up as an ObjectID first, and an integer as an int64, then as a string. It returns the
+// typed ID, which is the string when the document does not exist.
+func findByPathID(coll *Collection, pathID string) (FindOneResult, any) {
+	if oid, err := primitive.ObjectIDFromHex(pathID); err == nil {
+		if result := coll.FindByID(oid); !errors.Is(result.Err, ErrDocumentNotFound) {
+			return result, oid
+		}
+	}
+
+	if n, err := strconv.ParseInt(pathID, 10, 64); err == nil {
+		if result := coll.FindByID(n); !errors.Is(result.Err, ErrDocumentNotFound) {
+			return result, n
+		}
+	}
+
+	return coll.FindByID(pathID), pathID
+}
+
+// httpPathID returns the URL path form of a document ID, the inverse of findByPathID.
+func httpPathID(id any) string {
+	if oid, ok := id.(primitive.ObjectID); ok {
+		return oid.Hex()
+	}
+
+	return fmt.Sprintf("%v", id)
+}
+
+// insertedID returns the ID of an inserted document: the typed _id given by the client, or the one
+// generated on insert.
+func insertedID(doc map[string]any, generated any) any {
+	if id, ok := doc[consts.DocumentFieldID]; ok {
+		return id
+	}
+
+	return generated
+}
@@ -0,0 +1,117 @@
+package gopherdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestHTTPServer opens an HTTP server on a new data directory.
+func newTestHTTPServer(t *testing.T) *HTTPServer {
+	t.Helper()
+
+	s, err := NewHTTPServer(t.TempDir())
+	if err != nil {
+		t.Fatalf("open server: %v", err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// serveTestRequest serves a request and returns the response.
+func serveTestRequest(s *HTTPServer, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestHTTPServerDecryptsEncryptedFields(t *testing.T) {
+	s := newTestHTTPServer(t)
+
+	sc, err := s.catalog.collection("app", "people")
+	if err != nil {
+		t.Fatalf("open collection: %v", err)
+	}
+
+	schema := EncryptionSchema{Fields: []EncryptedField{{Field: "ssn", KeyName: "k1", Algorithm: EncryptionRandom}}}
+	provider := NewLocalKeyProvider(map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+
+	if err := sc.coll.EnableFieldEncryption(schema, provider); err != nil {
+		t.Fatalf("enable encryption: %v", err)
+	}
+
+	if r := sc.coll.InsertOne(map[string]any{"_id": "p1", "name": "ana", "ssn": "123-45-6789"}); r.Err != nil {
+		t.Fatalf("insert: %v", r.Err)
+	}
+
+	w := serveTestRequest(s, http.MethodGet, "/dbs/app/colls/people/docs/p1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: status %d: %s", w.Code, w.Body)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+
+	if doc["ssn"] != "123-45-6789" {
+		t.Fatalf("get returned ssn %v, want the decrypted value", doc["ssn"])
+	}
+
+	w = serveTestRequest(s, http.MethodGet, "/dbs/app/colls/people/docs", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("find: status %d: %s", w.Code, w.Body)
+	}
+
+	var found struct {
+		Documents []map[string]any `json:"documents"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &found); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+
+	if len(found.Documents) != 1 || found.Documents[0]["ssn"] != "123-45-6789" {
+		t.Fatalf("find returned %v, want the document with its decrypted ssn", found.Documents)
+	}
+
+	w = serveTestRequest(s, http.MethodPatch, "/dbs/app/colls/people/docs/p1", `{"$set": {"name": "eva"}}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "123-45-6789") {
+		t.Fatalf("update: status %d: %s, want the stored document decrypted", w.Code, w.Body)
+	}
+
+	// Un handle nuevo no tiene el proveedor de claves
+	s.catalog.forget("app", "people")
+
+	w = serveTestRequest(s, http.MethodGet, "/dbs/app/colls/people/docs/p1", "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("get without key provider: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("find: %w", ErrKeyProviderRequired), http.StatusServiceUnavailable},
+		{fmt.Errorf("decrypt field ssn: %w", ErrEncryptionKeyNotFound), http.StatusServiceUnavailable},
+		{ErrInvalidEncryptionSchema, http.StatusBadRequest},
+		{&DocumentValidationError{Namespace: Namespace{Database: "app", Collection: "people"}}, http.StatusUnprocessableEntity},
+		{fmt.Errorf("update one failed: %w", ErrVersionConflict), http.StatusPreconditionFailed},
+		{fmt.Errorf("unexpected"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := httpStatus(tt.err); got != tt.want {
+			t.Errorf("httpStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
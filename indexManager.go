@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 
@@ -42,9 +44,9 @@ func newIndexManager(storage storage.Storage, dbname, collname string) *IndexMan
 
 // List returns all the indexes for the collection.
 func (m *IndexManager) List() []IndexModel {
-	m.loadMetadata()
+	meta, _ := m.loadMetadata()
 
-	return m.metadata.Indexes
+	return meta.Indexes
 }
 
 // buildMetadataKey builds the key for the collection metadata.
@@ -52,57 +54,168 @@ func (m *IndexManager) buildMetadataKey() string {
 	return fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, m.dbname, m.collname)
 }
 
-// loadMetadata loads the collection metadata from the storage.
-func (m *IndexManager) loadMetadata() error {
+// loadMetadata loads the collection metadata from the storage and returns it. Operations read the
+// returned copy, which later loads do not change, rather than the metadata of the manager. When
+// the metadata cannot be loaded, the one loaded before is returned with the error.
+func (m *IndexManager) loadMetadata() (CollectionMetadata, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	var meta CollectionMetadata
+
 	data, err := m.storage.Get(m.buildMetadataKey())
+	switch {
+	case errors.Is(err, storage.ErrKeyNotFound):
+		meta = defaultCollectionMetadata(m.dbname, m.collname)
+	case err != nil:
+		return m.metadata, err
+	default:
+		if err := bson.Unmarshal(data, &meta); err != nil {
+			return m.metadata, err
+		}
+	}
+
+	m.metadata = meta
+
+	return meta, nil
+}
+
+// current returns the metadata loaded last, without reading the storage.
+func (m *IndexManager) current() CollectionMetadata {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.metadata
+}
+
+// updateMetadata loads the collection metadata, applies fn to a copy of it and saves it.
+func (m *IndexManager) updateMetadata(fn func(meta *CollectionMetadata) error) error {
+	meta, err := m.loadMetadata()
 	if err != nil {
-		if errors.Is(err, storage.ErrKeyNotFound) {
-			m.metadata = CollectionMetadata{
-				Name: fmt.Sprintf(
-					consts.CollectionKeyStringFormat,
-					m.dbname,
-					m.collname,
-				),
-				Indexes: []IndexModel{
-					{
-						Fields: []IndexField{
-							{
-								Name:  consts.DocumentFieldID,
-								Order: 1,
-							},
-						},
-						Options: IndexOptions{
-							Name:   "_id_",
-							Unique: true,
-						},
-					},
-				},
-				DocumentCount: 0,
-			}
+		return err
+	}
 
-			return nil
-		}
+	// Las copias comparten el array de índices, así que fn recibe uno propio
+	meta.Indexes = slices.Clone(meta.Indexes)
 
+	if err := fn(&meta); err != nil {
 		return err
 	}
 
-	return bson.Unmarshal(data, &m.metadata)
+	return m.saveMetadata(meta)
 }
 
-// saveMetadata saves the collection metadata to the storage.
-func (m *IndexManager) saveMetadata() error {
+// defaultCollectionMetadata returns the metadata of a collection that has not been persisted yet.
+func defaultCollectionMetadata(dbname, collname string) CollectionMetadata {
+	return CollectionMetadata{
+		Name: fmt.Sprintf(
+			consts.CollectionKeyStringFormat,
+			dbname,
+			collname,
+		),
+		Indexes: []IndexModel{
+			{
+				Fields: []IndexField{
+					{
+						Name:  consts.DocumentFieldID,
+						Order: 1,
+					},
+				},
+				Options: IndexOptions{
+					Name:   "_id_",
+					Unique: true,
+				},
+			},
+		},
+		DocumentCount: 0,
+		KeyFormat:     documentKeyFormat,
+	}
+}
+
+// saveMetadata saves the collection metadata to the storage and makes it the loaded one. The
+// document count and the sequence are kept as stored: they only change with the write
+// transactions that insert and delete documents.
+func (m *IndexManager) saveMetadata(meta CollectionMetadata) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	data, err := bson.Marshal(m.metadata)
+	var stored CollectionMetadata
+
+	if data, err := m.storage.Get(m.buildMetadataKey()); err == nil {
+		if err := bson.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+
+		meta.DocumentCount = stored.DocumentCount
+		meta.Sequence = stored.Sequence
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		return err
+	}
+
+	data, err := bson.Marshal(meta)
 	if err != nil {
 		return err
 	}
 
-	return m.storage.Put(m.buildMetadataKey(), data)
+	if err := m.storage.Put(m.buildMetadataKey(), data); err != nil {
+		return err
+	}
+
+	m.metadata = meta
+
+	return saveDatabaseMetadata(m.storage, m.storage.Put, m.dbname)
+}
+
+// adjustCollectionMetadata adds the document count deltas of a write transaction, by collection
+// name, to the stored metadata of the collections, and advances their sequences to the highest
+// IDs the transaction used. The metadata of a collection that was not stored yet is created along
+// with that of its database. The caller serializes the commits of the database, so the metadata
+// read here is not changed until the transaction commits.
+func adjustCollectionMetadata(engine storage.Storage, txn storage.Transaction, dbname string, counts, sequences map[string]int64) error {
+	collnames := slices.Collect(maps.Keys(counts))
+	for collname := range sequences {
+		if _, ok := counts[collname]; !ok {
+			collnames = append(collnames, collname)
+		}
+	}
+
+	for _, collname := range collnames {
+		delta, seq := counts[collname], sequences[collname]
+
+		key := fmt.Sprintf(consts.MetadataCollectionKeyStringFormat, dbname, collname)
+		meta := defaultCollectionMetadata(dbname, collname)
+
+		data, err := engine.Get(key)
+		if err == nil {
+			if err := bson.Unmarshal(data, &meta); err != nil {
+				return err
+			}
+
+			if delta == 0 && seq <= meta.Sequence {
+				continue
+			}
+		} else if errors.Is(err, storage.ErrKeyNotFound) {
+			if err := saveDatabaseMetadata(engine, txn.Put, dbname); err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+
+		meta.DocumentCount = max(meta.DocumentCount+delta, 0)
+		meta.Sequence = max(meta.Sequence, seq)
+
+		data, err = bson.Marshal(meta)
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Put(key, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // getDocumentIdFromIndexKey gets the document id from an index key.
@@ -116,10 +229,10 @@ func (m *IndexManager) getDocumentIdFromIndexKey(indexKey string) (string, error
 }
 
 // getDocumentIndexKeysByIndex gets all the document ids for a given index.
-func (m *IndexManager) getDocumentIndexKeysByIndex(index IndexModel) ([]string, error) {
+func (m *IndexManager) getDocumentIndexKeysByIndex(r storageReader, index IndexModel) ([]string, error) {
 	indexKeyPrefix := m.buildIndexFieldsKey(index)
 
-	entries, err := m.storage.ScanKeys(indexKeyPrefix)
+	entries, err := r.ScanKeys(indexKeyPrefix)
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +241,11 @@ func (m *IndexManager) getDocumentIndexKeysByIndex(index IndexModel) ([]string,
 }
 
 // getDocumentIndexKeysByIndexAndFilter gets the document ids for a given index.
-func (m *IndexManager) getDocumentIndexKeysByIndexAndFilter(index IndexModel, indexFilter map[string]any) ([]string, error) {
+func (m *IndexManager) getDocumentIndexKeysByIndexAndFilter(
+	r storageReader,
+	index IndexModel,
+	indexFilter map[string]any,
+) ([]string, error) {
 	indexKeyPrefix, err := m.buildDocumentIndexKey(index, indexFilter, true)
 	if err != nil {
 		return nil, err
@@ -139,7 +256,7 @@ func (m *IndexManager) getDocumentIndexKeysByIndexAndFilter(index IndexModel, in
 		fmt.Sprintf("/%v", consts.RemoverWildcard),
 	)
 
-	entries, err := m.storage.ScanKeys(indexKeyPrefix)
+	entries, err := r.ScanKeys(indexKeyPrefix)
 	if err != nil {
 		return nil, err
 	}
@@ -200,7 +317,7 @@ func (m *IndexManager) buildDocumentIndexKey(index IndexModel, doc map[string]an
 	joinedFields := strings.Join(fields, "|")
 	joinedValues := strings.Join(values, "|")
 
-	docId := fmt.Sprintf("%v", doc[consts.DocumentFieldID])
+	docId := encodeDocumentID(doc[consts.DocumentFieldID])
 	if isPrefix {
 		docId = consts.RemoverWildcard
 	}
@@ -216,9 +333,13 @@ func (m *IndexManager) buildDocumentIndexKey(index IndexModel, doc map[string]an
 	), nil
 }
 
-// checkUniqueness checks if the document violates the uniqueness constraint of the index.
-func (m *IndexManager) checkUniqueness(doc map[string]any) error {
-	for _, idx := range m.metadata.Indexes {
+// checkUniqueness checks if the document violates the uniqueness constraint of the index. The
+// entries are read through r, usually the write transaction, so the entries written by the
+// transaction count and the ones read join its read set. The entry of the document itself is not a
+// violation; with the previous version of an updated document, the indexes whose entry does not
+// change are not checked.
+func (m *IndexManager) checkUniqueness(r storageReader, doc, previous map[string]any) error {
+	for _, idx := range m.current().Indexes {
 		if !idx.isUnique() {
 			continue
 		}
@@ -228,38 +349,57 @@ func (m *IndexManager) checkUniqueness(doc map[string]any) error {
 			return err
 		}
 
-		key := strings.TrimSuffix(idxKey, fmt.Sprintf("%v", doc[consts.DocumentFieldID]))
-		entries, err := m.storage.ScanKeys(key)
+		if previous != nil {
+			if prevKey, err := m.buildDocumentIndexKey(idx, previous, false); err == nil && prevKey == idxKey {
+				continue
+			}
+		}
+
+		key := strings.TrimSuffix(idxKey, encodeDocumentID(doc[consts.DocumentFieldID]))
+		entries, err := r.ScanKeys(key)
 
 		if err != nil {
 			return err
 		}
 
-		if len(entries) > 0 {
-			return fmt.Errorf("%w: fields %+v", ErrUniqueIndexViolation, idx.Fields)
+		for _, entry := range entries {
+			if entry != idxKey {
+				return fmt.Errorf("%w: fields %+v", ErrUniqueIndexViolation, idx.Fields)
+			}
 		}
 	}
 
 	return nil
 }
 
-// CreateMany creates many indexes.
+// CreateMany creates many indexes. The indexes are built in the background.
 func (m *IndexManager) CreateMany(ctx context.Context, indexes []IndexModel) error {
 	if indexes == nil || len(indexes) == 0 {
 		return nil
 	}
 
-	m.loadMetadata()
 	defer m.buildIndexes(ctx)
 
+	return m.createMany(indexes)
+}
+
+// createMany adds the indexes to the collection metadata without building them.
+func (m *IndexManager) createMany(indexes []IndexModel) error {
 	indexes = splitCompoundIndexes(indexes)
 
+	return m.updateMetadata(func(meta *CollectionMetadata) error {
+		return addIndexes(meta, indexes)
+	})
+}
+
+// addIndexes adds the indexes to the metadata of a collection.
+func addIndexes(meta *CollectionMetadata, indexes []IndexModel) error {
 	for _, newidx := range indexes {
 		if err := newidx.validate(); err != nil {
 			return err
 		}
 
-		for _, idx := range m.metadata.Indexes {
+		for _, idx := range meta.Indexes {
 			if idx.Options.Name == newidx.Options.Name {
 				if newidx.isAutogenerated() {
 					continue
@@ -297,15 +437,15 @@ func (m *IndexManager) CreateMany(ctx context.Context, indexes []IndexModel) err
 			}
 		}
 
-		m.metadata.Indexes = append(m.metadata.Indexes, newidx)
+		meta.Indexes = append(meta.Indexes, newidx)
 	}
 
-	return m.saveMetadata()
+	return nil
 }
 
 // indexDocument indexes a document.
 func (m *IndexManager) indexDocument(txn storage.Transaction, doc map[string]any) error {
-	for _, idx := range m.metadata.Indexes {
+	for _, idx := range m.current().Indexes {
 		idxKey, err := m.buildDocumentIndexKey(idx, doc, false)
 		if err != nil {
 			return err
@@ -319,15 +459,20 @@ func (m *IndexManager) indexDocument(txn storage.Transaction, doc map[string]any
 	return nil
 }
 
-// deleteDocumentIndexes deletes the indexes for a document.
-func (m *IndexManager) deleteDocumentIndexes(doc map[string]any) error {
-	for _, idx := range m.metadata.Indexes {
+// deleteDocumentIndexes deletes the indexes for a document. Indexes on fields the document does
+// not have are skipped.
+func (m *IndexManager) deleteDocumentIndexes(txn storage.Transaction, doc map[string]any) error {
+	for _, idx := range m.current().Indexes {
 		idxKey, err := m.buildDocumentIndexKey(idx, doc, false)
+		if errors.Is(err, ErrMissingFieldForIndex) {
+			continue
+		}
+
 		if err != nil {
 			return err
 		}
 
-		if err := m.storage.Delete(idxKey); err != nil {
+		if err := txn.Delete(idxKey); err != nil {
 			return err
 		}
 	}
@@ -350,43 +495,50 @@ func (m *IndexManager) buildDocumentsKey() string {
 	)
 }
 
-// buildIndexes builds the indexes for a collection.
+// buildIndexes builds the indexes for a collection in the background.
 func (m *IndexManager) buildIndexes(ctx context.Context) {
-	go func() {
-		m.loadMetadata()
+	go m.rebuildIndexes(ctx)
+}
 
-		docsPrefix := strings.TrimSuffix(
-			m.buildDocumentKey(consts.RemoverWildcard),
-			consts.RemoverWildcard,
-		)
+// rebuildIndexes indexes every document of the collection.
+func (m *IndexManager) rebuildIndexes(ctx context.Context) error {
+	if _, err := m.loadMetadata(); err != nil {
+		return err
+	}
 
-		docs, err := m.storage.Scan(docsPrefix)
-		if err != nil {
-			return
-		}
+	docsPrefix := strings.TrimSuffix(
+		m.buildDocumentKey(consts.RemoverWildcard),
+		consts.RemoverWildcard,
+	)
 
-		txn := m.storage.BeginTx()
+	docs, err := m.storage.Scan(docsPrefix)
+	if err != nil {
+		return err
+	}
 
-		for _, doc := range docs {
-			select {
-			case <-ctx.Done():
+	txn := m.storage.BeginTx()
+
+	for _, doc := range docs {
+		select {
+		case <-ctx.Done():
+			txn.Rollback()
+
+			return ctx.Err()
+		default:
+			var docMap map[string]any
+			if err := bson.Unmarshal(doc.Value, &docMap); err != nil {
 				txn.Rollback()
 
-				return
-			default:
-				var docMap map[string]any
-				if err := bson.Unmarshal(doc.Value, &docMap); err != nil {
-					return
-				}
+				return err
+			}
 
-				if err := m.indexDocument(txn, docMap); err != nil {
-					return
-				}
+			if err := m.indexDocument(txn, docMap); err != nil {
+				txn.Rollback()
+
+				return err
 			}
 		}
+	}
 
-		if err := txn.Commit(); err != nil {
-			return
-		}
-	}()
+	return txn.Commit()
 }
@@ -0,0 +1,52 @@
+package gopherdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOperationsFailOnUnreadableMetadata(t *testing.T) {
+	db, err := NewMemoryDatabase("test")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	defer db.Close()
+
+	coll, err := db.Collection("accounts")
+	if err != nil {
+		t.Fatalf("open collection: %v", err)
+	}
+
+	index := NewIndexModel().AddField("email", 1).SetName("email_1").Value()
+	if err := coll.CreateIndex(context.Background(), index); err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+
+	if r := coll.InsertOne(map[string]any{"_id": 1, "email": "a@example.com"}); r.Err != nil {
+		t.Fatalf("insert: %v", r.Err)
+	}
+
+	// Un documento BSON truncado no se puede decodificar
+	if err := db.storage.Put(coll.IndexManager.buildMetadataKey(), []byte{0x05}); err != nil {
+		t.Fatalf("corrupt metadata: %v", err)
+	}
+
+	filter := map[string]any{"email": "a@example.com"}
+
+	if r := coll.Find(filter); r.Err == nil {
+		t.Error("find planned without the metadata of the collection")
+	}
+
+	if r := coll.InsertOne(map[string]any{"_id": 2, "email": "b@example.com"}); r.Err == nil {
+		t.Error("insert wrote without the metadata of the collection")
+	}
+
+	if r := coll.UpdateOne(filter, map[string]any{"$set": map[string]any{"email": "c@example.com"}}); r.Err == nil {
+		t.Error("update wrote without the metadata of the collection")
+	}
+
+	if r := coll.DeleteOne(filter); r.Err == nil {
+		t.Error("delete wrote without the metadata of the collection")
+	}
+}
@@ -89,13 +89,7 @@ func (index *IndexModel) validate() error {
 	}
 
 	if strings.TrimSpace(index.Options.Name) == "" {
-		name := ""
-
-		for _, f := range index.Fields {
-			name += fmt.Sprintf("_%s_%d", f.Name, f.Order)
-		}
-
-		index.Options.Name = strings.TrimPrefix(name, "_")
+		index.Options.Name = index.defaultName()
 	}
 
 	if index.isCompound() {
@@ -111,6 +105,17 @@ func (index *IndexModel) validate() error {
 	return nil
 }
 
+// defaultName returns the name of an index created without one, built from its fields and orders.
+func (index IndexModel) defaultName() string {
+	name := ""
+
+	for _, f := range index.Fields {
+		name += fmt.Sprintf("_%s_%d", f.Name, f.Order)
+	}
+
+	return strings.TrimPrefix(name, "_")
+}
+
 // isCompound checks if the index model is a compound index.
 func (index IndexModel) isCompound() bool {
 	return len(index.Fields) > 1
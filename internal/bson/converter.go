@@ -1,9 +1,20 @@
 package bson
 
-import "go.mongodb.org/mongo-driver/bson"
+import (
+	"fmt"
+	"reflect"
 
-// ConvertToMap converts a value to a map.
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConvertToMap converts a value to a map. Values whose underlying type is bson.D are encoded as
+// ordered documents.
 func ConvertToMap(o any) (map[string]any, error) {
+	if rv := reflect.ValueOf(o); rv.IsValid() && rv.Type() != typeD && rv.Type().ConvertibleTo(typeD) {
+		o = rv.Convert(typeD).Interface()
+	}
+
 	jsonData, err := bson.Marshal(o)
 	if err != nil {
 		return nil, err
@@ -28,3 +39,85 @@ func ConvertToStruct(m map[string]any, o any) error {
 
 	return bson.Unmarshal(jsonData, o)
 }
+
+// Normalize converts the documents nested in a value to map[string]any and its arrays to []any,
+// so values decoded as primitive.D, primitive.M or primitive.A can be handled uniformly.
+func Normalize(v any) any {
+	switch typed := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(typed))
+		for k, val := range typed {
+			out[k] = Normalize(val)
+		}
+
+		return out
+	case primitive.M:
+		return Normalize(map[string]any(typed))
+	case primitive.D:
+		out := make(map[string]any, len(typed))
+		for _, e := range typed {
+			out[e.Key] = Normalize(e.Value)
+		}
+
+		return out
+	case primitive.A:
+		return Normalize([]any(typed))
+	case []any:
+		out := make([]any, len(typed))
+		for i, val := range typed {
+			out[i] = Normalize(val)
+		}
+
+		return out
+	default:
+		return v
+	}
+}
+
+// typeD is the type of an ordered document.
+var typeD = reflect.TypeFor[primitive.D]()
+
+// ToDocument converts a document given as a map[string]any, a bson.M, a bson.D, a bson.Raw, a
+// type whose underlying type is bson.D or any value that marshals to a BSON document, such as a
+// struct, to a map[string]any whose nested documents and arrays are normalized. A nil value is an
+// empty document.
+func ToDocument(v any) (map[string]any, error) {
+	switch typed := v.(type) {
+	case nil:
+		return map[string]any{}, nil
+	case map[string]any, primitive.M, primitive.D:
+		doc, _ := Normalize(typed).(map[string]any)
+		if doc == nil {
+			doc = map[string]any{}
+		}
+
+		return doc, nil
+	case bson.Raw:
+		var doc primitive.D
+		if err := bson.Unmarshal(typed, &doc); err != nil {
+			return nil, err
+		}
+
+		return ToDocument(doc)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Type().ConvertibleTo(typeD) {
+		return ToDocument(rv.Convert(typeD).Interface().(primitive.D))
+	}
+
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct && rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("%T is not a document", v)
+	}
+
+	doc, err := ConvertToMap(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return ToDocument(doc)
+}
@@ -7,6 +7,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// Raw is a raw BSON document.
+type Raw = bson.Raw
+
 // Marshal marshals a value to BSON.
 func Marshal(v any) ([]byte, error) {
 	return bson.Marshal(v)
@@ -17,6 +20,16 @@ func Unmarshal(data []byte, v any) error {
 	return bson.Unmarshal(data, v)
 }
 
+// MarshalExtJSON marshals a value to Extended JSON, canonical or relaxed.
+func MarshalExtJSON(v any, canonical bool) ([]byte, error) {
+	return bson.MarshalExtJSON(v, canonical, false)
+}
+
+// UnmarshalExtJSON unmarshals an Extended JSON document, canonical or relaxed, to a value.
+func UnmarshalExtJSON(data []byte, v any) error {
+	return bson.UnmarshalExtJSON(data, false, v)
+}
+
 // ValidateBSON validates a BSON document.
 func ValidateBSON(data []byte) error {
 	if len(data) < 5 {
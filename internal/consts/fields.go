@@ -3,4 +3,6 @@ package consts
 const (
 	// DocumentFieldID is the field name for the document ID.
 	DocumentFieldID = "_id"
+	// DocumentFieldVersion is the field name for the version of a document of a versioned collection.
+	DocumentFieldVersion = "_version"
 )
@@ -13,4 +13,12 @@ var (
 	MetadataDatabaseKeyStringFormat   = "meta/dbs/%s"
 	MetadataCollectionKeyPathmatcher  = pathmatcher.NewPath("meta/dbs/{db}/colls/{collection}")
 	MetadataCollectionKeyStringFormat = "meta/dbs/%s/colls/%s"
+	ChangeKeyPathmatcher              = pathmatcher.NewPath("meta/dbs/{db}/changes/{seq}")
+	ChangeKeyStringFormat             = "meta/dbs/%s/changes/%020d"
+	ChangesKeyStringFormat            = "meta/dbs/%s/changes/"
+	OplogKeyPathmatcher               = pathmatcher.NewPath("meta/dbs/{db}/oplog/{ts}")
+	OplogKeyStringFormat              = "meta/dbs/%s/oplog/%020d"
+	OplogEntriesKeyStringFormat       = "meta/dbs/%s/oplog/"
+	OplogTrimmedKeyStringFormat       = "meta/dbs/%s/oplogTrimmed"
+	ReplicationKeyStringFormat        = "meta/dbs/%s/replication"
 )
@@ -0,0 +1,204 @@
+// Package fieldcrypt encrypts and decrypts individual document values.
+//
+// Encrypted values are stored as BSON binaries of subtype 6, the subtype MongoDB uses for
+// client-side field level encryption. The payload layout is:
+//
+//	version (1 byte) | algorithm (1 byte) | key name length (1 byte) | key name | nonce (12 bytes) | ciphertext
+//
+// The plaintext is the BSON document {"v": value}, so the value keeps its type.
+//
+// Two algorithms are supported, both AES-256-GCM:
+//   - Deterministic derives the nonce from an HMAC-SHA256 of the field and the plaintext, so equal
+//     values encrypt to equal ciphertexts and can be compared and indexed.
+//   - Random uses a random nonce; values cannot be queried.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Algorithm is an encryption algorithm.
+type Algorithm byte
+
+const (
+	// Deterministic encrypts equal values to equal ciphertexts.
+	Deterministic Algorithm = 1
+	// Random encrypts every value with a random nonce.
+	Random Algorithm = 2
+)
+
+const (
+	// BinarySubtype is the BSON binary subtype of encrypted values.
+	BinarySubtype = 0x06
+	// KeySize is the size in bytes of the keys.
+	KeySize = 32
+	// payloadVersion is the version of the payload layout.
+	payloadVersion = 1
+	// nonceSize is the size of the AES-GCM nonce.
+	nonceSize = 12
+	// valueField is the field that wraps the plaintext value.
+	valueField = "v"
+)
+
+var (
+	// ErrInvalidKey is returned when a key is not KeySize bytes long.
+	ErrInvalidKey = errors.New("field encryption key must be 32 bytes long")
+	// ErrInvalidPayload is returned when an encrypted value is malformed.
+	ErrInvalidPayload = errors.New("invalid encrypted payload")
+	// ErrUnknownAlgorithm is returned when an algorithm is not supported.
+	ErrUnknownAlgorithm = errors.New("unknown encryption algorithm")
+)
+
+// Cipher encrypts and decrypts values with a single key.
+type Cipher struct {
+	keyName string
+	aead    cipher.AEAD
+	macKey  []byte
+}
+
+// NewCipher creates a cipher for the named key.
+func NewCipher(keyName string, key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+
+	if len(keyName) == 0 || len(keyName) > 255 {
+		return nil, fmt.Errorf("%w: key name must be between 1 and 255 bytes long", ErrInvalidPayload)
+	}
+
+	encKey, err := hkdf.Key(sha256.New, key, nil, "gopherdb field encryption", KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	macKey, err := hkdf.Key(sha256.New, key, nil, "gopherdb field encryption nonce", KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{
+		keyName: keyName,
+		aead:    aead,
+		macKey:  macKey,
+	}, nil
+}
+
+// Encrypt encrypts the value of the given field.
+func (c *Cipher) Encrypt(field string, algorithm Algorithm, value any) (primitive.Binary, error) {
+	plaintext, err := bson.Marshal(bson.D{{Key: valueField, Value: value}})
+	if err != nil {
+		return primitive.Binary{}, fmt.Errorf("marshal value: %w", err)
+	}
+
+	header := make([]byte, 0, 3+len(c.keyName))
+	header = append(header, payloadVersion, byte(algorithm), byte(len(c.keyName)))
+	header = append(header, c.keyName...)
+
+	ad := associatedData(header, field)
+
+	nonce := make([]byte, nonceSize)
+
+	switch algorithm {
+	case Deterministic:
+		mac := hmac.New(sha256.New, c.macKey)
+		mac.Write(ad)
+		mac.Write(plaintext)
+		copy(nonce, mac.Sum(nil))
+	case Random:
+		if _, err := rand.Read(nonce); err != nil {
+			return primitive.Binary{}, err
+		}
+	default:
+		return primitive.Binary{}, ErrUnknownAlgorithm
+	}
+
+	data := make([]byte, 0, len(header)+nonceSize+len(plaintext)+c.aead.Overhead())
+	data = append(data, header...)
+	data = append(data, nonce...)
+	data = c.aead.Seal(data, nonce, plaintext, ad)
+
+	return primitive.Binary{Subtype: BinarySubtype, Data: data}, nil
+}
+
+// Decrypt decrypts an encrypted value of the given field.
+func (c *Cipher) Decrypt(field string, bin primitive.Binary) (any, error) {
+	header, nonce, ciphertext, err := splitPayload(bin)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, associatedData(header, field))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt field %s: %w", field, err)
+	}
+
+	var doc map[string]any
+	if err := bson.Unmarshal(plaintext, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal field %s: %w", field, err)
+	}
+
+	return doc[valueField], nil
+}
+
+// IsEncrypted reports whether v is an encrypted value.
+func IsEncrypted(v any) (primitive.Binary, bool) {
+	bin, ok := v.(primitive.Binary)
+	if !ok || bin.Subtype != BinarySubtype {
+		return primitive.Binary{}, false
+	}
+
+	return bin, true
+}
+
+// KeyName returns the name of the key an encrypted value was encrypted with.
+func KeyName(bin primitive.Binary) (string, error) {
+	header, _, _, err := splitPayload(bin)
+	if err != nil {
+		return "", err
+	}
+
+	return string(header[3:]), nil
+}
+
+// splitPayload splits an encrypted payload into its header, nonce and ciphertext.
+func splitPayload(bin primitive.Binary) ([]byte, []byte, []byte, error) {
+	data := bin.Data
+	if bin.Subtype != BinarySubtype || len(data) < 3 || data[0] != payloadVersion {
+		return nil, nil, nil, ErrInvalidPayload
+	}
+
+	headerLen := 3 + int(data[2])
+	if len(data) < headerLen+nonceSize {
+		return nil, nil, nil, ErrInvalidPayload
+	}
+
+	return data[:headerLen], data[headerLen : headerLen+nonceSize], data[headerLen+nonceSize:], nil
+}
+
+// associatedData binds a ciphertext to its header and field.
+func associatedData(header []byte, field string) []byte {
+	ad := make([]byte, 0, len(header)+len(field))
+	ad = append(ad, header...)
+
+	return append(ad, field...)
+}
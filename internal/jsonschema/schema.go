@@ -0,0 +1,419 @@
+// Package jsonschema validates documents against the MongoDB $jsonSchema dialect of JSON Schema.
+package jsonschema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/queryengine"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidSchema is returned when a schema uses an unknown keyword or a keyword with an invalid
+// value.
+var ErrInvalidSchema = errors.New("invalid $jsonSchema")
+
+// bsonTypes are the aliases accepted by bsonType. number matches any numeric type.
+var bsonTypes = []string{
+	"double", "string", "object", "array", "binData", "objectId", "bool", "date", "null",
+	"regex", "int", "long", "decimal", "timestamp", "number",
+}
+
+// Failure is a value of a document that does not satisfy its schema.
+type Failure struct {
+	// Path is the dotted path of the value, with the positions of array items as fields. It is
+	// empty for the document itself.
+	Path string
+	// Reason describes the keyword the value fails.
+	Reason string
+}
+
+// Schema is a compiled schema.
+type Schema struct {
+	bsonTypes            []string
+	required             []string
+	properties           map[string]*Schema
+	enum                 []any
+	minimum              *float64
+	maximum              *float64
+	pattern              *regexp.Regexp
+	items                *Schema
+	tupleItems           []*Schema
+	additionalProperties *bool
+	additionalSchema     *Schema
+}
+
+// Compile compiles a schema, the value of the $jsonSchema operator.
+func Compile(schema map[string]any) (*Schema, error) {
+	doc, _ := bson.Normalize(schema).(map[string]any)
+
+	return compile(doc, "")
+}
+
+// compile compiles the schema found at path.
+func compile(doc map[string]any, path string) (*Schema, error) {
+	s := &Schema{}
+
+	for keyword, v := range doc {
+		var err error
+
+		switch keyword {
+		case "bsonType":
+			s.bsonTypes, err = compileTypes(v)
+		case "required":
+			s.required, err = compileStrings(v)
+		case "properties":
+			s.properties, err = compileProperties(v, path)
+		case "enum":
+			list, ok := v.([]any)
+			if !ok || len(list) == 0 {
+				err = errors.New("must be a non-empty array")
+			}
+
+			s.enum = list
+		case "minimum", "maximum":
+			n, ok := toFloat64(v)
+			if !ok {
+				err = errors.New("must be a number")
+			} else if keyword == "minimum" {
+				s.minimum = &n
+			} else {
+				s.maximum = &n
+			}
+		case "pattern":
+			expr, ok := v.(string)
+			if !ok {
+				err = errors.New("must be a string")
+			} else {
+				s.pattern, err = regexp.Compile(expr)
+			}
+		case "items":
+			s.items, s.tupleItems, err = compileItems(v, path)
+		case "additionalProperties":
+			switch typed := v.(type) {
+			case bool:
+				s.additionalProperties = &typed
+			case map[string]any:
+				s.additionalSchema, err = compile(typed, join(path, keyword))
+			default:
+				err = errors.New("must be a boolean or a schema")
+			}
+		case "title", "description":
+			if _, ok := v.(string); !ok {
+				err = errors.New("must be a string")
+			}
+		default:
+			err = errors.New("is not a supported keyword")
+		}
+
+		if err != nil {
+			if errors.Is(err, ErrInvalidSchema) {
+				return nil, err
+			}
+
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSchema, join(path, keyword), err)
+		}
+	}
+
+	return s, nil
+}
+
+// compileTypes compiles the value of bsonType, a type alias or an array of them.
+func compileTypes(v any) ([]string, error) {
+	types, err := compileStrings(v)
+	if err != nil {
+		if alias, ok := v.(string); ok {
+			types = []string{alias}
+		} else {
+			return nil, errors.New("must be a string or an array of strings")
+		}
+	}
+
+	for _, t := range types {
+		if !slices.Contains(bsonTypes, t) {
+			return nil, fmt.Errorf("unknown type %q", t)
+		}
+	}
+
+	return types, nil
+}
+
+// compileStrings compiles an array of strings.
+func compileStrings(v any) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, errors.New("must be an array of strings")
+	}
+
+	out := make([]string, 0, len(list))
+
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, errors.New("must be an array of strings")
+		}
+
+		out = append(out, s)
+	}
+
+	return out, nil
+}
+
+// compileProperties compiles the schemas of the properties of an object.
+func compileProperties(v any, path string) (map[string]*Schema, error) {
+	doc, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("must be a document")
+	}
+
+	out := make(map[string]*Schema, len(doc))
+
+	for field, sub := range doc {
+		subdoc, ok := sub.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("property %s must be a schema", field)
+		}
+
+		schema, err := compile(subdoc, join(path, "properties."+field))
+		if err != nil {
+			return nil, err
+		}
+
+		out[field] = schema
+	}
+
+	return out, nil
+}
+
+// compileItems compiles the value of items, a schema for every item or an array with a schema for
+// each position.
+func compileItems(v any, path string) (*Schema, []*Schema, error) {
+	switch typed := v.(type) {
+	case map[string]any:
+		schema, err := compile(typed, join(path, "items"))
+
+		return schema, nil, err
+	case []any:
+		out := make([]*Schema, 0, len(typed))
+
+		for i, item := range typed {
+			doc, ok := item.(map[string]any)
+			if !ok {
+				return nil, nil, errors.New("must be a schema or an array of schemas")
+			}
+
+			schema, err := compile(doc, join(path, fmt.Sprintf("items.%d", i)))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			out = append(out, schema)
+		}
+
+		return nil, out, nil
+	default:
+		return nil, nil, errors.New("must be a schema or an array of schemas")
+	}
+}
+
+// Validate validates a document and returns the values that fail the schema, sorted by path.
+func (s *Schema) Validate(doc map[string]any) []Failure {
+	failures := s.validate(bson.Normalize(doc), "", nil)
+
+	sort.SliceStable(failures, func(i, j int) bool {
+		return failures[i].Path < failures[j].Path
+	})
+
+	return failures
+}
+
+// validate validates the value found at path, appending its failures.
+func (s *Schema) validate(v any, path string, failures []Failure) []Failure {
+	fail := func(format string, args ...any) {
+		failures = append(failures, Failure{Path: path, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.bsonTypes) > 0 && !slices.ContainsFunc(s.bsonTypes, func(t string) bool { return hasType(v, t) }) {
+		fail("type %s is not %s", typeOf(v), strings.Join(s.bsonTypes, " or "))
+
+		return failures
+	}
+
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(item any) bool { return queryengine.Compare(v, item) == 0 }) {
+		fail("value %v is not one of the enum values", v)
+	}
+
+	if n, ok := toFloat64(v); ok {
+		if s.minimum != nil && n < *s.minimum {
+			fail("value %v is less than the minimum %v", v, *s.minimum)
+		}
+
+		if s.maximum != nil && n > *s.maximum {
+			fail("value %v is greater than the maximum %v", v, *s.maximum)
+		}
+	}
+
+	if str, ok := v.(string); ok && s.pattern != nil && !s.pattern.MatchString(str) {
+		fail("value %q does not match the pattern %s", str, s.pattern)
+	}
+
+	switch typed := v.(type) {
+	case map[string]any:
+		failures = s.validateObject(typed, path, failures)
+	case []any:
+		for i, item := range typed {
+			itemPath := join(path, fmt.Sprint(i))
+
+			switch {
+			case s.items != nil:
+				failures = s.items.validate(item, itemPath, failures)
+			case i < len(s.tupleItems):
+				failures = s.tupleItems[i].validate(item, itemPath, failures)
+			}
+		}
+	}
+
+	return failures
+}
+
+// validateObject validates the fields of an object found at path.
+func (s *Schema) validateObject(doc map[string]any, path string, failures []Failure) []Failure {
+	for _, field := range s.required {
+		if _, ok := doc[field]; !ok {
+			failures = append(failures, Failure{Path: join(path, field), Reason: "field is required"})
+		}
+	}
+
+	fields := make([]string, 0, len(doc))
+	for field := range doc {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		fieldPath := join(path, field)
+
+		if schema, ok := s.properties[field]; ok {
+			failures = schema.validate(doc[field], fieldPath, failures)
+
+			continue
+		}
+
+		switch {
+		case s.additionalSchema != nil:
+			failures = s.additionalSchema.validate(doc[field], fieldPath, failures)
+		case s.additionalProperties != nil && !*s.additionalProperties:
+			failures = append(failures, Failure{Path: fieldPath, Reason: "additional field is not allowed"})
+		}
+	}
+
+	return failures
+}
+
+// hasType reports whether a value is of a bsonType alias.
+func hasType(v any, alias string) bool {
+	if alias == "number" {
+		return slices.Contains([]string{"double", "int", "long", "decimal"}, typeOf(v))
+	}
+
+	return typeOf(v) == alias
+}
+
+// typeOf returns the bsonType alias of a value.
+func typeOf(v any) string {
+	switch typed := v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return "null"
+	case float32, float64:
+		return "double"
+	case string, primitive.Symbol:
+		return "string"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case primitive.Binary, []byte:
+		return "binData"
+	case primitive.ObjectID:
+		return "objectId"
+	case bool:
+		return "bool"
+	case time.Time, primitive.DateTime:
+		return "date"
+	case primitive.Regex:
+		return "regex"
+	case int8, int16, int32, uint8, uint16:
+		return "int"
+	case int:
+		if typed >= math.MinInt32 && typed <= math.MaxInt32 {
+			return "int"
+		}
+
+		return "long"
+	case int64, uint, uint32, uint64:
+		return "long"
+	case primitive.Decimal128:
+		return "decimal"
+	case primitive.Timestamp:
+		return "timestamp"
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// toFloat64 converts a numeric value to a float64.
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// join joins a field to a dotted path.
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+
+	return path + "." + field
+}
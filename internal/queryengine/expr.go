@@ -1,7 +1,14 @@
 package queryengine
 
 import (
+	"bytes"
+	"cmp"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Expr is a query expression.
@@ -50,7 +57,17 @@ type ComparisonExpr struct {
 
 // Evaluate evaluates the expression.
 func (c ComparisonExpr) Evaluate(doc map[string]any) bool {
-	val, ok := doc[c.Field]
+	val, ok := lookupField(doc, c.Field)
+
+	switch c.Operator {
+	case OperatorExists:
+		return ok == truthy(c.Value)
+	case OperatorNotEqual:
+		return !ok || compare(val, c.Value) != 0
+	case OperatorNotIn:
+		return !ok || !in(val, c.Value)
+	}
+
 	if !ok {
 		return false
 	}
@@ -58,8 +75,6 @@ func (c ComparisonExpr) Evaluate(doc map[string]any) bool {
 	switch c.Operator {
 	case OperatorEqual:
 		return compare(val, c.Value) == 0
-	case OperatorNotEqual:
-		return compare(val, c.Value) != 0
 	case OperatorGreaterThan:
 		return compare(val, c.Value) > 0
 	case OperatorLessThan:
@@ -68,46 +83,204 @@ func (c ComparisonExpr) Evaluate(doc map[string]any) bool {
 		return compare(val, c.Value) >= 0
 	case OperatorLessThanOrEqual:
 		return compare(val, c.Value) <= 0
-	case OperatorExists:
-		exists := val != nil
-
-		return exists == c.Value
 	case OperatorIn:
-		list, ok := c.Value.([]any)
-		if !ok {
-			return false
-		}
+		return in(val, c.Value)
+	default:
+		return false
+	}
+}
 
-		for _, item := range list {
-			if compare(val, item) == 0 {
-				return true
-			}
+// in reports whether the value is equal to an item of the list.
+func in(val, list any) bool {
+	items, ok := toSlice(list)
+	if !ok {
+		return false
+	}
+
+	for _, item := range items {
+		if compare(val, item) == 0 {
+			return true
 		}
+	}
+
+	return false
+}
 
+// truthy reports whether a value counts as true, as MongoDB does for $exists.
+func truthy(v any) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case nil:
 		return false
 	default:
-		return false
+		if f, ok := toFloat64(v); ok {
+			return f != 0
+		}
+
+		return true
+	}
+}
+
+// Compare compares two values following the BSON comparison order. It returns a negative number
+// when a sorts before b, zero when they are equal and a positive number otherwise.
+func Compare(a, b any) int {
+	return compare(a, b)
+}
+
+// lookupField returns the value of a field, following dot notation into embedded documents.
+func lookupField(doc map[string]any, field string) (any, bool) {
+	if val, ok := doc[field]; ok {
+		return val, true
+	}
+
+	head, rest, found := strings.Cut(field, ".")
+	if !found {
+		return nil, false
+	}
+
+	switch sub := doc[head].(type) {
+	case map[string]any:
+		return lookupField(sub, rest)
+	case primitive.M:
+		return lookupField(sub, rest)
+	case primitive.D:
+		m := make(map[string]any, len(sub))
+		for _, e := range sub {
+			m[e.Key] = e.Value
+		}
+
+		return lookupField(m, rest)
+	default:
+		return nil, false
 	}
 }
 
-// compare compares two values.
+// compare compares two values following the BSON comparison order: values of different types
+// are ordered by type and numbers of any type are compared by value.
 func compare(a, b any) int {
 	af, aok := toFloat64(a)
 	bf, bok := toFloat64(b)
 
 	if aok && bok {
-		if af < bf {
-			return -1
-		} else if af > bf {
-			return 1
-		}
+		return cmp.Compare(af, bf)
+	}
+
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return cmp.Compare(ta, tb)
+	}
 
+	switch av := a.(type) {
+	case nil:
 		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case bool:
+		return cmp.Compare(boolToInt(av), boolToInt(b.(bool)))
+	case primitive.Binary:
+		bv := b.(primitive.Binary)
+		if c := cmp.Compare(av.Subtype, bv.Subtype); c != 0 {
+			return c
+		}
+
+		return bytes.Compare(av.Data, bv.Data)
+	case []byte:
+		return bytes.Compare(av, b.([]byte))
+	case primitive.ObjectID:
+		bv := b.(primitive.ObjectID)
+
+		return bytes.Compare(av[:], bv[:])
+	case time.Time, primitive.DateTime:
+		at, _ := toTime(a)
+		bt, _ := toTime(b)
+
+		return at.Compare(bt)
+	default:
+		if reflect.DeepEqual(a, b) {
+			return 0
+		}
+
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
+
+// typeOrder returns the position of the type of v in the BSON comparison order.
+func typeOrder(v any) int {
+	if _, ok := toFloat64(v); ok {
+		return 3
+	}
+
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 2
+	case string, primitive.Symbol:
+		return 4
+	case map[string]any, primitive.M, primitive.D:
+		return 5
+	case []any, primitive.A:
+		return 6
+	case primitive.Binary, []byte:
+		return 7
+	case primitive.ObjectID:
+		return 8
+	case bool:
+		return 9
+	case time.Time, primitive.DateTime:
+		return 10
+	case primitive.Timestamp:
+		return 11
+	case primitive.Regex:
+		return 12
+	default:
+		return 13
+	}
+}
+
+// boolToInt converts a bool to an int.
+func boolToInt(b bool) int {
+	if b {
+		return 1
 	}
 
 	return 0
 }
 
+// toTime converts a date value to a time.Time.
+func toTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case primitive.DateTime:
+		return t.Time(), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// toSlice converts any slice or array value to a []any.
+func toSlice(v any) ([]any, bool) {
+	if list, ok := v.([]any); ok {
+		return list, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	if _, isBytes := v.([]byte); isBytes {
+		return nil, false
+	}
+
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+
+	return list, true
+}
+
 // toFloat64 converts a value to a float64.
 func toFloat64(v any) (float64, bool) {
 	switch n := v.(type) {
@@ -121,6 +294,16 @@ func toFloat64(v any) (float64, bool) {
 		return float64(n), true
 	case int64:
 		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
 	case float64:
 		return n, true
 	case float32:
@@ -21,6 +21,8 @@ const (
 	OperatorLessThanOrEqual Operator = "$lte"
 	// OperatorIn is the in operator.
 	OperatorIn Operator = "$in"
+	// OperatorNotIn is the not in operator.
+	OperatorNotIn Operator = "$nin"
 	// OperatorExists is the exists operator.
 	OperatorExists Operator = "$exists"
 	// OperatorType is the type operator.
@@ -35,7 +37,7 @@ func (o Operator) IsValid() bool {
 	switch o {
 	case OperatorEqual, OperatorNotEqual, OperatorGreaterThan,
 		OperatorLessThan, OperatorGreaterThanOrEqual, OperatorLessThanOrEqual,
-		OperatorIn, OperatorExists, OperatorType:
+		OperatorIn, OperatorNotIn, OperatorExists, OperatorType:
 		return true
 	default:
 		return false
@@ -2,9 +2,9 @@ package storage
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/dgraph-io/badger/v4"
 	"github.com/dgraph-io/ristretto/v2/z"
@@ -14,33 +14,47 @@ import (
 // badgerEngine is an implementation of the StorageEngine interface that uses BadgerDB as the underlying storage engine.
 type badgerEngine struct {
 	db *badger.DB
+	// keep is the number of versions of each key that badger keeps when it compacts.
+	keep int
+	// mu guards retainedTs.
+	mu sync.Mutex
+	// retainedTs is the timestamp of the oldest commit whose visible versions are all kept.
+	retainedTs uint64
 }
 
-// newBadgerEngine creates a new BadgerDB-based storage engine at the given path.
-func newBadgerEngine(path string) (*badgerEngine, error) {
-	if err := os.MkdirAll(path, P0755); err != nil {
+// newBadgerEngine creates a new BadgerDB-based storage engine for the given configuration.
+func newBadgerEngine(cfg Config) (*badgerEngine, error) {
+	opts, err := newBadgerOptions(cfg)
+	if err != nil {
 		return nil, err
 	}
 
-	opts := badger.DefaultOptions(path).
-		WithValueLogFileSize(128 << 20)
-	opts.Logger = nil
+	if !opts.InMemory {
+		if err := os.MkdirAll(cfg.Path, P0755); err != nil {
+			return nil, err
+		}
+	}
 
 	db, err := badger.Open(opts)
 	if err != nil {
-		return nil, err
+		return nil, translateBadgerError(err)
 	}
 
 	if db.IsClosed() {
 		return nil, ErrDatabaseClosed
 	}
 
-	return &badgerEngine{db: db}, nil
+	e := &badgerEngine{db: db, keep: opts.NumVersionsToKeep}
+
+	// Las compactaciones anteriores pudieron descartar versiones de cualquier commit previo
+	e.retainedTs = e.CommitTs()
+
+	return e, nil
 }
 
 // BeginTx starts a new transaction.
 func (e *badgerEngine) BeginTx() Transaction {
-	return newBadgerTransaction(e.db.NewTransaction(true))
+	return newBadgerTransaction(e, e.db.NewTransaction(true))
 }
 
 // Put inserts a key-value pair into the storage engine.
@@ -49,13 +63,24 @@ func (e *badgerEngine) Put(key string, value []byte) error {
 		value = []byte{}
 	}
 
-	return e.db.Update(func(txn *badger.Txn) error {
+	err := e.db.Update(func(txn *badger.Txn) error {
 		return txn.Set([]byte(key), value)
 	})
+	if err != nil {
+		return translateBadgerError(err)
+	}
+
+	e.retain([]string{key})
+
+	return nil
 }
 
 // Stream streams the database for all keys that match the prefix.
 func (e *badgerEngine) Stream(ctx context.Context, prefix string, yield func(key string, value []byte) error) error {
+	// Orchestrate may report the cancellation it triggers instead of the error returned by Send,
+	// so the error returned by yield is kept aside.
+	var yieldErr error
+
 	stream := e.db.NewStream()
 	stream.Prefix = []byte(prefix)
 	stream.Send = func(buf *z.Buffer) error {
@@ -66,7 +91,13 @@ func (e *badgerEngine) Stream(ctx context.Context, prefix string, yield func(key
 			}
 
 			for _, kv := range kvList.Kv {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
 				if err := yield(string(kv.Key), kv.Value); err != nil {
+					yieldErr = err
+
 					return err
 				}
 			}
@@ -78,6 +109,10 @@ func (e *badgerEngine) Stream(ctx context.Context, prefix string, yield func(key
 	}
 
 	if err := stream.Orchestrate(ctx); err != nil {
+		if yieldErr != nil {
+			err = yieldErr
+		}
+
 		return fmt.Errorf("stream execution failed: %w", err)
 	}
 
@@ -91,11 +126,7 @@ func (e *badgerEngine) Get(key string) ([]byte, error) {
 	err := e.db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte(key))
 		if err != nil {
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				return ErrKeyNotFound
-			}
-
-			return err
+			return translateBadgerError(err)
 		}
 
 		value, err = item.ValueCopy(nil)
@@ -115,13 +146,44 @@ func (e *badgerEngine) Get(key string) ([]byte, error) {
 
 // Delete removes a key-value pair from the storage engine.
 func (e *badgerEngine) Delete(key string) error {
-	return e.db.Update(func(txn *badger.Txn) error {
+	err := e.db.Update(func(txn *badger.Txn) error {
 		return txn.Delete([]byte(key))
 	})
+	if err != nil {
+		return translateBadgerError(err)
+	}
+
+	e.retain([]string{key})
+
+	return nil
+}
+
+// DropPrefix deletes every key that matches the given prefix. Badger drops the key range from its
+// tables directly, which is much faster than deleting key by key; writes are blocked meanwhile.
+func (e *badgerEngine) DropPrefix(prefix string) error {
+	if err := e.db.DropPrefix([]byte(prefix)); err != nil {
+		return translateBadgerError(err)
+	}
+
+	// Las versiones de las claves se descartan todas, sin dejar rastro de sus commits
+	e.raiseRetainedTs(e.CommitTs())
+
+	return nil
+}
+
+// Size returns the size in bytes of the LSM tree and of the value log, as last computed by Badger.
+func (e *badgerEngine) Size() (lsm, vlog int64) {
+	return e.db.Size()
 }
 
 // Scan scans the storage engine for all keys that match the given prefix.
 func (e *badgerEngine) Scan(prefix string) ([]KV, error) {
+	return e.ScanContext(context.Background(), prefix)
+}
+
+// ScanContext scans the storage engine for all keys that match the given prefix until the context
+// is done.
+func (e *badgerEngine) ScanContext(ctx context.Context, prefix string) ([]KV, error) {
 	results := make([]KV, 0)
 
 	opts := badger.DefaultIteratorOptions
@@ -131,6 +193,10 @@ func (e *badgerEngine) Scan(prefix string) ([]KV, error) {
 		defer it.Close()
 
 		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			if err := checkScan(ctx, len(results)); err != nil {
+				return err
+			}
+
 			item := it.Item()
 			k := item.Key()
 			err := item.Value(func(v []byte) error {
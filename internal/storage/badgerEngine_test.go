@@ -0,0 +1,57 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/internal/storage/storagetest"
+)
+
+func TestBadgerEngineConformance(t *testing.T) {
+	results := storagetest.Run(func() (storage.Storage, error) {
+		return storage.NewStorage(t.TempDir())
+	})
+
+	checkResults(t, results)
+}
+
+func TestBadgerEngineReadAtBeforeOpen(t *testing.T) {
+	path := t.TempDir()
+
+	s, err := storage.NewStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := s.(storage.Historian).CommitTs()
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = storage.NewStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	// Una compactación anterior a la apertura pudo descartar versiones de ese commit
+	if _, err := s.(storage.Historian).ReadAt(ts - 1); !errors.Is(err, storage.ErrSnapshotTooOld) {
+		t.Fatalf("read at %d before the open: got error %v, want %v", ts-1, err, storage.ErrSnapshotTooOld)
+	}
+
+	r, err := s.(storage.Historian).ReadAt(ts)
+	if err != nil {
+		t.Fatalf("read at the latest commit: %v", err)
+	}
+
+	if got, err := r.Get("a"); err != nil || string(got) != "1" {
+		t.Fatalf("get: got %q, %v, want 1", got, err)
+	}
+}
@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// badgerSnapshot reads the keys of a badger engine as they were after a past commit. Badger keeps
+// the versions of every key until a compaction discards the ones beyond NumVersionsToKeep and
+// those older than a deletion; the snapshot fails with ErrSnapshotTooOld once a compaction may
+// discard a version visible at its timestamp.
+type badgerSnapshot struct {
+	e  *badgerEngine
+	ts uint64
+}
+
+// CommitTs returns the timestamp of the latest commit.
+func (e *badgerEngine) CommitTs() uint64 {
+	txn := e.db.NewTransaction(false)
+	defer txn.Discard()
+
+	return txn.ReadTs()
+}
+
+// ReadAt returns a reader of the keys as they were after the commit with timestamp ts.
+func (e *badgerEngine) ReadAt(ts uint64) (Reader, error) {
+	s := &badgerSnapshot{e: e, ts: ts}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// History returns the kept versions of a key, newest first.
+func (e *badgerEngine) History(key string) ([]Version, error) {
+	versions := make([]Version, 0)
+
+	err := e.db.View(func(txn *badger.Txn) error {
+		return iterateVersions(txn, []byte(key), func(item *badger.Item) error {
+			if !bytes.Equal(item.Key(), []byte(key)) {
+				return nil
+			}
+
+			v := Version{Ts: item.Version(), Deleted: item.IsDeletedOrExpired()}
+
+			if !v.Deleted {
+				var err error
+
+				v.Value, err = item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+			}
+
+			versions = append(versions, v)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, translateBadgerError(err)
+	}
+
+	return versions, nil
+}
+
+// Get returns the value of a key at the timestamp of the snapshot.
+func (s *badgerSnapshot) Get(key string) ([]byte, error) {
+	var (
+		value []byte
+		found bool
+	)
+
+	err := s.e.db.View(func(txn *badger.Txn) error {
+		return s.ascend(txn, key, func(item *badger.Item) error {
+			if !bytes.Equal(item.Key(), []byte(key)) {
+				return nil
+			}
+
+			var err error
+
+			value, err = item.ValueCopy([]byte{})
+			found = true
+
+			return err
+		})
+	})
+	if err != nil {
+		return nil, translateBadgerError(err)
+	}
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+
+	return value, nil
+}
+
+// Scan scans the keys that match the prefix at the timestamp of the snapshot.
+func (s *badgerSnapshot) Scan(prefix string) ([]KV, error) {
+	return s.ScanContext(context.Background(), prefix)
+}
+
+// ScanContext scans the keys that match the prefix at the timestamp of the snapshot until the
+// context is done.
+func (s *badgerSnapshot) ScanContext(ctx context.Context, prefix string) ([]KV, error) {
+	results := make([]KV, 0)
+
+	err := s.e.db.View(func(txn *badger.Txn) error {
+		return s.ascend(txn, prefix, func(item *badger.Item) error {
+			if err := checkScan(ctx, len(results)); err != nil {
+				return err
+			}
+
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			results = append(results, KV{Key: string(item.Key()), Value: value})
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, translateBadgerError(err)
+	}
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ScanKeys scans the keys that match the prefix at the timestamp of the snapshot.
+func (s *badgerSnapshot) ScanKeys(prefix string) ([]string, error) {
+	results := make([]string, 0)
+
+	err := s.e.db.View(func(txn *badger.Txn) error {
+		return s.ascend(txn, prefix, func(item *badger.Item) error {
+			results = append(results, string(item.Key()))
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, translateBadgerError(err)
+	}
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// check fails with ErrSnapshotTooOld when a compaction may have discarded a version visible at the
+// timestamp of the snapshot. The reads check after reading, since the versions they read were
+// still kept if none of them could be discarded yet.
+func (s *badgerSnapshot) check() error {
+	if retainedTs := s.e.retained(); s.ts < retainedTs {
+		return snapshotTooOld(s.ts, retainedTs)
+	}
+
+	return nil
+}
+
+// ascend calls fn with the version visible at the timestamp of the snapshot of each key that
+// matches the prefix and was not deleted by then.
+func (s *badgerSnapshot) ascend(txn *badger.Txn, prefix string, fn func(item *badger.Item) error) error {
+	var last []byte
+
+	return iterateVersions(txn, []byte(prefix), func(item *badger.Item) error {
+		// Las versiones de una clave llegan de la más nueva a la más antigua
+		if item.Version() > s.ts || (last != nil && bytes.Equal(item.Key(), last)) {
+			return nil
+		}
+
+		last = item.KeyCopy(last)
+
+		if item.IsDeletedOrExpired() {
+			return nil
+		}
+
+		return fn(item)
+	})
+}
+
+// iterateVersions calls fn with every kept version of the keys that match the prefix, including
+// deletions, in key order and newest first for each key.
+func iterateVersions(txn *badger.Txn, prefix []byte, fn func(item *badger.Item) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.AllVersions = true
+	opts.Prefix = prefix
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := fn(it.Item()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retained returns the timestamp of the oldest commit whose visible versions are all kept.
+func (e *badgerEngine) retained() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.retainedTs
+}
+
+// raiseRetainedTs moves the oldest commit whose visible versions are all kept up to ts.
+func (e *badgerEngine) raiseRetainedTs(ts uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.retainedTs = max(e.retainedTs, ts)
+}
+
+// retain moves the oldest commit whose visible versions are all kept past the versions of the
+// keys that a compaction may discard after a write. When the versions cannot be read, no earlier
+// commit is readable anymore.
+func (e *badgerEngine) retain(keys []string) {
+	var ts uint64
+
+	err := e.db.View(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			oldest, err := oldestKept(txn, []byte(key), e.keep)
+			if err != nil {
+				return err
+			}
+
+			ts = max(ts, oldest)
+		}
+
+		return nil
+	})
+	if err != nil {
+		ts = e.CommitTs()
+	}
+
+	e.raiseRetainedTs(ts)
+}
+
+// oldestKept returns the timestamp of the oldest version of a key that a compaction keeps, or 0 if
+// it keeps them all. Badger keeps the newest keep versions, and none older than a deletion.
+func oldestKept(txn *badger.Txn, key []byte, keep int) (uint64, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.AllVersions = true
+	opts.PrefetchValues = false
+	opts.Prefix = key
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var (
+		kept   int
+		oldest uint64
+	)
+
+	for it.Seek(key); it.ValidForPrefix(key); it.Next() {
+		item := it.Item()
+		if !bytes.Equal(item.Key(), key) {
+			break
+		}
+
+		// Hay una versión más antigua que la última que se conserva
+		if oldest != 0 {
+			return oldest, nil
+		}
+
+		kept++
+
+		if item.IsDeletedOrExpired() || kept == keep {
+			oldest = item.Version()
+		}
+	}
+
+	return 0, nil
+}
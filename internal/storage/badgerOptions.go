@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	badgeroptions "github.com/dgraph-io/badger/v4/options"
+)
+
+// Engine-specific options understood by the badger engine.
+const (
+	// OptionInMemory keeps all badger data in memory (bool).
+	OptionInMemory = "inMemory"
+	// OptionSyncWrites syncs every write to disk before returning (bool).
+	OptionSyncWrites = "syncWrites"
+	// OptionCompression sets the block compression: "none", "snappy" or "zstd" (string).
+	OptionCompression = "compression"
+	// OptionBlockCacheSize sets the block cache size in bytes (int64).
+	OptionBlockCacheSize = "blockCacheSize"
+	// OptionIndexCacheSize sets the index cache size in bytes (int64).
+	OptionIndexCacheSize = "indexCacheSize"
+	// OptionValueThreshold sets the size above which values are kept in the value log (int64).
+	OptionValueThreshold = "valueThreshold"
+	// OptionValueLogFileSize sets the maximum size of a value log file in bytes (int64).
+	OptionValueLogFileSize = "valueLogFileSize"
+	// OptionMemTableSize sets the memtable size in bytes (int64).
+	OptionMemTableSize = "memTableSize"
+	// OptionReadOnly opens the database in read-only mode (bool).
+	OptionReadOnly = "readOnly"
+	// OptionEncryptionKey sets the AES encryption key of 16, 24 or 32 bytes ([]byte).
+	OptionEncryptionKey = "encryptionKey"
+	// OptionEncryptionKeyRotation sets how often a new data key is generated (time.Duration).
+	OptionEncryptionKeyRotation = "encryptionKeyRotation"
+	// OptionLogger sets the logger used by the engine (Logger).
+	OptionLogger = "logger"
+	// OptionNumVersionsToKeep sets how many versions of each key are kept for the reads of past
+	// commits, 1 by default (int64). The memory engine understands it too.
+	OptionNumVersionsToKeep = "numVersionsToKeep"
+)
+
+const (
+	// CompressionNone disables block compression.
+	CompressionNone = "none"
+	// CompressionSnappy compresses blocks with Snappy.
+	CompressionSnappy = "snappy"
+	// CompressionZSTD compresses blocks with ZSTD.
+	CompressionZSTD = "zstd"
+)
+
+const (
+	// defaultValueLogFileSize is the default maximum size of a value log file.
+	defaultValueLogFileSize = 128 << 20
+	// defaultEncryptedIndexCacheSize is the index cache size used for encrypted databases.
+	defaultEncryptedIndexCacheSize = 100 << 20
+)
+
+// ErrInvalidEngineOption is returned when an engine option has an invalid value.
+var ErrInvalidEngineOption = errors.New("invalid engine option")
+
+// Logger is the logger used by the storage engines.
+type Logger interface {
+	Errorf(format string, args ...any)
+	Warningf(format string, args ...any)
+	Infof(format string, args ...any)
+	Debugf(format string, args ...any)
+}
+
+// newBadgerOptions builds the badger options for the given configuration.
+func newBadgerOptions(cfg Config) (badger.Options, error) {
+	opts := badger.DefaultOptions(cfg.Path).
+		WithValueLogFileSize(defaultValueLogFileSize)
+	opts.Logger = nil
+
+	inMemory, err := boolOption(cfg.Options, OptionInMemory)
+	if err != nil {
+		return opts, err
+	}
+
+	if inMemory {
+		opts = opts.WithInMemory(true).WithDir("").WithValueDir("")
+	}
+
+	syncWrites, err := boolOption(cfg.Options, OptionSyncWrites)
+	if err != nil {
+		return opts, err
+	}
+
+	readOnly, err := boolOption(cfg.Options, OptionReadOnly)
+	if err != nil {
+		return opts, err
+	}
+
+	opts = opts.WithSyncWrites(syncWrites).WithReadOnly(readOnly)
+
+	if v, ok := cfg.Options[OptionCompression]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return opts, fmt.Errorf("%w: %s must be a string", ErrInvalidEngineOption, OptionCompression)
+		}
+
+		switch s {
+		case CompressionNone:
+			opts = opts.WithCompression(badgeroptions.None)
+		case CompressionSnappy:
+			opts = opts.WithCompression(badgeroptions.Snappy)
+		case CompressionZSTD:
+			opts = opts.WithCompression(badgeroptions.ZSTD)
+		default:
+			return opts, fmt.Errorf("%w: unknown compression %q", ErrInvalidEngineOption, s)
+		}
+	}
+
+	sizes := []struct {
+		key   string
+		apply func(int64)
+	}{
+		{OptionBlockCacheSize, func(n int64) { opts = opts.WithBlockCacheSize(n) }},
+		{OptionIndexCacheSize, func(n int64) { opts = opts.WithIndexCacheSize(n) }},
+		{OptionValueThreshold, func(n int64) { opts = opts.WithValueThreshold(n) }},
+		{OptionValueLogFileSize, func(n int64) { opts = opts.WithValueLogFileSize(n) }},
+		{OptionMemTableSize, func(n int64) { opts = opts.WithMemTableSize(n) }},
+	}
+
+	for _, size := range sizes {
+		n, ok, err := int64Option(cfg.Options, size.key)
+		if err != nil {
+			return opts, err
+		}
+
+		if ok {
+			size.apply(n)
+		}
+	}
+
+	versions, err := numVersionsToKeepOption(cfg.Options)
+	if err != nil {
+		return opts, err
+	}
+
+	opts = opts.WithNumVersionsToKeep(versions)
+
+	if v, ok := cfg.Options[OptionEncryptionKey]; ok && v != nil {
+		key, ok := v.([]byte)
+		if !ok {
+			return opts, fmt.Errorf("%w: %s must be a []byte", ErrInvalidEngineOption, OptionEncryptionKey)
+		}
+
+		if n := len(key); n != 16 && n != 24 && n != 32 {
+			return opts, fmt.Errorf("%w: %s must be 16, 24 or 32 bytes long", ErrInvalidEngineOption, OptionEncryptionKey)
+		}
+
+		opts = opts.WithEncryptionKey(key)
+	}
+
+	if v, ok := cfg.Options[OptionEncryptionKeyRotation]; ok && v != nil {
+		d, ok := v.(time.Duration)
+		if !ok || d <= 0 {
+			return opts, fmt.Errorf("%w: %s must be a positive time.Duration", ErrInvalidEngineOption, OptionEncryptionKeyRotation)
+		}
+
+		opts = opts.WithEncryptionKeyRotationDuration(d)
+	}
+
+	if v, ok := cfg.Options[OptionLogger]; ok && v != nil {
+		logger, ok := v.(Logger)
+		if !ok {
+			return opts, fmt.Errorf("%w: %s must implement Logger", ErrInvalidEngineOption, OptionLogger)
+		}
+
+		opts = opts.WithLogger(logger)
+	}
+
+	// Badger panics when blocks or indexes must be decoded without a cache.
+	needsCache := opts.Compression != badgeroptions.None || len(opts.EncryptionKey) > 0
+	if needsCache && opts.BlockCacheSize <= 0 {
+		return opts, fmt.Errorf("%w: %s must be set when compression or encryption are enabled", ErrInvalidEngineOption, OptionBlockCacheSize)
+	}
+
+	if len(opts.EncryptionKey) > 0 && opts.IndexCacheSize <= 0 {
+		if _, ok := cfg.Options[OptionIndexCacheSize]; ok {
+			return opts, fmt.Errorf("%w: %s must be set when encryption is enabled", ErrInvalidEngineOption, OptionIndexCacheSize)
+		}
+
+		opts = opts.WithIndexCacheSize(defaultEncryptedIndexCacheSize)
+	}
+
+	return opts, nil
+}
+
+// boolOption returns the boolean option with the given key.
+func boolOption(opts map[string]any, key string) (bool, error) {
+	v, ok := opts[key]
+	if !ok || v == nil {
+		return false, nil
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: %s must be a bool", ErrInvalidEngineOption, key)
+	}
+
+	return b, nil
+}
+
+// numVersionsToKeepOption returns the number of versions of each key to keep, 1 by default.
+func numVersionsToKeepOption(opts map[string]any) (int, error) {
+	n, ok, err := int64Option(opts, OptionNumVersionsToKeep)
+	if err != nil || !ok {
+		return 1, err
+	}
+
+	if n < 1 || n > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %s must be between 1 and %d", ErrInvalidEngineOption, OptionNumVersionsToKeep, math.MaxInt32)
+	}
+
+	return int(n), nil
+}
+
+// int64Option returns the integer option with the given key.
+func int64Option(opts map[string]any, key string) (int64, bool, error) {
+	v, ok := opts[key]
+	if !ok || v == nil {
+		return 0, false, nil
+	}
+
+	switch n := v.(type) {
+	case int:
+		return int64(n), true, nil
+	case int32:
+		return int64(n), true, nil
+	case int64:
+		return n, true, nil
+	case uint32:
+		return int64(n), true, nil
+	default:
+		return 0, false, fmt.Errorf("%w: %s must be an integer", ErrInvalidEngineOption, key)
+	}
+}
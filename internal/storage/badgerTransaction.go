@@ -1,30 +1,29 @@
 package storage
 
 import (
-	"errors"
+	"context"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
 // badgerTransaction is a transaction for the badger storage engine.
 type badgerTransaction struct {
+	e   *badgerEngine
 	txn *badger.Txn
+	// keys are the keys written by the transaction.
+	keys []string
 }
 
-// newBadgerTransaction creates a new badger transaction.
-func newBadgerTransaction(txn *badger.Txn) *badgerTransaction {
-	return &badgerTransaction{txn: txn}
+// newBadgerTransaction creates a new badger transaction of the engine.
+func newBadgerTransaction(e *badgerEngine, txn *badger.Txn) *badgerTransaction {
+	return &badgerTransaction{e: e, txn: txn}
 }
 
 // Get returns the value for a given key.
 func (t *badgerTransaction) Get(key string) ([]byte, error) {
 	item, err := t.txn.Get([]byte(key))
 	if err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil, ErrKeyNotFound
-		}
-
-		return nil, err
+		return nil, translateBadgerError(err)
 	}
 
 	value, err := item.ValueCopy(nil)
@@ -37,16 +36,33 @@ func (t *badgerTransaction) Get(key string) ([]byte, error) {
 
 // Put sets the value for a given key.
 func (t *badgerTransaction) Put(key string, value []byte) error {
-	return t.txn.Set([]byte(key), value)
+	if err := t.txn.Set([]byte(key), value); err != nil {
+		return translateBadgerError(err)
+	}
+
+	t.keys = append(t.keys, key)
+
+	return nil
 }
 
 // Delete deletes the value for a given key.
 func (t *badgerTransaction) Delete(key string) error {
-	return t.txn.Delete([]byte(key))
+	if err := t.txn.Delete([]byte(key)); err != nil {
+		return translateBadgerError(err)
+	}
+
+	t.keys = append(t.keys, key)
+
+	return nil
 }
 
 // Scan scans the database for all keys that match the prefix.
 func (t *badgerTransaction) Scan(prefix string) ([]KV, error) {
+	return t.ScanContext(context.Background(), prefix)
+}
+
+// ScanContext scans the database for all keys that match the prefix until the context is done.
+func (t *badgerTransaction) ScanContext(ctx context.Context, prefix string) ([]KV, error) {
 	results := make([]KV, 0)
 
 	opts := badger.DefaultIteratorOptions
@@ -55,6 +71,10 @@ func (t *badgerTransaction) Scan(prefix string) ([]KV, error) {
 	defer it.Close()
 
 	for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+		if err := checkScan(ctx, len(results)); err != nil {
+			return nil, err
+		}
+
 		item := it.Item()
 		k := item.Key()
 		err := item.Value(func(v []byte) error {
@@ -95,7 +115,15 @@ func (t *badgerTransaction) ScanKeys(prefix string) ([]string, error) {
 
 // Commit commits the current transaction.
 func (t *badgerTransaction) Commit() error {
-	return t.txn.Commit()
+	if err := t.txn.Commit(); err != nil {
+		return translateBadgerError(err)
+	}
+
+	if len(t.keys) > 0 {
+		t.e.retain(t.keys)
+	}
+
+	return nil
 }
 
 // Rollback rolls back the current transaction.
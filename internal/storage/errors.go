@@ -1,10 +1,55 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
 
 var (
 	// ErrKeyNotFound is returned when a key is not found in the storage.
 	ErrKeyNotFound = errors.New("key not found")
 	// ErrDatabaseClosed is returned when a database is closed.
 	ErrDatabaseClosed = errors.New("database is closed")
+	// ErrConflict is returned when a transaction conflicts with another committed transaction.
+	ErrConflict = errors.New("transaction conflict, please retry")
+	// ErrTransactionDiscarded is returned when a transaction is used after commit or rollback.
+	ErrTransactionDiscarded = errors.New("transaction has been discarded")
+	// ErrEmptyKey is returned when an empty key is written.
+	ErrEmptyKey = errors.New("key cannot be empty")
+	// ErrReadOnly is returned when writing to a storage opened in read-only mode.
+	ErrReadOnly = errors.New("storage is read-only")
+	// ErrSnapshotTooOld is returned when reading at a timestamp whose versions may no longer be kept.
+	ErrSnapshotTooOld = errors.New("snapshot is older than the kept versions")
 )
+
+// snapshotTooOld returns ErrSnapshotTooOld for a read at ts of an engine that keeps every version
+// only from the commit with timestamp retainedTs on.
+func snapshotTooOld(ts, retainedTs uint64) error {
+	return fmt.Errorf("%w: %d is before %d", ErrSnapshotTooOld, ts, retainedTs)
+}
+
+// translateBadgerError maps badger errors to storage errors.
+func translateBadgerError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, badger.ErrKeyNotFound):
+		return ErrKeyNotFound
+	case errors.Is(err, badger.ErrConflict):
+		return ErrConflict
+	case errors.Is(err, badger.ErrDiscardedTxn):
+		return ErrTransactionDiscarded
+	case errors.Is(err, badger.ErrEmptyKey):
+		return ErrEmptyKey
+	case errors.Is(err, badger.ErrReadOnlyTxn):
+		return ErrReadOnly
+	case errors.Is(err, badger.ErrEncryptionKeyMismatch):
+		return ErrEncryptionKeyMismatch
+	case errors.Is(err, badger.ErrDBClosed):
+		return ErrDatabaseClosed
+	default:
+		return err
+	}
+}
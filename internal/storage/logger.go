@@ -2,20 +2,25 @@ package storage
 
 import "log"
 
+// BadgerLogger is a Logger that writes to the standard logger.
 type BadgerLogger struct{}
 
+// Errorf logs an error message.
 func (l *BadgerLogger) Errorf(format string, args ...interface{}) {
-	log.Printf(format, args...)
+	log.Printf("ERROR: "+format, args...)
 }
 
+// Warningf logs a warning message.
 func (l *BadgerLogger) Warningf(format string, args ...interface{}) {
-	log.Printf(format, args...)
+	log.Printf("WARNING: "+format, args...)
 }
 
+// Infof logs an informational message.
 func (l *BadgerLogger) Infof(format string, args ...interface{}) {
-	log.Printf(format, args...)
+	log.Printf("INFO: "+format, args...)
 }
 
+// Debugf logs a debug message.
 func (l *BadgerLogger) Debugf(format string, args ...interface{}) {
-	log.Printf(format, args...)
+	log.Printf("DEBUG: "+format, args...)
 }
@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// memoryEngine is an implementation of the Storage interface that keeps every key in an ordered
+// in-memory skiplist. Transactions are snapshot isolated and conflicts are detected at commit time.
+type memoryEngine struct {
+	mu      sync.RWMutex
+	list    *skiplist
+	ts      uint64
+	readers map[uint64]int
+	closed  bool
+	// keep is the number of versions of each key kept besides those still visible to a reader.
+	keep int
+	// retainedTs is the timestamp of the oldest commit whose visible versions are all kept.
+	retainedTs uint64
+}
+
+// newMemoryEngine creates a new in-memory storage engine.
+func newMemoryEngine() *memoryEngine {
+	return &memoryEngine{
+		list:    newSkiplist(),
+		readers: make(map[uint64]int),
+		keep:    1,
+	}
+}
+
+// newMemoryEngineFromConfig creates a new in-memory storage engine for the given configuration.
+func newMemoryEngineFromConfig(cfg Config) (*memoryEngine, error) {
+	keep, err := numVersionsToKeepOption(cfg.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newMemoryEngine()
+	e.keep = keep
+
+	return e, nil
+}
+
+// BeginTx starts a new transaction.
+func (e *memoryEngine) BeginTx() Transaction {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	readTs := e.ts
+	e.readers[readTs]++
+
+	return newMemoryTransaction(e, readTs)
+}
+
+// Put inserts a key-value pair into the storage engine.
+func (e *memoryEngine) Put(key string, value []byte) error {
+	txn := e.BeginTx()
+	if err := txn.Put(key, value); err != nil {
+		txn.Rollback()
+
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// Stream streams the database for all keys that match the prefix.
+func (e *memoryEngine) Stream(ctx context.Context, prefix string, yield func(key string, value []byte) error) error {
+	kvs, err := e.Scan(prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, kv := range kvs {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("stream execution failed: %w", err)
+		}
+
+		if err := yield(kv.Key, kv.Value); err != nil {
+			return fmt.Errorf("stream execution failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get retrieves a value from the storage engine for the given key.
+func (e *memoryEngine) Get(key string) ([]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.read(key, e.ts)
+}
+
+// Delete removes a key-value pair from the storage engine.
+func (e *memoryEngine) Delete(key string) error {
+	txn := e.BeginTx()
+	if err := txn.Delete(key); err != nil {
+		txn.Rollback()
+
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// DropPrefix deletes every key that matches the given prefix in a single commit.
+func (e *memoryEngine) DropPrefix(prefix string) error {
+	keys, err := e.ScanKeys(prefix)
+	if err != nil {
+		return err
+	}
+
+	txn := e.BeginTx()
+
+	for _, key := range keys {
+		if err := txn.Delete(key); err != nil {
+			txn.Rollback()
+
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
+
+// Scan scans the storage engine for all keys that match the given prefix.
+func (e *memoryEngine) Scan(prefix string) ([]KV, error) {
+	return e.ScanContext(context.Background(), prefix)
+}
+
+// ScanContext scans the storage engine for all keys that match the given prefix until the context
+// is done.
+func (e *memoryEngine) ScanContext(ctx context.Context, prefix string) ([]KV, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.scan(ctx, prefix, e.ts)
+}
+
+// ScanKeys scans the storage engine for all keys that match the given prefix.
+func (e *memoryEngine) ScanKeys(prefix string) ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.scanKeys(prefix, e.ts)
+}
+
+// PrintAllKeys prints all keys in the storage engine.
+func (e *memoryEngine) PrintAllKeys() error {
+	keys, err := e.ScanKeys("")
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		fmt.Println("Key:", k)
+	}
+
+	return nil
+}
+
+// Close closes the storage engine.
+func (e *memoryEngine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrDatabaseClosed
+	}
+
+	e.closed = true
+	e.list = newSkiplist()
+
+	return nil
+}
+
+// read returns the value of key visible at readTs. The caller must hold the lock.
+func (e *memoryEngine) read(key string, readTs uint64) ([]byte, error) {
+	if e.closed {
+		return nil, ErrDatabaseClosed
+	}
+
+	n := e.list.get(key)
+	if n == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	v, ok := n.visible(readTs)
+	if !ok || v.deleted {
+		return nil, ErrKeyNotFound
+	}
+
+	return copyBytes(v.value), nil
+}
+
+// scan returns the keys that match the prefix visible at readTs with their values, until the
+// context is done. The caller must hold the lock.
+func (e *memoryEngine) scan(ctx context.Context, prefix string, readTs uint64) ([]KV, error) {
+	if e.closed {
+		return nil, ErrDatabaseClosed
+	}
+
+	var err error
+
+	results := make([]KV, 0)
+
+	e.list.ascend(prefix, func(n *skiplistNode) bool {
+		if err = checkScan(ctx, len(results)); err != nil {
+			return false
+		}
+
+		if v, ok := n.visible(readTs); ok && !v.deleted {
+			results = append(results, KV{Key: n.key, Value: copyBytes(v.value)})
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// scanKeys returns the keys that match the prefix visible at readTs. The caller must hold the
+// lock.
+func (e *memoryEngine) scanKeys(prefix string, readTs uint64) ([]string, error) {
+	if e.closed {
+		return nil, ErrDatabaseClosed
+	}
+
+	results := make([]string, 0)
+
+	e.list.ascend(prefix, func(n *skiplistNode) bool {
+		if v, ok := n.visible(readTs); ok && !v.deleted {
+			results = append(results, n.key)
+		}
+
+		return true
+	})
+
+	return results, nil
+}
+
+// commit applies the pending writes of a transaction if none of the keys it read were
+// modified by a transaction committed after its snapshot.
+func (e *memoryEngine) commit(txn *memoryTransaction) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.releaseReader(txn.readTs)
+
+	if e.closed {
+		return ErrDatabaseClosed
+	}
+
+	if len(txn.writes) == 0 {
+		return nil
+	}
+
+	for key := range txn.reads {
+		if n := e.list.get(key); n != nil && n.latestTs() > txn.readTs {
+			return ErrConflict
+		}
+	}
+
+	e.ts++
+	commitTs := e.ts
+	minReadTs := e.minReadTs()
+
+	for key, w := range txn.writes {
+		n := e.list.getOrInsert(key)
+		n.versions = append(n.versions, memoryVersion{
+			ts:      commitTs,
+			value:   w.value,
+			deleted: w.deleted,
+		})
+
+		if n.prune(minReadTs, e.keep) {
+			e.retainedTs = max(e.retainedTs, n.versions[0].ts)
+		}
+
+		if len(n.versions) == 1 && n.versions[0].deleted && n.versions[0].ts <= minReadTs {
+			e.list.remove(key)
+		}
+	}
+
+	return nil
+}
+
+// discard releases the snapshot held by a transaction.
+func (e *memoryEngine) discard(txn *memoryTransaction) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.releaseReader(txn.readTs)
+}
+
+// releaseReader unregisters a reader of the given snapshot. The caller must hold the lock.
+func (e *memoryEngine) releaseReader(readTs uint64) {
+	e.readers[readTs]--
+	if e.readers[readTs] <= 0 {
+		delete(e.readers, readTs)
+	}
+}
+
+// minReadTs returns the oldest snapshot still in use. The caller must hold the lock.
+func (e *memoryEngine) minReadTs() uint64 {
+	minTs := e.ts
+
+	for ts := range e.readers {
+		if ts < minTs {
+			minTs = ts
+		}
+	}
+
+	return minTs
+}
+
+// copyBytes returns a copy of b that never aliases engine memory.
+func copyBytes(b []byte) []byte {
+	copied := make([]byte, len(b))
+	copy(copied, b)
+
+	return copied
+}
@@ -0,0 +1,281 @@
+package storage_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/internal/storage/storagetest"
+)
+
+func TestMemoryEngineConformance(t *testing.T) {
+	results := storagetest.Run(func() (storage.Storage, error) {
+		return storage.NewMemoryStorage(), nil
+	})
+
+	checkResults(t, results)
+}
+
+func TestMemoryEngineConcurrentIncrements(t *testing.T) {
+	const (
+		workers    = 8
+		increments = 200
+	)
+
+	s := storage.NewMemoryStorage()
+	defer s.Close()
+
+	if err := s.Put("counter", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, workers)
+
+	for range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range increments {
+				if err := increment(s, "counter"); err != nil {
+					errs <- err
+
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	value, err := s.Get("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := strconv.Itoa(workers * increments); string(value) != want {
+		t.Fatalf("counter = %s, want %s", value, want)
+	}
+}
+
+func TestMemoryEngineConcurrentSnapshots(t *testing.T) {
+	const (
+		accounts  = 10
+		balance   = 100
+		transfers = 300
+	)
+
+	s := storage.NewMemoryStorage()
+	defer s.Close()
+
+	for i := range accounts {
+		if err := s.Put(fmt.Sprintf("acct/%02d", i), []byte(strconv.Itoa(balance))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		writers sync.WaitGroup
+	)
+
+	errs := make(chan error, 8)
+	done := make(chan struct{})
+
+	// Los escritores mueven saldo entre cuentas e insertan y borran claves que los lectores recorren
+	for w := range 4 {
+		writers.Add(1)
+
+		go func() {
+			defer writers.Done()
+
+			for i := range transfers {
+				from := fmt.Sprintf("acct/%02d", (w+i)%accounts)
+				to := fmt.Sprintf("acct/%02d", (w+i+1)%accounts)
+
+				if err := transfer(s, from, to); err != nil {
+					errs <- err
+
+					return
+				}
+
+				scratch := fmt.Sprintf("acct/tmp/%d/%d", w, i)
+				if err := s.Put(scratch, []byte("0")); err != nil {
+					errs <- err
+
+					return
+				}
+
+				if err := s.Delete(scratch); err != nil {
+					errs <- err
+
+					return
+				}
+			}
+		}()
+	}
+
+	for range 4 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if err := checkTotal(s, accounts*balance); err != nil {
+					errs <- err
+
+					return
+				}
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(done)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if err := checkTotal(s, accounts*balance); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// increment adds one to the integer stored at key, retrying on conflicts.
+func increment(s storage.Storage, key string) error {
+	for {
+		txn := s.BeginTx()
+
+		n, err := readInt(txn, key)
+		if err != nil {
+			txn.Rollback()
+
+			return err
+		}
+
+		if err := txn.Put(key, []byte(strconv.Itoa(n+1))); err != nil {
+			txn.Rollback()
+
+			return err
+		}
+
+		err = txn.Commit()
+		if errors.Is(err, storage.ErrConflict) {
+			continue
+		}
+
+		return err
+	}
+}
+
+// transfer moves one unit from one account to another, retrying on conflicts.
+func transfer(s storage.Storage, from, to string) error {
+	for {
+		txn := s.BeginTx()
+
+		a, err := readInt(txn, from)
+		if err != nil {
+			txn.Rollback()
+
+			return err
+		}
+
+		b, err := readInt(txn, to)
+		if err != nil {
+			txn.Rollback()
+
+			return err
+		}
+
+		if err := txn.Put(from, []byte(strconv.Itoa(a-1))); err != nil {
+			txn.Rollback()
+
+			return err
+		}
+
+		if err := txn.Put(to, []byte(strconv.Itoa(b+1))); err != nil {
+			txn.Rollback()
+
+			return err
+		}
+
+		err = txn.Commit()
+		if errors.Is(err, storage.ErrConflict) {
+			continue
+		}
+
+		return err
+	}
+}
+
+// checkTotal checks that the balances of a snapshot of the accounts add up to want.
+func checkTotal(s storage.Storage, want int) error {
+	txn := s.BeginTx()
+	defer txn.Rollback()
+
+	kvs, err := txn.Scan("acct/")
+	if err != nil {
+		return err
+	}
+
+	total := 0
+
+	for _, kv := range kvs {
+		n, err := strconv.Atoi(string(kv.Value))
+		if err != nil {
+			return fmt.Errorf("%s: %w", kv.Key, err)
+		}
+
+		total += n
+	}
+
+	if total != want {
+		return fmt.Errorf("total = %d, want %d", total, want)
+	}
+
+	return nil
+}
+
+// readInt reads the integer stored at key through a transaction.
+func readInt(txn storage.Transaction, key string) (int, error) {
+	value, err := txn.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(string(value))
+}
+
+// checkResults reports each conformance result as a subtest.
+func checkResults(t *testing.T, results []storagetest.Result) {
+	t.Helper()
+
+	for _, r := range results {
+		t.Run(r.Name, func(t *testing.T) {
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+		})
+	}
+}
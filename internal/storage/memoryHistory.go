@@ -0,0 +1,112 @@
+package storage
+
+import "context"
+
+// memorySnapshot reads the keys of a memory engine as they were after a past commit. The engine
+// keeps the versions still visible to a reader and the newest ones up to its keep setting; the
+// snapshot fails with ErrSnapshotTooOld once it drops a version visible at its timestamp.
+type memorySnapshot struct {
+	e  *memoryEngine
+	ts uint64
+}
+
+// CommitTs returns the timestamp of the latest commit.
+func (e *memoryEngine) CommitTs() uint64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.ts
+}
+
+// ReadAt returns a reader of the keys as they were after the commit with timestamp ts.
+func (e *memoryEngine) ReadAt(ts uint64) (Reader, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	s := &memorySnapshot{e: e, ts: ts}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// History returns the kept versions of a key, newest first.
+func (e *memoryEngine) History(key string) ([]Version, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.closed {
+		return nil, ErrDatabaseClosed
+	}
+
+	versions := make([]Version, 0)
+
+	n := e.list.get(key)
+	if n == nil {
+		return versions, nil
+	}
+
+	for i := len(n.versions) - 1; i >= 0; i-- {
+		v := n.versions[i]
+		versions = append(versions, Version{Ts: v.ts, Deleted: v.deleted})
+
+		if !v.deleted {
+			versions[len(versions)-1].Value = copyBytes(v.value)
+		}
+	}
+
+	return versions, nil
+}
+
+// Get returns the value of a key at the timestamp of the snapshot.
+func (s *memorySnapshot) Get(key string) ([]byte, error) {
+	s.e.mu.RLock()
+	defer s.e.mu.RUnlock()
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	return s.e.read(key, s.ts)
+}
+
+// Scan scans the keys that match the prefix at the timestamp of the snapshot.
+func (s *memorySnapshot) Scan(prefix string) ([]KV, error) {
+	return s.ScanContext(context.Background(), prefix)
+}
+
+// ScanContext scans the keys that match the prefix at the timestamp of the snapshot until the
+// context is done.
+func (s *memorySnapshot) ScanContext(ctx context.Context, prefix string) ([]KV, error) {
+	s.e.mu.RLock()
+	defer s.e.mu.RUnlock()
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	return s.e.scan(ctx, prefix, s.ts)
+}
+
+// ScanKeys scans the keys that match the prefix at the timestamp of the snapshot.
+func (s *memorySnapshot) ScanKeys(prefix string) ([]string, error) {
+	s.e.mu.RLock()
+	defer s.e.mu.RUnlock()
+
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+
+	return s.e.scanKeys(prefix, s.ts)
+}
+
+// check fails with ErrSnapshotTooOld when the engine dropped a version visible at the timestamp of
+// the snapshot. The caller must hold the lock.
+func (s *memorySnapshot) check() error {
+	if s.ts < s.e.retainedTs {
+		return snapshotTooOld(s.ts, s.e.retainedTs)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// memoryWrite is a pending write of a memory transaction.
+type memoryWrite struct {
+	value   []byte
+	deleted bool
+}
+
+// memoryTransaction is a transaction for the memory storage engine.
+type memoryTransaction struct {
+	engine *memoryEngine
+	readTs uint64
+	writes map[string]memoryWrite
+	reads  map[string]struct{}
+	done   bool
+}
+
+// newMemoryTransaction creates a new memory transaction reading at the given snapshot.
+func newMemoryTransaction(engine *memoryEngine, readTs uint64) *memoryTransaction {
+	return &memoryTransaction{
+		engine: engine,
+		readTs: readTs,
+		writes: make(map[string]memoryWrite),
+		reads:  make(map[string]struct{}),
+	}
+}
+
+// Get returns the value for a given key.
+func (t *memoryTransaction) Get(key string) ([]byte, error) {
+	if t.done {
+		return nil, ErrTransactionDiscarded
+	}
+
+	if w, ok := t.writes[key]; ok {
+		if w.deleted {
+			return nil, ErrKeyNotFound
+		}
+
+		return copyBytes(w.value), nil
+	}
+
+	t.reads[key] = struct{}{}
+
+	t.engine.mu.RLock()
+	defer t.engine.mu.RUnlock()
+
+	return t.engine.read(key, t.readTs)
+}
+
+// Put sets the value for a given key.
+func (t *memoryTransaction) Put(key string, value []byte) error {
+	if t.done {
+		return ErrTransactionDiscarded
+	}
+
+	if key == "" {
+		return ErrEmptyKey
+	}
+
+	t.writes[key] = memoryWrite{value: copyBytes(value)}
+
+	return nil
+}
+
+// Delete deletes the value for a given key.
+func (t *memoryTransaction) Delete(key string) error {
+	if t.done {
+		return ErrTransactionDiscarded
+	}
+
+	if key == "" {
+		return ErrEmptyKey
+	}
+
+	t.writes[key] = memoryWrite{deleted: true}
+
+	return nil
+}
+
+// Scan scans the database for all keys that match the prefix.
+func (t *memoryTransaction) Scan(prefix string) ([]KV, error) {
+	return t.ScanContext(context.Background(), prefix)
+}
+
+// ScanContext scans the database for all keys that match the prefix until the context is done.
+func (t *memoryTransaction) ScanContext(ctx context.Context, prefix string) ([]KV, error) {
+	if t.done {
+		return nil, ErrTransactionDiscarded
+	}
+
+	return t.scan(ctx, prefix, true)
+}
+
+// ScanKeys scans the database for all keys that match the prefix.
+func (t *memoryTransaction) ScanKeys(prefix string) ([]string, error) {
+	if t.done {
+		return nil, ErrTransactionDiscarded
+	}
+
+	kvs, err := t.scan(context.Background(), prefix, false)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]string, 0, len(kvs))
+
+	for _, kv := range kvs {
+		results = append(results, kv.Key)
+	}
+
+	return results, nil
+}
+
+// scan merges the snapshot with the pending writes of the transaction, until the context is done.
+func (t *memoryTransaction) scan(ctx context.Context, prefix string, withValues bool) ([]KV, error) {
+	var err error
+
+	merged := make(map[string][]byte)
+
+	t.engine.mu.RLock()
+	t.engine.list.ascend(prefix, func(n *skiplistNode) bool {
+		if err = checkScan(ctx, len(merged)); err != nil {
+			return false
+		}
+
+		t.reads[n.key] = struct{}{}
+
+		if v, ok := n.visible(t.readTs); ok && !v.deleted {
+			merged[n.key] = v.value
+		}
+
+		return true
+	})
+	t.engine.mu.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key, w := range t.writes {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		if w.deleted {
+			delete(merged, key)
+		} else {
+			merged[key] = w.value
+		}
+	}
+
+	keys := make([]string, 0, len(merged))
+	for key := range merged {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	results := make([]KV, 0, len(keys))
+
+	for _, key := range keys {
+		kv := KV{Key: key}
+		if withValues {
+			kv.Value = copyBytes(merged[key])
+		}
+
+		results = append(results, kv)
+	}
+
+	return results, nil
+}
+
+// Commit commits the current transaction.
+func (t *memoryTransaction) Commit() error {
+	if t.done {
+		return ErrTransactionDiscarded
+	}
+
+	t.done = true
+
+	return t.engine.commit(t)
+}
+
+// Rollback rolls back the current transaction.
+func (t *memoryTransaction) Rollback() {
+	if t.done {
+		return
+	}
+
+	t.done = true
+	t.engine.discard(t)
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+const (
+	// EngineBadger is the name of the BadgerDB storage engine.
+	EngineBadger = "badger"
+	// EngineMemory is the name of the in-memory storage engine.
+	EngineMemory = "memory"
+)
+
+// Config is the configuration passed to a storage engine factory.
+type Config struct {
+	// Path is the directory where the engine keeps its data, if any.
+	Path string
+	// Options are the engine-specific options.
+	Options map[string]any
+}
+
+// Factory creates a storage engine from a configuration.
+type Factory func(cfg Config) (Storage, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func init() {
+	Register(EngineBadger, func(cfg Config) (Storage, error) {
+		return newBadgerEngine(cfg)
+	})
+	Register(EngineMemory, func(cfg Config) (Storage, error) {
+		return newMemoryEngineFromConfig(cfg)
+	})
+}
+
+// Register makes a storage engine available by the provided name.
+// It panics if the factory is nil or if Register is called twice with the same name.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("storage: Register factory is nil")
+	}
+
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("storage: Register called twice for engine %s", name))
+	}
+
+	registry[name] = factory
+}
+
+// Lookup returns the factory registered with the given name.
+func Lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factory, ok := registry[name]
+
+	return factory, ok
+}
+
+// Engines returns the sorted names of the registered storage engines.
+func Engines() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"math/rand/v2"
+	"strings"
+)
+
+const (
+	// skiplistMaxLevel is the maximum number of levels of the skiplist.
+	skiplistMaxLevel = 24
+	// skiplistP is the probability of promoting a node to the next level.
+	skiplistP = 0.25
+)
+
+// memoryVersion is a committed version of a key.
+type memoryVersion struct {
+	ts      uint64
+	value   []byte
+	deleted bool
+}
+
+// skiplistNode is a node of the skiplist.
+type skiplistNode struct {
+	key      string
+	versions []memoryVersion
+	next     []*skiplistNode
+}
+
+// skiplist is an ordered map of keys to their committed versions.
+// It is not safe for concurrent use; callers must synchronize access.
+type skiplist struct {
+	head  *skiplistNode
+	level int
+	len   int
+}
+
+// newSkiplist creates a new empty skiplist.
+func newSkiplist() *skiplist {
+	return &skiplist{
+		head:  &skiplistNode{next: make([]*skiplistNode, skiplistMaxLevel)},
+		level: 1,
+	}
+}
+
+// randomLevel returns a random level for a new node.
+func (s *skiplist) randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+
+	return level
+}
+
+// findPredecessors returns the last node before key for every level.
+func (s *skiplist) findPredecessors(key string) []*skiplistNode {
+	update := make([]*skiplistNode, skiplistMaxLevel)
+	x := s.head
+
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+
+		update[i] = x
+	}
+
+	return update
+}
+
+// get returns the node for the given key or nil if it does not exist.
+func (s *skiplist) get(key string) *skiplistNode {
+	n := s.seek(key)
+	if n != nil && n.key == key {
+		return n
+	}
+
+	return nil
+}
+
+// seek returns the first node whose key is greater than or equal to key.
+func (s *skiplist) seek(key string) *skiplistNode {
+	x := s.head
+
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+	}
+
+	return x.next[0]
+}
+
+// getOrInsert returns the node for the given key, inserting it if needed.
+func (s *skiplist) getOrInsert(key string) *skiplistNode {
+	update := s.findPredecessors(key)
+	if n := update[0].next[0]; n != nil && n.key == key {
+		return n
+	}
+
+	level := s.randomLevel()
+	if level > s.level {
+		for i := s.level; i < level; i++ {
+			update[i] = s.head
+		}
+
+		s.level = level
+	}
+
+	n := &skiplistNode{key: key, next: make([]*skiplistNode, level)}
+	for i := range level {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+
+	s.len++
+
+	return n
+}
+
+// remove removes the node for the given key.
+func (s *skiplist) remove(key string) {
+	update := s.findPredecessors(key)
+
+	n := update[0].next[0]
+	if n == nil || n.key != key {
+		return
+	}
+
+	for i := range s.level {
+		if update[i].next[i] != n {
+			break
+		}
+
+		update[i].next[i] = n.next[i]
+	}
+
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+
+	s.len--
+}
+
+// ascend calls fn for every node whose key has the given prefix, in order.
+// Iteration stops when fn returns false.
+func (s *skiplist) ascend(prefix string, fn func(n *skiplistNode) bool) {
+	for n := s.seek(prefix); n != nil && strings.HasPrefix(n.key, prefix); n = n.next[0] {
+		if !fn(n) {
+			return
+		}
+	}
+}
+
+// visible returns the newest version of the node visible at readTs.
+func (n *skiplistNode) visible(readTs uint64) (memoryVersion, bool) {
+	for i := len(n.versions) - 1; i >= 0; i-- {
+		if n.versions[i].ts <= readTs {
+			return n.versions[i], true
+		}
+	}
+
+	return memoryVersion{}, false
+}
+
+// latestTs returns the timestamp of the newest committed version of the node.
+func (n *skiplistNode) latestTs() uint64 {
+	if len(n.versions) == 0 {
+		return 0
+	}
+
+	return n.versions[len(n.versions)-1].ts
+}
+
+// prune drops the versions that are no longer visible to any reader at or after minReadTs, except
+// for the newest keep versions, and reports whether it dropped any.
+func (n *skiplistNode) prune(minReadTs uint64, keep int) bool {
+	keepFrom := 0
+
+	for i := len(n.versions) - 1; i >= 0; i-- {
+		if n.versions[i].ts <= minReadTs {
+			keepFrom = i
+
+			break
+		}
+	}
+
+	keepFrom = min(keepFrom, max(len(n.versions)-keep, 0))
+
+	if keepFrom == 0 {
+		return false
+	}
+
+	n.versions = append([]memoryVersion(nil), n.versions[keepFrom:]...)
+
+	return true
+}
@@ -36,13 +36,79 @@ type Storage interface {
 	Scan(prefix string) ([]KV, error)
 	// ScanKeys scans the database for all keys that match the prefix.
 	ScanKeys(prefix string) ([]string, error)
+	// DropPrefix deletes every key that matches the prefix. It is not part of any transaction.
+	DropPrefix(prefix string) error
 	// PrintAllKeys prints all keys in the database.
 	PrintAllKeys() error
 	// Close closes the storage engine.
 	Close() error
 }
 
+// Sizer is implemented by the storage engines that can report the size of their files.
+type Sizer interface {
+	// Size returns the size in bytes of the LSM tree and of the value log.
+	Size() (lsm, vlog int64)
+}
+
+// Reader reads the keys of a storage engine.
+type Reader interface {
+	// Get returns the value for a given key.
+	Get(key string) ([]byte, error)
+	// Scan scans the database for all keys that match the prefix.
+	Scan(prefix string) ([]KV, error)
+	// ScanKeys scans the database for all keys that match the prefix.
+	ScanKeys(prefix string) ([]string, error)
+}
+
+// ContextScanner is implemented by the readers whose scans stop when a context is done.
+type ContextScanner interface {
+	// ScanContext scans the keys that match the prefix like Scan, and returns the error of the
+	// context when it is done before the scan ends.
+	ScanContext(ctx context.Context, prefix string) ([]KV, error)
+}
+
+// scanCheckInterval is the number of keys a scan reads between checks of its context.
+const scanCheckInterval = 256
+
+// checkScan returns the error of the context of a scan every scanCheckInterval keys read.
+func checkScan(ctx context.Context, read int) error {
+	if read%scanCheckInterval != 0 {
+		return nil
+	}
+
+	return ctx.Err()
+}
+
+// Historian is implemented by the storage engines that keep past versions of their keys, so they
+// can be read as they were after a past commit. How many versions are kept is set with
+// OptionNumVersionsToKeep.
+type Historian interface {
+	// CommitTs returns the timestamp of the latest commit.
+	CommitTs() uint64
+	// ReadAt returns a reader of the keys as they were after the commit with timestamp ts. It fails
+	// with ErrSnapshotTooOld when a version visible at ts may have been discarded since, and so do
+	// the reads of the reader once that happens.
+	ReadAt(ts uint64) (Reader, error)
+	// History returns the kept versions of a key, newest first.
+	History(key string) ([]Version, error)
+}
+
+// Version is a committed version of a key.
+type Version struct {
+	// Ts is the timestamp of the commit that wrote the version.
+	Ts uint64
+	// Value is the value of the key, empty when the commit deleted it.
+	Value []byte
+	// Deleted reports whether the commit deleted the key.
+	Deleted bool
+}
+
 // NewStorage creates a new storage engine.
 func NewStorage(path string) (Storage, error) {
-	return newBadgerEngine(path)
+	return newBadgerEngine(Config{Path: path})
+}
+
+// NewMemoryStorage creates a new storage engine that keeps all data in memory.
+func NewMemoryStorage() Storage {
+	return newMemoryEngine()
 }
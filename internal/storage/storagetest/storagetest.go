@@ -0,0 +1,744 @@
+// Package storagetest implements a conformance suite for storage engines.
+//
+// Every engine used by gopherdb must pass the suite before it can be trusted:
+//
+//	results := storagetest.Run(func() (storage.Storage, error) {
+//		return storage.NewMemoryStorage(), nil
+//	})
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/wirvii/gopherdb/internal/storage"
+)
+
+// Factory creates a fresh, empty storage engine for a single conformance case.
+type Factory func() (storage.Storage, error)
+
+// Case is a conformance case.
+type Case struct {
+	Name string
+	Run  func(s storage.Storage) error
+}
+
+// Result is the outcome of a conformance case.
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Passed reports whether the case passed.
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Cases returns every conformance case.
+func Cases() []Case {
+	return []Case{
+		{Name: "PutGet", Run: testPutGet},
+		{Name: "PutEmptyValue", Run: testPutEmptyValue},
+		{Name: "PutEmptyKey", Run: testPutEmptyKey},
+		{Name: "Delete", Run: testDelete},
+		{Name: "DropPrefix", Run: testDropPrefix},
+		{Name: "ScanOrdering", Run: testScanOrdering},
+		{Name: "ScanPrefixBoundaries", Run: testScanPrefixBoundaries},
+		{Name: "TxnReadYourWrites", Run: testTxnReadYourWrites},
+		{Name: "TxnIsolation", Run: testTxnIsolation},
+		{Name: "TxnScanMergesWrites", Run: testTxnScanMergesWrites},
+		{Name: "TxnConflict", Run: testTxnConflict},
+		{Name: "TxnBlindWritesDoNotConflict", Run: testTxnBlindWrites},
+		{Name: "TxnRollback", Run: testTxnRollback},
+		{Name: "TxnUseAfterRollback", Run: testTxnUseAfterRollback},
+		{Name: "Stream", Run: testStream},
+		{Name: "StreamYieldError", Run: testStreamYieldError},
+		{Name: "StreamCancellation", Run: testStreamCancellation},
+		{Name: "ScanContextCancellation", Run: testScanContextCancellation},
+		{Name: "ReadAtPastRetention", Run: testReadAtPastRetention},
+	}
+}
+
+// Run runs every conformance case against a fresh engine created by factory.
+func Run(factory Factory) []Result {
+	cases := Cases()
+	results := make([]Result, 0, len(cases))
+
+	for _, c := range cases {
+		results = append(results, Result{
+			Name: c.Name,
+			Err:  runCase(factory, c),
+		})
+	}
+
+	return results
+}
+
+// runCase runs a single case and closes the engine afterwards.
+func runCase(factory Factory, c Case) (err error) {
+	s, err := factory()
+	if err != nil {
+		return fmt.Errorf("create engine: %w", err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+
+		if cerr := s.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("close engine: %w", cerr)
+		}
+	}()
+
+	return c.Run(s)
+}
+
+// expectValue checks that key holds want.
+func expectValue(get func(string) ([]byte, error), key string, want []byte) error {
+	got, err := get(key)
+	if err != nil {
+		return fmt.Errorf("get %q: %w", key, err)
+	}
+
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("get %q: got %q, want %q", key, got, want)
+	}
+
+	return nil
+}
+
+// expectNotFound checks that key does not exist.
+func expectNotFound(get func(string) ([]byte, error), key string) error {
+	_, err := get(key)
+	if !errors.Is(err, storage.ErrKeyNotFound) {
+		return fmt.Errorf("get %q: got error %v, want %v", key, err, storage.ErrKeyNotFound)
+	}
+
+	return nil
+}
+
+// expectKeys checks that kvs holds exactly the given keys in order.
+func expectKeys(op string, got []string, want []string) error {
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("%s: got keys %q, want %q", op, got, want)
+	}
+
+	return nil
+}
+
+// kvKeys returns the keys of kvs.
+func kvKeys(kvs []storage.KV) []string {
+	keys := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+
+	return keys
+}
+
+func testPutGet(s storage.Storage) error {
+	if err := expectNotFound(s.Get, "k"); err != nil {
+		return err
+	}
+
+	if err := s.Put("k", []byte("v1")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	if err := expectValue(s.Get, "k", []byte("v1")); err != nil {
+		return err
+	}
+
+	if err := s.Put("k", []byte("v2")); err != nil {
+		return fmt.Errorf("overwrite: %w", err)
+	}
+
+	return expectValue(s.Get, "k", []byte("v2"))
+}
+
+func testPutEmptyValue(s storage.Storage) error {
+	if err := s.Put("k", nil); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	got, err := s.Get("k")
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+
+	if len(got) != 0 {
+		return fmt.Errorf("get: got %q, want empty value", got)
+	}
+
+	return nil
+}
+
+func testPutEmptyKey(s storage.Storage) error {
+	if err := s.Put("", []byte("v")); !errors.Is(err, storage.ErrEmptyKey) {
+		return fmt.Errorf("put: got error %v, want %v", err, storage.ErrEmptyKey)
+	}
+
+	txn := s.BeginTx()
+	defer txn.Rollback()
+
+	if err := txn.Put("", []byte("v")); !errors.Is(err, storage.ErrEmptyKey) {
+		return fmt.Errorf("txn put: got error %v, want %v", err, storage.ErrEmptyKey)
+	}
+
+	return nil
+}
+
+func testDelete(s storage.Storage) error {
+	if err := s.Put("k", []byte("v")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	if err := s.Delete("k"); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	if err := expectNotFound(s.Get, "k"); err != nil {
+		return err
+	}
+
+	if err := s.Delete("missing"); err != nil {
+		return fmt.Errorf("delete missing key: %w", err)
+	}
+
+	keys, err := s.ScanKeys("")
+	if err != nil {
+		return fmt.Errorf("scan keys: %w", err)
+	}
+
+	return expectKeys("scan keys", keys, []string{})
+}
+
+func testDropPrefix(s storage.Storage) error {
+	for _, key := range []string{"a/1", "a/2", "a/3/x", "a1", "b/1"} {
+		if err := s.Put(key, []byte(key)); err != nil {
+			return fmt.Errorf("put %q: %w", key, err)
+		}
+	}
+
+	if err := s.DropPrefix("a/"); err != nil {
+		return fmt.Errorf("drop prefix: %w", err)
+	}
+
+	if err := expectNotFound(s.Get, "a/1"); err != nil {
+		return err
+	}
+
+	keys, err := s.ScanKeys("")
+	if err != nil {
+		return fmt.Errorf("scan keys: %w", err)
+	}
+
+	if err := expectKeys("scan keys", keys, []string{"a1", "b/1"}); err != nil {
+		return err
+	}
+
+	if err := s.DropPrefix("missing/"); err != nil {
+		return fmt.Errorf("drop missing prefix: %w", err)
+	}
+
+	return nil
+}
+
+func testScanOrdering(s storage.Storage) error {
+	want := make([]string, 0, 100)
+
+	for i := range 100 {
+		want = append(want, fmt.Sprintf("p/%03d", i))
+	}
+
+	for i := range want {
+		// Insert in a scrambled order.
+		key := want[(i*37)%len(want)]
+		if err := s.Put(key, []byte(key)); err != nil {
+			return fmt.Errorf("put %q: %w", key, err)
+		}
+	}
+
+	kvs, err := s.Scan("p/")
+	if err != nil {
+		return fmt.Errorf("scan: %w", err)
+	}
+
+	if err := expectKeys("scan", kvKeys(kvs), want); err != nil {
+		return err
+	}
+
+	for _, kv := range kvs {
+		if string(kv.Value) != kv.Key {
+			return fmt.Errorf("scan: key %q has value %q", kv.Key, kv.Value)
+		}
+	}
+
+	keys, err := s.ScanKeys("p/")
+	if err != nil {
+		return fmt.Errorf("scan keys: %w", err)
+	}
+
+	return expectKeys("scan keys", keys, want)
+}
+
+func testScanPrefixBoundaries(s storage.Storage) error {
+	for _, key := range []string{"a", "a/", "a/1", "a/2", "a0", "a.", "b", "a/1/x"} {
+		if err := s.Put(key, []byte(key)); err != nil {
+			return fmt.Errorf("put %q: %w", key, err)
+		}
+	}
+
+	kvs, err := s.Scan("a/")
+	if err != nil {
+		return fmt.Errorf("scan: %w", err)
+	}
+
+	if err := expectKeys("scan a/", kvKeys(kvs), []string{"a/", "a/1", "a/1/x", "a/2"}); err != nil {
+		return err
+	}
+
+	keys, err := s.ScanKeys("a/1")
+	if err != nil {
+		return fmt.Errorf("scan keys: %w", err)
+	}
+
+	if err := expectKeys("scan keys a/1", keys, []string{"a/1", "a/1/x"}); err != nil {
+		return err
+	}
+
+	keys, err = s.ScanKeys("c")
+	if err != nil {
+		return fmt.Errorf("scan keys: %w", err)
+	}
+
+	if err := expectKeys("scan keys c", keys, []string{}); err != nil {
+		return err
+	}
+
+	keys, err = s.ScanKeys("")
+	if err != nil {
+		return fmt.Errorf("scan keys: %w", err)
+	}
+
+	return expectKeys("scan keys all", keys, []string{"a", "a.", "a/", "a/1", "a/1/x", "a/2", "a0", "b"})
+}
+
+func testTxnReadYourWrites(s storage.Storage) error {
+	if err := s.Put("k", []byte("old")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	txn := s.BeginTx()
+	defer txn.Rollback()
+
+	if err := txn.Put("k", []byte("new")); err != nil {
+		return fmt.Errorf("txn put: %w", err)
+	}
+
+	if err := expectValue(txn.Get, "k", []byte("new")); err != nil {
+		return fmt.Errorf("inside txn: %w", err)
+	}
+
+	if err := expectValue(s.Get, "k", []byte("old")); err != nil {
+		return fmt.Errorf("outside txn before commit: %w", err)
+	}
+
+	if err := txn.Delete("k"); err != nil {
+		return fmt.Errorf("txn delete: %w", err)
+	}
+
+	if err := expectNotFound(txn.Get, "k"); err != nil {
+		return fmt.Errorf("inside txn: %w", err)
+	}
+
+	if err := txn.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return expectNotFound(s.Get, "k")
+}
+
+func testTxnIsolation(s storage.Storage) error {
+	if err := s.Put("k", []byte("v1")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	reader := s.BeginTx()
+	defer reader.Rollback()
+
+	if err := s.Put("k", []byte("v2")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	if err := s.Put("new", []byte("v")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	if err := expectValue(reader.Get, "k", []byte("v1")); err != nil {
+		return fmt.Errorf("snapshot read: %w", err)
+	}
+
+	if err := expectNotFound(reader.Get, "new"); err != nil {
+		return fmt.Errorf("snapshot read: %w", err)
+	}
+
+	keys, err := reader.ScanKeys("")
+	if err != nil {
+		return fmt.Errorf("snapshot scan: %w", err)
+	}
+
+	return expectKeys("snapshot scan", keys, []string{"k"})
+}
+
+func testTxnScanMergesWrites(s storage.Storage) error {
+	for _, key := range []string{"p/1", "p/3", "p/5"} {
+		if err := s.Put(key, []byte("committed")); err != nil {
+			return fmt.Errorf("put %q: %w", key, err)
+		}
+	}
+
+	txn := s.BeginTx()
+	defer txn.Rollback()
+
+	if err := txn.Put("p/2", []byte("pending")); err != nil {
+		return fmt.Errorf("txn put: %w", err)
+	}
+
+	if err := txn.Put("p/5", []byte("pending")); err != nil {
+		return fmt.Errorf("txn put: %w", err)
+	}
+
+	if err := txn.Delete("p/3"); err != nil {
+		return fmt.Errorf("txn delete: %w", err)
+	}
+
+	kvs, err := txn.Scan("p/")
+	if err != nil {
+		return fmt.Errorf("txn scan: %w", err)
+	}
+
+	if err := expectKeys("txn scan", kvKeys(kvs), []string{"p/1", "p/2", "p/5"}); err != nil {
+		return err
+	}
+
+	want := []string{"committed", "pending", "pending"}
+	for i, kv := range kvs {
+		if string(kv.Value) != want[i] {
+			return fmt.Errorf("txn scan: key %q has value %q, want %q", kv.Key, kv.Value, want[i])
+		}
+	}
+
+	keys, err := txn.ScanKeys("p/")
+	if err != nil {
+		return fmt.Errorf("txn scan keys: %w", err)
+	}
+
+	return expectKeys("txn scan keys", keys, []string{"p/1", "p/2", "p/5"})
+}
+
+func testTxnConflict(s storage.Storage) error {
+	if err := s.Put("counter", []byte("0")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	txn1 := s.BeginTx()
+	defer txn1.Rollback()
+
+	txn2 := s.BeginTx()
+	defer txn2.Rollback()
+
+	if _, err := txn1.Get("counter"); err != nil {
+		return fmt.Errorf("txn1 get: %w", err)
+	}
+
+	if _, err := txn2.Get("counter"); err != nil {
+		return fmt.Errorf("txn2 get: %w", err)
+	}
+
+	if err := txn2.Put("counter", []byte("2")); err != nil {
+		return fmt.Errorf("txn2 put: %w", err)
+	}
+
+	if err := txn2.Commit(); err != nil {
+		return fmt.Errorf("txn2 commit: %w", err)
+	}
+
+	if err := txn1.Put("counter", []byte("1")); err != nil {
+		return fmt.Errorf("txn1 put: %w", err)
+	}
+
+	if err := txn1.Commit(); !errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("txn1 commit: got error %v, want %v", err, storage.ErrConflict)
+	}
+
+	return expectValue(s.Get, "counter", []byte("2"))
+}
+
+func testTxnBlindWrites(s storage.Storage) error {
+	txn1 := s.BeginTx()
+	defer txn1.Rollback()
+
+	txn2 := s.BeginTx()
+	defer txn2.Rollback()
+
+	if err := txn1.Put("k", []byte("1")); err != nil {
+		return fmt.Errorf("txn1 put: %w", err)
+	}
+
+	if err := txn2.Put("k", []byte("2")); err != nil {
+		return fmt.Errorf("txn2 put: %w", err)
+	}
+
+	if err := txn2.Commit(); err != nil {
+		return fmt.Errorf("txn2 commit: %w", err)
+	}
+
+	if err := txn1.Commit(); err != nil {
+		return fmt.Errorf("txn1 commit: %w", err)
+	}
+
+	return expectValue(s.Get, "k", []byte("1"))
+}
+
+func testTxnRollback(s storage.Storage) error {
+	if err := s.Put("keep", []byte("v")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	txn := s.BeginTx()
+
+	if err := txn.Put("new", []byte("v")); err != nil {
+		return fmt.Errorf("txn put: %w", err)
+	}
+
+	if err := txn.Delete("keep"); err != nil {
+		return fmt.Errorf("txn delete: %w", err)
+	}
+
+	txn.Rollback()
+
+	if err := expectNotFound(s.Get, "new"); err != nil {
+		return fmt.Errorf("after rollback: %w", err)
+	}
+
+	return expectValue(s.Get, "keep", []byte("v"))
+}
+
+func testTxnUseAfterRollback(s storage.Storage) error {
+	txn := s.BeginTx()
+	txn.Rollback()
+
+	if _, err := txn.Get("k"); !errors.Is(err, storage.ErrTransactionDiscarded) {
+		return fmt.Errorf("get: got error %v, want %v", err, storage.ErrTransactionDiscarded)
+	}
+
+	if err := txn.Put("k", []byte("v")); !errors.Is(err, storage.ErrTransactionDiscarded) {
+		return fmt.Errorf("put: got error %v, want %v", err, storage.ErrTransactionDiscarded)
+	}
+
+	return expectNotFound(s.Get, "k")
+}
+
+func testStream(s storage.Storage) error {
+	want := []string{"s/1", "s/2", "s/3", "s/4"}
+
+	for _, key := range append([]string{"r", "t"}, want...) {
+		if err := s.Put(key, []byte(key)); err != nil {
+			return fmt.Errorf("put %q: %w", key, err)
+		}
+	}
+
+	got := make([]string, 0)
+
+	err := s.Stream(context.Background(), "s/", func(key string, value []byte) error {
+		if string(value) != key {
+			return fmt.Errorf("key %q has value %q", key, value)
+		}
+
+		got = append(got, key)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("stream: %w", err)
+	}
+
+	// Engines may stream ranges in parallel, so only the set of keys is compared.
+	slices.Sort(got)
+
+	return expectKeys("stream", got, want)
+}
+
+func testStreamYieldError(s storage.Storage) error {
+	for i := range 10 {
+		if err := s.Put(fmt.Sprintf("s/%d", i), []byte("v")); err != nil {
+			return fmt.Errorf("put: %w", err)
+		}
+	}
+
+	errStop := errors.New("stop")
+
+	err := s.Stream(context.Background(), "s/", func(_ string, _ []byte) error {
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		return fmt.Errorf("stream: got error %v, want %v", err, errStop)
+	}
+
+	return nil
+}
+
+func testStreamCancellation(s storage.Storage) error {
+	for i := range 10 {
+		if err := s.Put(fmt.Sprintf("s/%d", i), []byte("v")); err != nil {
+			return fmt.Errorf("put: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	seen := 0
+
+	err := s.Stream(ctx, "s/", func(_ string, _ []byte) error {
+		seen++
+		cancel()
+
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("stream: got error %v, want %v", err, context.Canceled)
+	}
+
+	if seen >= 10 {
+		return fmt.Errorf("stream: yielded %d keys after cancellation", seen)
+	}
+
+	return nil
+}
+
+func testScanContextCancellation(s storage.Storage) error {
+	for i := range 10 {
+		if err := s.Put(fmt.Sprintf("c/%d", i), []byte("v")); err != nil {
+			return fmt.Errorf("put: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	readers := map[string]any{"storage": s}
+
+	txn := s.BeginTx()
+	defer txn.Rollback()
+
+	readers["transaction"] = txn
+
+	for name, r := range readers {
+		scanner, ok := r.(storage.ContextScanner)
+		if !ok {
+			continue
+		}
+
+		if _, err := scanner.ScanContext(ctx, "c/"); !errors.Is(err, context.Canceled) {
+			return fmt.Errorf("%s scan: got error %v, want %v", name, err, context.Canceled)
+		}
+
+		kvs, err := scanner.ScanContext(context.Background(), "c/")
+		if err != nil {
+			return fmt.Errorf("%s scan: %w", name, err)
+		}
+
+		if len(kvs) != 10 {
+			return fmt.Errorf("%s scan: got %d keys, want 10", name, len(kvs))
+		}
+	}
+
+	return nil
+}
+
+// testReadAtPastRetention checks, on engines that keep history with their default of one version
+// per key, that reading a commit fails once a version visible at it may be discarded.
+func testReadAtPastRetention(s storage.Storage) error {
+	historian, ok := s.(storage.Historian)
+	if !ok {
+		return nil
+	}
+
+	if err := s.Put("h/a", []byte("1")); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	ts := historian.CommitTs()
+
+	txn := s.BeginTx()
+	if err := txn.Put("h/b", []byte("1")); err != nil {
+		txn.Rollback()
+
+		return fmt.Errorf("txn put: %w", err)
+	}
+
+	if err := txn.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	// Una clave nueva no descarta ninguna versión
+	r, err := historian.ReadAt(ts)
+	if err != nil {
+		return fmt.Errorf("read at %d after an insert: %w", ts, err)
+	}
+
+	if err := expectValue(r.Get, "h/a", []byte("1")); err != nil {
+		return err
+	}
+
+	if err := expectNotFound(r.Get, "h/b"); err != nil {
+		return err
+	}
+
+	txn = s.BeginTx()
+	if err := txn.Put("h/a", []byte("2")); err != nil {
+		txn.Rollback()
+
+		return fmt.Errorf("txn put: %w", err)
+	}
+
+	if err := txn.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	if _, err := historian.ReadAt(ts); !errors.Is(err, storage.ErrSnapshotTooOld) {
+		return fmt.Errorf("read at %d after an update: got error %v, want %v", ts, err, storage.ErrSnapshotTooOld)
+	}
+
+	if _, err := r.Scan("h/"); !errors.Is(err, storage.ErrSnapshotTooOld) {
+		return fmt.Errorf("scan of an open reader after an update: got error %v, want %v", err, storage.ErrSnapshotTooOld)
+	}
+
+	ts = historian.CommitTs()
+
+	if err := s.Delete("h/b"); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	if _, err := historian.ReadAt(ts); !errors.Is(err, storage.ErrSnapshotTooOld) {
+		return fmt.Errorf("read at %d after a delete: got error %v, want %v", ts, err, storage.ErrSnapshotTooOld)
+	}
+
+	r, err = historian.ReadAt(historian.CommitTs())
+	if err != nil {
+		return fmt.Errorf("read at the latest commit: %w", err)
+	}
+
+	kvs, err := r.Scan("h/")
+	if err != nil {
+		return fmt.Errorf("scan at the latest commit: %w", err)
+	}
+
+	return expectKeys("scan at the latest commit", kvKeys(kvs), []string{"h/a"})
+}
@@ -0,0 +1,93 @@
+// Package ulid generates Universally Unique Lexicographically Sortable Identifiers.
+//
+// A ULID is a 48-bit millisecond timestamp followed by 80 random bits, encoded as 26 characters
+// of Crockford's base32. IDs generated in the same millisecond by a Generator increment the
+// random part of the previous one, so they sort in the order they were generated.
+package ulid
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// encoding is Crockford's base32 alphabet.
+const encoding = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Generator generates monotonic ULIDs. It is safe for concurrent use.
+type Generator struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	lastRnd [10]byte
+}
+
+// defaultGenerator is the generator used by New.
+var defaultGenerator Generator
+
+// New returns a new ULID from the default generator.
+func New() string {
+	return defaultGenerator.New()
+}
+
+// New returns a new ULID, greater than the previous one of the generator.
+func (g *Generator) New() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+
+	if ms <= g.lastMs {
+		// El mismo milisegundo (o un reloj que retrocede): se incrementa la parte aleatoria
+		ms = g.lastMs
+
+		for i := len(g.lastRnd) - 1; i >= 0; i-- {
+			g.lastRnd[i]++
+			if g.lastRnd[i] != 0 {
+				break
+			}
+		}
+	} else {
+		_, _ = rand.Read(g.lastRnd[:])
+		g.lastMs = ms
+	}
+
+	var id [16]byte
+
+	for i := range 6 {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+
+	copy(id[6:], g.lastRnd[:])
+
+	return encode(id)
+}
+
+// encode encodes the 128 bits of a ULID as 26 base32 characters, the first one holding the three
+// highest bits.
+func encode(id [16]byte) string {
+	out := make([]byte, 26)
+
+	// 128 bits = 3 + 25*5: se recorren de menor a mayor peso
+	var acc uint32
+
+	bits := 0
+	pos := len(out) - 1
+
+	for i := len(id) - 1; i >= 0; i-- {
+		acc |= uint32(id[i]) << bits
+		bits += 8
+
+		for bits >= 5 && pos >= 0 {
+			out[pos] = encoding[acc&0x1f]
+			acc >>= 5
+			bits -= 5
+			pos--
+		}
+	}
+
+	if pos >= 0 {
+		out[pos] = encoding[acc&0x1f]
+	}
+
+	return string(out)
+}
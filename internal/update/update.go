@@ -0,0 +1,481 @@
+// Package update applies MongoDB update operators to documents.
+package update
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/queryengine"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Operator is an update operator.
+type Operator string
+
+const (
+	// OperatorSet sets the value of a field.
+	OperatorSet Operator = "$set"
+	// OperatorUnset removes a field.
+	OperatorUnset Operator = "$unset"
+	// OperatorSetOnInsert sets the value of a field when the update inserts a document.
+	OperatorSetOnInsert Operator = "$setOnInsert"
+	// OperatorInc increments a field by a number.
+	OperatorInc Operator = "$inc"
+	// OperatorMul multiplies a field by a number.
+	OperatorMul Operator = "$mul"
+	// OperatorMin sets a field to the value if it is lower than the current one.
+	OperatorMin Operator = "$min"
+	// OperatorMax sets a field to the value if it is greater than the current one.
+	OperatorMax Operator = "$max"
+	// OperatorRename renames a field.
+	OperatorRename Operator = "$rename"
+	// OperatorCurrentDate sets a field to the current date.
+	OperatorCurrentDate Operator = "$currentDate"
+	// OperatorPush appends values to an array.
+	OperatorPush Operator = "$push"
+	// OperatorAddToSet appends values to an array unless they are already present.
+	OperatorAddToSet Operator = "$addToSet"
+	// OperatorPull removes the values of an array that match a condition.
+	OperatorPull Operator = "$pull"
+	// OperatorPop removes the first or the last value of an array.
+	OperatorPop Operator = "$pop"
+)
+
+// modifierEach is the modifier of $push and $addToSet that adds several values.
+const modifierEach = "$each"
+
+// ErrInvalidUpdate is returned when an update document is not valid.
+var ErrInvalidUpdate = errors.New("invalid update")
+
+// IsOperatorDocument reports whether an update document is made of update operators, as opposed
+// to a replacement document.
+func IsOperatorDocument(update map[string]any) bool {
+	if len(update) == 0 {
+		return false
+	}
+
+	for key := range update {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Apply applies the update operators to a copy of the document. The $setOnInsert operator is only
+// applied when insert is true.
+func Apply(doc, update map[string]any, insert bool) (map[string]any, error) {
+	out, _ := bson.Normalize(doc).(map[string]any)
+	if out == nil {
+		out = make(map[string]any)
+	}
+
+	for key, spec := range update {
+		fields, ok := bson.Normalize(spec).(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s requires a document", ErrInvalidUpdate, key)
+		}
+
+		for field, value := range fields {
+			if err := apply(out, Operator(key), field, value, insert); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// NewDocument builds the document inserted by an upsert: the equality conditions of the filter
+// with the update applied.
+func NewDocument(filter, update map[string]any) (map[string]any, error) {
+	doc := make(map[string]any)
+	collectEqualities(doc, filter)
+
+	if !IsOperatorDocument(update) {
+		out := make(map[string]any, len(update)+1)
+		if id, ok := doc["_id"]; ok {
+			out["_id"] = id
+		}
+
+		maps.Copy(out, update)
+
+		return out, nil
+	}
+
+	return Apply(doc, update, true)
+}
+
+// collectEqualities copies the fields the filter compares by equality into the document.
+func collectEqualities(doc, filter map[string]any) {
+	for key, value := range filter {
+		if key == queryengine.OperatorAnd.String() {
+			clauses, _ := bson.Normalize(value).([]any)
+			for _, clause := range clauses {
+				if sub, ok := clause.(map[string]any); ok {
+					collectEqualities(doc, sub)
+				}
+			}
+
+			continue
+		}
+
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+
+		cond, ok := bson.Normalize(value).(map[string]any)
+		if !ok {
+			setPath(doc, key, value)
+
+			continue
+		}
+
+		if eq, ok := cond[queryengine.OperatorEqual.String()]; ok {
+			setPath(doc, key, eq)
+		} else if !IsOperatorDocument(cond) {
+			setPath(doc, key, cond)
+		}
+	}
+}
+
+// apply applies a single operator to a field.
+func apply(doc map[string]any, op Operator, field string, value any, insert bool) error {
+	current, exists := getPath(doc, field)
+
+	switch op {
+	case OperatorSet:
+		return setPath(doc, field, value)
+	case OperatorSetOnInsert:
+		if !insert {
+			return nil
+		}
+
+		return setPath(doc, field, value)
+	case OperatorUnset:
+		unsetPath(doc, field)
+
+		return nil
+	case OperatorInc, OperatorMul:
+		if !exists {
+			current = int32(0)
+			if op == OperatorMul {
+				value = zeroLike(value)
+			}
+		}
+
+		result, err := arithmetic(op, current, value)
+		if err != nil {
+			return fmt.Errorf("%w: %s on field %s: %w", ErrInvalidUpdate, op, field, err)
+		}
+
+		return setPath(doc, field, result)
+	case OperatorMin:
+		if !exists || queryengine.Compare(value, current) < 0 {
+			return setPath(doc, field, value)
+		}
+
+		return nil
+	case OperatorMax:
+		if !exists || queryengine.Compare(value, current) > 0 {
+			return setPath(doc, field, value)
+		}
+
+		return nil
+	case OperatorRename:
+		target, ok := value.(string)
+		if !ok || target == "" {
+			return fmt.Errorf("%w: $rename target of %s must be a string", ErrInvalidUpdate, field)
+		}
+
+		if !exists {
+			return nil
+		}
+
+		unsetPath(doc, field)
+
+		return setPath(doc, target, current)
+	case OperatorCurrentDate:
+		now := time.Now()
+
+		if spec, ok := value.(map[string]any); ok && spec["$type"] == "timestamp" {
+			return setPath(doc, field, primitive.Timestamp{T: uint32(now.Unix())})
+		}
+
+		return setPath(doc, field, primitive.NewDateTimeFromTime(now))
+	case OperatorPush, OperatorAddToSet:
+		list, err := arrayField(current, exists, field)
+		if err != nil {
+			return err
+		}
+
+		values := []any{value}
+		if spec, ok := value.(map[string]any); ok {
+			if each, ok := spec[modifierEach].([]any); ok {
+				values = each
+			}
+		}
+
+		for _, v := range values {
+			if op == OperatorAddToSet && contains(list, v) {
+				continue
+			}
+
+			list = append(list, v)
+		}
+
+		return setPath(doc, field, list)
+	case OperatorPull:
+		if !exists {
+			return nil
+		}
+
+		list, err := arrayField(current, exists, field)
+		if err != nil {
+			return err
+		}
+
+		match, err := pullMatcher(value)
+		if err != nil {
+			return err
+		}
+
+		kept := make([]any, 0, len(list))
+
+		for _, v := range list {
+			if !match(v) {
+				kept = append(kept, v)
+			}
+		}
+
+		return setPath(doc, field, kept)
+	case OperatorPop:
+		if !exists {
+			return nil
+		}
+
+		list, err := arrayField(current, exists, field)
+		if err != nil || len(list) == 0 {
+			return err
+		}
+
+		if n, ok := toFloat64(value); ok && n < 0 {
+			list = list[1:]
+		} else {
+			list = list[:len(list)-1]
+		}
+
+		return setPath(doc, field, list)
+	default:
+		return fmt.Errorf("%w: unknown operator %s", ErrInvalidUpdate, op)
+	}
+}
+
+// pullMatcher returns a function that reports whether an array value matches a $pull condition.
+func pullMatcher(cond any) (func(v any) bool, error) {
+	spec, ok := cond.(map[string]any)
+	if !ok {
+		return func(v any) bool { return queryengine.Compare(v, cond) == 0 }, nil
+	}
+
+	filter := spec
+	if IsOperatorDocument(spec) {
+		filter = map[string]any{"v": spec}
+	}
+
+	expr, err := queryengine.ParseFilter(filter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: $pull: %w", ErrInvalidUpdate, err)
+	}
+
+	return func(v any) bool {
+		if IsOperatorDocument(spec) {
+			return expr.Evaluate(map[string]any{"v": v})
+		}
+
+		doc, ok := v.(map[string]any)
+
+		return ok && expr.Evaluate(doc)
+	}, nil
+}
+
+// arrayField returns the current value of an array field.
+func arrayField(current any, exists bool, field string) ([]any, error) {
+	if !exists || current == nil {
+		return []any{}, nil
+	}
+
+	list, ok := current.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: field %s is not an array", ErrInvalidUpdate, field)
+	}
+
+	return append([]any(nil), list...), nil
+}
+
+// contains reports whether the list has a value equal to v.
+func contains(list []any, v any) bool {
+	for _, item := range list {
+		if queryengine.Compare(item, v) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getPath returns the value of a field, following dot notation into embedded documents.
+func getPath(doc map[string]any, path string) (any, bool) {
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		v, ok := doc[path]
+
+		return v, ok
+	}
+
+	sub, ok := doc[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+
+	return getPath(sub, rest)
+}
+
+// setPath sets the value of a field, creating the embedded documents of the path.
+func setPath(doc map[string]any, path string, value any) error {
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		doc[path] = value
+
+		return nil
+	}
+
+	sub, ok := doc[head].(map[string]any)
+	if !ok {
+		if _, exists := doc[head]; exists && doc[head] != nil {
+			return fmt.Errorf("%w: cannot create field %s in a non-document value", ErrInvalidUpdate, path)
+		}
+
+		sub = make(map[string]any)
+		doc[head] = sub
+	}
+
+	return setPath(sub, rest, value)
+}
+
+// unsetPath removes a field, following dot notation into embedded documents.
+func unsetPath(doc map[string]any, path string) {
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		delete(doc, path)
+
+		return
+	}
+
+	if sub, ok := doc[head].(map[string]any); ok {
+		unsetPath(sub, rest)
+	}
+}
+
+// arithmetic adds or multiplies two numbers, keeping the narrowest type that holds the result.
+func arithmetic(op Operator, a, b any) (any, error) {
+	ai, aInt := toInt64(a)
+	bi, bInt := toInt64(b)
+
+	if aInt && bInt {
+		var (
+			result   int64
+			overflow bool
+		)
+
+		if op == OperatorInc {
+			result = ai + bi
+			overflow = (bi > 0 && result < ai) || (bi < 0 && result > ai)
+		} else {
+			result = ai * bi
+			overflow = ai != 0 && result/ai != bi
+		}
+
+		if !overflow {
+			_, a32 := a.(int32)
+			_, b32 := b.(int32)
+
+			if a32 && b32 && result >= math.MinInt32 && result <= math.MaxInt32 {
+				return int32(result), nil
+			}
+
+			return result, nil
+		}
+	}
+
+	af, aNum := toFloat64(a)
+	bf, bNum := toFloat64(b)
+
+	if !aNum || !bNum {
+		return nil, errors.New("cannot apply to a non-numeric value")
+	}
+
+	if op == OperatorInc {
+		return af + bf, nil
+	}
+
+	return af * bf, nil
+}
+
+// zeroLike returns a zero of the numeric type of v.
+func zeroLike(v any) any {
+	switch v.(type) {
+	case float64, float32:
+		return float64(0)
+	case int64, int:
+		return int64(0)
+	default:
+		return int32(0)
+	}
+}
+
+// toInt64 converts an integer value to an int64.
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// toFloat64 converts a numeric value to a float64.
+func toFloat64(v any) (float64, bool) {
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
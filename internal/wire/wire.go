@@ -0,0 +1,336 @@
+// Package wire reads and writes the messages of the MongoDB wire protocol.
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OpCode is the operation code of a wire protocol message.
+type OpCode int32
+
+const (
+	// OpReply is the reply to an OP_QUERY message.
+	OpReply OpCode = 1
+	// OpQuery is the legacy query message, still used by drivers for the first handshake.
+	OpQuery OpCode = 2004
+	// OpMsg is the extensible message used by every command since MongoDB 3.6.
+	OpMsg OpCode = 2013
+)
+
+const (
+	// FlagChecksumPresent indicates that the OP_MSG ends with a CRC-32C checksum.
+	FlagChecksumPresent uint32 = 1 << 0
+	// FlagMoreToCome indicates that the sender does not wait for a reply.
+	FlagMoreToCome uint32 = 1 << 1
+)
+
+const (
+	// headerSize is the size of the standard message header.
+	headerSize = 16
+	// MaxMessageSize is the largest message accepted by the server.
+	MaxMessageSize = 48_000_000
+	// MaxDocumentSize is the largest BSON document accepted by the server.
+	MaxDocumentSize = 16 * 1024 * 1024
+	// MaxWriteBatchSize is the largest number of documents in a write command.
+	MaxWriteBatchSize = 100_000
+)
+
+// ErrMalformedMessage is returned when a message cannot be parsed.
+var ErrMalformedMessage = errors.New("malformed wire message")
+
+// Sequence is a document sequence of an OP_MSG, the kind 1 section used to send the documents of
+// bulk writes outside the command body.
+type Sequence struct {
+	Identifier string
+	Documents  []bson.Raw
+}
+
+// Message is a wire protocol request.
+type Message struct {
+	RequestID int32
+	OpCode    OpCode
+	Flags     uint32
+	// Body is the command document.
+	Body bson.Raw
+	// Sequences are the document sequences of an OP_MSG.
+	Sequences []Sequence
+	// Namespace is the full collection name of an OP_QUERY.
+	Namespace string
+}
+
+// ReadMessage reads an OP_MSG or OP_QUERY message.
+func ReadMessage(r io.Reader) (*Message, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	length := int32(binary.LittleEndian.Uint32(header[0:4]))
+	if length < headerSize || length > MaxMessageSize {
+		return nil, fmt.Errorf("%w: invalid length %d", ErrMalformedMessage, length)
+	}
+
+	payload := make([]byte, length-headerSize)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		RequestID: int32(binary.LittleEndian.Uint32(header[4:8])),
+		OpCode:    OpCode(binary.LittleEndian.Uint32(header[12:16])),
+	}
+
+	switch msg.OpCode {
+	case OpMsg:
+		return msg, msg.parseMsg(payload)
+	case OpQuery:
+		return msg, msg.parseQuery(payload)
+	default:
+		return msg, fmt.Errorf("%w: unsupported op code %d", ErrMalformedMessage, msg.OpCode)
+	}
+}
+
+// parseMsg parses the payload of an OP_MSG.
+func (m *Message) parseMsg(payload []byte) error {
+	if len(payload) < 4 {
+		return fmt.Errorf("%w: missing flags", ErrMalformedMessage)
+	}
+
+	m.Flags = binary.LittleEndian.Uint32(payload)
+	payload = payload[4:]
+
+	if m.Flags&FlagChecksumPresent != 0 {
+		if len(payload) < 4 {
+			return fmt.Errorf("%w: missing checksum", ErrMalformedMessage)
+		}
+
+		payload = payload[:len(payload)-4]
+	}
+
+	for len(payload) > 0 {
+		kind := payload[0]
+		payload = payload[1:]
+
+		switch kind {
+		case 0:
+			doc, rest, err := readDocument(payload)
+			if err != nil {
+				return err
+			}
+
+			m.Body = doc
+			payload = rest
+		case 1:
+			if len(payload) < 4 {
+				return fmt.Errorf("%w: truncated document sequence", ErrMalformedMessage)
+			}
+
+			size := int(binary.LittleEndian.Uint32(payload))
+			if size < 4 || size > len(payload) {
+				return fmt.Errorf("%w: invalid document sequence size %d", ErrMalformedMessage, size)
+			}
+
+			section := payload[4:size]
+			payload = payload[size:]
+
+			ident, section, err := readCString(section)
+			if err != nil {
+				return err
+			}
+
+			seq := Sequence{Identifier: ident}
+
+			for len(section) > 0 {
+				var doc bson.Raw
+
+				doc, section, err = readDocument(section)
+				if err != nil {
+					return err
+				}
+
+				seq.Documents = append(seq.Documents, doc)
+			}
+
+			m.Sequences = append(m.Sequences, seq)
+		default:
+			return fmt.Errorf("%w: unknown section kind %d", ErrMalformedMessage, kind)
+		}
+	}
+
+	if m.Body == nil {
+		return fmt.Errorf("%w: missing body section", ErrMalformedMessage)
+	}
+
+	return nil
+}
+
+// parseQuery parses the payload of an OP_QUERY. Only commands sent to a $cmd namespace are
+// supported.
+func (m *Message) parseQuery(payload []byte) error {
+	if len(payload) < 4 {
+		return fmt.Errorf("%w: missing flags", ErrMalformedMessage)
+	}
+
+	m.Flags = binary.LittleEndian.Uint32(payload)
+
+	ns, rest, err := readCString(payload[4:])
+	if err != nil {
+		return err
+	}
+
+	if len(rest) < 8 {
+		return fmt.Errorf("%w: truncated query", ErrMalformedMessage)
+	}
+
+	m.Namespace = ns
+
+	query, _, err := readDocument(rest[8:])
+	if err != nil {
+		return err
+	}
+
+	if inner, ok := query.Lookup("$query").DocumentOK(); ok {
+		query = inner
+	} else if inner, ok := query.Lookup("query").DocumentOK(); ok {
+		query = inner
+	}
+
+	m.Body = query
+
+	return nil
+}
+
+// Database returns the database the command is sent to.
+func (m *Message) Database() string {
+	if m.OpCode == OpQuery {
+		db, _, _ := strings.Cut(m.Namespace, ".")
+
+		return db
+	}
+
+	db, _ := m.Body.Lookup("$db").StringValueOK()
+
+	return db
+}
+
+// Command returns the command document with the document sequences added as array fields.
+func (m *Message) Command() (primitive.D, error) {
+	var cmd primitive.D
+	if err := bson.Unmarshal(m.Body, &cmd); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedMessage, err)
+	}
+
+	for _, seq := range m.Sequences {
+		docs := make(primitive.A, 0, len(seq.Documents))
+
+		for _, raw := range seq.Documents {
+			var doc primitive.D
+			if err := bson.Unmarshal(raw, &doc); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrMalformedMessage, err)
+			}
+
+			docs = append(docs, doc)
+		}
+
+		cmd = append(cmd, primitive.E{Key: seq.Identifier, Value: docs})
+	}
+
+	return cmd, nil
+}
+
+// WriteMsg writes an OP_MSG reply with a single body section.
+func WriteMsg(w io.Writer, requestID, responseTo int32, body any) error {
+	doc, err := bson.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	buf.Grow(headerSize + 5 + len(doc))
+	writeHeader(&buf, headerSize+5+len(doc), requestID, responseTo, OpMsg)
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.WriteByte(0)
+	buf.Write(doc)
+
+	return flush(w, buf.Bytes())
+}
+
+// WriteReply writes an OP_REPLY with a single document, the reply to an OP_QUERY command.
+func WriteReply(w io.Writer, requestID, responseTo int32, body any) error {
+	doc, err := bson.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	buf.Grow(headerSize + 20 + len(doc))
+	writeHeader(&buf, headerSize+20+len(doc), requestID, responseTo, OpReply)
+	binary.Write(&buf, binary.LittleEndian, int32(0))
+	binary.Write(&buf, binary.LittleEndian, int64(0))
+	binary.Write(&buf, binary.LittleEndian, int32(0))
+	binary.Write(&buf, binary.LittleEndian, int32(1))
+	buf.Write(doc)
+
+	return flush(w, buf.Bytes())
+}
+
+// writeHeader writes a standard message header.
+func writeHeader(buf *bytes.Buffer, length int, requestID, responseTo int32, op OpCode) {
+	binary.Write(buf, binary.LittleEndian, int32(length))
+	binary.Write(buf, binary.LittleEndian, requestID)
+	binary.Write(buf, binary.LittleEndian, responseTo)
+	binary.Write(buf, binary.LittleEndian, int32(op))
+}
+
+// flush writes the message and flushes buffered writers.
+func flush(w io.Writer, data []byte) error {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	if bw, ok := w.(*bufio.Writer); ok {
+		return bw.Flush()
+	}
+
+	return nil
+}
+
+// readDocument reads a BSON document from the start of data.
+func readDocument(data []byte) (bson.Raw, []byte, error) {
+	if len(data) < 5 {
+		return nil, nil, fmt.Errorf("%w: truncated document", ErrMalformedMessage)
+	}
+
+	size := int(binary.LittleEndian.Uint32(data))
+	if size < 5 || size > len(data) {
+		return nil, nil, fmt.Errorf("%w: invalid document size %d", ErrMalformedMessage, size)
+	}
+
+	doc := bson.Raw(data[:size])
+	if err := doc.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrMalformedMessage, err)
+	}
+
+	return doc, data[size:], nil
+}
+
+// readCString reads a null terminated string from the start of data.
+func readCString(data []byte) (string, []byte, error) {
+	idx := bytes.IndexByte(data, 0)
+	if idx < 0 {
+		return "", nil, fmt.Errorf("%w: unterminated string", ErrMalformedMessage)
+	}
+
+	return string(data[:idx]), data[idx+1:], nil
+}
@@ -0,0 +1,11 @@
+package gopherdb
+
+import (
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// NewStdLogger returns a storage logger that writes to the standard logger.
+func NewStdLogger() options.Logger {
+	return &storage.BadgerLogger{}
+}
@@ -1,11 +1,20 @@
 package gopherdb
 
+import "time"
+
 type DatabaseMetadata struct {
-	Name string `json:"name"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type CollectionMetadata struct {
-	Name          string       `json:"name"`
-	Indexes       []IndexModel `json:"indexes"`
-	DocumentCount int64        `json:"document_count"`
+	Name          string                `json:"name"`
+	Indexes       []IndexModel          `json:"indexes"`
+	DocumentCount int64                 `json:"document_count"`
+	Encryption    *EncryptionSchema     `json:"encryption"`
+	Validation    *CollectionValidation `json:"validation"`
+	IDStrategy    string                `json:"id_strategy"`
+	Sequence      int64                 `json:"sequence"`
+	Versioned     bool                  `json:"versioned"`
+	KeyFormat     int                   `json:"key_format"`
 }
@@ -0,0 +1,500 @@
+package gopherdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// oplogBatchSize is the number of entries an oplog cursor reads from storage at a time.
+const oplogBatchSize = 256
+
+// OplogEntry is an operation recorded in the oplog.
+type OplogEntry struct {
+	// Timestamp orders the entries. It is strictly increasing and is the time of the operation in
+	// nanoseconds since the Unix epoch, bumped when needed to stay monotonic.
+	Timestamp         uint64             `bson:"ts"`
+	Operation         OperationType      `bson:"op"`
+	Namespace         Namespace          `bson:"ns"`
+	DocumentID        any                `bson:"id"`
+	Document          map[string]any     `bson:"o,omitempty"`
+	UpdateDescription *UpdateDescription `bson:"u,omitempty"`
+	// To is the new namespace of a renamed collection.
+	To *Namespace `bson:"to,omitempty"`
+}
+
+// Time returns the time of the operation.
+func (e OplogEntry) Time() time.Time {
+	return time.Unix(0, int64(e.Timestamp)).UTC()
+}
+
+// oplogSlot is the timestamp and size of a stored oplog entry, kept to apply the retention.
+type oplogSlot struct {
+	ts   uint64
+	size int64
+}
+
+// oplog writes the operations of a database to a durable, ordered log.
+// Writes are serialized by the change hub of the database.
+type oplog struct {
+	dbname  string
+	storage storage.Storage
+	maxSize int64
+	maxAge  time.Duration
+
+	mu      sync.Mutex
+	lastTS  uint64
+	trimTS  uint64
+	slots   []oplogSlot
+	size    int64
+	changed chan struct{}
+	cursors map[*OplogCursor]struct{}
+}
+
+// newOplog opens the oplog of a database and applies its retention.
+func newOplog(engine storage.Storage, dbname string, opt *options.OplogOptions, readOnly bool) (*oplog, error) {
+	l := &oplog{
+		dbname:  dbname,
+		storage: engine,
+		changed: make(chan struct{}),
+		cursors: make(map[*OplogCursor]struct{}),
+	}
+
+	if opt.MaxSize != nil {
+		l.maxSize = *opt.MaxSize
+	}
+
+	if opt.MaxAge != nil {
+		l.maxAge = *opt.MaxAge
+	}
+
+	data, err := engine.Get(l.trimmedKey())
+	if err == nil {
+		l.trimTS, err = strconv.ParseUint(string(data), 10, 64)
+	}
+
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, err
+	}
+
+	kvs, err := engine.Scan(l.prefix())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kv := range kvs {
+		ts, err := l.parseKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		l.slots = append(l.slots, oplogSlot{ts: ts, size: int64(len(kv.Value))})
+		l.size += int64(len(kv.Value))
+		l.lastTS = ts
+	}
+
+	if readOnly {
+		return l, nil
+	}
+
+	txn := engine.BeginTx()
+
+	trimmed, size, err := l.trim(txn, l.size, time.Now())
+	if err != nil {
+		txn.Rollback()
+
+		return nil, err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return nil, err
+	}
+
+	if trimmed > 0 {
+		l.trimTS = l.slots[trimmed-1].ts
+	}
+
+	l.slots = l.slots[trimmed:]
+	l.size = size
+
+	return l, nil
+}
+
+// prefix returns the key prefix of the oplog entries.
+func (l *oplog) prefix() string {
+	return fmt.Sprintf(consts.OplogEntriesKeyStringFormat, l.dbname)
+}
+
+// buildKey builds the key of an oplog entry.
+func (l *oplog) buildKey(ts uint64) string {
+	return fmt.Sprintf(consts.OplogKeyStringFormat, l.dbname, ts)
+}
+
+// trimmedKey returns the key that keeps the timestamp of the last trimmed entry.
+func (l *oplog) trimmedKey() string {
+	return fmt.Sprintf(consts.OplogTrimmedKeyStringFormat, l.dbname)
+}
+
+// parseKey returns the timestamp of an oplog key.
+func (l *oplog) parseKey(key string) (uint64, error) {
+	match, err := consts.OplogKeyPathmatcher.Match(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(match["ts"], 10, 64)
+}
+
+// write writes the entries of the changes in the transaction and trims the entries that fall out
+// of the retention. The in-memory state is only updated by advance, once the transaction commits.
+func (l *oplog) write(txn storage.Transaction, changes []ChangeEvent, now time.Time) ([]oplogSlot, int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ts := l.lastTS
+	written := make([]oplogSlot, 0, len(changes))
+	size := l.size
+
+	for _, event := range changes {
+		ts = max(ts+1, uint64(now.UnixNano()))
+
+		entry := OplogEntry{
+			Timestamp:  ts,
+			Operation:  event.OperationType,
+			Namespace:  event.Namespace,
+			DocumentID: event.DocumentKey[consts.DocumentFieldID],
+		}
+
+		switch event.OperationType {
+		case OperationInsert, OperationReplace:
+			entry.Document = event.FullDocument
+		case OperationUpdate:
+			entry.UpdateDescription = event.UpdateDescription
+		case OperationRename:
+			entry.To = event.To
+		}
+
+		data, err := bson.Marshal(entry)
+		if err != nil {
+			return nil, 0, fmt.Errorf("oplog marshal failed: %w", err)
+		}
+
+		if err := txn.Put(l.buildKey(ts), data); err != nil {
+			return nil, 0, fmt.Errorf("oplog write failed: %w", err)
+		}
+
+		written = append(written, oplogSlot{ts: ts, size: int64(len(data))})
+		size += int64(len(data))
+	}
+
+	trimmed, _, err := l.trim(txn, size, now)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return written, trimmed, nil
+}
+
+// trim deletes in the transaction the oldest entries that exceed the retention, given the total
+// size of the oplog. It returns how many stored entries were deleted and the remaining size.
+func (l *oplog) trim(txn storage.Transaction, size int64, now time.Time) (int, int64, error) {
+	var cutoff uint64
+	if l.maxAge > 0 {
+		cutoff = uint64(max(now.Add(-l.maxAge).UnixNano(), 0))
+	}
+
+	trimmed := 0
+
+	for _, slot := range l.slots {
+		if (l.maxSize <= 0 || size <= l.maxSize) && slot.ts >= cutoff {
+			break
+		}
+
+		if err := txn.Delete(l.buildKey(slot.ts)); err != nil {
+			return 0, 0, fmt.Errorf("oplog trim failed: %w", err)
+		}
+
+		size -= slot.size
+		trimmed++
+	}
+
+	if trimmed > 0 {
+		ts := strconv.FormatUint(l.slots[trimmed-1].ts, 10)
+		if err := txn.Put(l.trimmedKey(), []byte(ts)); err != nil {
+			return 0, 0, fmt.Errorf("oplog trim failed: %w", err)
+		}
+	}
+
+	return trimmed, size, nil
+}
+
+// advance applies a committed write to the in-memory state and wakes up the tailing cursors.
+func (l *oplog) advance(written []oplogSlot, trimmed int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, slot := range l.slots[:trimmed] {
+		l.size -= slot.size
+		l.trimTS = slot.ts
+	}
+
+	l.slots = l.slots[trimmed:]
+
+	for _, slot := range written {
+		l.slots = append(l.slots, slot)
+		l.size += slot.size
+		l.lastTS = slot.ts
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// last returns the timestamp of the last entry.
+func (l *oplog) last() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lastTS
+}
+
+// covers reports whether every entry after the given timestamp is still in the oplog.
+func (l *oplog) covers(after uint64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return after >= l.trimTS
+}
+
+// wait returns a channel that is closed when new entries are written.
+func (l *oplog) wait() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.changed
+}
+
+// register registers a cursor so it is closed with the database.
+func (l *oplog) register(cur *OplogCursor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cursors[cur] = struct{}{}
+}
+
+// unregister unregisters a cursor.
+func (l *oplog) unregister(cur *OplogCursor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.cursors, cur)
+}
+
+// closeAll closes every cursor of the oplog.
+func (l *oplog) closeAll() {
+	l.mu.Lock()
+	cursors := make([]*OplogCursor, 0, len(l.cursors))
+
+	for cur := range l.cursors {
+		cursors = append(cursors, cur)
+	}
+	l.mu.Unlock()
+
+	for _, cur := range cursors {
+		cur.Close()
+	}
+}
+
+// read reads up to oplogBatchSize entries after the given timestamp.
+func (l *oplog) read(ctx context.Context, after uint64) ([]OplogEntry, error) {
+	keys, err := l.storage.ScanKeys(l.prefix())
+	if err != nil {
+		return nil, err
+	}
+
+	// Las claves llevan el timestamp con ceros a la izquierda, así que el orden es el del oplog.
+	from, _ := slices.BinarySearch(keys, l.buildKey(after+1))
+	entries := make([]OplogEntry, 0, min(len(keys)-from, oplogBatchSize))
+
+	for _, key := range keys[from:] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		data, err := l.storage.Get(key)
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			// La entrada se recortó por la retención después de listar las claves.
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		var entry OplogEntry
+		if err := bson.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("oplog unmarshal failed: %w", err)
+		}
+
+		entries = append(entries, entry)
+
+		if len(entries) == oplogBatchSize {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// OplogCursor reads the entries of the oplog in order.
+type OplogCursor struct {
+	oplog   *oplog
+	follow  bool
+	lastTS  uint64
+	batch   []OplogEntry
+	current OplogEntry
+	done    chan struct{}
+	once    sync.Once
+	err     error
+}
+
+// TailOplog returns a cursor over the oplog of the database. With the follow option the cursor
+// waits for new entries once it reaches the end of the oplog.
+func (db *Database) TailOplog(ctx context.Context, opts ...*options.OplogTailOptions) (*OplogCursor, error) {
+	if db.changes.oplog == nil {
+		return nil, ErrOplogDisabled
+	}
+
+	opt := options.OplogTail()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	cursor := &OplogCursor{
+		oplog: db.changes.oplog,
+		done:  make(chan struct{}),
+	}
+
+	if opt.StartAfter != nil {
+		cursor.lastTS = *opt.StartAfter
+	}
+
+	if opt.Follow != nil {
+		cursor.follow = *opt.Follow
+	}
+
+	cursor.oplog.register(cursor)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cursor.Close()
+		case <-cursor.done:
+		}
+	}()
+
+	return cursor, nil
+}
+
+// Next advances the cursor to the next entry and reports whether there is one.
+// It returns false at the end of the oplog, unless the cursor follows it, when the context is done,
+// the cursor is closed or an error occurs.
+func (cur *OplogCursor) Next(ctx context.Context) bool {
+	for {
+		if cur.err != nil || cur.isClosed() {
+			return false
+		}
+
+		if len(cur.batch) > 0 {
+			cur.current = cur.batch[0]
+			cur.batch = cur.batch[1:]
+			cur.lastTS = cur.current.Timestamp
+
+			return true
+		}
+
+		// Tomamos el canal antes de leer para no perder escrituras concurrentes.
+		changed := cur.oplog.wait()
+
+		batch, err := cur.oplog.read(ctx, cur.lastTS)
+		if err != nil {
+			cur.err = err
+
+			return false
+		}
+
+		if len(batch) > 0 {
+			cur.batch = batch
+
+			continue
+		}
+
+		if !cur.follow {
+			return false
+		}
+
+		select {
+		case <-ctx.Done():
+			cur.err = ctx.Err()
+
+			return false
+		case <-cur.done:
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// isClosed reports whether the cursor is closed.
+func (cur *OplogCursor) isClosed() bool {
+	select {
+	case <-cur.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Entry returns the current entry.
+func (cur *OplogCursor) Entry() OplogEntry {
+	return cur.current
+}
+
+// Decode unmarshals the current entry into v.
+func (cur *OplogCursor) Decode(v any) error {
+	data, err := bson.Marshal(cur.current)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(data, v)
+}
+
+// Timestamp returns the timestamp of the last entry read, to resume reading after it.
+func (cur *OplogCursor) Timestamp() uint64 {
+	return cur.lastTS
+}
+
+// Err returns the error that stopped the cursor, if any.
+func (cur *OplogCursor) Err() error {
+	return cur.err
+}
+
+// Close closes the cursor.
+func (cur *OplogCursor) Close() error {
+	cur.once.Do(func() {
+		close(cur.done)
+		cur.oplog.unregister(cur)
+	})
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package options
+
+// AuditOptions es un struct que contiene las opciones del registro de auditoría de una base de datos.
+type AuditOptions struct {
+	Path       *string
+	MaxSize    *int64
+	MaxFiles   *int
+	Collection *string
+	Principal  *string
+}
+
+// Audit crea una nueva instancia de auditOptions.
+func Audit() *AuditOptions {
+	return &AuditOptions{}
+}
+
+// Merge combina las opciones de varios registros de auditoría.
+func (o *AuditOptions) Merge(opts ...*AuditOptions) *AuditOptions {
+	for _, opt := range opts {
+		if opt.Path != nil {
+			o.Path = opt.Path
+		}
+
+		if opt.MaxSize != nil {
+			o.MaxSize = opt.MaxSize
+		}
+
+		if opt.MaxFiles != nil {
+			o.MaxFiles = opt.MaxFiles
+		}
+
+		if opt.Collection != nil {
+			o.Collection = opt.Collection
+		}
+
+		if opt.Principal != nil {
+			o.Principal = opt.Principal
+		}
+	}
+
+	return o
+}
+
+// SetPath establece el archivo NDJSON en el que se escriben los registros de auditoría.
+func (o *AuditOptions) SetPath(path string) *AuditOptions {
+	o.Path = &path
+
+	return o
+}
+
+// SetMaxSize establece el tamaño en bytes a partir del cual se rota el archivo. Con 0, el valor por
+// defecto, el archivo no se rota.
+func (o *AuditOptions) SetMaxSize(size int64) *AuditOptions {
+	o.MaxSize = &size
+
+	return o
+}
+
+// SetMaxFiles establece cuántos archivos rotados se conservan. Con 0, el valor por defecto, se
+// conservan todos.
+func (o *AuditOptions) SetMaxFiles(n int) *AuditOptions {
+	o.MaxFiles = &n
+
+	return o
+}
+
+// SetCollection establece la colección interna de la base de datos en la que se guardan los
+// registros de auditoría.
+func (o *AuditOptions) SetCollection(name string) *AuditOptions {
+	o.Collection = &name
+
+	return o
+}
+
+// SetPrincipal establece la etiqueta con la que se registran las operaciones de quien usa la base
+// de datos embebida.
+func (o *AuditOptions) SetPrincipal(principal string) *AuditOptions {
+	o.Principal = &principal
+
+	return o
+}
@@ -0,0 +1,35 @@
+package options
+
+// BulkWriteOptions es un struct que contiene las opciones de una escritura en bloque.
+type BulkWriteOptions struct {
+	Ordered *bool
+}
+
+// BulkWrite crea una nueva instancia de BulkWriteOptions. Por defecto las operaciones se ejecutan
+// en orden.
+func BulkWrite() *BulkWriteOptions {
+	ordered := true
+
+	return &BulkWriteOptions{
+		Ordered: &ordered,
+	}
+}
+
+// Merge combina las opciones de varias escrituras en bloque.
+func (o *BulkWriteOptions) Merge(opts ...*BulkWriteOptions) *BulkWriteOptions {
+	for _, opt := range opts {
+		if opt.Ordered != nil {
+			o.Ordered = opt.Ordered
+		}
+	}
+
+	return o
+}
+
+// SetOrdered establece si las operaciones se ejecutan en orden, deteniéndose en la primera que
+// falla, o si se ejecutan todas aunque alguna falle.
+func (o *BulkWriteOptions) SetOrdered(ordered bool) *BulkWriteOptions {
+	o.Ordered = &ordered
+
+	return o
+}
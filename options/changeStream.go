@@ -0,0 +1,66 @@
+package options
+
+const (
+	// FullDocumentDefault incluye el documento completo solo en inserciones y reemplazos.
+	FullDocumentDefault = "default"
+	// FullDocumentUpdateLookup incluye también el documento resultante de las actualizaciones.
+	FullDocumentUpdateLookup = "updateLookup"
+	// FullDocumentWhenAvailable incluye la imagen del documento cuando está disponible.
+	FullDocumentWhenAvailable = "whenAvailable"
+	// FullDocumentRequired incluye la imagen del documento y falla si no está disponible.
+	FullDocumentRequired = "required"
+	// FullDocumentOff no incluye la imagen del documento.
+	FullDocumentOff = "off"
+)
+
+// ChangeStreamOptions es un struct que contiene las opciones para un change stream.
+type ChangeStreamOptions struct {
+	FullDocument             *string
+	FullDocumentBeforeChange *string
+	ResumeAfter              *string
+}
+
+// ChangeStream crea una nueva instancia de changeStreamOptions.
+func ChangeStream() *ChangeStreamOptions {
+	return &ChangeStreamOptions{}
+}
+
+// Merge combina las opciones de varios change streams.
+func (o *ChangeStreamOptions) Merge(opts ...*ChangeStreamOptions) *ChangeStreamOptions {
+	for _, opt := range opts {
+		if opt.FullDocument != nil {
+			o.FullDocument = opt.FullDocument
+		}
+
+		if opt.FullDocumentBeforeChange != nil {
+			o.FullDocumentBeforeChange = opt.FullDocumentBeforeChange
+		}
+
+		if opt.ResumeAfter != nil {
+			o.ResumeAfter = opt.ResumeAfter
+		}
+	}
+
+	return o
+}
+
+// SetFullDocument establece cuándo se incluye el documento después del cambio.
+func (o *ChangeStreamOptions) SetFullDocument(fullDocument string) *ChangeStreamOptions {
+	o.FullDocument = &fullDocument
+
+	return o
+}
+
+// SetFullDocumentBeforeChange establece cuándo se incluye el documento antes del cambio.
+func (o *ChangeStreamOptions) SetFullDocumentBeforeChange(fullDocument string) *ChangeStreamOptions {
+	o.FullDocumentBeforeChange = &fullDocument
+
+	return o
+}
+
+// SetResumeAfter establece el resume token a partir del cual se reanuda el change stream.
+func (o *ChangeStreamOptions) SetResumeAfter(token string) *ChangeStreamOptions {
+	o.ResumeAfter = &token
+
+	return o
+}
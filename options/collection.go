@@ -0,0 +1,117 @@
+package options
+
+const (
+	// ValidationLevelStrict valida todas las inserciones y actualizaciones.
+	ValidationLevelStrict = "strict"
+	// ValidationLevelModerate valida las inserciones y las actualizaciones de documentos que ya
+	// cumplían el validador; los documentos que no lo cumplían se pueden actualizar libremente.
+	ValidationLevelModerate = "moderate"
+
+	// ValidationActionError rechaza las escrituras de documentos que no cumplen el validador.
+	ValidationActionError = "error"
+	// ValidationActionWarn acepta las escrituras y registra una advertencia en el logger.
+	ValidationActionWarn = "warn"
+
+	// IDStrategyUUID genera los _id como cadenas UUID v4. Es la estrategia por defecto.
+	IDStrategyUUID = "uuid"
+	// IDStrategyUUIDv7 genera los _id como cadenas UUID v7, ordenadas por fecha de creación.
+	IDStrategyUUIDv7 = "uuidv7"
+	// IDStrategyObjectID genera los _id como primitive.ObjectID, como los drivers de MongoDB. Un
+	// _id NilObjectID se trata como ausente.
+	IDStrategyObjectID = "objectId"
+	// IDStrategyULID genera los _id como cadenas ULID, ordenadas por fecha de creación.
+	IDStrategyULID = "ulid"
+	// IDStrategySequence genera los _id como int64 consecutivos a partir de 1. El contador se guarda
+	// en los metadatos de la colección. Un _id 0, como el de un struct sin asignar, se trata como
+	// ausente.
+	IDStrategySequence = "sequence"
+	// IDStrategyProvided no genera los _id: los documentos sin _id se rechazan.
+	IDStrategyProvided = "provided"
+)
+
+// CollectionOptions es un struct que contiene las opciones de una colección.
+type CollectionOptions struct {
+	Validator        map[string]any
+	ValidationLevel  *string
+	ValidationAction *string
+	IDStrategy       *string
+	Versioned        *bool
+}
+
+// Collection crea una nueva instancia de collectionOptions.
+func Collection() *CollectionOptions {
+	return &CollectionOptions{}
+}
+
+// Merge combina las opciones de varias colecciones.
+func (o *CollectionOptions) Merge(opts ...*CollectionOptions) *CollectionOptions {
+	for _, opt := range opts {
+		if opt.Validator != nil {
+			o.Validator = opt.Validator
+		}
+
+		if opt.ValidationLevel != nil {
+			o.ValidationLevel = opt.ValidationLevel
+		}
+
+		if opt.ValidationAction != nil {
+			o.ValidationAction = opt.ValidationAction
+		}
+
+		if opt.IDStrategy != nil {
+			o.IDStrategy = opt.IDStrategy
+		}
+
+		if opt.Versioned != nil {
+			o.Versioned = opt.Versioned
+		}
+	}
+
+	return o
+}
+
+// SetValidator establece el validador de los documentos, un documento {$jsonSchema: {...}}. Un
+// validador vacío elimina la validación de la colección.
+func (o *CollectionOptions) SetValidator(validator map[string]any) *CollectionOptions {
+	if validator == nil {
+		validator = map[string]any{}
+	}
+
+	o.Validator = validator
+
+	return o
+}
+
+// SetValidationLevel establece qué escrituras se validan: ValidationLevelStrict (por defecto) o
+// ValidationLevelModerate.
+func (o *CollectionOptions) SetValidationLevel(level string) *CollectionOptions {
+	o.ValidationLevel = &level
+
+	return o
+}
+
+// SetValidationAction establece qué ocurre con los documentos que no cumplen el validador:
+// ValidationActionError (por defecto) o ValidationActionWarn.
+func (o *CollectionOptions) SetValidationAction(action string) *CollectionOptions {
+	o.ValidationAction = &action
+
+	return o
+}
+
+// SetIDStrategy establece cómo se generan los _id de los documentos que no lo tienen:
+// IDStrategyUUID (por defecto), IDStrategyUUIDv7, IDStrategyObjectID, IDStrategyULID,
+// IDStrategySequence o IDStrategyProvided.
+func (o *CollectionOptions) SetIDStrategy(strategy string) *CollectionOptions {
+	o.IDStrategy = &strategy
+
+	return o
+}
+
+// SetVersioned establece si los documentos de la colección llevan un campo _version, que empieza
+// en 1 y aumenta con cada modificación, para el control de concurrencia optimista (ver
+// UpdateOptions.SetVersion).
+func (o *CollectionOptions) SetVersioned(versioned bool) *CollectionOptions {
+	o.Versioned = &versioned
+
+	return o
+}
@@ -0,0 +1,273 @@
+package options
+
+import "time"
+
+const (
+	// EngineBadger es el nombre del motor de almacenamiento en disco basado en BadgerDB.
+	EngineBadger = "badger"
+	// EngineMemory es el nombre del motor de almacenamiento en memoria.
+	EngineMemory = "memory"
+)
+
+// Compression es el algoritmo de compresión de los bloques de datos.
+type Compression string
+
+const (
+	// CompressionNone desactiva la compresión.
+	CompressionNone Compression = "none"
+	// CompressionSnappy comprime los bloques con Snappy.
+	CompressionSnappy Compression = "snappy"
+	// CompressionZSTD comprime los bloques con ZSTD.
+	CompressionZSTD Compression = "zstd"
+)
+
+// Logger es la interfaz que debe implementar un logger del motor de almacenamiento.
+type Logger interface {
+	Errorf(format string, args ...any)
+	Warningf(format string, args ...any)
+	Infof(format string, args ...any)
+	Debugf(format string, args ...any)
+}
+
+// DatabaseOptions es un struct que contiene las opciones para abrir una base de datos.
+type DatabaseOptions struct {
+	Engine            *string
+	EngineOptions     map[string]any
+	InMemory          *bool
+	SyncWrites        *bool
+	Compression       *Compression
+	BlockCacheSize    *int64
+	IndexCacheSize    *int64
+	ValueThreshold    *int64
+	ValueLogFileSize  *int64
+	MemTableSize      *int64
+	ReadOnly          *bool
+	EncryptionKey     []byte
+	KeyRotation       *time.Duration
+	Logger            Logger
+	ChangeHistory     *int64
+	Oplog             *OplogOptions
+	Audit             *AuditOptions
+	NumVersionsToKeep *int64
+}
+
+// Database crea una nueva instancia de databaseOptions.
+func Database() *DatabaseOptions {
+	return &DatabaseOptions{}
+}
+
+// Merge combina las opciones de varias bases de datos.
+func (o *DatabaseOptions) Merge(opts ...*DatabaseOptions) *DatabaseOptions {
+	for _, opt := range opts {
+		if opt.Engine != nil {
+			o.Engine = opt.Engine
+		}
+
+		for k, v := range opt.EngineOptions {
+			o.SetEngineOption(k, v)
+		}
+
+		if opt.InMemory != nil {
+			o.InMemory = opt.InMemory
+		}
+
+		if opt.SyncWrites != nil {
+			o.SyncWrites = opt.SyncWrites
+		}
+
+		if opt.Compression != nil {
+			o.Compression = opt.Compression
+		}
+
+		if opt.BlockCacheSize != nil {
+			o.BlockCacheSize = opt.BlockCacheSize
+		}
+
+		if opt.IndexCacheSize != nil {
+			o.IndexCacheSize = opt.IndexCacheSize
+		}
+
+		if opt.ValueThreshold != nil {
+			o.ValueThreshold = opt.ValueThreshold
+		}
+
+		if opt.ValueLogFileSize != nil {
+			o.ValueLogFileSize = opt.ValueLogFileSize
+		}
+
+		if opt.MemTableSize != nil {
+			o.MemTableSize = opt.MemTableSize
+		}
+
+		if opt.ReadOnly != nil {
+			o.ReadOnly = opt.ReadOnly
+		}
+
+		if opt.EncryptionKey != nil {
+			o.EncryptionKey = opt.EncryptionKey
+		}
+
+		if opt.KeyRotation != nil {
+			o.KeyRotation = opt.KeyRotation
+		}
+
+		if opt.Logger != nil {
+			o.Logger = opt.Logger
+		}
+
+		if opt.ChangeHistory != nil {
+			o.ChangeHistory = opt.ChangeHistory
+		}
+
+		if opt.Oplog != nil {
+			o.Oplog = opt.Oplog
+		}
+
+		if opt.Audit != nil {
+			o.Audit = opt.Audit
+		}
+
+		if opt.NumVersionsToKeep != nil {
+			o.NumVersionsToKeep = opt.NumVersionsToKeep
+		}
+	}
+
+	return o
+}
+
+// SetEngine establece el motor de almacenamiento de la base de datos.
+func (o *DatabaseOptions) SetEngine(engine string) *DatabaseOptions {
+	o.Engine = &engine
+
+	return o
+}
+
+// SetEngineOption establece una opción específica del motor de almacenamiento.
+func (o *DatabaseOptions) SetEngineOption(key string, value any) *DatabaseOptions {
+	if o.EngineOptions == nil {
+		o.EngineOptions = make(map[string]any)
+	}
+
+	o.EngineOptions[key] = value
+
+	return o
+}
+
+// SetInMemory establece si los datos de Badger se mantienen solo en memoria.
+func (o *DatabaseOptions) SetInMemory(inMemory bool) *DatabaseOptions {
+	o.InMemory = &inMemory
+
+	return o
+}
+
+// SetSyncWrites establece si cada escritura se sincroniza con el disco antes de retornar.
+func (o *DatabaseOptions) SetSyncWrites(syncWrites bool) *DatabaseOptions {
+	o.SyncWrites = &syncWrites
+
+	return o
+}
+
+// SetCompression establece el algoritmo de compresión de los bloques.
+func (o *DatabaseOptions) SetCompression(compression Compression) *DatabaseOptions {
+	o.Compression = &compression
+
+	return o
+}
+
+// SetBlockCacheSize establece el tamaño en bytes de la caché de bloques.
+func (o *DatabaseOptions) SetBlockCacheSize(size int64) *DatabaseOptions {
+	o.BlockCacheSize = &size
+
+	return o
+}
+
+// SetIndexCacheSize establece el tamaño en bytes de la caché de índices.
+func (o *DatabaseOptions) SetIndexCacheSize(size int64) *DatabaseOptions {
+	o.IndexCacheSize = &size
+
+	return o
+}
+
+// SetValueThreshold establece el tamaño a partir del cual los valores se guardan en el value log.
+func (o *DatabaseOptions) SetValueThreshold(threshold int64) *DatabaseOptions {
+	o.ValueThreshold = &threshold
+
+	return o
+}
+
+// SetValueLogFileSize establece el tamaño máximo en bytes de cada archivo del value log.
+func (o *DatabaseOptions) SetValueLogFileSize(size int64) *DatabaseOptions {
+	o.ValueLogFileSize = &size
+
+	return o
+}
+
+// SetMemTableSize establece el tamaño en bytes de la memtable.
+func (o *DatabaseOptions) SetMemTableSize(size int64) *DatabaseOptions {
+	o.MemTableSize = &size
+
+	return o
+}
+
+// SetReadOnly establece si la base de datos se abre en modo solo lectura.
+func (o *DatabaseOptions) SetReadOnly(readOnly bool) *DatabaseOptions {
+	o.ReadOnly = &readOnly
+
+	return o
+}
+
+// SetEncryptionKey establece la clave AES de 16, 24 o 32 bytes para cifrar los datos.
+func (o *DatabaseOptions) SetEncryptionKey(key []byte) *DatabaseOptions {
+	o.EncryptionKey = key
+
+	return o
+}
+
+// SetKeyRotation establece cada cuánto se genera una nueva clave de datos para el cifrado.
+func (o *DatabaseOptions) SetKeyRotation(d time.Duration) *DatabaseOptions {
+	o.KeyRotation = &d
+
+	return o
+}
+
+// SetLogger establece el logger del motor de almacenamiento.
+func (o *DatabaseOptions) SetLogger(logger Logger) *DatabaseOptions {
+	o.Logger = logger
+
+	return o
+}
+
+// SetChangeHistory establece cuántos eventos de cambio se persisten para reanudar change streams.
+// Con 0, el valor por defecto, los change streams solo reciben cambios en vivo.
+func (o *DatabaseOptions) SetChangeHistory(events int64) *DatabaseOptions {
+	o.ChangeHistory = &events
+
+	return o
+}
+
+// SetOplog activa el oplog de la base de datos con las opciones de retención dadas.
+func (o *DatabaseOptions) SetOplog(oplog *OplogOptions) *DatabaseOptions {
+	o.Oplog = oplog
+
+	return o
+}
+
+// SetAudit activa el registro de auditoría de las escrituras, los cambios de índices y las
+// eliminaciones de colecciones de la base de datos.
+func (o *DatabaseOptions) SetAudit(audit *AuditOptions) *DatabaseOptions {
+	o.Audit = audit
+
+	return o
+}
+
+// SetNumVersionsToKeep establece cuántas versiones de cada documento guarda el motor de
+// almacenamiento para consultarlas con Database.SnapshotAt, FindOptions.SetReadAt y
+// Collection.History. Por defecto se guarda solo la última. Badger descarta las versiones
+// sobrantes al compactar, así que pueden seguir visibles un tiempo con Collection.History. Leer un
+// commit del que se pudo descartar alguna versión falla con ErrSnapshotTooOld, igual que leer uno
+// anterior a la apertura de una base de datos en disco.
+func (o *DatabaseOptions) SetNumVersionsToKeep(versions int64) *DatabaseOptions {
+	o.NumVersionsToKeep = &versions
+
+	return o
+}
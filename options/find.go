@@ -1,5 +1,14 @@
 package options
 
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 // SortField es un struct que contiene el campo y el orden para una consulta.
 type SortField struct {
 	Field string
@@ -8,9 +17,12 @@ type SortField struct {
 
 // FindOptions es un struct que contiene las opciones para una consulta.
 type FindOptions struct {
-	Skip  *int64
-	Limit *int64
-	Sort  []SortField
+	Skip    *int64
+	Limit   *int64
+	Sort    []SortField
+	ReadAt  *uint64
+	MaxTime *time.Duration
+	err     error
 }
 
 // Find crea una nueva instancia de findOptions.
@@ -32,6 +44,18 @@ func (o *FindOptions) Merge(opts ...*FindOptions) *FindOptions {
 		if opt.Sort != nil {
 			o.Sort = opt.Sort
 		}
+
+		if opt.ReadAt != nil {
+			o.ReadAt = opt.ReadAt
+		}
+
+		if opt.MaxTime != nil {
+			o.MaxTime = opt.MaxTime
+		}
+
+		if opt.err != nil {
+			o.err = opt.err
+		}
 	}
 
 	return o
@@ -51,13 +75,118 @@ func (o *FindOptions) SetLimit(limit int64) *FindOptions {
 	return o
 }
 
-// SetSort establece el campo y el orden para una consulta.
-func (o *FindOptions) SetSort(sort SortField) *FindOptions {
-	if o.Sort == nil {
-		o.Sort = make([]SortField, 0)
-	}
+// SetSort añade campos al orden de una consulta. El orden puede ser un SortField, un []SortField,
+// un bson.D, un bson.M o un map[string]any con 1 (ascendente) o -1 (descendente) por campo. Los
+// campos de un mapa no tienen orden, así que se añaden por nombre; un bson.D conserva el orden de
+// sus campos. Un orden inválido se devuelve en Validate y hace fallar la consulta.
+func (o *FindOptions) SetSort(sort any) *FindOptions {
+	o.Sort, o.err = appendSort(o.Sort, o.err, sort)
+
+	return o
+}
 
-	o.Sort = append(o.Sort, sort)
+// SetReadAt establece el timestamp del commit en el que se lee la colección, como lo devuelve
+// Database.CommitTimestamp: la consulta ve los documentos tal como estaban tras ese commit. Ver
+// Database.SnapshotAt.
+func (o *FindOptions) SetReadAt(ts uint64) *FindOptions {
+	o.ReadAt = &ts
 
 	return o
 }
+
+// SetMaxTime establece el tiempo máximo de ejecución de la consulta. Una consulta que lo supera
+// falla con ErrMaxTimeExceeded.
+func (o *FindOptions) SetMaxTime(d time.Duration) *FindOptions {
+	o.MaxTime = &d
+
+	return o
+}
+
+// Validate devuelve el error de las opciones inválidas, como un orden mal formado.
+func (o *FindOptions) Validate() error {
+	return o.err
+}
+
+// appendSort añade a un orden los campos de una especificación de orden. Devuelve el orden sin
+// cambios y el error si la especificación no es válida, o el error anterior.
+func appendSort(fields []SortField, err error, sort any) ([]SortField, error) {
+	added, sortErr := sortFields(sort)
+	if sortErr != nil {
+		return fields, sortErr
+	}
+
+	if fields == nil {
+		fields = make([]SortField, 0, len(added))
+	}
+
+	return append(fields, added...), err
+}
+
+// sortFields convierte una especificación de orden en campos de orden.
+func sortFields(sort any) ([]SortField, error) {
+	switch typed := sort.(type) {
+	case SortField:
+		return []SortField{typed}, nil
+	case []SortField:
+		return typed, nil
+	case primitive.D:
+		fields := make([]SortField, 0, len(typed))
+
+		for _, e := range typed {
+			order, err := sortOrder(e.Key, e.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, SortField{Field: e.Key, Order: order})
+		}
+
+		return fields, nil
+	case primitive.M:
+		return sortFields(map[string]any(typed))
+	case map[string]any:
+		keys := make([]string, 0, len(typed))
+		for k := range typed {
+			keys = append(keys, k)
+		}
+
+		slices.Sort(keys)
+
+		spec := make(primitive.D, 0, len(keys))
+		for _, k := range keys {
+			spec = append(spec, primitive.E{Key: k, Value: typed[k]})
+		}
+
+		return sortFields(spec)
+	}
+
+	if rv := reflect.ValueOf(sort); rv.IsValid() && rv.Type().ConvertibleTo(reflect.TypeFor[primitive.D]()) {
+		return sortFields(rv.Convert(reflect.TypeFor[primitive.D]()).Interface())
+	}
+
+	return nil, fmt.Errorf("invalid sort specification of type %T", sort)
+}
+
+// sortOrder devuelve el orden de un campo, que debe ser 1 o -1.
+func sortOrder(field string, v any) (int, error) {
+	var order int
+
+	switch n := v.(type) {
+	case int:
+		order = n
+	case int32:
+		order = int(n)
+	case int64:
+		order = int(n)
+	case float64:
+		if n == float64(int(n)) {
+			order = int(n)
+		}
+	}
+
+	if order != 1 && order != -1 {
+		return 0, fmt.Errorf("invalid sort order %v for field %s", v, field)
+	}
+
+	return order, nil
+}
@@ -0,0 +1,227 @@
+package options
+
+import "fmt"
+
+const (
+	// ReturnDocumentBefore devuelve el documento tal como estaba antes de modificarlo. Es el valor
+	// por defecto.
+	ReturnDocumentBefore = "before"
+	// ReturnDocumentAfter devuelve el documento tal como queda después de modificarlo.
+	ReturnDocumentAfter = "after"
+)
+
+// FindOneAndUpdateOptions es un struct que contiene las opciones de FindOneAndUpdate.
+type FindOneAndUpdateOptions struct {
+	Sort           []SortField
+	Projection     map[string]any
+	Upsert         *bool
+	ReturnDocument *string
+	err            error
+}
+
+// FindOneAndUpdate crea una nueva instancia de FindOneAndUpdateOptions.
+func FindOneAndUpdate() *FindOneAndUpdateOptions {
+	return &FindOneAndUpdateOptions{}
+}
+
+// Merge combina las opciones de varias operaciones.
+func (o *FindOneAndUpdateOptions) Merge(opts ...*FindOneAndUpdateOptions) *FindOneAndUpdateOptions {
+	for _, opt := range opts {
+		if opt.Sort != nil {
+			o.Sort = opt.Sort
+		}
+
+		if opt.Projection != nil {
+			o.Projection = opt.Projection
+		}
+
+		if opt.Upsert != nil {
+			o.Upsert = opt.Upsert
+		}
+
+		if opt.ReturnDocument != nil {
+			o.ReturnDocument = opt.ReturnDocument
+		}
+
+		if opt.err != nil {
+			o.err = opt.err
+		}
+	}
+
+	return o
+}
+
+// SetSort añade campos al orden que decide qué documento se modifica cuando varios cumplen el
+// filtro. Acepta las mismas especificaciones que FindOptions.SetSort.
+func (o *FindOneAndUpdateOptions) SetSort(sort any) *FindOneAndUpdateOptions {
+	o.Sort, o.err = appendSort(o.Sort, o.err, sort)
+
+	return o
+}
+
+// SetProjection establece los campos del documento devuelto.
+func (o *FindOneAndUpdateOptions) SetProjection(projection map[string]any) *FindOneAndUpdateOptions {
+	o.Projection = projection
+
+	return o
+}
+
+// SetUpsert establece si se inserta un documento cuando ninguno cumple el filtro.
+func (o *FindOneAndUpdateOptions) SetUpsert(upsert bool) *FindOneAndUpdateOptions {
+	o.Upsert = &upsert
+
+	return o
+}
+
+// SetReturnDocument establece qué versión del documento se devuelve: ReturnDocumentBefore o
+// ReturnDocumentAfter.
+func (o *FindOneAndUpdateOptions) SetReturnDocument(returnDocument string) *FindOneAndUpdateOptions {
+	o.ReturnDocument = &returnDocument
+
+	return o
+}
+
+// Validate devuelve el error de las opciones inválidas, como un orden mal formado.
+func (o *FindOneAndUpdateOptions) Validate() error {
+	return validateReturnDocument(o.err, o.ReturnDocument)
+}
+
+// FindOneAndReplaceOptions es un struct que contiene las opciones de FindOneAndReplace.
+type FindOneAndReplaceOptions struct {
+	Sort           []SortField
+	Projection     map[string]any
+	Upsert         *bool
+	ReturnDocument *string
+	err            error
+}
+
+// FindOneAndReplace crea una nueva instancia de FindOneAndReplaceOptions.
+func FindOneAndReplace() *FindOneAndReplaceOptions {
+	return &FindOneAndReplaceOptions{}
+}
+
+// Merge combina las opciones de varias operaciones.
+func (o *FindOneAndReplaceOptions) Merge(opts ...*FindOneAndReplaceOptions) *FindOneAndReplaceOptions {
+	for _, opt := range opts {
+		if opt.Sort != nil {
+			o.Sort = opt.Sort
+		}
+
+		if opt.Projection != nil {
+			o.Projection = opt.Projection
+		}
+
+		if opt.Upsert != nil {
+			o.Upsert = opt.Upsert
+		}
+
+		if opt.ReturnDocument != nil {
+			o.ReturnDocument = opt.ReturnDocument
+		}
+
+		if opt.err != nil {
+			o.err = opt.err
+		}
+	}
+
+	return o
+}
+
+// SetSort añade campos al orden que decide qué documento se reemplaza cuando varios cumplen el
+// filtro. Acepta las mismas especificaciones que FindOptions.SetSort.
+func (o *FindOneAndReplaceOptions) SetSort(sort any) *FindOneAndReplaceOptions {
+	o.Sort, o.err = appendSort(o.Sort, o.err, sort)
+
+	return o
+}
+
+// SetProjection establece los campos del documento devuelto.
+func (o *FindOneAndReplaceOptions) SetProjection(projection map[string]any) *FindOneAndReplaceOptions {
+	o.Projection = projection
+
+	return o
+}
+
+// SetUpsert establece si se inserta el documento cuando ninguno cumple el filtro.
+func (o *FindOneAndReplaceOptions) SetUpsert(upsert bool) *FindOneAndReplaceOptions {
+	o.Upsert = &upsert
+
+	return o
+}
+
+// SetReturnDocument establece qué versión del documento se devuelve: ReturnDocumentBefore o
+// ReturnDocumentAfter.
+func (o *FindOneAndReplaceOptions) SetReturnDocument(returnDocument string) *FindOneAndReplaceOptions {
+	o.ReturnDocument = &returnDocument
+
+	return o
+}
+
+// Validate devuelve el error de las opciones inválidas, como un orden mal formado.
+func (o *FindOneAndReplaceOptions) Validate() error {
+	return validateReturnDocument(o.err, o.ReturnDocument)
+}
+
+// FindOneAndDeleteOptions es un struct que contiene las opciones de FindOneAndDelete.
+type FindOneAndDeleteOptions struct {
+	Sort       []SortField
+	Projection map[string]any
+	err        error
+}
+
+// FindOneAndDelete crea una nueva instancia de FindOneAndDeleteOptions.
+func FindOneAndDelete() *FindOneAndDeleteOptions {
+	return &FindOneAndDeleteOptions{}
+}
+
+// Merge combina las opciones de varias operaciones.
+func (o *FindOneAndDeleteOptions) Merge(opts ...*FindOneAndDeleteOptions) *FindOneAndDeleteOptions {
+	for _, opt := range opts {
+		if opt.Sort != nil {
+			o.Sort = opt.Sort
+		}
+
+		if opt.Projection != nil {
+			o.Projection = opt.Projection
+		}
+
+		if opt.err != nil {
+			o.err = opt.err
+		}
+	}
+
+	return o
+}
+
+// SetSort añade campos al orden que decide qué documento se elimina cuando varios cumplen el
+// filtro. Acepta las mismas especificaciones que FindOptions.SetSort.
+func (o *FindOneAndDeleteOptions) SetSort(sort any) *FindOneAndDeleteOptions {
+	o.Sort, o.err = appendSort(o.Sort, o.err, sort)
+
+	return o
+}
+
+// SetProjection establece los campos del documento devuelto.
+func (o *FindOneAndDeleteOptions) SetProjection(projection map[string]any) *FindOneAndDeleteOptions {
+	o.Projection = projection
+
+	return o
+}
+
+// Validate devuelve el error de las opciones inválidas, como un orden mal formado.
+func (o *FindOneAndDeleteOptions) Validate() error {
+	return o.err
+}
+
+// validateReturnDocument devuelve el error de unas opciones o el de un ReturnDocument desconocido.
+func validateReturnDocument(err error, returnDocument *string) error {
+	if err != nil {
+		return err
+	}
+
+	if returnDocument != nil && *returnDocument != ReturnDocumentBefore && *returnDocument != ReturnDocumentAfter {
+		return fmt.Errorf("unknown return document %q", *returnDocument)
+	}
+
+	return nil
+}
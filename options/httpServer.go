@@ -0,0 +1,70 @@
+package options
+
+// HTTPServerOptions es un struct que contiene las opciones del servidor HTTP.
+type HTTPServerOptions struct {
+	Database    *DatabaseOptions
+	MaxBodySize *int64
+	Canonical   *bool
+	Auth        *bool
+}
+
+// HTTPServer crea una nueva instancia de httpServerOptions.
+func HTTPServer() *HTTPServerOptions {
+	return &HTTPServerOptions{}
+}
+
+// Merge combina las opciones de varios servidores.
+func (o *HTTPServerOptions) Merge(opts ...*HTTPServerOptions) *HTTPServerOptions {
+	for _, opt := range opts {
+		if opt.Database != nil {
+			if o.Database == nil {
+				o.Database = Database()
+			}
+
+			o.Database = o.Database.Merge(opt.Database)
+		}
+
+		if opt.MaxBodySize != nil {
+			o.MaxBodySize = opt.MaxBodySize
+		}
+
+		if opt.Canonical != nil {
+			o.Canonical = opt.Canonical
+		}
+
+		if opt.Auth != nil {
+			o.Auth = opt.Auth
+		}
+	}
+
+	return o
+}
+
+// SetDatabase establece las opciones con las que se abren las bases de datos servidas.
+func (o *HTTPServerOptions) SetDatabase(opt *DatabaseOptions) *HTTPServerOptions {
+	o.Database = opt
+
+	return o
+}
+
+// SetMaxBodySize establece el tamaño máximo en bytes del cuerpo de una petición.
+func (o *HTTPServerOptions) SetMaxBodySize(size int64) *HTTPServerOptions {
+	o.MaxBodySize = &size
+
+	return o
+}
+
+// SetCanonical establece si las respuestas usan Extended JSON canónico en lugar del relajado.
+func (o *HTTPServerOptions) SetCanonical(canonical bool) *HTTPServerOptions {
+	o.Canonical = &canonical
+
+	return o
+}
+
+// SetAuth establece si los clientes deben autenticarse con un usuario y solo pueden hacer lo que
+// permiten sus roles.
+func (o *HTTPServerOptions) SetAuth(auth bool) *HTTPServerOptions {
+	o.Auth = &auth
+
+	return o
+}
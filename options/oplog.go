@@ -0,0 +1,83 @@
+package options
+
+import "time"
+
+// OplogOptions es un struct que contiene las opciones del oplog de una base de datos.
+type OplogOptions struct {
+	MaxSize *int64
+	MaxAge  *time.Duration
+}
+
+// Oplog crea una nueva instancia de oplogOptions.
+func Oplog() *OplogOptions {
+	return &OplogOptions{}
+}
+
+// Merge combina las opciones de varios oplogs.
+func (o *OplogOptions) Merge(opts ...*OplogOptions) *OplogOptions {
+	for _, opt := range opts {
+		if opt.MaxSize != nil {
+			o.MaxSize = opt.MaxSize
+		}
+
+		if opt.MaxAge != nil {
+			o.MaxAge = opt.MaxAge
+		}
+	}
+
+	return o
+}
+
+// SetMaxSize establece el tamaño máximo en bytes del oplog. Con 0 no se limita el tamaño.
+func (o *OplogOptions) SetMaxSize(size int64) *OplogOptions {
+	o.MaxSize = &size
+
+	return o
+}
+
+// SetMaxAge establece la antigüedad máxima de las entradas del oplog. Con 0 no se limita la antigüedad.
+func (o *OplogOptions) SetMaxAge(d time.Duration) *OplogOptions {
+	o.MaxAge = &d
+
+	return o
+}
+
+// OplogTailOptions es un struct que contiene las opciones para leer el oplog.
+type OplogTailOptions struct {
+	StartAfter *uint64
+	Follow     *bool
+}
+
+// OplogTail crea una nueva instancia de oplogTailOptions.
+func OplogTail() *OplogTailOptions {
+	return &OplogTailOptions{}
+}
+
+// Merge combina las opciones de varias lecturas del oplog.
+func (o *OplogTailOptions) Merge(opts ...*OplogTailOptions) *OplogTailOptions {
+	for _, opt := range opts {
+		if opt.StartAfter != nil {
+			o.StartAfter = opt.StartAfter
+		}
+
+		if opt.Follow != nil {
+			o.Follow = opt.Follow
+		}
+	}
+
+	return o
+}
+
+// SetStartAfter establece el timestamp después del cual empieza la lectura.
+func (o *OplogTailOptions) SetStartAfter(ts uint64) *OplogTailOptions {
+	o.StartAfter = &ts
+
+	return o
+}
+
+// SetFollow establece si la lectura espera nuevas entradas al llegar al final del oplog.
+func (o *OplogTailOptions) SetFollow(follow bool) *OplogTailOptions {
+	o.Follow = &follow
+
+	return o
+}
@@ -0,0 +1,30 @@
+package options
+
+// RenameCollectionOptions es un struct que contiene las opciones para renombrar una colección.
+type RenameCollectionOptions struct {
+	DropTarget *bool
+}
+
+// RenameCollection crea una nueva instancia de renameCollectionOptions.
+func RenameCollection() *RenameCollectionOptions {
+	return &RenameCollectionOptions{}
+}
+
+// Merge combina las opciones de varios renombrados.
+func (o *RenameCollectionOptions) Merge(opts ...*RenameCollectionOptions) *RenameCollectionOptions {
+	for _, opt := range opts {
+		if opt.DropTarget != nil {
+			o.DropTarget = opt.DropTarget
+		}
+	}
+
+	return o
+}
+
+// SetDropTarget establece si se elimina la colección de destino cuando ya existe. Por defecto el
+// renombrado falla en ese caso.
+func (o *RenameCollectionOptions) SetDropTarget(drop bool) *RenameCollectionOptions {
+	o.DropTarget = &drop
+
+	return o
+}
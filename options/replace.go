@@ -0,0 +1,42 @@
+package options
+
+// ReplaceOptions es un struct que contiene las opciones para un reemplazo.
+type ReplaceOptions struct {
+	Upsert  *bool
+	Version *int64
+}
+
+// Replace crea una nueva instancia de ReplaceOptions.
+func Replace() *ReplaceOptions {
+	return &ReplaceOptions{}
+}
+
+// Merge combina las opciones de varios reemplazos.
+func (o *ReplaceOptions) Merge(opts ...*ReplaceOptions) *ReplaceOptions {
+	for _, opt := range opts {
+		if opt.Upsert != nil {
+			o.Upsert = opt.Upsert
+		}
+
+		if opt.Version != nil {
+			o.Version = opt.Version
+		}
+	}
+
+	return o
+}
+
+// SetUpsert establece si se inserta el documento cuando ninguno cumple el filtro.
+func (o *ReplaceOptions) SetUpsert(upsert bool) *ReplaceOptions {
+	o.Upsert = &upsert
+
+	return o
+}
+
+// SetVersion establece la versión que debe tener el documento para reemplazarlo, como
+// UpdateOptions.SetVersion.
+func (o *ReplaceOptions) SetVersion(version int64) *ReplaceOptions {
+	o.Version = &version
+
+	return o
+}
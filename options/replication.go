@@ -0,0 +1,43 @@
+package options
+
+import "time"
+
+// ReplicationOptions es un struct que contiene las opciones de replicación.
+type ReplicationOptions struct {
+	HeartbeatInterval *time.Duration
+	RetryInterval     *time.Duration
+}
+
+// Replication crea una nueva instancia de replicationOptions.
+func Replication() *ReplicationOptions {
+	return &ReplicationOptions{}
+}
+
+// Merge combina las opciones de varias replicaciones.
+func (o *ReplicationOptions) Merge(opts ...*ReplicationOptions) *ReplicationOptions {
+	for _, opt := range opts {
+		if opt.HeartbeatInterval != nil {
+			o.HeartbeatInterval = opt.HeartbeatInterval
+		}
+
+		if opt.RetryInterval != nil {
+			o.RetryInterval = opt.RetryInterval
+		}
+	}
+
+	return o
+}
+
+// SetHeartbeatInterval establece cada cuánto el primario informa su último timestamp a los followers.
+func (o *ReplicationOptions) SetHeartbeatInterval(d time.Duration) *ReplicationOptions {
+	o.HeartbeatInterval = &d
+
+	return o
+}
+
+// SetRetryInterval establece cuánto espera un follower antes de reconectarse al primario.
+func (o *ReplicationOptions) SetRetryInterval(d time.Duration) *ReplicationOptions {
+	o.RetryInterval = &d
+
+	return o
+}
@@ -12,8 +12,9 @@ func Update() *UpdateOptions {
 
 // UpdateOptions es un struct que contiene las opciones para una actualización.
 type UpdateOptions struct {
-	Upsert *bool
-	Set    *bool
+	Upsert  *bool
+	Set     *bool
+	Version *int64
 }
 
 // Merge combina las opciones de varias consultas.
@@ -26,6 +27,10 @@ func (o *UpdateOptions) Merge(opts ...*UpdateOptions) *UpdateOptions {
 		if opt.Set != nil {
 			o.Set = opt.Set
 		}
+
+		if opt.Version != nil {
+			o.Version = opt.Version
+		}
 	}
 
 	return o
@@ -44,3 +49,12 @@ func (o *UpdateOptions) SetSet(set bool) *UpdateOptions {
 
 	return o
 }
+
+// SetVersion establece la versión que debe tener el documento para actualizarlo. Si tiene otra,
+// la actualización falla con ErrVersionConflict. Los documentos de las colecciones sin versiones
+// tienen la versión 0.
+func (o *UpdateOptions) SetVersion(version int64) *UpdateOptions {
+	o.Version = &version
+
+	return o
+}
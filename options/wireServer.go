@@ -0,0 +1,60 @@
+package options
+
+import "time"
+
+// WireServerOptions es un struct que contiene las opciones del servidor del protocolo de MongoDB.
+type WireServerOptions struct {
+	Database      *DatabaseOptions
+	CursorTimeout *time.Duration
+	Auth          *bool
+}
+
+// WireServer crea una nueva instancia de wireServerOptions.
+func WireServer() *WireServerOptions {
+	return &WireServerOptions{}
+}
+
+// Merge combina las opciones de varios servidores.
+func (o *WireServerOptions) Merge(opts ...*WireServerOptions) *WireServerOptions {
+	for _, opt := range opts {
+		if opt.Database != nil {
+			if o.Database == nil {
+				o.Database = Database()
+			}
+
+			o.Database = o.Database.Merge(opt.Database)
+		}
+
+		if opt.CursorTimeout != nil {
+			o.CursorTimeout = opt.CursorTimeout
+		}
+
+		if opt.Auth != nil {
+			o.Auth = opt.Auth
+		}
+	}
+
+	return o
+}
+
+// SetDatabase establece las opciones con las que se abren las bases de datos servidas.
+func (o *WireServerOptions) SetDatabase(opt *DatabaseOptions) *WireServerOptions {
+	o.Database = opt
+
+	return o
+}
+
+// SetCursorTimeout establece cuánto tiempo puede estar inactivo un cursor antes de cerrarse.
+func (o *WireServerOptions) SetCursorTimeout(d time.Duration) *WireServerOptions {
+	o.CursorTimeout = &d
+
+	return o
+}
+
+// SetAuth establece si los clientes deben autenticarse con un usuario y solo pueden hacer lo que
+// permiten sus roles.
+func (o *WireServerOptions) SetAuth(auth bool) *WireServerOptions {
+	o.Auth = &auth
+
+	return o
+}
@@ -0,0 +1,256 @@
+package gopherdb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// evalExpr evaluates an aggregation expression: "$field" paths, documents and arrays of
+// expressions, and literals.
+func evalExpr(doc map[string]any, expr any) any {
+	switch e := expr.(type) {
+	case string:
+		if path, ok := strings.CutPrefix(e, "$"); ok {
+			v, _ := lookupPath(doc, path)
+
+			return v
+		}
+
+		return e
+	case map[string]any:
+		out := make(map[string]any, len(e))
+		for k, v := range e {
+			out[k] = evalExpr(doc, v)
+		}
+
+		return out
+	case []any:
+		out := make([]any, len(e))
+		for i, v := range e {
+			out[i] = evalExpr(doc, v)
+		}
+
+		return out
+	default:
+		return e
+	}
+}
+
+// lookupPath returns the value of a field, following dot notation into embedded documents.
+func lookupPath(doc map[string]any, path string) (any, bool) {
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		v, ok := doc[path]
+
+		return v, ok
+	}
+
+	sub, ok := doc[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+
+	return lookupPath(sub, rest)
+}
+
+// fieldTree is a set of field paths; a nil subtree selects the whole field.
+type fieldTree map[string]fieldTree
+
+// add adds a dotted field path to the tree.
+func (t fieldTree) add(path string) {
+	head, rest, nested := strings.Cut(path, ".")
+
+	sub, exists := t[head]
+	if exists && sub == nil {
+		return
+	}
+
+	if !nested {
+		t[head] = nil
+
+		return
+	}
+
+	if sub == nil {
+		sub = fieldTree{}
+		t[head] = sub
+	}
+
+	sub.add(rest)
+}
+
+// project keeps the selected fields of a document when include is true, or removes them otherwise.
+func (t fieldTree) project(doc map[string]any, include bool) map[string]any {
+	out := make(map[string]any, len(doc))
+
+	for k, v := range doc {
+		sub, selected := t[k]
+
+		switch {
+		case !selected:
+			if !include {
+				out[k] = v
+			}
+		case sub == nil:
+			if include {
+				out[k] = v
+			}
+		default:
+			if projected, ok := sub.projectValue(v, include); ok {
+				out[k] = projected
+			}
+		}
+	}
+
+	return out
+}
+
+// projectValue projects the embedded documents of a value selected by a nested path.
+func (t fieldTree) projectValue(v any, include bool) (any, bool) {
+	switch typed := v.(type) {
+	case map[string]any:
+		return t.project(typed, include), true
+	case []any:
+		out := make([]any, 0, len(typed))
+
+		for _, item := range typed {
+			if projected, ok := t.projectValue(item, include); ok {
+				out = append(out, projected)
+			}
+		}
+
+		return out, true
+	default:
+		return v, !include
+	}
+}
+
+// queryProjection is the projection of a find command or a $project stage.
+type queryProjection struct {
+	include  bool
+	fields   fieldTree
+	computed map[string]any
+}
+
+// parseProjection parses a projection. Computed fields are only allowed in $project stages.
+func parseProjection(spec map[string]any, expressions bool) (*queryProjection, error) {
+	if len(spec) == 0 {
+		return nil, nil
+	}
+
+	p := &queryProjection{fields: fieldTree{}, computed: map[string]any{}}
+	modeSet := false
+	excludeID := false
+
+	for field, v := range spec {
+		switch v.(type) {
+		case bool, int32, int64, float64:
+		default:
+			if !expressions {
+				return nil, fmt.Errorf("%w: unsupported projection for field %s", ErrInvalidQuery, field)
+			}
+
+			p.computed[field] = v
+
+			continue
+		}
+
+		on := wireTruthy(v)
+
+		if field == consts.DocumentFieldID {
+			excludeID = !on
+
+			continue
+		}
+
+		if modeSet && on != p.include {
+			return nil, fmt.Errorf("%w: cannot mix inclusion and exclusion in projection, field %s", ErrInvalidQuery, field)
+		}
+
+		p.include = on
+		modeSet = true
+		p.fields.add(field)
+	}
+
+	if len(p.computed) > 0 {
+		if modeSet && !p.include {
+			return nil, fmt.Errorf("%w: cannot use computed fields in an exclusion projection", ErrInvalidQuery)
+		}
+
+		p.include = true
+	} else if !modeSet {
+		p.include = !excludeID
+	}
+
+	if p.include && !excludeID {
+		p.fields.add(consts.DocumentFieldID)
+	} else if !p.include && excludeID {
+		p.fields.add(consts.DocumentFieldID)
+	}
+
+	return p, nil
+}
+
+// apply projects a document.
+func (p *queryProjection) apply(doc map[string]any) map[string]any {
+	if p == nil {
+		return doc
+	}
+
+	out := p.fields.project(doc, p.include)
+	for field, expr := range p.computed {
+		out[field] = evalExpr(doc, expr)
+	}
+
+	return out
+}
+
+// applyRaw projects stored documents. Without a projection the stored BSON is returned as is.
+func (p *queryProjection) applyRaw(kvs []storage.KV) ([]bson.Raw, error) {
+	out := make([]bson.Raw, 0, len(kvs))
+
+	for _, kv := range kvs {
+		if p == nil {
+			out = append(out, kv.Value)
+
+			continue
+		}
+
+		doc, _ := bson.Normalize(kv.Document()).(map[string]any)
+
+		data, err := bson.Marshal(p.apply(doc))
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, data)
+	}
+
+	return out, nil
+}
+
+// parseSortSpec parses a sort specification keeping the order of its fields.
+func parseSortSpec(spec primitive.D) ([]options.SortField, error) {
+	if len(spec) == 0 {
+		return nil, nil
+	}
+
+	sort := make([]options.SortField, 0, len(spec))
+
+	for _, e := range spec {
+		order, ok := wireInt(e.Value)
+		if !ok || (order != 1 && order != -1) {
+			return nil, fmt.Errorf("%w: invalid sort order for field %s", ErrInvalidQuery, e.Key)
+		}
+
+		sort = append(sort, options.SortField{Field: e.Key, Order: int(order)})
+	}
+
+	return sort, nil
+}
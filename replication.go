@@ -0,0 +1,1011 @@
+package gopherdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/options"
+)
+
+const (
+	// replicationRolePrimary is the role of a writable database.
+	replicationRolePrimary = "primary"
+	// replicationRoleFollower is the role of a read-only database that replicates a primary.
+	replicationRoleFollower = "follower"
+)
+
+const (
+	msgHello         = "hello"
+	msgSnapshotBegin = "snapshotBegin"
+	msgKV            = "kv"
+	msgSnapshotEnd   = "snapshotEnd"
+	msgOp            = "op"
+	msgHeartbeat     = "heartbeat"
+	msgError         = "error"
+)
+
+const (
+	// defaultHeartbeatInterval is how often a primary reports its last timestamp by default.
+	defaultHeartbeatInterval = time.Second
+	// defaultRetryInterval is how long a follower waits before reconnecting by default.
+	defaultRetryInterval = time.Second
+	// replicationMaxMessageSize is the largest message accepted from a peer.
+	replicationMaxMessageSize = 64 << 20
+	// replicationBatchSize is the number of keys written per transaction while applying a snapshot.
+	replicationBatchSize = 500
+)
+
+// replicationState is the persisted replication state of a database.
+type replicationState struct {
+	// NodeID identifies the database as a replication source.
+	NodeID string `bson:"nodeId"`
+	Role   string `bson:"role"`
+	// Primary is the address of the primary a follower replicates.
+	Primary string `bson:"primary,omitempty"`
+	// Source is the node ID of the primary the data of a follower comes from.
+	Source string `bson:"source,omitempty"`
+	// Applied is the timestamp of the last oplog entry of the source applied by a follower.
+	Applied uint64 `bson:"applied"`
+	// Replayed is the timestamp of the last oplog entry that may already be in the snapshot the
+	// follower copied. Entries up to it may find their documents changed by later entries.
+	Replayed uint64 `bson:"replayed,omitempty"`
+}
+
+// replicationMessage is a message of the replication protocol. Messages are BSON documents, which
+// carry their own length, sent back to back over the connection.
+type replicationMessage struct {
+	Type      string      `bson:"type"`
+	Database  string      `bson:"db,omitempty"`
+	Source    string      `bson:"source,omitempty"`
+	Timestamp uint64      `bson:"ts,omitempty"`
+	Until     uint64      `bson:"until,omitempty"`
+	Key       string      `bson:"key,omitempty"`
+	Value     []byte      `bson:"value,omitempty"`
+	Entry     *OplogEntry `bson:"entry,omitempty"`
+	Error     string      `bson:"error,omitempty"`
+}
+
+// replicationConn reads and writes replication messages.
+type replicationConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex
+	w    *bufio.Writer
+}
+
+// newReplicationConn wraps a connection.
+func newReplicationConn(conn net.Conn) *replicationConn {
+	return &replicationConn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+}
+
+// write buffers a message.
+func (c *replicationConn) write(msg replicationMessage) error {
+	data, err := bson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = c.w.Write(data)
+
+	return err
+}
+
+// send writes a message and flushes the connection.
+func (c *replicationConn) send(msg replicationMessage) error {
+	if err := c.write(msg); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.w.Flush()
+}
+
+// receive reads the next message.
+func (c *replicationConn) receive() (replicationMessage, error) {
+	var msg replicationMessage
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return msg, err
+	}
+
+	size := binary.LittleEndian.Uint32(header)
+	if size < 5 || size > replicationMaxMessageSize {
+		return msg, fmt.Errorf("%w: message of %d bytes", ErrReplicationProtocol, size)
+	}
+
+	data := make([]byte, size)
+	copy(data, header)
+
+	if _, err := io.ReadFull(c.r, data[4:]); err != nil {
+		return msg, err
+	}
+
+	if err := bson.Unmarshal(data, &msg); err != nil {
+		return msg, fmt.Errorf("%w: %w", ErrReplicationProtocol, err)
+	}
+
+	return msg, nil
+}
+
+// replicationKey returns the key of the replication state.
+func (db *Database) replicationKey() string {
+	return fmt.Sprintf(consts.ReplicationKeyStringFormat, db.name)
+}
+
+// loadReplicationState loads the replication state of the database.
+func (db *Database) loadReplicationState() (replicationState, error) {
+	var st replicationState
+
+	data, err := db.storage.Get(db.replicationKey())
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return st, nil
+	}
+
+	if err != nil {
+		return st, err
+	}
+
+	if err := bson.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("replication state unmarshal failed: %w", err)
+	}
+
+	return st, nil
+}
+
+// saveReplicationState saves the replication state of the database in a transaction.
+func (db *Database) saveReplicationState(txn storage.Transaction, st replicationState) error {
+	data, err := bson.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("replication state marshal failed: %w", err)
+	}
+
+	return txn.Put(db.replicationKey(), data)
+}
+
+// updateReplicationState applies fn to the replication state of the database and saves it.
+func (db *Database) updateReplicationState(fn func(st *replicationState)) (replicationState, error) {
+	st, err := db.loadReplicationState()
+	if err != nil {
+		return st, err
+	}
+
+	if st.NodeID == "" {
+		st.NodeID = uuid.NewString()
+	}
+
+	if st.Role == "" {
+		st.Role = replicationRolePrimary
+	}
+
+	fn(&st)
+
+	txn := db.storage.BeginTx()
+	if err := db.saveReplicationState(txn, st); err != nil {
+		txn.Rollback()
+
+		return st, err
+	}
+
+	return st, txn.Commit()
+}
+
+// checkWritable returns ErrNotPrimary if the database is a follower.
+func (db *Database) checkWritable() error {
+	if db.follower.Load() {
+		return ErrNotPrimary
+	}
+
+	return nil
+}
+
+// isReplicatedKey reports whether a key is copied to the followers in a snapshot. The oplog, the
+// change history and the replication state belong to each node.
+func (db *Database) isReplicatedKey(key string) bool {
+	meta := fmt.Sprintf(consts.MetadataDatabaseKeyStringFormat, db.name)
+
+	switch {
+	case key == meta:
+		return true
+	case strings.HasPrefix(key, fmt.Sprintf(consts.ChangesKeyStringFormat, db.name)),
+		strings.HasPrefix(key, fmt.Sprintf(consts.OplogEntriesKeyStringFormat, db.name)),
+		key == fmt.Sprintf(consts.OplogTrimmedKeyStringFormat, db.name),
+		key == db.replicationKey():
+		return false
+	default:
+		for _, prefix := range db.snapshotPrefixes() {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// snapshotPrefixes returns the key prefixes copied in a snapshot, besides the database metadata.
+func (db *Database) snapshotPrefixes() []string {
+	return []string{
+		fmt.Sprintf(consts.CollectionKeyStringFormat, db.name, ""),
+		fmt.Sprintf(consts.MetadataDatabaseKeyStringFormat, db.name) + "/",
+	}
+}
+
+// ServeReplication serves the oplog of the database to the followers that connect to the listener.
+// A follower that is new, comes from another source or fell behind the oplog retention first
+// receives a snapshot of the data. It blocks until the context is done or the listener fails.
+// The database must be opened with the oplog enabled.
+func (db *Database) ServeReplication(ctx context.Context, ln net.Listener, opts ...*options.ReplicationOptions) error {
+	if db.changes.oplog == nil {
+		return ErrOplogDisabled
+	}
+
+	opt := options.Replication()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	st, err := db.updateReplicationState(func(*replicationState) {})
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			db.serveFollower(ctx, conn, st.NodeID, opt)
+		}()
+	}
+}
+
+// serveFollower serves a follower connection until it fails or the context is done.
+func (db *Database) serveFollower(ctx context.Context, conn net.Conn, nodeID string, opt *options.ReplicationOptions) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	rc := newReplicationConn(conn)
+
+	if err := db.streamOplog(ctx, cancel, rc, nodeID, opt); err != nil && ctx.Err() == nil {
+		rc.send(replicationMessage{Type: msgError, Error: err.Error()})
+	}
+}
+
+// streamOplog sends the snapshot the follower needs and then the oplog entries it has not applied.
+func (db *Database) streamOplog(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	rc *replicationConn,
+	nodeID string,
+	opt *options.ReplicationOptions,
+) error {
+	hello, err := rc.receive()
+	if err != nil {
+		return err
+	}
+
+	if hello.Type != msgHello {
+		return fmt.Errorf("%w: expected %s, got %s", ErrReplicationProtocol, msgHello, hello.Type)
+	}
+
+	if hello.Database != db.name {
+		return fmt.Errorf("%w: follower database %q does not match %q", ErrReplicationProtocol, hello.Database, db.name)
+	}
+
+	l := db.changes.oplog
+	after := hello.Timestamp
+
+	if hello.Source != nodeID || !l.covers(after) {
+		after, err = db.sendSnapshot(ctx, rc, nodeID)
+		if err != nil {
+			return err
+		}
+	}
+
+	interval := defaultHeartbeatInterval
+	if opt.HeartbeatInterval != nil {
+		interval = *opt.HeartbeatInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := rc.send(replicationMessage{Type: msgHeartbeat, Timestamp: l.last()}); err != nil {
+				cancel()
+
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	cursor, err := db.TailOplog(ctx, options.OplogTail().SetStartAfter(after).SetFollow(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	for cursor.Next(ctx) {
+		if !l.covers(after) {
+			return errors.New("follower fell behind the oplog retention")
+		}
+
+		entry := cursor.Entry()
+		if err := rc.send(replicationMessage{Type: msgOp, Entry: &entry}); err != nil {
+			return err
+		}
+
+		after = entry.Timestamp
+	}
+
+	return cursor.Err()
+}
+
+// sendSnapshot sends a copy of the data of the database and returns the oplog timestamp it starts
+// from. Entries written while the snapshot is taken are sent again afterwards and applied
+// idempotently.
+func (db *Database) sendSnapshot(ctx context.Context, rc *replicationConn, nodeID string) (uint64, error) {
+	ts := db.changes.oplog.last()
+
+	if err := rc.write(replicationMessage{Type: msgSnapshotBegin, Source: nodeID, Timestamp: ts}); err != nil {
+		return 0, err
+	}
+
+	metaKey := fmt.Sprintf(consts.MetadataDatabaseKeyStringFormat, db.name)
+
+	data, err := db.storage.Get(metaKey)
+	if err == nil {
+		err = rc.write(replicationMessage{Type: msgKV, Key: metaKey, Value: data})
+	}
+
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, err
+	}
+
+	for _, prefix := range db.snapshotPrefixes() {
+		err := db.storage.Stream(ctx, prefix, func(key string, value []byte) error {
+			if !db.isReplicatedKey(key) {
+				return nil
+			}
+
+			return rc.write(replicationMessage{Type: msgKV, Key: key, Value: value})
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Las entradas escritas mientras se copiaba pueden estar ya en la copia
+	until := db.changes.oplog.last()
+
+	if err := rc.send(replicationMessage{Type: msgSnapshotEnd, Source: nodeID, Timestamp: ts, Until: until}); err != nil {
+		return 0, err
+	}
+
+	return ts, nil
+}
+
+// ReplicationStatus is the replication status of a follower.
+type ReplicationStatus struct {
+	// Primary is the address of the primary.
+	Primary string
+	// Connected reports whether the follower is connected to the primary.
+	Connected bool
+	// AppliedTimestamp is the oplog timestamp of the last operation applied.
+	AppliedTimestamp uint64
+	// PrimaryTimestamp is the oplog timestamp of the last operation of the primary.
+	PrimaryTimestamp uint64
+	// LastHeartbeat is when the primary was last heard from.
+	LastHeartbeat time.Time
+	// Err is the error that broke the last connection, if any.
+	Err error
+}
+
+// Lag returns how far behind the primary the follower is, as the time between the last operation
+// of the primary and the last operation applied.
+func (s ReplicationStatus) Lag() time.Duration {
+	if s.PrimaryTimestamp <= s.AppliedTimestamp {
+		return 0
+	}
+
+	return time.Duration(s.PrimaryTimestamp - s.AppliedTimestamp)
+}
+
+// Follower replicates a primary into a read-only database.
+type Follower struct {
+	db      *Database
+	primary string
+	opt     *options.ReplicationOptions
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu     sync.Mutex
+	status ReplicationStatus
+}
+
+// Follow makes the database a read-only follower of the primary at the given address. The follower
+// copies a snapshot of the primary when needed and then applies its oplog until it is closed or
+// promoted, reconnecting when the connection breaks. The role is persisted, so the database stays
+// read-only when reopened until it follows again or is promoted.
+func (db *Database) Follow(ctx context.Context, primary string, opts ...*options.ReplicationOptions) (*Follower, error) {
+	opt := options.Replication()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	f := &Follower{
+		db:      db,
+		primary: primary,
+		opt:     opt,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		status:  ReplicationStatus{Primary: primary},
+	}
+
+	if !db.following.CompareAndSwap(nil, f) {
+		cancel()
+
+		return nil, ErrAlreadyFollowing
+	}
+
+	_, err := db.updateReplicationState(func(st *replicationState) {
+		st.Role = replicationRoleFollower
+		st.Primary = primary
+	})
+	if err != nil {
+		db.following.CompareAndSwap(f, nil)
+		cancel()
+
+		return nil, err
+	}
+
+	db.follower.Store(true)
+
+	go f.run(ctx)
+
+	return f, nil
+}
+
+// Promote stops following the primary, if the database is following one, and makes the database
+// writable. The data stays as replicated so far.
+func (db *Database) Promote() error {
+	if f := db.following.Load(); f != nil {
+		f.Close()
+	}
+
+	_, err := db.updateReplicationState(func(st *replicationState) {
+		st.Role = replicationRolePrimary
+		st.Primary = ""
+	})
+	if err != nil {
+		return err
+	}
+
+	db.follower.Store(false)
+
+	return nil
+}
+
+// Status returns the replication status of the follower.
+func (f *Follower) Status() ReplicationStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.status
+}
+
+// Promote stops following the primary and makes the database writable.
+func (f *Follower) Promote() error {
+	return f.db.Promote()
+}
+
+// Close stops following the primary. The database stays a read-only follower.
+func (f *Follower) Close() error {
+	f.cancel()
+	<-f.done
+	f.db.following.CompareAndSwap(f, nil)
+
+	return nil
+}
+
+// updateStatus applies fn to the status of the follower.
+func (f *Follower) updateStatus(fn func(s *ReplicationStatus)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fn(&f.status)
+}
+
+// run replicates the primary, reconnecting until the context is done.
+func (f *Follower) run(ctx context.Context) {
+	defer close(f.done)
+
+	retry := defaultRetryInterval
+	if f.opt.RetryInterval != nil {
+		retry = *f.opt.RetryInterval
+	}
+
+	for {
+		err := f.sync(ctx)
+
+		f.updateStatus(func(s *ReplicationStatus) {
+			s.Connected = false
+
+			if ctx.Err() == nil {
+				s.Err = err
+			}
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// sync connects to the primary and applies what it sends until the connection breaks.
+func (f *Follower) sync(ctx context.Context) error {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", f.primary)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+
+		conn.Close()
+	}()
+
+	st, err := f.db.loadReplicationState()
+	if err != nil {
+		return err
+	}
+
+	rc := newReplicationConn(conn)
+
+	hello := replicationMessage{
+		Type:      msgHello,
+		Database:  f.db.name,
+		Source:    st.Source,
+		Timestamp: st.Applied,
+	}
+	if err := rc.send(hello); err != nil {
+		return err
+	}
+
+	f.updateStatus(func(s *ReplicationStatus) {
+		s.Connected = true
+		s.AppliedTimestamp = st.Applied
+		s.Err = nil
+	})
+
+	var snapshot *snapshotWriter
+
+	for {
+		msg, err := rc.receive()
+		if err != nil {
+			return err
+		}
+
+		switch msg.Type {
+		case msgSnapshotBegin:
+			snapshot, err = f.db.beginSnapshot(&st)
+		case msgKV:
+			if snapshot == nil {
+				return fmt.Errorf("%w: %s outside a snapshot", ErrReplicationProtocol, msg.Type)
+			}
+
+			err = snapshot.put(msg.Key, msg.Value)
+		case msgSnapshotEnd:
+			if snapshot == nil {
+				return fmt.Errorf("%w: %s outside a snapshot", ErrReplicationProtocol, msg.Type)
+			}
+
+			err = snapshot.finish(&st, msg.Source, msg.Timestamp, msg.Until)
+			snapshot = nil
+		case msgOp:
+			if snapshot != nil || msg.Entry == nil {
+				return fmt.Errorf("%w: unexpected %s", ErrReplicationProtocol, msg.Type)
+			}
+
+			err = f.db.applyReplicated(&st, *msg.Entry)
+		case msgHeartbeat:
+			f.updateStatus(func(s *ReplicationStatus) {
+				s.PrimaryTimestamp = max(s.PrimaryTimestamp, msg.Timestamp)
+				s.LastHeartbeat = time.Now()
+			})
+		case msgError:
+			return fmt.Errorf("%w: primary: %s", ErrReplicationProtocol, msg.Error)
+		default:
+			return fmt.Errorf("%w: unknown message %s", ErrReplicationProtocol, msg.Type)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		f.updateStatus(func(s *ReplicationStatus) {
+			s.AppliedTimestamp = st.Applied
+			s.PrimaryTimestamp = max(s.PrimaryTimestamp, st.Applied)
+		})
+	}
+}
+
+// snapshotWriter writes the keys of a snapshot in batches.
+type snapshotWriter struct {
+	db  *Database
+	txn storage.Transaction
+	n   int
+}
+
+// beginSnapshot forgets the replication progress and deletes the replicated keys of the database,
+// so a snapshot can be written.
+func (db *Database) beginSnapshot(st *replicationState) (*snapshotWriter, error) {
+	st.Source = ""
+	st.Applied = 0
+
+	w := &snapshotWriter{db: db, txn: db.storage.BeginTx()}
+
+	if err := db.saveReplicationState(w.txn, *st); err != nil {
+		w.txn.Rollback()
+
+		return nil, err
+	}
+
+	keys := []string{fmt.Sprintf(consts.MetadataDatabaseKeyStringFormat, db.name)}
+
+	for _, prefix := range db.snapshotPrefixes() {
+		found, err := db.storage.ScanKeys(prefix)
+		if err != nil {
+			w.txn.Rollback()
+
+			return nil, err
+		}
+
+		keys = append(keys, found...)
+	}
+
+	for _, key := range keys {
+		if !db.isReplicatedKey(key) {
+			continue
+		}
+
+		if err := w.write(func(txn storage.Transaction) error { return txn.Delete(key) }); err != nil {
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// write runs op in the current batch, committing it when it is full.
+func (w *snapshotWriter) write(op func(txn storage.Transaction) error) error {
+	if err := op(w.txn); err != nil {
+		w.txn.Rollback()
+
+		return err
+	}
+
+	w.n++
+
+	if w.n < replicationBatchSize {
+		return nil
+	}
+
+	if err := w.txn.Commit(); err != nil {
+		return err
+	}
+
+	w.txn = w.db.storage.BeginTx()
+	w.n = 0
+
+	return nil
+}
+
+// put writes a key of the snapshot.
+func (w *snapshotWriter) put(key string, value []byte) error {
+	if !w.db.isReplicatedKey(key) {
+		return fmt.Errorf("%w: key %s is not replicated", ErrReplicationProtocol, key)
+	}
+
+	return w.write(func(txn storage.Transaction) error { return txn.Put(key, value) })
+}
+
+// finish commits the snapshot and records the source and timestamp it was taken at, and the
+// timestamp of the last entry it may already hold.
+func (w *snapshotWriter) finish(st *replicationState, source string, ts, until uint64) error {
+	next := *st
+	next.Source = source
+	next.Applied = ts
+	next.Replayed = until
+
+	if err := w.db.saveReplicationState(w.txn, next); err != nil {
+		w.txn.Rollback()
+
+		return err
+	}
+
+	if err := w.txn.Commit(); err != nil {
+		return err
+	}
+
+	*st = next
+
+	return nil
+}
+
+// applyReplicated applies an oplog entry of the primary and records it as applied in the same
+// transaction. Entries already applied are skipped. When the entry shows that the data diverged
+// from the primary, the source is forgotten so the primary sends a new snapshot on the next
+// connection.
+func (db *Database) applyReplicated(st *replicationState, entry OplogEntry) error {
+	if entry.Timestamp <= st.Applied {
+		return nil
+	}
+
+	next := *st
+	next.Applied = entry.Timestamp
+
+	err := db.applyReplicatedEntry(next, entry)
+	if errors.Is(err, ErrReplicaDiverged) {
+		next = *st
+		next.Source = ""
+
+		txn := db.storage.BeginTx()
+		if serr := db.saveReplicationState(txn, next); serr != nil {
+			txn.Rollback()
+
+			return serr
+		}
+
+		if serr := txn.Commit(); serr != nil {
+			return serr
+		}
+
+		*st = next
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*st = next
+
+	return nil
+}
+
+// applyReplicatedEntry applies an oplog entry and saves the replication state in the same
+// transaction. The keys of a dropped collection are dropped before.
+func (db *Database) applyReplicatedEntry(st replicationState, entry OplogEntry) error {
+	replayed := entry.Timestamp <= st.Replayed
+	name := entry.Namespace.Collection
+
+	if entry.Operation == OperationDrop {
+		if _, err := db.dropCollectionKeys(name); err != nil {
+			return err
+		}
+	}
+
+	txn := db.newWriteTxn()
+
+	var err error
+
+	switch entry.Operation {
+	case OperationDrop:
+		err = db.deleteCollectionMetadata(txn, name)
+	case OperationRename:
+		if entry.To == nil {
+			err = fmt.Errorf("%w: rename without a target", ErrReplicationProtocol)
+		} else {
+			err = db.moveCollection(txn, name, entry.To.Collection)
+		}
+
+		// Una copia que ya refleja el cambio de nombre no tiene la colección de origen
+		if replayed && errors.Is(err, ErrCollectionNotFound) {
+			err = nil
+		} else if errors.Is(err, ErrCollectionNotFound) || errors.Is(err, ErrCollectionExists) {
+			err = fmt.Errorf("%w: %w", ErrReplicaDiverged, err)
+		}
+	default:
+		var c *Collection
+
+		c, err = newCollection(db, name)
+		if err == nil {
+			err = c.applyOplogEntry(txn, entry, replayed)
+		}
+	}
+
+	if err != nil {
+		txn.Rollback()
+
+		return fmt.Errorf("apply oplog entry %d: %w", entry.Timestamp, err)
+	}
+
+	if err := db.saveReplicationState(txn, st); err != nil {
+		txn.Rollback()
+
+		return err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+
+	if entry.Operation == OperationDrop || entry.Operation == OperationRename {
+		db.resetCollections(name)
+
+		if entry.To != nil {
+			db.resetCollections(entry.To.Collection)
+		}
+	}
+
+	return nil
+}
+
+// applyOplogEntry applies an oplog entry to the collection. The result only depends on the entry
+// and the current document, so applying an entry twice leaves the same document. An update of a
+// missing document means the follower diverged, unless the entry was replayed over a snapshot that
+// may already hold a later delete.
+func (c *Collection) applyOplogEntry(txn *writeTxn, entry OplogEntry, replayed bool) error {
+	if _, err := c.IndexManager.loadMetadata(); err != nil {
+		return err
+	}
+
+	key := c.buildDocumentKey(encodeDocumentID(entry.DocumentID))
+
+	var before map[string]any
+
+	data, err := txn.Get(key)
+	switch {
+	case err == nil:
+		if err := bson.Unmarshal(data, &before); err != nil {
+			return fmt.Errorf("bson unmarshal failed: %w", err)
+		}
+	case !errors.Is(err, storage.ErrKeyNotFound):
+		return err
+	}
+
+	var after map[string]any
+
+	switch entry.Operation {
+	case OperationInsert, OperationReplace:
+		after = entry.Document
+	case OperationUpdate:
+		if before == nil && !replayed {
+			return fmt.Errorf("%w: update of missing document %v in %s", ErrReplicaDiverged, entry.DocumentID, c.collname)
+		}
+
+		if before == nil || entry.UpdateDescription == nil {
+			return nil
+		}
+
+		after = make(map[string]any, len(before))
+		maps.Copy(after, before)
+		maps.Copy(after, entry.UpdateDescription.UpdatedFields)
+
+		for _, field := range entry.UpdateDescription.RemovedFields {
+			delete(after, field)
+		}
+	case OperationDelete:
+		if before == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("%w: unknown operation %s", ErrReplicationProtocol, entry.Operation)
+	}
+
+	if before != nil {
+		if err := c.IndexManager.deleteDocumentIndexes(txn, before); err != nil {
+			return fmt.Errorf("delete document indexes failed: %w", err)
+		}
+	}
+
+	event := c.newChangeEvent(entry.Operation, entry.DocumentID)
+	event.FullDocumentBeforeChange = before
+
+	if after == nil {
+		if err := txn.Delete(key); err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+
+		txn.count(c.collname, -1)
+	} else {
+		bdoc, err := bson.Marshal(after)
+		if err != nil {
+			return fmt.Errorf("bson marshal failed: %w", err)
+		}
+
+		if err := txn.Put(key, bdoc); err != nil {
+			return fmt.Errorf("storage put failed: %w", err)
+		}
+
+		if err := c.IndexManager.indexDocument(txn, after); err != nil {
+			return fmt.Errorf("index document failed: %w", err)
+		}
+
+		if before == nil {
+			txn.count(c.collname, 1)
+
+			if n, ok := integerDocumentID(entry.DocumentID); ok {
+				txn.sequence(c.collname, n)
+			}
+		}
+
+		event.FullDocument = after
+		event.UpdateDescription = entry.UpdateDescription
+	}
+
+	txn.record(event)
+	c.ensureInitialized(txn)
+
+	return nil
+}
@@ -12,6 +12,8 @@ import (
 type InsertOneResult struct {
 	InsertedID any
 	Err        error
+	// raw is the stored document.
+	raw storage.KV
 }
 
 // InsertManyResult es el resultado de una inserción de múltiples documentos.
@@ -20,20 +22,62 @@ type InsertManyResult struct {
 	Err         error
 }
 
+// BulkWriteResult es el resultado de una escritura en bloque. Los IDs se indexan por la posición
+// de su operación.
+type BulkWriteResult struct {
+	InsertedCount int64
+	MatchedCount  int64
+	ModifiedCount int64
+	DeletedCount  int64
+	UpsertedCount int64
+	InsertedIDs   map[int]any
+	UpsertedIDs   map[int]any
+	// WriteErrors son los errores de las operaciones que fallaron.
+	WriteErrors []BulkWriteError
+	Err         error
+}
+
+// BulkWriteError es el error de una operación de una escritura en bloque.
+type BulkWriteError struct {
+	// Index es la posición de la operación.
+	Index int
+	Err   error
+}
+
+// Error devuelve el mensaje del error con la posición de la operación.
+func (e BulkWriteError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+// Unwrap devuelve el error de la operación.
+func (e BulkWriteError) Unwrap() error {
+	return e.Err
+}
+
 // FindOneResult es el resultado de una consulta de un documento.
 type FindOneResult struct {
-	raw       storage.KV
-	IndexUsed *IndexModel
-	Err       error
+	raw        storage.KV
+	encryption *fieldEncryptor
+	IndexUsed  *IndexModel
+	Err        error
 }
 
 // Document returns the document of the find one result.
+// Encrypted fields that cannot be decrypted are returned as stored.
 func (r *FindOneResult) Document() map[string]any {
 	if r.Err != nil {
 		return nil
 	}
 
-	return r.raw.Document()
+	doc := r.raw.Document()
+
+	if r.encryption != nil && doc != nil {
+		if decrypted, err := r.encryption.decryptDocument(doc); err == nil {
+			return decrypted
+		}
+	}
+
+	return doc
 }
 
 // Unmarshal unmarshals the result into a struct.
@@ -42,12 +86,92 @@ func (r *FindOneResult) Unmarshal(result any) error {
 		return r.Err
 	}
 
-	return bson.Unmarshal(r.raw.Value, result)
+	return unmarshalDocument(r.raw, r.encryption, result)
+}
+
+// unmarshalDocument unmarshals a stored document into v, decrypting its encrypted fields.
+func unmarshalDocument(kv storage.KV, enc *fieldEncryptor, v any) error {
+	if enc == nil {
+		return bson.Unmarshal(kv.Value, v)
+	}
+
+	var doc map[string]any
+	if err := bson.Unmarshal(kv.Value, &doc); err != nil {
+		return err
+	}
+
+	decrypted, err := enc.decryptDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	return bson.ConvertToStruct(decrypted, v)
+}
+
+// decryptKV returns a stored document with its encrypted fields decrypted, keeping the order of
+// its fields.
+func decryptKV(kv storage.KV, enc *fieldEncryptor) (storage.KV, error) {
+	if enc == nil {
+		return kv, nil
+	}
+
+	data, err := enc.decryptRaw(kv.Value)
+	if err != nil {
+		return storage.KV{}, err
+	}
+
+	return storage.KV{Key: kv.Key, Value: data}, nil
+}
+
+// decrypted returns the stored document of the result with its encrypted fields decrypted.
+func (r *FindOneResult) decrypted() (bson.Raw, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	kv, err := decryptKV(r.raw, r.encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	return kv.Value, nil
+}
+
+// DocumentVersion es una versión de un documento guardada por el motor de almacenamiento.
+type DocumentVersion struct {
+	// Timestamp es el timestamp del commit que escribió la versión.
+	Timestamp uint64
+	// Deleted indica si el commit eliminó el documento.
+	Deleted    bool
+	raw        storage.KV
+	encryption *fieldEncryptor
+}
+
+// Document returns the document of the version, or nil when the version is a deletion.
+func (v *DocumentVersion) Document() map[string]any {
+	if v.Deleted {
+		return nil
+	}
+
+	r := FindOneResult{raw: v.raw, encryption: v.encryption}
+
+	return r.Document()
+}
+
+// Unmarshal unmarshals the document of the version into the given value. It returns
+// ErrDocumentNotFound when the version is a deletion.
+func (v *DocumentVersion) Unmarshal(result any) error {
+	if v.Deleted {
+		return ErrDocumentNotFound
+	}
+
+	return unmarshalDocument(v.raw, v.encryption, result)
 }
 
 // FindResult es el resultado de una consulta.
 type FindResult struct {
 	raw        []storage.KV
+	encryption *fieldEncryptor
 	TotalCount int64
 	IndexUsed  *IndexModel
 	Err        error
@@ -86,7 +210,7 @@ func (r *FindResult) Unmarshal(results any) error {
 
 		currElem := sliceVal.Index(index).Addr().Interface()
 
-		err := bson.Unmarshal(kv.Value, currElem)
+		err := unmarshalDocument(kv, r.encryption, currElem)
 
 		if err != nil {
 			return fmt.Errorf("error unmarshalling result: %w", err)
@@ -100,10 +224,36 @@ func (r *FindResult) Unmarshal(results any) error {
 	return nil
 }
 
+// decrypted returns the stored documents of the result with their encrypted fields decrypted.
+func (r *FindResult) decrypted() ([]storage.KV, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+
+	if r.encryption == nil {
+		return r.raw, nil
+	}
+
+	kvs := make([]storage.KV, 0, len(r.raw))
+
+	for _, kv := range r.raw {
+		decrypted, err := decryptKV(kv, r.encryption)
+		if err != nil {
+			return nil, err
+		}
+
+		kvs = append(kvs, decrypted)
+	}
+
+	return kvs, nil
+}
+
 // DeleteOneResult es el resultado de una eliminación de un documento.
 type DeleteOneResult struct {
 	DeletedID any
 	Err       error
+	// raw is the deleted document.
+	raw storage.KV
 }
 
 // DeleteManyResult es el resultado de una eliminación de múltiples documentos.
@@ -115,11 +265,27 @@ type DeleteManyResult struct {
 // UpdateOneResult es el resultado de una actualización de un documento.
 type UpdateOneResult struct {
 	UpsertedID any
-	Err        error
+	// MatchedCount is 1 when a document matched the filter, 0 when none did or it was upserted.
+	MatchedCount int64
+	// ModifiedCount is 1 when the matched document changed.
+	ModifiedCount int64
+	Err           error
+	// matched is the stored document before the update, empty when it was upserted.
+	matched storage.KV
+	// updated is the stored document after the update.
+	updated storage.KV
 }
 
 // UpdateManyResult es el resultado de una actualización de múltiples documentos.
 type UpdateManyResult struct {
+	// MatchedCount is the number of documents that matched the filter.
+	MatchedCount int64
+	// ModifiedCount is the number of matched documents that changed.
+	ModifiedCount int64
+	// UpsertedID is the ID of the document inserted when none matched and upsert was set.
+	UpsertedID any
+	// UpsertedIDs are the IDs of the inserted documents: the one of UpsertedID for UpdateMany, and
+	// every one for Update.
 	UpsertedIDs []any
 	Err         error
 }
@@ -0,0 +1,168 @@
+package gopherdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/storage"
+)
+
+// CollectionStats are the storage statistics of a collection, computed from a scan of its keys.
+type CollectionStats struct {
+	Namespace Namespace `bson:"ns"`
+	// Count is the number of documents.
+	Count int64 `bson:"count"`
+	// Size is the total size in bytes of the stored documents.
+	Size int64 `bson:"size"`
+	// AvgObjSize is the average size in bytes of a stored document.
+	AvgObjSize int64 `bson:"avgObjSize"`
+	// Indexes are the statistics of each index of the collection metadata.
+	Indexes []IndexStats `bson:"indexes"`
+	// TotalIndexSize is the size in bytes of the entries of every index.
+	TotalIndexSize int64 `bson:"totalIndexSize"`
+	// Keys is the number of storage keys of the collection, its metadata included.
+	Keys int64 `bson:"keys"`
+}
+
+// IndexStats are the storage statistics of an index.
+type IndexStats struct {
+	Index IndexModel `bson:"index"`
+	// Keys is the number of entries of the index.
+	Keys int64 `bson:"keys"`
+	// Size is the size in bytes of the keys and values of the entries.
+	Size int64 `bson:"size"`
+}
+
+// DatabaseStats are the storage statistics of a database.
+type DatabaseStats struct {
+	Name        string            `bson:"db"`
+	Collections []CollectionStats `bson:"collections"`
+	// Objects is the number of documents of every collection.
+	Objects int64 `bson:"objects"`
+	// DataSize is the size in bytes of the documents of every collection.
+	DataSize int64 `bson:"dataSize"`
+	// IndexSize is the size in bytes of the index entries of every collection.
+	IndexSize int64 `bson:"indexSize"`
+	// Keys is the number of storage keys of the collections.
+	Keys int64 `bson:"keys"`
+	// LSMSize and VlogSize are the sizes in bytes of the LSM tree and the value log of the storage
+	// engine, which every database of a data directory shares. Badger refreshes them periodically,
+	// and they are zero for engines that do not report them, such as the memory engine.
+	LSMSize  int64 `bson:"lsmSize"`
+	VlogSize int64 `bson:"vlogSize"`
+}
+
+// Stats returns the storage statistics of the collection. It streams every key of the collection,
+// so it takes time proportional to its size.
+func (c *Collection) Stats() (CollectionStats, error) {
+	return c.StatsContext(context.Background())
+}
+
+// StatsContext is like Stats, but stops streaming the keys of the collection when the context is
+// done, returning its error.
+func (c *Collection) StatsContext(ctx context.Context) (CollectionStats, error) {
+	if err := ctx.Err(); err != nil {
+		return CollectionStats{}, err
+	}
+
+	meta, err := c.IndexManager.loadMetadata()
+	if err != nil {
+		return CollectionStats{}, err
+	}
+
+	indexes := meta.Indexes
+
+	stats := CollectionStats{
+		Namespace: Namespace{Database: c.dbname, Collection: c.collname},
+		Indexes:   make([]IndexStats, len(indexes)),
+	}
+
+	byName := make(map[string]*IndexStats, len(indexes))
+
+	for i, idx := range indexes {
+		stats.Indexes[i].Index = idx
+		byName[idx.Options.Name] = &stats.Indexes[i]
+	}
+
+	if _, err := c.storage.Get(c.IndexManager.buildMetadataKey()); err == nil {
+		stats.Keys++
+	}
+
+	prefix := fmt.Sprintf(consts.CollectionKeyStringFormat, c.dbname, c.collname) + "/"
+
+	err = c.storage.Stream(ctx, prefix, func(key string, value []byte) error {
+		stats.Keys++
+
+		kind, rest, _ := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+
+		switch kind {
+		case "docs":
+			stats.Count++
+			stats.Size += int64(len(value))
+		case "idxs":
+			size := int64(len(key) + len(value))
+			stats.TotalIndexSize += size
+
+			name, _, _ := strings.Cut(rest, "/")
+			if idx, ok := byName[name]; ok {
+				idx.Keys++
+				idx.Size += size
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return CollectionStats{}, err
+	}
+
+	if stats.Count > 0 {
+		stats.AvgObjSize = stats.Size / stats.Count
+	}
+
+	return stats, nil
+}
+
+// Stats returns the storage statistics of the database and of each of its collections.
+func (db *Database) Stats() (DatabaseStats, error) {
+	return db.StatsContext(context.Background())
+}
+
+// StatsContext is like Stats, but stops when the context is done, returning its error.
+func (db *Database) StatsContext(ctx context.Context) (DatabaseStats, error) {
+	names, err := db.collectionNames()
+	if err != nil {
+		return DatabaseStats{}, err
+	}
+
+	stats := DatabaseStats{
+		Name:        db.name,
+		Collections: make([]CollectionStats, 0, len(names)),
+	}
+
+	for _, name := range names {
+		coll, err := newCollection(db, name)
+		if err != nil {
+			return DatabaseStats{}, err
+		}
+
+		cs, err := coll.StatsContext(ctx)
+		if err != nil {
+			return DatabaseStats{}, err
+		}
+
+		stats.Collections = append(stats.Collections, cs)
+		stats.Objects += cs.Count
+		stats.DataSize += cs.Size
+		stats.IndexSize += cs.TotalIndexSize
+		stats.Keys += cs.Keys
+	}
+
+	if sizer, ok := db.storage.(storage.Sizer); ok {
+		stats.LSMSize, stats.VlogSize = sizer.Size()
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,159 @@
+package gopherdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/wirvii/gopherdb/internal/storage"
+)
+
+// maxWriteAttempts bounds the attempts of a write that conflicts with concurrent transactions.
+const maxWriteAttempts = 16
+
+// storageReader reads the keys of a database, either the committed ones or those seen by a
+// transaction.
+type storageReader interface {
+	Get(key string) ([]byte, error)
+	Scan(prefix string) ([]storage.KV, error)
+	ScanKeys(prefix string) ([]string, error)
+}
+
+// scanContext scans the keys of a reader that match the prefix until the context is done. Readers
+// that cannot stop a scan are checked once it ends.
+func scanContext(ctx context.Context, r storageReader, prefix string) ([]storage.KV, error) {
+	if scanner, ok := r.(storage.ContextScanner); ok {
+		return scanner.ScanContext(ctx, prefix)
+	}
+
+	kvs, err := r.Scan(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return kvs, nil
+}
+
+// writeTxn is a storage transaction that collects the changes made by a write operation.
+// The changes are journaled in the same transaction and published to the change streams of the
+// database once it commits.
+type writeTxn struct {
+	storage.Transaction
+	// ctx is the context of the write, checked by the scans of the transaction.
+	ctx     context.Context
+	db      *Database
+	changes []ChangeEvent
+	counts  map[string]int64
+	// sequences holds the highest ID of the sequence of each collection used by the transaction.
+	sequences map[string]int64
+}
+
+// beginWrite starts a new write transaction. Followers only accept replicated writes.
+func (db *Database) beginWrite() (*writeTxn, error) {
+	if err := db.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	return db.newWriteTxn(), nil
+}
+
+// newWriteTxn starts a new write transaction without checking the replication role.
+func (db *Database) newWriteTxn() *writeTxn {
+	return &writeTxn{
+		Transaction: db.storage.BeginTx(),
+		ctx:         context.Background(),
+		db:          db,
+	}
+}
+
+// record records a change made by the transaction.
+func (t *writeTxn) record(event ChangeEvent) {
+	t.changes = append(t.changes, event)
+}
+
+// count records a change of the number of documents of a collection, applied to its metadata
+// when the transaction commits.
+func (t *writeTxn) count(collname string, delta int64) {
+	if t.counts == nil {
+		t.counts = make(map[string]int64)
+	}
+
+	t.counts[collname] += delta
+}
+
+// create records that the transaction writes to a collection, so its metadata is stored when the
+// transaction commits if it was not stored yet.
+func (t *writeTxn) create(collname string) {
+	t.count(collname, 0)
+}
+
+// sequence records an ID of the sequence of a collection used by the transaction. The highest one
+// is stored in the metadata of the collection when the transaction commits.
+func (t *writeTxn) sequence(collname string, id int64) {
+	if t.sequences == nil {
+		t.sequences = make(map[string]int64)
+	}
+
+	t.sequences[collname] = max(t.sequences[collname], id)
+}
+
+// ScanContext scans the keys that match the prefix seen by the transaction until the context is
+// done.
+func (t *writeTxn) ScanContext(ctx context.Context, prefix string) ([]storage.KV, error) {
+	return scanContext(ctx, t.Transaction, prefix)
+}
+
+// Commit commits the transaction and publishes its changes.
+func (t *writeTxn) Commit() error {
+	if len(t.changes) == 0 && len(t.counts) == 0 && len(t.sequences) == 0 {
+		return t.Transaction.Commit()
+	}
+
+	return t.db.changes.commit(t.Transaction, t.changes, t.counts, t.sequences)
+}
+
+// runWrite runs a write in a new write transaction and commits it. A write whose commit conflicts
+// with a concurrent transaction is run again in a new transaction, up to maxWriteAttempts times,
+// so it must only change the transaction. The context is checked before each attempt and by the
+// scans of the transaction.
+func (db *Database) runWrite(ctx context.Context, write func(txn *writeTxn) error) error {
+	var err error
+
+	for range maxWriteAttempts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err = db.runWriteOnce(ctx, write); !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// runWriteOnce runs a write in a new write transaction and commits it.
+func (db *Database) runWriteOnce(ctx context.Context, write func(txn *writeTxn) error) error {
+	txn, err := db.beginWrite()
+	if err != nil {
+		return err
+	}
+
+	txn.ctx = ctx
+
+	if err := write(txn); err != nil {
+		txn.Rollback()
+
+		return err
+	}
+
+	if err := txn.Commit(); err != nil {
+		return fmt.Errorf("commit failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package gopherdb
+
+import (
+	"context"
+	"iter"
+	"reflect"
+
+	"github.com/wirvii/gopherdb/options"
+)
+
+// TypedCollection is a collection whose documents are values of type T, usually a struct with bson
+// tags. Documents are checked by the compiler when they are written and decoded into T when they
+// are read.
+type TypedCollection[T any] struct {
+	coll *Collection
+}
+
+// NewTypedCollection returns the typed handle of a collection of the database. The options are
+// those of Database.Collection. T must be a struct, a pointer to a struct or a map with string
+// keys.
+func NewTypedCollection[T any](db *Database, name string, opts ...*options.CollectionOptions) (*TypedCollection[T], error) {
+	coll, err := db.Collection(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return Typed[T](coll)
+}
+
+// Typed returns the typed handle of an open collection. T must be a struct, a pointer to a struct
+// or a map with string keys.
+func Typed[T any](coll *Collection) (*TypedCollection[T], error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct:
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+	default:
+		return nil, ErrDocumentTypeInvalid
+	}
+
+	return &TypedCollection[T]{coll: coll}, nil
+}
+
+// Collection returns the untyped handle of the collection.
+func (c *TypedCollection[T]) Collection() *Collection {
+	return c.coll
+}
+
+// InsertOne inserts a document and returns its ID.
+func (c *TypedCollection[T]) InsertOne(doc T) (any, error) {
+	return c.InsertOneContext(context.Background(), doc)
+}
+
+// InsertOneContext is like InsertOne, but fails with the error of the context when it is done.
+func (c *TypedCollection[T]) InsertOneContext(ctx context.Context, doc T) (any, error) {
+	result := c.coll.InsertOneContext(ctx, doc)
+
+	return result.InsertedID, result.Err
+}
+
+// InsertMany inserts documents in a single transaction and returns their IDs.
+func (c *TypedCollection[T]) InsertMany(docs []T) ([]any, error) {
+	return c.InsertManyContext(context.Background(), docs)
+}
+
+// InsertManyContext is like InsertMany, but stops when the context is done. See
+// Collection.InsertContext.
+func (c *TypedCollection[T]) InsertManyContext(ctx context.Context, docs []T) ([]any, error) {
+	result := c.coll.InsertContext(ctx, docs)
+
+	return result.InsertedIDs, result.Err
+}
+
+// FindByID returns the document with the ID, or ErrDocumentNotFound.
+func (c *TypedCollection[T]) FindByID(id any) (T, error) {
+	return c.FindByIDContext(context.Background(), id)
+}
+
+// FindByIDContext is like FindByID, but fails with the error of the context when it is done.
+func (c *TypedCollection[T]) FindByIDContext(ctx context.Context, id any) (T, error) {
+	var doc T
+
+	result := c.coll.FindByIDContext(ctx, id)
+	err := result.Unmarshal(&doc)
+
+	return doc, err
+}
+
+// FindOne returns the first document that matches the filter, or ErrDocumentNotFound.
+func (c *TypedCollection[T]) FindOne(filter any) (T, error) {
+	return c.FindOneContext(context.Background(), filter)
+}
+
+// FindOneContext is like FindOne, but stops when the context is done.
+func (c *TypedCollection[T]) FindOneContext(ctx context.Context, filter any) (T, error) {
+	var doc T
+
+	result := c.coll.FindOneContext(ctx, filter)
+	err := result.Unmarshal(&doc)
+
+	return doc, err
+}
+
+// Find returns the documents that match the filter.
+func (c *TypedCollection[T]) Find(filter any, opts ...*options.FindOptions) ([]T, error) {
+	return c.FindContext(context.Background(), filter, opts...)
+}
+
+// FindContext is like Find, but stops when the context is done. See Collection.FindContext.
+func (c *TypedCollection[T]) FindContext(ctx context.Context, filter any, opts ...*options.FindOptions) ([]T, error) {
+	docs := make([]T, 0)
+
+	for doc, err := range c.AllContext(ctx, filter, opts...) {
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// All returns an iterator over the documents that match the filter. Each document is decoded when
+// the iteration reaches it; a failed query yields its error once.
+func (c *TypedCollection[T]) All(filter any, opts ...*options.FindOptions) iter.Seq2[T, error] {
+	return c.AllContext(context.Background(), filter, opts...)
+}
+
+// AllContext is like All, but the query stops when the context is done.
+func (c *TypedCollection[T]) AllContext(ctx context.Context, filter any, opts ...*options.FindOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		result := c.coll.FindContext(ctx, filter, opts...)
+		if result.Err != nil {
+			var zero T
+
+			yield(zero, result.Err)
+
+			return
+		}
+
+		for _, kv := range result.raw {
+			var doc T
+
+			err := unmarshalDocument(kv, result.encryption, &doc)
+			if !yield(doc, err) {
+				return
+			}
+		}
+	}
+}
+
+// CountDocuments counts the documents that match the filter.
+func (c *TypedCollection[T]) CountDocuments(filter any) (int64, error) {
+	return c.coll.CountDocuments(filter)
+}
+
+// CountDocumentsContext is like CountDocuments, but stops when the context is done.
+func (c *TypedCollection[T]) CountDocumentsContext(ctx context.Context, filter any) (int64, error) {
+	return c.coll.CountDocumentsContext(ctx, filter)
+}
+
+// UpdateOne updates the first document that matches the filter with update operators, or replaces
+// it with a document of type T.
+func (c *TypedCollection[T]) UpdateOne(filter any, update any, opts ...*options.UpdateOptions) (any, error) {
+	return c.UpdateOneContext(context.Background(), filter, update, opts...)
+}
+
+// UpdateOneContext is like UpdateOne, but fails with the error of the context when it is done.
+func (c *TypedCollection[T]) UpdateOneContext(
+	ctx context.Context,
+	filter any,
+	update any,
+	opts ...*options.UpdateOptions,
+) (any, error) {
+	result := c.coll.UpdateOneContext(ctx, filter, update, opts...)
+
+	return result.UpsertedID, result.Err
+}
+
+// UpdateMany applies update operators to every document that matches the filter and returns the
+// number of matched and modified documents.
+func (c *TypedCollection[T]) UpdateMany(filter any, update any, opts ...*options.UpdateOptions) (int64, int64, error) {
+	return c.UpdateManyContext(context.Background(), filter, update, opts...)
+}
+
+// UpdateManyContext is like UpdateMany, but stops when the context is done. See
+// Collection.UpdateManyContext.
+func (c *TypedCollection[T]) UpdateManyContext(
+	ctx context.Context,
+	filter any,
+	update any,
+	opts ...*options.UpdateOptions,
+) (int64, int64, error) {
+	result := c.coll.UpdateManyContext(ctx, filter, update, opts...)
+
+	return result.MatchedCount, result.ModifiedCount, result.Err
+}
+
+// ReplaceOne replaces the first document that matches the filter with a document of type T,
+// keeping its _id, and returns the ID.
+func (c *TypedCollection[T]) ReplaceOne(filter any, replacement T, opts ...*options.ReplaceOptions) (any, error) {
+	return c.ReplaceOneContext(context.Background(), filter, replacement, opts...)
+}
+
+// ReplaceOneContext is like ReplaceOne, but fails with the error of the context when it is done.
+func (c *TypedCollection[T]) ReplaceOneContext(
+	ctx context.Context,
+	filter any,
+	replacement T,
+	opts ...*options.ReplaceOptions,
+) (any, error) {
+	result := c.coll.ReplaceOneContext(ctx, filter, replacement, opts...)
+
+	return result.UpsertedID, result.Err
+}
+
+// DeleteOne deletes the first document that matches the filter and returns its ID.
+func (c *TypedCollection[T]) DeleteOne(filter any) (any, error) {
+	return c.DeleteOneContext(context.Background(), filter)
+}
+
+// DeleteOneContext is like DeleteOne, but fails with the error of the context when it is done.
+func (c *TypedCollection[T]) DeleteOneContext(ctx context.Context, filter any) (any, error) {
+	result := c.coll.DeleteOneContext(ctx, filter)
+
+	return result.DeletedID, result.Err
+}
+
+// DeleteByID deletes the document with the ID.
+func (c *TypedCollection[T]) DeleteByID(id any) error {
+	return c.DeleteByIDContext(context.Background(), id)
+}
+
+// DeleteByIDContext is like DeleteByID, but fails with the error of the context when it is done.
+func (c *TypedCollection[T]) DeleteByIDContext(ctx context.Context, id any) error {
+	return c.coll.DeleteByIDContext(ctx, id).Err
+}
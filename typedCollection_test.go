@@ -0,0 +1,111 @@
+package gopherdb_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type order struct {
+	ID   int64  `bson:"_id"`
+	Item string `bson:"item"`
+}
+
+type note struct {
+	ID   primitive.ObjectID `bson:"_id"`
+	Text string             `bson:"text"`
+}
+
+// newTypedTestCollection opens a typed collection of a new memory database.
+func newTypedTestCollection[T any](t *testing.T, name string, opts ...*options.CollectionOptions) *gopherdb.TypedCollection[T] {
+	t.Helper()
+
+	coll, err := gopherdb.Typed[T](newTestCollection(t, name, opts...))
+	if err != nil {
+		t.Fatalf("typed collection: %v", err)
+	}
+
+	return coll
+}
+
+func TestTypedCollectionSequenceIDs(t *testing.T) {
+	orders := newTypedTestCollection[order](t, "orders", options.Collection().SetIDStrategy(options.IDStrategySequence))
+
+	id, err := orders.InsertOne(order{Item: "apple"})
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if id != int64(1) {
+		t.Fatalf("first ID %v, want 1", id)
+	}
+
+	ids, err := orders.InsertMany([]order{{Item: "pear"}, {Item: "plum"}})
+	if err != nil {
+		t.Fatalf("insert many: %v", err)
+	}
+
+	if !slices.Equal(ids, []any{int64(2), int64(3)}) {
+		t.Fatalf("IDs %v, want [2 3]", ids)
+	}
+
+	got, err := orders.FindByID(int64(1))
+	if err != nil || got.Item != "apple" {
+		t.Fatalf("find 1: got %+v, %v, want the apple order", got, err)
+	}
+
+	if _, err := orders.ReplaceOne(map[string]any{"_id": int64(2)}, order{Item: "peach"}); err != nil {
+		t.Fatalf("replace without an ID: %v", err)
+	}
+
+	got, err = orders.FindByID(int64(2))
+	if err != nil || got.Item != "peach" {
+		t.Fatalf("find 2: got %+v, %v, want the replaced order", got, err)
+	}
+
+	n, err := orders.CountDocuments(nil)
+	if err != nil || n != 3 {
+		t.Fatalf("count %d, %v, want 3", n, err)
+	}
+}
+
+func TestTypedCollectionObjectIDs(t *testing.T) {
+	notes := newTypedTestCollection[note](t, "notes", options.Collection().SetIDStrategy(options.IDStrategyObjectID))
+
+	first, err := notes.InsertOne(note{Text: "a"})
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	second, err := notes.InsertOne(note{Text: "b"})
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	for _, id := range []any{first, second} {
+		if oid, ok := id.(primitive.ObjectID); !ok || oid.IsZero() {
+			t.Fatalf("ID %v, want a generated ObjectID", id)
+		}
+	}
+
+	if first == second {
+		t.Fatalf("both notes got the ID %v", first)
+	}
+}
+
+func TestTypedCollectionZeroIDOfOtherStrategies(t *testing.T) {
+	orders := newTypedTestCollection[order](t, "orders")
+
+	// Con la estrategia UUID un _id 0 es un ID dado, no uno ausente
+	id, err := orders.InsertOne(order{Item: "apple"})
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if id != int64(0) {
+		t.Fatalf("ID %v, want the given 0", id)
+	}
+}
@@ -0,0 +1,242 @@
+package gopherdb
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/wirvii/gopherdb/internal/queryengine"
+)
+
+// documentFields caches the field paths of the document types, by reflect.Type.
+var documentFields sync.Map
+
+// Filter builds the filter of a TypedCollection. Fields are named after the Go fields of T, with
+// dots for nested structs, and translated to the names stored in the documents through the bson
+// tags of T; a field that T does not have fails the filter when it is built. Several conditions on
+// the same field are combined.
+type Filter[T any] struct {
+	doc map[string]any
+	err error
+}
+
+// NewFilter returns an empty filter on the documents of type T, which matches every document.
+func NewFilter[T any]() *Filter[T] {
+	return &Filter[T]{doc: map[string]any{}}
+}
+
+// Eq matches the documents whose field is equal to the value.
+func (f *Filter[T]) Eq(field string, value any) *Filter[T] {
+	return f.where(field, queryengine.OperatorEqual, value)
+}
+
+// Ne matches the documents whose field is not equal to the value or is missing.
+func (f *Filter[T]) Ne(field string, value any) *Filter[T] {
+	return f.where(field, queryengine.OperatorNotEqual, value)
+}
+
+// Gt matches the documents whose field is greater than the value.
+func (f *Filter[T]) Gt(field string, value any) *Filter[T] {
+	return f.where(field, queryengine.OperatorGreaterThan, value)
+}
+
+// Gte matches the documents whose field is greater than or equal to the value.
+func (f *Filter[T]) Gte(field string, value any) *Filter[T] {
+	return f.where(field, queryengine.OperatorGreaterThanOrEqual, value)
+}
+
+// Lt matches the documents whose field is less than the value.
+func (f *Filter[T]) Lt(field string, value any) *Filter[T] {
+	return f.where(field, queryengine.OperatorLessThan, value)
+}
+
+// Lte matches the documents whose field is less than or equal to the value.
+func (f *Filter[T]) Lte(field string, value any) *Filter[T] {
+	return f.where(field, queryengine.OperatorLessThanOrEqual, value)
+}
+
+// In matches the documents whose field is equal to one of the values.
+func (f *Filter[T]) In(field string, values ...any) *Filter[T] {
+	return f.where(field, queryengine.OperatorIn, values)
+}
+
+// Nin matches the documents whose field is not equal to any of the values.
+func (f *Filter[T]) Nin(field string, values ...any) *Filter[T] {
+	return f.where(field, queryengine.OperatorNotIn, values)
+}
+
+// Exists matches the documents that have the field, or that do not have it when exists is false.
+func (f *Filter[T]) Exists(field string, exists bool) *Filter[T] {
+	return f.where(field, queryengine.OperatorExists, exists)
+}
+
+// Or matches the documents that match the filter and at least one of the alternatives.
+func (f *Filter[T]) Or(alternatives ...*Filter[T]) *Filter[T] {
+	clauses := make([]any, 0, len(alternatives))
+
+	for _, alt := range alternatives {
+		doc, err := alt.Build()
+		if err != nil && f.err == nil {
+			f.err = err
+		}
+
+		clauses = append(clauses, doc)
+	}
+
+	if prev, ok := f.doc[queryengine.OperatorOr.String()]; ok {
+		f.doc[queryengine.OperatorAnd.String()] = []any{
+			map[string]any{queryengine.OperatorOr.String(): prev},
+			map[string]any{queryengine.OperatorOr.String(): clauses},
+		}
+
+		delete(f.doc, queryengine.OperatorOr.String())
+
+		return f
+	}
+
+	f.doc[queryengine.OperatorOr.String()] = clauses
+
+	return f
+}
+
+// Build returns the filter document, or the first error of the conditions.
+func (f *Filter[T]) Build() (map[string]any, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	doc := make(map[string]any, len(f.doc))
+	for k, v := range f.doc {
+		if cond, ok := v.(map[string]any); ok {
+			v = maps.Clone(cond)
+		}
+
+		doc[k] = v
+	}
+
+	return doc, nil
+}
+
+// where adds a condition on a field.
+func (f *Filter[T]) where(field string, op queryengine.Operator, value any) *Filter[T] {
+	path, err := FieldPath[T](field)
+	if err != nil {
+		if f.err == nil {
+			f.err = err
+		}
+
+		return f
+	}
+
+	cond, ok := f.doc[path].(map[string]any)
+	if !ok {
+		cond = map[string]any{}
+		f.doc[path] = cond
+	}
+
+	cond[op.String()] = value
+
+	return f
+}
+
+// FieldPath returns the dotted path stored in the documents for a field of T, named after its Go
+// fields with dots for nested structs. The stored names of the fields are accepted as well. Every
+// path is accepted when T is a map.
+func FieldPath[T any](field string) (string, error) {
+	t := reflect.TypeFor[T]()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return field, nil
+	}
+
+	paths, ok := documentFields.Load(t)
+	if !ok {
+		paths, _ = documentFields.LoadOrStore(t, fieldPaths(t, "", "", map[reflect.Type]bool{}))
+	}
+
+	path, ok := paths.(map[string]string)[field]
+	if !ok {
+		return "", fmt.Errorf("%w: %s has no field %s", ErrInvalidQuery, t, field)
+	}
+
+	return path, nil
+}
+
+// fieldPaths maps the Go paths and the stored paths of the fields of a struct type to their stored
+// paths, descending into nested and inlined structs and the structs of slices. Types already being
+// walked are not walked again, so recursive types terminate.
+func fieldPaths(t reflect.Type, goPrefix, bsonPrefix string, walking map[reflect.Type]bool) map[string]string {
+	out := map[string]string{}
+
+	walking[t] = true
+	defer delete(walking, t)
+
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, inline, skip := bsonFieldTag(sf)
+		if skip {
+			continue
+		}
+
+		goPath, bsonPath := goPrefix+sf.Name, bsonPrefix+name
+
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array {
+			ft = ft.Elem()
+		}
+
+		nested := ft.Kind() == reflect.Struct && !walking[ft] && ft.PkgPath() != "time"
+
+		if inline && nested {
+			maps.Copy(out, fieldPaths(ft, goPrefix, bsonPrefix, walking))
+
+			continue
+		}
+
+		out[goPath] = bsonPath
+		out[bsonPath] = bsonPath
+
+		if nested {
+			maps.Copy(out, fieldPaths(ft, goPath+".", bsonPath+".", walking))
+		}
+	}
+
+	return out
+}
+
+// bsonFieldTag parses the bson tag of a struct field as the mongo driver does: the name defaults to
+// the lowercased Go name, "-" skips the field and the inline flag flattens a nested struct.
+func bsonFieldTag(sf reflect.StructField) (name string, inline, skip bool) {
+	tag, ok := sf.Tag.Lookup("bson")
+	if !ok && !strings.Contains(string(sf.Tag), ":") && sf.Tag != "" {
+		tag = string(sf.Tag)
+	}
+
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			inline = true
+		}
+	}
+
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+
+	return name, inline, false
+}
@@ -0,0 +1,232 @@
+// Package update builds update documents for the collections of gopherdb:
+//
+//	update.Set("status", "active").Inc("logins", 1).Push("tags", "new")
+//
+// An Update is an ordered document of update operators, so the order of its operators and fields is
+// kept when it is encoded. It can be passed to Collection.UpdateOne as it is.
+package update
+
+import (
+	"github.com/wirvii/gopherdb/internal/bson"
+	op "github.com/wirvii/gopherdb/internal/update"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// modifierEach is the modifier of $push and $addToSet that adds several values.
+const modifierEach = "$each"
+
+// Update is an update document whose operators and fields keep the order in which they were
+// added.
+type Update primitive.D
+
+// Set sets the value of a field.
+func Set(field string, value any) Update {
+	return Update{}.Set(field, value)
+}
+
+// SetOnInsert sets the value of a field when the update inserts a document.
+func SetOnInsert(field string, value any) Update {
+	return Update{}.SetOnInsert(field, value)
+}
+
+// Unset removes fields.
+func Unset(fields ...string) Update {
+	return Update{}.Unset(fields...)
+}
+
+// Inc increments a field by a number.
+func Inc(field string, n any) Update {
+	return Update{}.Inc(field, n)
+}
+
+// Mul multiplies a field by a number.
+func Mul(field string, n any) Update {
+	return Update{}.Mul(field, n)
+}
+
+// Min sets a field to the value if it is lower than the current one.
+func Min(field string, value any) Update {
+	return Update{}.Min(field, value)
+}
+
+// Max sets a field to the value if it is greater than the current one.
+func Max(field string, value any) Update {
+	return Update{}.Max(field, value)
+}
+
+// Rename renames a field.
+func Rename(field, to string) Update {
+	return Update{}.Rename(field, to)
+}
+
+// CurrentDate sets a field to the current date.
+func CurrentDate(field string) Update {
+	return Update{}.CurrentDate(field)
+}
+
+// Push appends values to an array.
+func Push(field string, values ...any) Update {
+	return Update{}.Push(field, values...)
+}
+
+// AddToSet appends values to an array unless they are already present.
+func AddToSet(field string, values ...any) Update {
+	return Update{}.AddToSet(field, values...)
+}
+
+// Pull removes the values of an array equal to the value, or matching it when it is a condition
+// document such as {$gte: 5}.
+func Pull(field string, cond any) Update {
+	return Update{}.Pull(field, cond)
+}
+
+// PopFirst removes the first value of an array.
+func PopFirst(field string) Update {
+	return Update{}.PopFirst(field)
+}
+
+// PopLast removes the last value of an array.
+func PopLast(field string) Update {
+	return Update{}.PopLast(field)
+}
+
+// Set adds a $set of a field to the update.
+func (u Update) Set(field string, value any) Update {
+	return u.with(op.OperatorSet, field, value)
+}
+
+// SetOnInsert adds a $setOnInsert of a field to the update.
+func (u Update) SetOnInsert(field string, value any) Update {
+	return u.with(op.OperatorSetOnInsert, field, value)
+}
+
+// Unset adds an $unset of fields to the update.
+func (u Update) Unset(fields ...string) Update {
+	for _, field := range fields {
+		u = u.with(op.OperatorUnset, field, "")
+	}
+
+	return u
+}
+
+// Inc adds an $inc of a field to the update.
+func (u Update) Inc(field string, n any) Update {
+	return u.with(op.OperatorInc, field, n)
+}
+
+// Mul adds a $mul of a field to the update.
+func (u Update) Mul(field string, n any) Update {
+	return u.with(op.OperatorMul, field, n)
+}
+
+// Min adds a $min of a field to the update.
+func (u Update) Min(field string, value any) Update {
+	return u.with(op.OperatorMin, field, value)
+}
+
+// Max adds a $max of a field to the update.
+func (u Update) Max(field string, value any) Update {
+	return u.with(op.OperatorMax, field, value)
+}
+
+// Rename adds a $rename of a field to the update.
+func (u Update) Rename(field, to string) Update {
+	return u.with(op.OperatorRename, field, to)
+}
+
+// CurrentDate adds a $currentDate of a field to the update.
+func (u Update) CurrentDate(field string) Update {
+	return u.with(op.OperatorCurrentDate, field, true)
+}
+
+// Push adds a $push of values to the update.
+func (u Update) Push(field string, values ...any) Update {
+	return u.with(op.OperatorPush, field, each(values))
+}
+
+// AddToSet adds an $addToSet of values to the update.
+func (u Update) AddToSet(field string, values ...any) Update {
+	return u.with(op.OperatorAddToSet, field, each(values))
+}
+
+// Pull adds a $pull of a value or a condition to the update.
+func (u Update) Pull(field string, cond any) Update {
+	return u.with(op.OperatorPull, field, cond)
+}
+
+// PopFirst adds a $pop of the first value of an array to the update.
+func (u Update) PopFirst(field string) Update {
+	return u.with(op.OperatorPop, field, int32(-1))
+}
+
+// PopLast adds a $pop of the last value of an array to the update.
+func (u Update) PopLast(field string) Update {
+	return u.with(op.OperatorPop, field, int32(1))
+}
+
+// D returns the update as an ordered document.
+func (u Update) D() primitive.D {
+	return primitive.D(u)
+}
+
+// Map returns the update as a map of update operators.
+func (u Update) Map() map[string]any {
+	doc, _ := bson.Normalize(primitive.D(u)).(map[string]any)
+
+	return doc
+}
+
+// MarshalBSON encodes the update as a BSON document.
+func (u Update) MarshalBSON() ([]byte, error) {
+	return bson.Marshal(primitive.D(u))
+}
+
+// with returns a copy of the update with the field added to the document of the operator. The value
+// of a field the operator already sets is replaced.
+func (u Update) with(operator op.Operator, field string, value any) Update {
+	out := make(Update, 0, len(u)+1)
+
+	found := false
+
+	for _, e := range u {
+		if e.Key == string(operator) {
+			fields, _ := e.Value.(primitive.D)
+			e.Value = setField(fields, field, value)
+			found = true
+		}
+
+		out = append(out, e)
+	}
+
+	if !found {
+		out = append(out, primitive.E{Key: string(operator), Value: primitive.D{{Key: field, Value: value}}})
+	}
+
+	return out
+}
+
+// setField returns a copy of the document with the field set to the value, in its position when
+// the document already has it.
+func setField(doc primitive.D, field string, value any) primitive.D {
+	out := append(make(primitive.D, 0, len(doc)+1), doc...)
+
+	for i, e := range out {
+		if e.Key == field {
+			out[i].Value = value
+
+			return out
+		}
+	}
+
+	return append(out, primitive.E{Key: field, Value: value})
+}
+
+// each returns the value appended by $push or $addToSet: the value itself, or the values under
+// the $each modifier when there are several.
+func each(values []any) any {
+	if len(values) == 1 {
+		return values[0]
+	}
+
+	return primitive.D{{Key: modifierEach, Value: primitive.A(values)}}
+}
@@ -0,0 +1,585 @@
+package gopherdb
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/options"
+	"github.com/xdg-go/scram"
+)
+
+const (
+	// AdminDatabase is the reserved database that holds the users of the server modes.
+	AdminDatabase = "admin"
+	// usersCollection is the collection of the admin database that holds the users.
+	usersCollection = "system.users"
+	// scramIterations is the PBKDF2 iteration count of new SCRAM-SHA-256 credentials, the MongoDB
+	// default.
+	scramIterations = 15000
+	// scramSaltSize is the size of the salt of new SCRAM-SHA-256 credentials.
+	scramSaltSize = 28
+	// tokenIDSize and tokenSecretSize are the sizes of the two parts of an API token.
+	tokenIDSize     = 6
+	tokenSecretSize = 32
+	// AnyDatabase grants a role on every database.
+	AnyDatabase = "*"
+)
+
+// Role is a built-in role of the server modes.
+type Role string
+
+const (
+	// RoleRead reads documents, collections and indexes.
+	RoleRead Role = "read"
+	// RoleReadWrite reads and writes documents, and creates and drops collections and indexes.
+	RoleReadWrite Role = "readWrite"
+	// RoleDBAdmin lists, creates and drops collections and indexes, without access to documents.
+	RoleDBAdmin Role = "dbAdmin"
+	// RoleRoot can do everything on every database, including the system collections.
+	RoleRoot Role = "root"
+)
+
+// action is something a request does on a database or a collection.
+type action int
+
+const (
+	// actionRead reads documents.
+	actionRead action = iota
+	// actionWrite inserts, updates and deletes documents.
+	actionWrite
+	// actionInspect lists collections and indexes.
+	actionInspect
+	// actionManage creates and drops collections and indexes.
+	actionManage
+)
+
+// roleActions are the actions allowed by each role, besides root.
+var roleActions = map[Role][]action{
+	RoleRead:      {actionRead, actionInspect},
+	RoleReadWrite: {actionRead, actionWrite, actionInspect, actionManage},
+	RoleDBAdmin:   {actionInspect, actionManage},
+}
+
+// RoleGrant grants a role on a database, or on a single collection of it. The database
+// AnyDatabase matches every database. Root is granted without a database.
+type RoleGrant struct {
+	Role       Role   `bson:"role"`
+	Database   string `bson:"db,omitempty"`
+	Collection string `bson:"coll,omitempty"`
+}
+
+// String returns the grant as role@db or role@db.coll.
+func (g RoleGrant) String() string {
+	switch {
+	case g.Database == "":
+		return string(g.Role)
+	case g.Collection == "":
+		return fmt.Sprintf("%s@%s", g.Role, g.Database)
+	default:
+		return fmt.Sprintf("%s@%s.%s", g.Role, g.Database, g.Collection)
+	}
+}
+
+// ParseRoleGrant parses a grant written as role, role@db or role@db.coll.
+func ParseRoleGrant(s string) (RoleGrant, error) {
+	role, scope, _ := strings.Cut(s, "@")
+	db, coll, _ := strings.Cut(scope, ".")
+
+	g := RoleGrant{Role: Role(role), Database: db, Collection: coll}
+
+	return g, g.validate()
+}
+
+// validate checks that the role exists and the scope suits it.
+func (g RoleGrant) validate() error {
+	if g.Role == RoleRoot {
+		if g.Database != "" {
+			return fmt.Errorf("%w: root is granted on every database", ErrInvalidRole)
+		}
+
+		return nil
+	}
+
+	if _, ok := roleActions[g.Role]; !ok {
+		return fmt.Errorf("%w: unknown role '%s'", ErrInvalidRole, g.Role)
+	}
+
+	if g.Database == "" {
+		return fmt.Errorf("%w: %s must be granted on a database", ErrInvalidRole, g.Role)
+	}
+
+	if g.Database != AnyDatabase && strings.ContainsAny(g.Database, "/. $") {
+		return fmt.Errorf("%w: invalid database name '%s'", ErrInvalidRole, g.Database)
+	}
+
+	return nil
+}
+
+// allows reports whether the grant allows an action on a collection, or on the database when
+// coll is empty.
+func (g RoleGrant) allows(act action, db, coll string) bool {
+	if g.Role == RoleRoot {
+		return true
+	}
+
+	if g.Database != AnyDatabase && g.Database != db {
+		return false
+	}
+
+	if g.Collection != "" && g.Collection != coll {
+		return false
+	}
+
+	return slices.Contains(roleActions[g.Role], act)
+}
+
+// User is a user of the server modes.
+type User struct {
+	Name   string
+	Roles  []RoleGrant
+	Tokens []string
+}
+
+// can reports whether the user may run an action on a collection, or on the database when coll
+// is empty. System collections are reserved for root.
+func (u *User) can(act action, db, coll string) bool {
+	if slices.ContainsFunc(u.Roles, func(g RoleGrant) bool { return g.Role == RoleRoot }) {
+		return true
+	}
+
+	if strings.HasPrefix(coll, "system.") {
+		return false
+	}
+
+	return slices.ContainsFunc(u.Roles, func(g RoleGrant) bool { return g.allows(act, db, coll) })
+}
+
+// canSee reports whether the user has any role on a database.
+func (u *User) canSee(db string) bool {
+	return slices.ContainsFunc(u.Roles, func(g RoleGrant) bool {
+		return g.Role == RoleRoot || g.Database == AnyDatabase || g.Database == db
+	})
+}
+
+// userDocument is a user as stored in the users collection.
+type userDocument struct {
+	Name   string           `bson:"_id"`
+	Roles  []RoleGrant      `bson:"roles"`
+	SCRAM  scramCredentials `bson:"scram"`
+	Tokens []userToken      `bson:"tokens"`
+}
+
+// scramCredentials are the SCRAM-SHA-256 credentials of a user. The password is not stored.
+type scramCredentials struct {
+	Salt       []byte `bson:"salt"`
+	Iterations int    `bson:"iterations"`
+	StoredKey  []byte `bson:"storedKey"`
+	ServerKey  []byte `bson:"serverKey"`
+}
+
+// userToken is an API token of a user. Only the hash of its secret is stored.
+type userToken struct {
+	ID      string    `bson:"id"`
+	Hash    []byte    `bson:"hash"`
+	Created time.Time `bson:"created"`
+}
+
+// user returns the public view of the stored user.
+func (d *userDocument) user() *User {
+	u := &User{Name: d.Name, Roles: d.Roles}
+	for _, t := range d.Tokens {
+		u.Tokens = append(u.Tokens, t.ID)
+	}
+
+	return u
+}
+
+// Users manages the users and roles of the server modes. They are kept in the system.users
+// collection of the reserved admin database, in the same storage as the data they protect.
+type Users struct {
+	catalog *catalog
+	owned   bool
+}
+
+// OpenUsers opens the users of the data directory at path, to manage them while no server holds
+// the directory open.
+func OpenUsers(path string, opts ...*options.DatabaseOptions) (*Users, error) {
+	opt := options.Database()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	cat, err := openCatalog(path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Users{catalog: cat, owned: true}, nil
+}
+
+// Close closes the data directory when it was opened by OpenUsers.
+func (u *Users) Close() error {
+	if !u.owned {
+		return nil
+	}
+
+	return u.catalog.close()
+}
+
+// Create creates a user with a password and roles.
+func (u *Users) Create(name, password string, roles ...RoleGrant) error {
+	if name == "" || password == "" {
+		return fmt.Errorf("%w: name and password are required", ErrInvalidUser)
+	}
+
+	for _, g := range roles {
+		if err := g.validate(); err != nil {
+			return err
+		}
+	}
+
+	creds, err := newSCRAMCredentials(name, password)
+	if err != nil {
+		return err
+	}
+
+	sc, err := u.collection()
+	if err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if _, err := u.find(sc, name); err == nil {
+		return fmt.Errorf("%w: %s", ErrUserExists, name)
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+
+	doc := userDocument{Name: name, Roles: slices.Clone(roles), SCRAM: creds, Tokens: []userToken{}}
+	if doc.Roles == nil {
+		doc.Roles = []RoleGrant{}
+	}
+
+	return sc.coll.InsertOne(doc).Err
+}
+
+// Drop removes a user.
+func (u *Users) Drop(name string) error {
+	return u.modify(name, nil)
+}
+
+// Get returns a user.
+func (u *Users) Get(name string) (*User, error) {
+	sc, err := u.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	doc, err := u.find(sc, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.user(), nil
+}
+
+// List returns every user sorted by name.
+func (u *Users) List() ([]*User, error) {
+	docs, err := u.all()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0, len(docs))
+	for i := range docs {
+		users = append(users, docs[i].user())
+	}
+
+	slices.SortFunc(users, func(a, b *User) int { return strings.Compare(a.Name, b.Name) })
+
+	return users, nil
+}
+
+// SetPassword changes the password of a user.
+func (u *Users) SetPassword(name, password string) error {
+	if password == "" {
+		return fmt.Errorf("%w: password is required", ErrInvalidUser)
+	}
+
+	creds, err := newSCRAMCredentials(name, password)
+	if err != nil {
+		return err
+	}
+
+	return u.modify(name, func(doc *userDocument) error {
+		doc.SCRAM = creds
+
+		return nil
+	})
+}
+
+// Grant grants roles to a user. Roles the user already has are ignored.
+func (u *Users) Grant(name string, roles ...RoleGrant) error {
+	for _, g := range roles {
+		if err := g.validate(); err != nil {
+			return err
+		}
+	}
+
+	return u.modify(name, func(doc *userDocument) error {
+		for _, g := range roles {
+			if !slices.Contains(doc.Roles, g) {
+				doc.Roles = append(doc.Roles, g)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Revoke revokes roles from a user.
+func (u *Users) Revoke(name string, roles ...RoleGrant) error {
+	return u.modify(name, func(doc *userDocument) error {
+		doc.Roles = slices.DeleteFunc(doc.Roles, func(g RoleGrant) bool { return slices.Contains(roles, g) })
+
+		return nil
+	})
+}
+
+// CreateToken creates an API token for a user and returns it. The token is shown only once; it
+// is stored hashed.
+func (u *Users) CreateToken(name string) (string, error) {
+	id := make([]byte, tokenIDSize)
+	secret := make([]byte, tokenSecretSize)
+
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	token := userToken{ID: hex.EncodeToString(id), Hash: hashToken(secret), Created: time.Now().UTC()}
+
+	err := u.modify(name, func(doc *userDocument) error {
+		doc.Tokens = append(doc.Tokens, token)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token.ID + "." + hex.EncodeToString(secret), nil
+}
+
+// RevokeToken revokes an API token of a user by its ID.
+func (u *Users) RevokeToken(name, id string) error {
+	return u.modify(name, func(doc *userDocument) error {
+		n := len(doc.Tokens)
+
+		doc.Tokens = slices.DeleteFunc(doc.Tokens, func(t userToken) bool { return t.ID == id })
+		if len(doc.Tokens) == n {
+			return fmt.Errorf("%w: %s", ErrTokenNotFound, id)
+		}
+
+		return nil
+	})
+}
+
+// authenticatePassword checks the password of a user against its SCRAM credentials.
+func (u *Users) authenticatePassword(name, password string) (*User, error) {
+	sc, err := u.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	sc.mu.Lock()
+	doc, err := u.find(sc, name)
+	sc.mu.Unlock()
+
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrAuthenticationFailed
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := scram.SHA256.NewClient(name, password, "")
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	creds := client.GetStoredCredentials(scram.KeyFactors{
+		Salt:  string(doc.SCRAM.Salt),
+		Iters: doc.SCRAM.Iterations,
+	})
+
+	if subtle.ConstantTimeCompare(creds.StoredKey, doc.SCRAM.StoredKey) != 1 {
+		return nil, ErrAuthenticationFailed
+	}
+
+	return doc.user(), nil
+}
+
+// authenticateToken returns the user an API token belongs to.
+func (u *Users) authenticateToken(token string) (*User, error) {
+	id, secretHex, ok := strings.Cut(token, ".")
+	secret, err := hex.DecodeString(secretHex)
+
+	if !ok || err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+
+	docs, err := u.all()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashToken(secret)
+
+	for i := range docs {
+		for _, t := range docs[i].Tokens {
+			if t.ID == id && subtle.ConstantTimeCompare(t.Hash, hash) == 1 {
+				return docs[i].user(), nil
+			}
+		}
+	}
+
+	return nil, ErrAuthenticationFailed
+}
+
+// scramServer returns a SCRAM-SHA-256 server that looks up the credentials of the users.
+func (u *Users) scramServer() (*scram.Server, error) {
+	return scram.SHA256.NewServer(func(name string) (scram.StoredCredentials, error) {
+		sc, err := u.collection()
+		if err != nil {
+			return scram.StoredCredentials{}, err
+		}
+
+		sc.mu.Lock()
+		doc, err := u.find(sc, name)
+		sc.mu.Unlock()
+
+		if err != nil {
+			return scram.StoredCredentials{}, ErrAuthenticationFailed
+		}
+
+		return scram.StoredCredentials{
+			KeyFactors: scram.KeyFactors{Salt: string(doc.SCRAM.Salt), Iters: doc.SCRAM.Iterations},
+			StoredKey:  doc.SCRAM.StoredKey,
+			ServerKey:  doc.SCRAM.ServerKey,
+		}, nil
+	})
+}
+
+// collection returns the users collection.
+func (u *Users) collection() (*servedCollection, error) {
+	return u.catalog.collection(AdminDatabase, usersCollection)
+}
+
+// find returns a stored user. The caller holds the collection lock.
+func (u *Users) find(sc *servedCollection, name string) (*userDocument, error) {
+	result := sc.coll.FindByID(name)
+	if errors.Is(result.Err, ErrDocumentNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, name)
+	}
+
+	var doc userDocument
+	if err := result.Unmarshal(&doc); err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// all returns every stored user.
+func (u *Users) all() ([]userDocument, error) {
+	sc, err := u.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	sc.mu.Lock()
+	result := sc.coll.Find(map[string]any{})
+	sc.mu.Unlock()
+
+	var docs []userDocument
+	if err := result.Unmarshal(&docs); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// modify changes a stored user, or removes it when fn is nil.
+func (u *Users) modify(name string, fn func(doc *userDocument) error) error {
+	sc, err := u.collection()
+	if err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	doc, err := u.find(sc, name)
+	if err != nil {
+		return err
+	}
+
+	if fn == nil {
+		return sc.coll.DeleteByID(name).Err
+	}
+
+	if err := fn(doc); err != nil {
+		return err
+	}
+
+	filter := map[string]any{consts.DocumentFieldID: name}
+
+	return sc.coll.UpdateOne(filter, doc, options.Update().SetSet(true)).Err
+}
+
+// newSCRAMCredentials derives the SCRAM-SHA-256 credentials of a password with a random salt.
+func newSCRAMCredentials(name, password string) (scramCredentials, error) {
+	client, err := scram.SHA256.NewClient(name, password, "")
+	if err != nil {
+		return scramCredentials{}, fmt.Errorf("%w: %v", ErrInvalidUser, err)
+	}
+
+	salt := make([]byte, scramSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return scramCredentials{}, err
+	}
+
+	creds := client.GetStoredCredentials(scram.KeyFactors{Salt: string(salt), Iters: scramIterations})
+
+	return scramCredentials{
+		Salt:       salt,
+		Iterations: scramIterations,
+		StoredKey:  creds.StoredKey,
+		ServerKey:  creds.ServerKey,
+	}, nil
+}
+
+// hashToken hashes the secret of an API token.
+func hashToken(secret []byte) []byte {
+	sum := sha256.Sum256(secret)
+
+	return sum[:]
+}
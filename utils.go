@@ -1,9 +1,12 @@
 package gopherdb
 
 import (
+	"encoding/hex"
 	"fmt"
 	"net/url"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // encodeForLexOrder encodes a value for lexicographical order.
@@ -47,6 +50,8 @@ func encodeForLexOrder(val any, desc bool) string {
 		s = v.Format(time.RFC3339)
 	case []byte:
 		s = string(v)
+	case primitive.Binary:
+		s = hex.EncodeToString(v.Data)
 	case time.Duration:
 		s = fmt.Sprintf("%020d", v)
 	default:
@@ -0,0 +1,203 @@
+package gopherdb
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/jsonschema"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// validatorJSONSchema is the operator of a validator that holds its JSON schema.
+const validatorJSONSchema = "$jsonSchema"
+
+// CollectionValidation is the document validation of a collection, persisted with its metadata.
+type CollectionValidation struct {
+	// Validator is the validator document, {$jsonSchema: {...}}.
+	Validator map[string]any `json:"validator"`
+	// Level is options.ValidationLevelStrict or options.ValidationLevelModerate.
+	Level string `json:"level"`
+	// Action is options.ValidationActionError or options.ValidationActionWarn.
+	Action string `json:"action"`
+}
+
+// ValidationFailure is a value of a document that does not satisfy the validator.
+type ValidationFailure struct {
+	// Path is the dotted path of the value, with the positions of array items as fields. It is
+	// empty for the document itself.
+	Path string
+	// Reason describes the schema keyword the value fails.
+	Reason string
+}
+
+// DocumentValidationError is returned when a write is rejected by the validator of a collection.
+// It wraps ErrDocumentValidation.
+type DocumentValidationError struct {
+	Namespace Namespace
+	Failures  []ValidationFailure
+}
+
+// Error returns the failing paths of the document and their reasons.
+func (e *DocumentValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Failures))
+
+	for _, f := range e.Failures {
+		path := f.Path
+		if path == "" {
+			path = "(document)"
+		}
+
+		reasons = append(reasons, path+": "+f.Reason)
+	}
+
+	return fmt.Sprintf("%v in %s.%s: %s", ErrDocumentValidation, e.Namespace.Database, e.Namespace.Collection,
+		strings.Join(reasons, "; "))
+}
+
+// Unwrap returns ErrDocumentValidation.
+func (e *DocumentValidationError) Unwrap() error {
+	return ErrDocumentValidation
+}
+
+// newCollectionValidation merges the validation options into the current validation of a
+// collection, or returns nil when the validator is removed.
+func newCollectionValidation(current *CollectionValidation, opt *options.CollectionOptions) (*CollectionValidation, error) {
+	v := CollectionValidation{
+		Level:  options.ValidationLevelStrict,
+		Action: options.ValidationActionError,
+	}
+
+	if current != nil {
+		v = *current
+	}
+
+	if opt.Validator != nil {
+		v.Validator = maps.Clone(opt.Validator)
+	}
+
+	if opt.ValidationLevel != nil {
+		v.Level = *opt.ValidationLevel
+	}
+
+	if opt.ValidationAction != nil {
+		v.Action = *opt.ValidationAction
+	}
+
+	if len(v.Validator) == 0 {
+		if current == nil && (opt.ValidationLevel != nil || opt.ValidationAction != nil) {
+			return nil, fmt.Errorf("%w: validation level and action need a validator", ErrInvalidSchema)
+		}
+
+		return nil, nil
+	}
+
+	if v.Level != options.ValidationLevelStrict && v.Level != options.ValidationLevelModerate {
+		return nil, fmt.Errorf("%w: unknown validation level %q", ErrInvalidSchema, v.Level)
+	}
+
+	if v.Action != options.ValidationActionError && v.Action != options.ValidationActionWarn {
+		return nil, fmt.Errorf("%w: unknown validation action %q", ErrInvalidSchema, v.Action)
+	}
+
+	if _, err := v.schema(); err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// schema compiles the JSON schema of the validator.
+func (v *CollectionValidation) schema() (*jsonschema.Schema, error) {
+	for op := range v.Validator {
+		if op != validatorJSONSchema {
+			return nil, fmt.Errorf("%w: unsupported validator operator %s", ErrInvalidSchema, op)
+		}
+	}
+
+	doc, ok := bson.Normalize(v.Validator[validatorJSONSchema]).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be a document", ErrInvalidSchema, validatorJSONSchema)
+	}
+
+	return jsonschema.Compile(doc)
+}
+
+// SetValidation sets the validator, validation level and validation action of the collection. The
+// validation is persisted with the collection; documents written before are not validated. An
+// empty validator removes the validation.
+func (c *Collection) SetValidation(opts ...*options.CollectionOptions) error {
+	if err := c.db.checkWritable(); err != nil {
+		return err
+	}
+
+	return c.IndexManager.updateMetadata(func(meta *CollectionMetadata) error {
+		v, err := newCollectionValidation(meta.Validation, options.Collection().Merge(opts...))
+		if err != nil {
+			return err
+		}
+
+		meta.Validation = v
+
+		return nil
+	})
+}
+
+// Validation returns the document validation of the collection, or nil if it has none.
+func (c *Collection) Validation() (*CollectionValidation, error) {
+	meta, err := c.IndexManager.loadMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	return meta.Validation, nil
+}
+
+// validateDocument validates a document about to be written against the validator of the
+// collection. previous is the stored document an update replaces, or nil for inserts; with the
+// moderate level, updates of documents that already fail the validator are not validated. With
+// the warn action the failures are logged and the write goes on.
+func (c *Collection) validateDocument(doc, previous map[string]any) error {
+	v := c.IndexManager.current().Validation
+	if v == nil {
+		return nil
+	}
+
+	schema, err := v.schema()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := doc[consts.DocumentFieldID]; !ok && previous != nil {
+		doc = maps.Clone(doc)
+		doc[consts.DocumentFieldID] = previous[consts.DocumentFieldID]
+	}
+
+	failures := schema.Validate(doc)
+	if len(failures) == 0 {
+		return nil
+	}
+
+	if previous != nil && v.Level == options.ValidationLevelModerate && len(schema.Validate(previous)) > 0 {
+		return nil
+	}
+
+	verr := &DocumentValidationError{
+		Namespace: Namespace{Database: c.dbname, Collection: c.collname},
+		Failures:  make([]ValidationFailure, 0, len(failures)),
+	}
+
+	for _, f := range failures {
+		verr.Failures = append(verr.Failures, ValidationFailure{Path: f.Path, Reason: f.Reason})
+	}
+
+	if v.Action == options.ValidationActionWarn {
+		c.db.logger.Warningf("document %v: %v", doc[consts.DocumentFieldID], verr)
+
+		return nil
+	}
+
+	return verr
+}
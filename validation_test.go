@@ -0,0 +1,72 @@
+package gopherdb_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/wirvii/gopherdb"
+	"github.com/wirvii/gopherdb/options"
+)
+
+// personSchema requires a string ssn and a number n.
+var personSchema = map[string]any{
+	"$jsonSchema": map[string]any{
+		"bsonType": "object",
+		"required": []any{"ssn"},
+		"properties": map[string]any{
+			"ssn": map[string]any{"bsonType": "string"},
+			"n":   map[string]any{"bsonType": "int"},
+		},
+	},
+}
+
+func TestUpdateValidatesDecryptedDocument(t *testing.T) {
+	for _, algorithm := range []gopherdb.EncryptionAlgorithm{gopherdb.EncryptionDeterministic, gopherdb.EncryptionRandom} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			coll := newTestCollection(t, "people", options.Collection().SetValidator(personSchema))
+
+			schema := gopherdb.EncryptionSchema{
+				Fields: []gopherdb.EncryptedField{{Field: "ssn", KeyName: "k1", Algorithm: algorithm}},
+			}
+			provider := gopherdb.NewLocalKeyProvider(map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+
+			if err := coll.EnableFieldEncryption(schema, provider); err != nil {
+				t.Fatalf("enable encryption: %v", err)
+			}
+
+			if r := coll.InsertOne(map[string]any{"_id": 1, "ssn": "123-45-6789"}); r.Err != nil {
+				t.Fatalf("insert: %v", r.Err)
+			}
+
+			r := coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$set": map[string]any{"n": 1}})
+			if r.Err != nil {
+				t.Fatalf("$set of another field: %v", r.Err)
+			}
+
+			if r.ModifiedCount != 1 {
+				t.Fatalf("modified %d, want 1", r.ModifiedCount)
+			}
+
+			r = coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$set": map[string]any{"n": 1}})
+			if r.Err != nil || r.ModifiedCount != 0 {
+				t.Fatalf("$set of the same value: modified %d, err %v, want 0 and no error", r.ModifiedCount, r.Err)
+			}
+
+			r = coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"n": 2})
+			if r.Err != nil {
+				t.Fatalf("merge update of another field: %v", r.Err)
+			}
+
+			r = coll.UpdateOne(map[string]any{"_id": 1}, map[string]any{"$set": map[string]any{"ssn": 42}})
+			if !errors.Is(r.Err, gopherdb.ErrDocumentValidation) {
+				t.Fatalf("$set of an invalid encrypted field: got %v, want %v", r.Err, gopherdb.ErrDocumentValidation)
+			}
+
+			result := coll.FindByID(1)
+			if doc := result.Document(); doc["ssn"] != "123-45-6789" {
+				t.Fatalf("stored ssn %v, want the decrypted original", doc["ssn"])
+			}
+		})
+	}
+}
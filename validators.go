@@ -2,6 +2,9 @@ package gopherdb
 
 import (
 	"reflect"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // validateDocumentSliceType valida que el tipo de un slice de documentos sea válido.
@@ -36,14 +39,18 @@ func validateDocumentSliceType(docs any) (reflect.Value, error) {
 		elemType = elemType.Elem()
 	}
 
-	if elemType.Kind() != reflect.Map && elemType.Kind() != reflect.Struct {
-		return reflect.Value{}, ErrDocumentSliceElementTypeInvalid
+	if elemType.Kind() == reflect.Interface {
+		for i := range docsVal.Len() {
+			if _, err := validateDocumentType(docsVal.Index(i).Interface()); err != nil {
+				return reflect.Value{}, ErrDocumentSliceElementTypeInvalid
+			}
+		}
+
+		return docsVal, nil
 	}
 
-	if elemType.Kind() == reflect.Map {
-		if elemType.Key().Kind() != reflect.String || elemType.Elem().Kind() != reflect.Interface {
-			return reflect.Value{}, ErrDocumentSliceElementTypeInvalid
-		}
+	if !isDocumentType(elemType) {
+		return reflect.Value{}, ErrDocumentSliceElementTypeInvalid
 	}
 
 	return docsVal, nil
@@ -64,17 +71,22 @@ func validateDocumentType(doc any) (reflect.Value, error) {
 		docVal = docVal.Elem()
 	}
 
-	docKind := docVal.Kind()
-	if docKind != reflect.Map && docKind != reflect.Struct {
+	if !isDocumentType(docVal.Type()) {
 		return reflect.Value{}, ErrDocumentTypeInvalid
 	}
 
-	if docKind == reflect.Map {
-		mapType := docVal.Type()
-		if mapType.Key().Kind() != reflect.String || mapType.Elem().Kind() != reflect.Interface {
-			return reflect.Value{}, ErrDocumentTypeInvalid
-		}
-	}
-
 	return docVal, nil
 }
+
+// isDocumentType reports whether the values of a type are documents: structs, maps with string
+// keys and interface values, and ordered documents such as bson.D and bson.Raw.
+func isDocumentType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.Interface
+	default:
+		return t == reflect.TypeFor[bson.Raw]() || t.ConvertibleTo(reflect.TypeFor[primitive.D]())
+	}
+}
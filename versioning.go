@@ -0,0 +1,67 @@
+package gopherdb
+
+import (
+	"fmt"
+
+	"github.com/wirvii/gopherdb/internal/consts"
+)
+
+// SetVersioned sets whether the documents of the collection have a _version field, which starts
+// at 1 on insert and grows by one with each update that changes the document. Updates with the
+// Version option fail with ErrVersionConflict when the document has another version, so an
+// update based on a stale read does not overwrite the writes made since. Documents stored before
+// versioning was enabled have version 0 until they are updated.
+func (c *Collection) SetVersioned(versioned bool) error {
+	if err := c.db.checkWritable(); err != nil {
+		return err
+	}
+
+	return c.IndexManager.updateMetadata(func(meta *CollectionMetadata) error {
+		meta.Versioned = versioned
+
+		return nil
+	})
+}
+
+// Versioned returns whether the documents of the collection have versions.
+func (c *Collection) Versioned() (bool, error) {
+	meta, err := c.IndexManager.loadMetadata()
+	if err != nil {
+		return false, err
+	}
+
+	return meta.Versioned, nil
+}
+
+// Version returns the version of the document of a versioned collection, or 0.
+func (r *FindOneResult) Version() int64 {
+	return documentVersion(r.raw.Document())
+}
+
+// checkVersion fails with ErrVersionConflict when a document does not have the expected version.
+func checkVersion(doc map[string]any, expected *int64) error {
+	if expected == nil {
+		return nil
+	}
+
+	if version := documentVersion(doc); version != *expected {
+		return fmt.Errorf("%w: document %v has version %d, expected %d",
+			ErrVersionConflict, doc[consts.DocumentFieldID], version, *expected)
+	}
+
+	return nil
+}
+
+// documentVersion returns the version of a document, 0 when it has none.
+func documentVersion(doc map[string]any) int64 {
+	switch v := doc[consts.DocumentFieldVersion].(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	default:
+		return 0
+	}
+}
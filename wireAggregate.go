@@ -0,0 +1,400 @@
+package gopherdb
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/queryengine"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// wireAggregate runs an aggregation pipeline on a collection and opens a cursor on its results.
+// The documents are read with the leading $match stage, so it can use an index, and the rest of
+// the pipeline runs in memory.
+func wireAggregate(s *WireServer, req *wireRequest) (primitive.D, error) {
+	collname, err := req.collection()
+	if err != nil {
+		return nil, newWireError(wireCodeFailedToParse, "aggregate is only supported on collections")
+	}
+
+	stages, err := req.array("pipeline")
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := req.document("cursor")
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := int64(-1)
+	if v, ok := cursor["batchSize"]; ok {
+		batchSize, _ = wireInt(v)
+	}
+
+	ns, err := s.catalog.collection(req.db, collname)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := make([]primitive.D, 0, len(stages))
+
+	for _, v := range stages {
+		stage, ok := v.(primitive.D)
+		if !ok || len(stage) != 1 {
+			return nil, newWireError(wireCodeFailedToParse, "each pipeline stage must be a document with a single field")
+		}
+
+		pipeline = append(pipeline, stage)
+	}
+
+	filter := map[string]any{}
+
+	if len(pipeline) > 0 && pipeline[0][0].Key == "$match" {
+		filter, _ = bson.Normalize(pipeline[0][0].Value).(map[string]any)
+		if filter == nil {
+			return nil, newWireError(wireCodeTypeMismatch, "$match must be a document")
+		}
+
+		pipeline = pipeline[1:]
+	}
+
+	ns.mu.Lock()
+	result := ns.coll.Find(filter)
+	ns.mu.Unlock()
+
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	docs := make([]map[string]any, 0, len(result.raw))
+	for _, kv := range result.raw {
+		doc, _ := bson.Normalize(kv.Document()).(map[string]any)
+		docs = append(docs, doc)
+	}
+
+	for _, stage := range pipeline {
+		docs, err = runWireStage(docs, stage[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]bson.Raw, 0, len(docs))
+
+	for _, doc := range docs {
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, data)
+	}
+
+	return s.cursorReply(req.namespace(collname), out, batchSize, false), nil
+}
+
+// runWireStage runs a pipeline stage on the documents.
+func runWireStage(docs []map[string]any, stage primitive.E) ([]map[string]any, error) {
+	switch stage.Key {
+	case "$match":
+		filter, ok := bson.Normalize(stage.Value).(map[string]any)
+		if !ok {
+			return nil, newWireError(wireCodeTypeMismatch, "$match must be a document")
+		}
+
+		expr, err := queryengine.ParseFilter(filter)
+		if err != nil {
+			return nil, newWireError(wireCodeBadValue, err.Error())
+		}
+
+		return slices.DeleteFunc(docs, func(doc map[string]any) bool { return !expr.Evaluate(doc) }), nil
+	case "$sort":
+		spec, ok := stage.Value.(primitive.D)
+		if !ok {
+			return nil, newWireError(wireCodeTypeMismatch, "$sort must be a document")
+		}
+
+		sort, err := parseSortSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		sortWireDocuments(docs, sort)
+
+		return docs, nil
+	case "$skip":
+		n, ok := wireInt(stage.Value)
+		if !ok || n < 0 {
+			return nil, newWireError(wireCodeBadValue, "$skip must be a non-negative number")
+		}
+
+		return docs[min(int(n), len(docs)):], nil
+	case "$limit":
+		n, ok := wireInt(stage.Value)
+		if !ok || n <= 0 {
+			return nil, newWireError(wireCodeBadValue, "$limit must be a positive number")
+		}
+
+		return docs[:min(int(n), len(docs))], nil
+	case "$project":
+		spec, ok := bson.Normalize(stage.Value).(map[string]any)
+		if !ok {
+			return nil, newWireError(wireCodeTypeMismatch, "$project must be a document")
+		}
+
+		projection, err := parseProjection(spec, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, doc := range docs {
+			docs[i] = projection.apply(doc)
+		}
+
+		return docs, nil
+	case "$count":
+		field, ok := stage.Value.(string)
+		if !ok || field == "" || strings.HasPrefix(field, "$") {
+			return nil, newWireError(wireCodeBadValue, "$count must be a non-empty field name")
+		}
+
+		if len(docs) == 0 {
+			return docs, nil
+		}
+
+		return []map[string]any{{field: int32(len(docs))}}, nil
+	case "$sample":
+		spec, _ := bson.Normalize(stage.Value).(map[string]any)
+
+		size, ok := wireInt(spec["size"])
+		if !ok || size < 0 {
+			return nil, newWireError(wireCodeBadValue, "$sample size must be a non-negative number")
+		}
+
+		rand.Shuffle(len(docs), func(i, j int) { docs[i], docs[j] = docs[j], docs[i] })
+
+		return docs[:min(int(size), len(docs))], nil
+	case "$group":
+		spec, ok := bson.Normalize(stage.Value).(map[string]any)
+		if !ok {
+			return nil, newWireError(wireCodeTypeMismatch, "$group must be a document")
+		}
+
+		return groupWireDocuments(docs, spec)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedPipelineStage, stage.Key)
+	}
+}
+
+// sortWireDocuments sorts documents following the BSON comparison order.
+func sortWireDocuments(docs []map[string]any, sort []options.SortField) {
+	slices.SortStableFunc(docs, func(a, b map[string]any) int {
+		for _, f := range sort {
+			va, _ := lookupPath(a, f.Field)
+			vb, _ := lookupPath(b, f.Field)
+
+			if c := queryengine.Compare(va, vb); c != 0 {
+				if f.Order < 0 {
+					return -c
+				}
+
+				return c
+			}
+		}
+
+		return 0
+	})
+}
+
+// wireGroup is a group of documents of a $group stage.
+type wireGroup struct {
+	id     any
+	values map[string]*wireAccumulator
+}
+
+// wireAccumulator accumulates the values of a group field.
+type wireAccumulator struct {
+	op     string
+	expr   any
+	sum    float64
+	ints   bool
+	count  int64
+	value  any
+	set    bool
+	values []any
+}
+
+// groupWireDocuments runs a $group stage.
+func groupWireDocuments(docs []map[string]any, spec map[string]any) ([]map[string]any, error) {
+	idExpr, ok := spec[consts.DocumentFieldID]
+	if !ok {
+		return nil, newWireError(wireCodeFailedToParse, "a group specification must include an _id")
+	}
+
+	accumulators := make(map[string][2]any, len(spec)-1)
+
+	for field, v := range spec {
+		if field == consts.DocumentFieldID {
+			continue
+		}
+
+		acc, ok := v.(map[string]any)
+		if !ok || len(acc) != 1 {
+			return nil, newWireError(wireCodeFailedToParse, fmt.Sprintf("the field '%s' must be an accumulator object", field))
+		}
+
+		for op, expr := range acc {
+			switch op {
+			case "$sum", "$avg", "$min", "$max", "$first", "$last", "$push", "$addToSet", "$count":
+				accumulators[field] = [2]any{op, expr}
+			default:
+				return nil, newWireError(wireCodeFailedToParse, fmt.Sprintf("unknown group operator '%s'", op))
+			}
+		}
+	}
+
+	groups := make([]*wireGroup, 0)
+	byKey := make(map[string]*wireGroup)
+
+	for _, doc := range docs {
+		id := evalExpr(doc, idExpr)
+		key := fmt.Sprintf("%T:%v", id, id)
+
+		g, ok := byKey[key]
+		if !ok {
+			g = &wireGroup{id: id, values: make(map[string]*wireAccumulator, len(accumulators))}
+
+			for field, acc := range accumulators {
+				g.values[field] = &wireAccumulator{op: acc[0].(string), expr: acc[1], ints: true}
+			}
+
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+
+		for _, acc := range g.values {
+			acc.add(doc)
+		}
+	}
+
+	out := make([]map[string]any, 0, len(groups))
+
+	for _, g := range groups {
+		doc := map[string]any{consts.DocumentFieldID: g.id}
+		for field, acc := range g.values {
+			doc[field] = acc.result()
+		}
+
+		out = append(out, doc)
+	}
+
+	return out, nil
+}
+
+// add adds the value of the accumulator expression for a document.
+func (a *wireAccumulator) add(doc map[string]any) {
+	if a.op == "$count" {
+		a.count++
+
+		return
+	}
+
+	v := evalExpr(doc, a.expr)
+
+	switch a.op {
+	case "$sum", "$avg":
+		if f, ok := wireNumber(v); ok {
+			if _, isInt := wireInt(v); !isInt || isFloat(v) {
+				a.ints = false
+			}
+
+			a.sum += f
+			a.count++
+		}
+	case "$min", "$max":
+		if v == nil {
+			return
+		}
+
+		c := queryengine.Compare(v, a.value)
+		if !a.set || (a.op == "$min" && c < 0) || (a.op == "$max" && c > 0) {
+			a.value = v
+			a.set = true
+		}
+	case "$first":
+		if !a.set {
+			a.value = v
+			a.set = true
+		}
+	case "$last":
+		a.value = v
+	case "$push":
+		a.values = append(a.values, v)
+	case "$addToSet":
+		if !slices.ContainsFunc(a.values, func(item any) bool { return queryengine.Compare(item, v) == 0 }) {
+			a.values = append(a.values, v)
+		}
+	}
+}
+
+// result returns the accumulated value.
+func (a *wireAccumulator) result() any {
+	switch a.op {
+	case "$count":
+		return wireInteger(a.count)
+	case "$sum":
+		if a.ints && a.sum == math.Trunc(a.sum) {
+			return wireInteger(int64(a.sum))
+		}
+
+		return a.sum
+	case "$avg":
+		if a.count == 0 {
+			return nil
+		}
+
+		return a.sum / float64(a.count)
+	case "$push", "$addToSet":
+		if a.values == nil {
+			return []any{}
+		}
+
+		return a.values
+	default:
+		return a.value
+	}
+}
+
+// wireNumber converts a numeric BSON value to a float64.
+func wireNumber(v any) (float64, bool) {
+	if f, ok := v.(float64); ok {
+		return f, true
+	}
+
+	n, ok := wireInt(v)
+
+	return float64(n), ok
+}
+
+// isFloat reports whether a value is a double.
+func isFloat(v any) bool {
+	_, ok := v.(float64)
+
+	return ok
+}
+
+// wireInteger returns n as an int32 when it fits, as MongoDB does for counts and integer sums.
+func wireInteger(n int64) any {
+	if n >= math.MinInt32 && n <= math.MaxInt32 {
+		return int32(n)
+	}
+
+	return n
+}
@@ -0,0 +1,103 @@
+package gopherdb
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// wireMechanism is the only SASL mechanism of the wire server.
+	wireMechanism = "SCRAM-SHA-256"
+	// wireConversationID is the ID of the SASL conversation of a connection; there is one at a time.
+	wireConversationID = int32(1)
+)
+
+// wireSaslStart starts a SCRAM-SHA-256 conversation with the first message of the client.
+func wireSaslStart(s *WireServer, req *wireRequest) (primitive.D, error) {
+	if s.users == nil {
+		return nil, newWireError(wireCodeAuthenticationFailed, "authentication is not enabled on this server")
+	}
+
+	mechanism, _ := req.lookup("mechanism")
+	if mechanism != wireMechanism {
+		return nil, newWireError(wireCodeMechanismUnavailable, fmt.Sprintf("unsupported mechanism %v, use %s", mechanism, wireMechanism))
+	}
+
+	server, err := s.users.scramServer()
+	if err != nil {
+		return nil, err
+	}
+
+	req.conn.user = nil
+	req.conn.conv = server.NewConversation()
+
+	return wireSaslStep(s, req)
+}
+
+// wireSaslContinue continues the SCRAM-SHA-256 conversation of the connection. The user is
+// authenticated when the conversation ends with a valid proof.
+func wireSaslContinue(s *WireServer, req *wireRequest) (primitive.D, error) {
+	if req.conn.conv == nil {
+		return nil, newWireError(wireCodeAuthenticationFailed, "no SASL conversation in progress")
+	}
+
+	if id, _ := req.integer("conversationId", 0); int32(id) != wireConversationID {
+		return nil, newWireError(wireCodeAuthenticationFailed, "unknown SASL conversation")
+	}
+
+	return wireSaslStep(s, req)
+}
+
+// wireSaslStep answers a message of the client in the SASL conversation of the connection.
+func wireSaslStep(s *WireServer, req *wireRequest) (primitive.D, error) {
+	conv := req.conn.conv
+
+	// Drivers that do not skip the empty exchange send one more message after the server proof.
+	if conv.Done() {
+		return wireSaslReply(true, nil), nil
+	}
+
+	payload, _ := req.lookup("payload")
+	data, ok := payload.(primitive.Binary)
+	if !ok {
+		return nil, newWireError(wireCodeTypeMismatch, "payload must be binary data")
+	}
+
+	response, err := conv.Step(string(data.Data))
+	if err != nil {
+		req.conn.conv = nil
+
+		return nil, fmt.Errorf("%w: %v", ErrAuthenticationFailed, err)
+	}
+
+	if conv.Done() && conv.Valid() {
+		user, err := s.users.Get(conv.Username())
+		if err != nil {
+			req.conn.conv = nil
+
+			return nil, ErrAuthenticationFailed
+		}
+
+		req.conn.user = user
+	}
+
+	return wireSaslReply(conv.Done(), []byte(response)), nil
+}
+
+// wireSaslReply builds the reply of a SASL step.
+func wireSaslReply(done bool, payload []byte) primitive.D {
+	return primitive.D{
+		{Key: "conversationId", Value: wireConversationID},
+		{Key: "done", Value: done},
+		{Key: "payload", Value: primitive.Binary{Data: payload}},
+	}
+}
+
+// wireLogout forgets the user authenticated on the connection.
+func wireLogout(_ *WireServer, req *wireRequest) (primitive.D, error) {
+	req.conn.user = nil
+	req.conn.conv = nil
+
+	return primitive.D{}, nil
+}
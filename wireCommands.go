@@ -0,0 +1,1460 @@
+package gopherdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/consts"
+	"github.com/wirvii/gopherdb/internal/queryengine"
+	"github.com/wirvii/gopherdb/internal/storage"
+	"github.com/wirvii/gopherdb/internal/update"
+	"github.com/wirvii/gopherdb/internal/wire"
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// wireMaxWireVersion is the wire version of MongoDB 6.0, the release the server emulates.
+	wireMaxWireVersion = 17
+	// wireServerVersion is the MongoDB version reported by buildInfo.
+	wireServerVersion = "6.0.0"
+	// wireSessionTimeoutMinutes is the logical session timeout reported to drivers.
+	wireSessionTimeoutMinutes = 30
+)
+
+// Error codes of the MongoDB server returned by the wire server.
+const (
+	wireCodeInternalError        = 1
+	wireCodeBadValue             = 2
+	wireCodeFailedToParse        = 9
+	wireCodeUnauthorized         = 13
+	wireCodeTypeMismatch         = 14
+	wireCodeIllegalOperation     = 20
+	wireCodeAuthenticationFailed = 18
+	wireCodeNamespaceNotFound    = 26
+	wireCodeCursorNotFound       = 43
+	wireCodeNamespaceExists      = 48
+	wireCodeMaxTimeMSExpired     = 50
+	wireCodeCommandNotFound      = 59
+	wireCodeImmutableField       = 66
+	wireCodeCannotCreateIndex    = 67
+	wireCodeInvalidNamespace     = 73
+	wireCodeIndexOptionsConflict = 85
+	wireCodeWriteConflict        = 112
+	wireCodeDocumentValidation   = 121
+	wireCodeMechanismUnavailable = 334
+	wireCodeUnknownPipelineStage = 40324
+	wireCodeNotWritablePrimary   = 10107
+	wireCodeDuplicateKey         = 11000
+)
+
+// wireCodeNames are the names of the error codes.
+var wireCodeNames = map[int32]string{
+	wireCodeInternalError:        "InternalError",
+	wireCodeBadValue:             "BadValue",
+	wireCodeFailedToParse:        "FailedToParse",
+	wireCodeUnauthorized:         "Unauthorized",
+	wireCodeTypeMismatch:         "TypeMismatch",
+	wireCodeIllegalOperation:     "IllegalOperation",
+	wireCodeAuthenticationFailed: "AuthenticationFailed",
+	wireCodeNamespaceNotFound:    "NamespaceNotFound",
+	wireCodeCursorNotFound:       "CursorNotFound",
+	wireCodeNamespaceExists:      "NamespaceExists",
+	wireCodeMaxTimeMSExpired:     "MaxTimeMSExpired",
+	wireCodeCommandNotFound:      "CommandNotFound",
+	wireCodeImmutableField:       "ImmutableField",
+	wireCodeCannotCreateIndex:    "CannotCreateIndex",
+	wireCodeInvalidNamespace:     "InvalidNamespace",
+	wireCodeIndexOptionsConflict: "IndexOptionsConflict",
+	wireCodeWriteConflict:        "WriteConflict",
+	wireCodeDocumentValidation:   "DocumentValidationFailure",
+	wireCodeMechanismUnavailable: "MechanismUnavailable",
+	wireCodeUnknownPipelineStage: "Location40324",
+	wireCodeNotWritablePrimary:   "NotWritablePrimary",
+	wireCodeDuplicateKey:         "DuplicateKey",
+}
+
+// wireError is a command error with a MongoDB error code.
+type wireError struct {
+	code    int32
+	message string
+}
+
+// newWireError creates a command error.
+func newWireError(code int32, message string) *wireError {
+	return &wireError{code: code, message: message}
+}
+
+// Error returns the error message.
+func (e *wireError) Error() string {
+	return e.message
+}
+
+// toWireError maps an error to a command error.
+func toWireError(err error) *wireError {
+	var we *wireError
+	if errors.As(err, &we) {
+		return we
+	}
+
+	code := int32(wireCodeInternalError)
+
+	switch {
+	case errors.Is(err, ErrNotPrimary):
+		code = wireCodeNotWritablePrimary
+	case errors.Is(err, ErrAuthenticationFailed):
+		code = wireCodeAuthenticationFailed
+	case errors.Is(err, ErrAuthenticationRequired), errors.Is(err, ErrUnauthorized):
+		code = wireCodeUnauthorized
+	case errors.Is(err, ErrUniqueIndexViolation):
+		code = wireCodeDuplicateKey
+	case errors.Is(err, ErrDocumentValidation):
+		code = wireCodeDocumentValidation
+	case errors.Is(err, ErrDocumentIDNoEditable):
+		code = wireCodeImmutableField
+	case errors.Is(err, ErrVersionConflict):
+		code = wireCodeWriteConflict
+	case errors.Is(err, ErrMaxTimeExceeded):
+		code = wireCodeMaxTimeMSExpired
+	case errors.Is(err, ErrInvalidUpdate):
+		code = wireCodeFailedToParse
+	case errors.Is(err, ErrUnsupportedPipelineStage):
+		code = wireCodeUnknownPipelineStage
+	case errors.Is(err, ErrIndexAlreadyExists):
+		code = wireCodeIndexOptionsConflict
+	case errors.Is(err, ErrInvalidNamespace):
+		code = wireCodeInvalidNamespace
+	case errors.Is(err, ErrCollectionNotFound):
+		code = wireCodeNamespaceNotFound
+	case errors.Is(err, ErrCollectionExists):
+		code = wireCodeNamespaceExists
+	case errors.Is(err, ErrEmptyIndexFields), errors.Is(err, ErrDuplicateIndexField), errors.Is(err, ErrInvalidIndexSpec):
+		code = wireCodeCannotCreateIndex
+	case errors.Is(err, ErrMissingFieldForIndex), errors.Is(err, ErrFieldNotQueryable), errors.Is(err, ErrInvalidQuery),
+		errors.Is(err, ErrInvalidSchema), errors.Is(err, ErrInvalidIDStrategy), errors.Is(err, ErrDocumentIDNotFound),
+		errors.Is(err, wire.ErrMalformedMessage):
+		code = wireCodeBadValue
+	}
+
+	return newWireError(code, err.Error())
+}
+
+// wireErrorReply builds the reply of a failed command.
+func wireErrorReply(err error) primitive.D {
+	we := toWireError(err)
+
+	return primitive.D{
+		{Key: "ok", Value: 0.0},
+		{Key: "errmsg", Value: we.message},
+		{Key: "code", Value: we.code},
+		{Key: "codeName", Value: wireCodeNames[we.code]},
+	}
+}
+
+// wireWriteError builds an entry of the writeErrors of a write command reply.
+func wireWriteError(index int, err error) primitive.D {
+	we := toWireError(err)
+
+	return primitive.D{
+		{Key: "index", Value: int32(index)},
+		{Key: "code", Value: we.code},
+		{Key: "errmsg", Value: we.message},
+	}
+}
+
+// wireRequest is a command received by the wire server.
+type wireRequest struct {
+	name string
+	db   string
+	cmd  primitive.D
+	conn *wireConn
+}
+
+// wireCommand runs a command and returns the fields of its reply.
+type wireCommand func(s *WireServer, req *wireRequest) (primitive.D, error)
+
+// wireCommands are the commands of the wire server by lowercase name.
+var wireCommands = map[string]wireCommand{
+	"hello":            wireHello,
+	"ismaster":         wireHello,
+	"ping":             wirePing,
+	"buildinfo":        wireBuildInfo,
+	"connectionstatus": wireConnectionStatus,
+	"endsessions":      wirePing,
+	"listdatabases":    wireListDatabases,
+	"find":             wireFind,
+	"getmore":          wireGetMore,
+	"killcursors":      wireKillCursors,
+	"insert":           wireInsert,
+	"update":           wireUpdate,
+	"delete":           wireDelete,
+	"findandmodify":    wireFindAndModify,
+	"aggregate":        wireAggregate,
+	"count":            wireCount,
+	"create":           wireCreate,
+	"collmod":          wireCollMod,
+	"createindexes":    wireCreateIndexes,
+	"listindexes":      wireListIndexes,
+	"listcollections":  wireListCollections,
+	"drop":             wireDrop,
+	"collstats":        wireCollStats,
+	"dbstats":          wireDBStats,
+	"dropdatabase":     wireDropDatabase,
+	"renamecollection": wireRenameCollection,
+	"saslstart":        wireSaslStart,
+	"saslcontinue":     wireSaslContinue,
+	"logout":           wireLogout,
+}
+
+// wirePublicCommands are the commands a client may run before it authenticates.
+var wirePublicCommands = map[string]bool{
+	"hello":            true,
+	"ismaster":         true,
+	"ping":             true,
+	"buildinfo":        true,
+	"connectionstatus": true,
+	"endsessions":      true,
+	"saslstart":        true,
+	"saslcontinue":     true,
+	"logout":           true,
+}
+
+// wireCommandActions are the actions of the commands that work on a database or a collection. The
+// other commands only need an authenticated user.
+var wireCommandActions = map[string]action{
+	"find":             actionRead,
+	"getmore":          actionRead,
+	"killcursors":      actionRead,
+	"aggregate":        actionRead,
+	"count":            actionRead,
+	"insert":           actionWrite,
+	"update":           actionWrite,
+	"delete":           actionWrite,
+	"findandmodify":    actionWrite,
+	"listindexes":      actionInspect,
+	"listcollections":  actionInspect,
+	"collstats":        actionInspect,
+	"dbstats":          actionInspect,
+	"create":           actionManage,
+	"collmod":          actionManage,
+	"createindexes":    actionManage,
+	"drop":             actionManage,
+	"dropdatabase":     actionManage,
+	"renamecollection": actionManage,
+}
+
+// lookup returns the value of a field of the command.
+func (r *wireRequest) lookup(key string) (any, bool) {
+	for _, e := range r.cmd {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// collection returns the collection named by the command.
+func (r *wireRequest) collection() (string, error) {
+	name, ok := r.cmd[0].Value.(string)
+	if !ok {
+		return "", newWireError(wireCodeInvalidNamespace, fmt.Sprintf("collection name must be a string in %s", r.name))
+	}
+
+	return name, nil
+}
+
+// namespace returns the full name of a collection of the command database.
+func (r *wireRequest) namespace(collname string) string {
+	return r.db + "." + collname
+}
+
+// document returns a document field of the command as a map. A missing field is an empty map.
+func (r *wireRequest) document(key string) (map[string]any, error) {
+	v, ok := r.lookup(key)
+	if !ok || v == nil {
+		return map[string]any{}, nil
+	}
+
+	doc, ok := bson.Normalize(v).(map[string]any)
+	if !ok {
+		return nil, newWireError(wireCodeTypeMismatch, fmt.Sprintf("%s must be a document", key))
+	}
+
+	return doc, nil
+}
+
+// ordered returns a document field of the command keeping the order of its fields.
+func (r *wireRequest) ordered(key string) (primitive.D, error) {
+	v, ok := r.lookup(key)
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	doc, ok := v.(primitive.D)
+	if !ok {
+		return nil, newWireError(wireCodeTypeMismatch, fmt.Sprintf("%s must be a document", key))
+	}
+
+	return doc, nil
+}
+
+// array returns an array field of the command.
+func (r *wireRequest) array(key string) ([]any, error) {
+	v, ok := r.lookup(key)
+	if !ok {
+		return nil, newWireError(wireCodeFailedToParse, fmt.Sprintf("%s is required", key))
+	}
+
+	list, ok := v.(primitive.A)
+	if !ok {
+		return nil, newWireError(wireCodeTypeMismatch, fmt.Sprintf("%s must be an array", key))
+	}
+
+	return list, nil
+}
+
+// integer returns a numeric field of the command, or def when it is missing.
+func (r *wireRequest) integer(key string, def int64) (int64, error) {
+	v, ok := r.lookup(key)
+	if !ok {
+		return def, nil
+	}
+
+	n, ok := wireInt(v)
+	if !ok {
+		return 0, newWireError(wireCodeTypeMismatch, fmt.Sprintf("%s must be a number", key))
+	}
+
+	return n, nil
+}
+
+// boolean returns a boolean field of the command, or def when it is missing.
+func (r *wireRequest) boolean(key string, def bool) bool {
+	v, ok := r.lookup(key)
+	if !ok {
+		return def
+	}
+
+	return wireTruthy(v)
+}
+
+// collectionOptions returns the validator, validationLevel and validationAction fields of the
+// command.
+func (r *wireRequest) collectionOptions() (*options.CollectionOptions, error) {
+	opt := options.Collection()
+
+	if _, ok := r.lookup("validator"); ok {
+		validator, err := r.document("validator")
+		if err != nil {
+			return nil, err
+		}
+
+		opt.SetValidator(validator)
+	}
+
+	for _, key := range []string{"validationLevel", "validationAction"} {
+		v, ok := r.lookup(key)
+		if !ok {
+			continue
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return nil, newWireError(wireCodeTypeMismatch, fmt.Sprintf("%s must be a string", key))
+		}
+
+		if key == "validationLevel" {
+			opt.SetValidationLevel(s)
+		} else {
+			opt.SetValidationAction(s)
+		}
+	}
+
+	return opt, nil
+}
+
+// wireHello answers the handshake of drivers, with the limits of the server.
+func wireHello(s *WireServer, req *wireRequest) (primitive.D, error) {
+	primary := "isWritablePrimary"
+	if strings.EqualFold(req.name, "isMaster") {
+		primary = "ismaster"
+	}
+
+	reply := primitive.D{
+		{Key: primary, Value: true},
+		{Key: "helloOk", Value: true},
+		{Key: "maxBsonObjectSize", Value: int32(wire.MaxDocumentSize)},
+		{Key: "maxMessageSizeBytes", Value: int32(wire.MaxMessageSize)},
+		{Key: "maxWriteBatchSize", Value: int32(wire.MaxWriteBatchSize)},
+		{Key: "localTime", Value: primitive.NewDateTimeFromTime(time.Now())},
+		{Key: "logicalSessionTimeoutMinutes", Value: int32(wireSessionTimeoutMinutes)},
+		{Key: "connectionId", Value: req.conn.id},
+		{Key: "minWireVersion", Value: int32(0)},
+		{Key: "maxWireVersion", Value: int32(wireMaxWireVersion)},
+		{Key: "readOnly", Value: false},
+	}
+
+	if _, ok := req.lookup("saslSupportedMechs"); ok && s.users != nil {
+		reply = append(reply, primitive.E{Key: "saslSupportedMechs", Value: primitive.A{wireMechanism}})
+	}
+
+	return reply, nil
+}
+
+// wirePing answers commands that only need an acknowledgement.
+func wirePing(*WireServer, *wireRequest) (primitive.D, error) {
+	return primitive.D{}, nil
+}
+
+// wireBuildInfo reports the emulated server version.
+func wireBuildInfo(*WireServer, *wireRequest) (primitive.D, error) {
+	return primitive.D{
+		{Key: "version", Value: wireServerVersion},
+		{Key: "gitVersion", Value: "gopherdb"},
+		{Key: "versionArray", Value: primitive.A{int32(6), int32(0), int32(0), int32(0)}},
+		{Key: "bits", Value: int32(64)},
+		{Key: "debug", Value: false},
+		{Key: "maxBsonObjectSize", Value: int32(wire.MaxDocumentSize)},
+		{Key: "storageEngines", Value: Engines()},
+	}, nil
+}
+
+// wireConnectionStatus reports the user authenticated on the connection.
+func wireConnectionStatus(_ *WireServer, req *wireRequest) (primitive.D, error) {
+	users := primitive.A{}
+	roles := primitive.A{}
+
+	if u := req.conn.user; u != nil {
+		users = append(users, primitive.D{{Key: "user", Value: u.Name}, {Key: "db", Value: AdminDatabase}})
+
+		for _, g := range u.Roles {
+			db := g.Database
+			if g.Role == RoleRoot {
+				db = AdminDatabase
+			}
+
+			roles = append(roles, primitive.D{{Key: "role", Value: string(g.Role)}, {Key: "db", Value: db}})
+		}
+	}
+
+	return primitive.D{
+		{Key: "authInfo", Value: primitive.D{
+			{Key: "authenticatedUsers", Value: users},
+			{Key: "authenticatedUserRoles", Value: roles},
+		}},
+	}, nil
+}
+
+// wireListDatabases lists the databases that have collections.
+func wireListDatabases(s *WireServer, req *wireRequest) (primitive.D, error) {
+	names, err := s.catalog.databaseNames()
+	if err != nil {
+		return nil, err
+	}
+
+	nameOnly := req.boolean("nameOnly", false)
+	dbs := make(primitive.A, 0, len(names))
+
+	for _, name := range names {
+		if s.users != nil && !req.conn.user.canSee(name) {
+			continue
+		}
+
+		if nameOnly {
+			dbs = append(dbs, primitive.D{{Key: "name", Value: name}})
+
+			continue
+		}
+
+		dbs = append(dbs, primitive.D{
+			{Key: "name", Value: name},
+			{Key: "sizeOnDisk", Value: int64(0)},
+			{Key: "empty", Value: false},
+		})
+	}
+
+	return primitive.D{
+		{Key: "databases", Value: dbs},
+		{Key: "totalSize", Value: int64(0)},
+	}, nil
+}
+
+// wireFind runs a query and opens a cursor on its results.
+func wireFind(s *WireServer, req *wireRequest) (primitive.D, error) {
+	collname, err := req.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := req.document("filter")
+	if err != nil {
+		return nil, err
+	}
+
+	sortSpec, err := req.ordered("sort")
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := req.document("projection")
+	if err != nil {
+		return nil, err
+	}
+
+	projection, err := parseProjection(spec, false)
+	if err != nil {
+		return nil, err
+	}
+
+	opt := options.Find()
+
+	opt.Sort, err = parseSortSpec(sortSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	skip, err := req.integer("skip", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := req.integer("limit", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize, err := req.integer("batchSize", -1)
+	if err != nil {
+		return nil, err
+	}
+
+	maxTimeMS, err := req.integer("maxTimeMS", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	singleBatch := req.boolean("singleBatch", false)
+
+	if limit < 0 {
+		limit = -limit
+		singleBatch = true
+	}
+
+	if skip > 0 {
+		opt.SetSkip(skip)
+	}
+
+	if maxTimeMS > 0 {
+		opt.SetMaxTime(time.Duration(maxTimeMS) * time.Millisecond)
+	}
+
+	if limit > 0 {
+		opt.SetLimit(limit)
+
+		if batchSize < 0 || batchSize > limit {
+			batchSize = limit
+		}
+	}
+
+	ns, err := s.catalog.collection(req.db, collname)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	result := ns.coll.FindContext(req.conn.ctx, filter, opt)
+	ns.mu.Unlock()
+
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	docs, err := projection.applyRaw(result.raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.cursorReply(req.namespace(collname), docs, batchSize, singleBatch), nil
+}
+
+// wireGetMore returns the next batch of a cursor.
+func wireGetMore(s *WireServer, req *wireRequest) (primitive.D, error) {
+	id, ok := wireInt(req.cmd[0].Value)
+	if !ok {
+		return nil, newWireError(wireCodeTypeMismatch, "getMore cursor id must be a number")
+	}
+
+	v, _ := req.lookup("collection")
+
+	collname, ok := v.(string)
+	if !ok {
+		return nil, newWireError(wireCodeTypeMismatch, "getMore collection must be a string")
+	}
+
+	batchSize, err := req.integer("batchSize", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := req.namespace(collname)
+
+	batch, next, err := s.nextBatch(id, ns, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return primitive.D{
+		{Key: "cursor", Value: primitive.D{
+			{Key: "nextBatch", Value: batch},
+			{Key: "id", Value: next},
+			{Key: "ns", Value: ns},
+		}},
+	}, nil
+}
+
+// wireKillCursors closes cursors of the command collection before they are exhausted. Cursors of
+// other collections are reported as not found.
+func wireKillCursors(s *WireServer, req *wireRequest) (primitive.D, error) {
+	collname, err := req.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := req.array("cursors")
+	if err != nil {
+		return nil, err
+	}
+
+	ns := req.namespace(collname)
+
+	killed := primitive.A{}
+	notFound := primitive.A{}
+
+	for _, v := range ids {
+		id, ok := wireInt(v)
+		if !ok {
+			return nil, newWireError(wireCodeTypeMismatch, "cursor ids must be numbers")
+		}
+
+		if s.killCursor(id, ns) {
+			killed = append(killed, id)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+
+	return primitive.D{
+		{Key: "cursorsKilled", Value: killed},
+		{Key: "cursorsNotFound", Value: notFound},
+		{Key: "cursorsAlive", Value: primitive.A{}},
+		{Key: "cursorsUnknown", Value: primitive.A{}},
+	}, nil
+}
+
+// writeNamespace returns the collection of a write command, failing on followers.
+func (s *WireServer) writeNamespace(req *wireRequest) (*servedCollection, error) {
+	collname, err := req.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := s.catalog.collection(req.db, collname)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ns.coll.db.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	return ns, nil
+}
+
+// wireInsert inserts documents. Each document is inserted in its own transaction; ordered inserts
+// stop at the first error.
+func wireInsert(s *WireServer, req *wireRequest) (primitive.D, error) {
+	ns, err := s.writeNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := req.array("documents")
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := req.boolean("ordered", true)
+	writeErrors := primitive.A{}
+	n := 0
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	for i, v := range docs {
+		doc, ok := bson.Normalize(v).(map[string]any)
+		if !ok {
+			err = newWireError(wireCodeTypeMismatch, "documents must be documents")
+		} else {
+			err = ns.as(req.conn.user).InsertOne(doc).Err
+		}
+
+		if err != nil {
+			writeErrors = append(writeErrors, wireWriteError(i, err))
+
+			if ordered {
+				break
+			}
+
+			continue
+		}
+
+		n++
+	}
+
+	reply := primitive.D{{Key: "n", Value: int32(n)}}
+	if len(writeErrors) > 0 {
+		reply = append(reply, primitive.E{Key: "writeErrors", Value: writeErrors})
+	}
+
+	return reply, nil
+}
+
+// wireUpdate runs update statements.
+func wireUpdate(s *WireServer, req *wireRequest) (primitive.D, error) {
+	ns, err := s.writeNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := req.array("updates")
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := req.boolean("ordered", true)
+	writeErrors := primitive.A{}
+	upserted := primitive.A{}
+	matched := 0
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	for i, v := range statements {
+		n, id, err := updateStatement(ns.as(req.conn.user), v)
+		matched += n
+
+		if err != nil {
+			writeErrors = append(writeErrors, wireWriteError(i, err))
+
+			if ordered {
+				break
+			}
+
+			continue
+		}
+
+		if id != nil {
+			upserted = append(upserted, primitive.D{
+				{Key: "index", Value: int32(i)},
+				{Key: consts.DocumentFieldID, Value: id},
+			})
+		}
+	}
+
+	reply := primitive.D{
+		{Key: "n", Value: int32(matched + len(upserted))},
+		{Key: "nModified", Value: int32(matched)},
+	}
+
+	if len(upserted) > 0 {
+		reply = append(reply, primitive.E{Key: "upserted", Value: upserted})
+	}
+
+	if len(writeErrors) > 0 {
+		reply = append(reply, primitive.E{Key: "writeErrors", Value: writeErrors})
+	}
+
+	return reply, nil
+}
+
+// updateStatement runs an update statement. It returns the number of updated documents and the
+// ID of the upserted document, if any.
+func updateStatement(coll *Collection, v any) (int, any, error) {
+	spec, ok := bson.Normalize(v).(map[string]any)
+	if !ok {
+		return 0, nil, newWireError(wireCodeTypeMismatch, "update statements must be documents")
+	}
+
+	filter, _ := spec["q"].(map[string]any)
+	if filter == nil {
+		filter = map[string]any{}
+	}
+
+	doc, ok := spec["u"].(map[string]any)
+	if !ok {
+		return 0, nil, newWireError(wireCodeFailedToParse, "update must be a document, pipeline updates are not supported")
+	}
+
+	operators := update.IsOperatorDocument(doc)
+	multi := wireTruthy(spec["multi"])
+
+	if multi && !operators {
+		return 0, nil, newWireError(wireCodeFailedToParse, "multi update is only supported with update operators")
+	}
+
+	opt := options.Find()
+	if !multi {
+		opt.SetLimit(1)
+	}
+
+	result := coll.Find(filter, opt)
+	if result.Err != nil {
+		return 0, nil, result.Err
+	}
+
+	if len(result.raw) == 0 {
+		if !wireTruthy(spec["upsert"]) {
+			return 0, nil, nil
+		}
+
+		newDoc, err := update.NewDocument(filter, doc)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if _, ok := newDoc[consts.DocumentFieldID]; !ok {
+			newDoc[consts.DocumentFieldID] = primitive.NewObjectID()
+		}
+
+		if err := coll.InsertOne(newDoc).Err; err != nil {
+			return 0, nil, err
+		}
+
+		return 0, newDoc[consts.DocumentFieldID], nil
+	}
+
+	if multi {
+		res := coll.UpdateMany(filter, doc)
+
+		return int(res.MatchedCount), nil, res.Err
+	}
+
+	n := 0
+
+	for _, kv := range result.raw {
+		id := kv.Document()[consts.DocumentFieldID]
+
+		res := coll.UpdateOne(map[string]any{consts.DocumentFieldID: id}, doc, options.Update().SetSet(!operators))
+		if res.Err != nil {
+			return n, nil, res.Err
+		}
+
+		n++
+	}
+
+	return n, nil, nil
+}
+
+// wireDelete runs delete statements.
+func wireDelete(s *WireServer, req *wireRequest) (primitive.D, error) {
+	ns, err := s.writeNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := req.array("deletes")
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := req.boolean("ordered", true)
+	writeErrors := primitive.A{}
+	deleted := 0
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	for i, v := range statements {
+		n, err := deleteStatement(ns.as(req.conn.user), v)
+		deleted += n
+
+		if err != nil {
+			writeErrors = append(writeErrors, wireWriteError(i, err))
+
+			if ordered {
+				break
+			}
+		}
+	}
+
+	reply := primitive.D{{Key: "n", Value: int32(deleted)}}
+	if len(writeErrors) > 0 {
+		reply = append(reply, primitive.E{Key: "writeErrors", Value: writeErrors})
+	}
+
+	return reply, nil
+}
+
+// deleteStatement runs a delete statement and returns the number of deleted documents.
+func deleteStatement(coll *Collection, v any) (int, error) {
+	spec, ok := bson.Normalize(v).(map[string]any)
+	if !ok {
+		return 0, newWireError(wireCodeTypeMismatch, "delete statements must be documents")
+	}
+
+	filter, _ := spec["q"].(map[string]any)
+	if filter == nil {
+		filter = map[string]any{}
+	}
+
+	limit, _ := wireInt(spec["limit"])
+
+	switch limit {
+	case 0:
+		result := coll.Delete(filter)
+
+		return len(result.DeletedIDs), result.Err
+	case 1:
+		result := coll.DeleteOne(filter)
+		if errors.Is(result.Err, ErrDocumentNotFound) {
+			return 0, nil
+		}
+
+		if result.Err != nil {
+			return 0, result.Err
+		}
+
+		return 1, nil
+	default:
+		return 0, newWireError(wireCodeFailedToParse, "delete limit must be 0 or 1")
+	}
+}
+
+// wireFindAndModify updates, replaces or removes the first document matching a query and returns
+// it, in a single transaction.
+func wireFindAndModify(s *WireServer, req *wireRequest) (primitive.D, error) {
+	ns, err := s.writeNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := req.document("query")
+	if err != nil {
+		return nil, err
+	}
+
+	sortSpec, err := req.ordered("sort")
+	if err != nil {
+		return nil, err
+	}
+
+	sort, err := parseSortSpec(sortSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := req.document("fields")
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := req.document("update")
+	if err != nil {
+		return nil, err
+	}
+
+	_, hasUpdate := req.lookup("update")
+	remove := req.boolean("remove", false)
+
+	switch {
+	case remove && hasUpdate:
+		return nil, newWireError(wireCodeFailedToParse, "cannot specify both an update and remove=true")
+	case remove && req.boolean("new", false):
+		return nil, newWireError(wireCodeFailedToParse, "cannot specify both new=true and remove=true")
+	case !remove && !hasUpdate:
+		return nil, newWireError(wireCodeFailedToParse, "either an update or remove=true must be specified")
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	coll := ns.as(req.conn.user)
+	lastError := primitive.D{}
+
+	var result FindOneResult
+
+	if remove {
+		result = coll.FindOneAndDeleteContext(req.conn.ctx, filter, &options.FindOneAndDeleteOptions{Sort: sort, Projection: fields})
+		if result.Err == nil {
+			lastError = append(lastError, primitive.E{Key: "n", Value: int32(1)})
+		}
+	} else {
+		updateOpt := options.Update().
+			SetSet(!update.IsOperatorDocument(doc)).
+			SetUpsert(req.boolean("upsert", false))
+
+		var one UpdateOneResult
+
+		result, one = coll.findOneAndUpdate(req.conn.ctx, filter, doc, sort, fields, updateOpt, req.boolean("new", false))
+
+		switch {
+		case one.Err != nil:
+		case one.matched.Key != "":
+			lastError = append(lastError,
+				primitive.E{Key: "n", Value: int32(1)},
+				primitive.E{Key: "updatedExisting", Value: true},
+			)
+		default:
+			lastError = append(lastError,
+				primitive.E{Key: "n", Value: int32(1)},
+				primitive.E{Key: "updatedExisting", Value: false},
+				primitive.E{Key: "upserted", Value: one.UpsertedID},
+			)
+		}
+	}
+
+	var value any
+
+	switch {
+	case errors.Is(result.Err, ErrDocumentNotFound):
+		if len(lastError) == 0 {
+			lastError = append(lastError, primitive.E{Key: "n", Value: int32(0)})
+		}
+	case result.Err != nil:
+		return nil, result.Err
+	default:
+		value = bson.Raw(result.raw.Value)
+	}
+
+	if !remove && len(lastError) == 1 {
+		lastError = append(lastError, primitive.E{Key: "updatedExisting", Value: false})
+	}
+
+	return primitive.D{
+		{Key: "lastErrorObject", Value: lastError},
+		{Key: "value", Value: value},
+	}, nil
+}
+
+// wireCount counts the documents matching a query.
+func wireCount(s *WireServer, req *wireRequest) (primitive.D, error) {
+	collname, err := req.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := req.document("query")
+	if err != nil {
+		return nil, err
+	}
+
+	skip, err := req.integer("skip", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := req.integer("limit", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := s.catalog.collection(req.db, collname)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if skip <= 0 && limit == 0 {
+		n, err := ns.coll.CountDocuments(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		return primitive.D{{Key: "n", Value: int32(n)}}, nil
+	}
+
+	opt := options.Find()
+
+	if skip > 0 {
+		opt.SetSkip(skip)
+	}
+
+	if limit != 0 {
+		opt.SetLimit(max(limit, -limit))
+	}
+
+	result := ns.coll.Find(filter, opt)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	return primitive.D{{Key: "n", Value: int32(len(result.raw))}}, nil
+}
+
+// wireCollStats returns the storage statistics of a collection.
+func wireCollStats(s *WireServer, req *wireRequest) (primitive.D, error) {
+	collname, err := req.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := s.catalog.collection(req.db, collname)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	stats, err := ns.coll.StatsContext(req.conn.ctx)
+	ns.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collStatsDocument(stats), nil
+}
+
+// wireDBStats returns the storage statistics of a database.
+func wireDBStats(s *WireServer, req *wireRequest) (primitive.D, error) {
+	db, err := s.catalog.database(req.db)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := db.StatsContext(req.conn.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return dbStatsDocument(stats), nil
+}
+
+// wireCreate creates an empty collection.
+func wireCreate(s *WireServer, req *wireRequest) (primitive.D, error) {
+	ns, err := s.writeNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	m := ns.coll.IndexManager
+
+	if _, err := m.storage.Get(m.buildMetadataKey()); err == nil {
+		return nil, newWireError(wireCodeNamespaceExists, fmt.Sprintf("collection %s already exists", req.namespace(m.collname)))
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, err
+	}
+
+	opt, err := req.collectionOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	validation, err := newCollectionValidation(nil, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return primitive.D{}, m.updateMetadata(func(meta *CollectionMetadata) error {
+		meta.Validation = validation
+
+		return nil
+	})
+}
+
+// wireCollMod changes the validator, validation level or validation action of a collection.
+func wireCollMod(s *WireServer, req *wireRequest) (primitive.D, error) {
+	ns, err := s.writeNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+
+	opt, err := req.collectionOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	m := ns.coll.IndexManager
+
+	if _, err := m.storage.Get(m.buildMetadataKey()); errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, req.namespace(m.collname))
+	} else if err != nil {
+		return nil, err
+	}
+
+	return primitive.D{}, ns.coll.SetValidation(opt)
+}
+
+// wireCreateIndexes creates indexes and builds them before replying.
+func wireCreateIndexes(s *WireServer, req *wireRequest) (primitive.D, error) {
+	ns, err := s.writeNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := req.array("indexes")
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]IndexModel, 0, len(specs))
+
+	for _, v := range specs {
+		spec, ok := v.(primitive.D)
+		if !ok {
+			return nil, newWireError(wireCodeTypeMismatch, "index specifications must be documents")
+		}
+
+		model, err := parseIndexSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		models = append(models, *model)
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	m := ns.coll.IndexManager
+	before := len(visibleIndexes(m.List()))
+
+	if err := createIndexes(context.Background(), ns.as(req.conn.user), models); err != nil {
+		if errors.Is(err, ErrMissingFieldForIndex) {
+			return nil, newWireError(wireCodeCannotCreateIndex, fmt.Sprintf("index build failed: %v", err))
+		}
+
+		return nil, err
+	}
+
+	return primitive.D{
+		{Key: "createdCollectionAutomatically", Value: false},
+		{Key: "numIndexesBefore", Value: int32(before)},
+		{Key: "numIndexesAfter", Value: int32(len(visibleIndexes(m.current().Indexes)))},
+	}, nil
+}
+
+// wireListIndexes lists the indexes of a collection.
+func wireListIndexes(s *WireServer, req *wireRequest) (primitive.D, error) {
+	collname, err := req.collection()
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := s.catalog.collection(req.db, collname)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	indexes := visibleIndexes(ns.coll.IndexManager.List())
+	ns.mu.Unlock()
+
+	docs := make([]bson.Raw, 0, len(indexes))
+
+	for _, idx := range indexes {
+		data, err := bson.Marshal(append(primitive.D{{Key: "v", Value: int32(2)}}, indexSpec(idx)...))
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, data)
+	}
+
+	return s.cursorReply(req.namespace(collname), docs, -1, false), nil
+}
+
+// wireListCollections lists the collections of a database.
+func wireListCollections(s *WireServer, req *wireRequest) (primitive.D, error) {
+	db, err := s.catalog.database(req.db)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := req.document("filter")
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := queryengine.ParseFilter(filter)
+	if err != nil {
+		return nil, newWireError(wireCodeBadValue, err.Error())
+	}
+
+	names, err := db.collectionNames()
+	if err != nil {
+		return nil, err
+	}
+
+	nameOnly := req.boolean("nameOnly", false)
+	docs := make([]bson.Raw, 0, len(names))
+
+	for _, name := range names {
+		if !expr.Evaluate(map[string]any{"name": name, "type": "collection"}) {
+			continue
+		}
+
+		info := primitive.D{
+			{Key: "name", Value: name},
+			{Key: "type", Value: "collection"},
+		}
+
+		if !nameOnly {
+			info = append(info,
+				primitive.E{Key: "options", Value: primitive.D{}},
+				primitive.E{Key: "info", Value: primitive.D{{Key: "readOnly", Value: db.follower.Load()}}},
+				primitive.E{Key: "idIndex", Value: primitive.D{
+					{Key: "v", Value: int32(2)},
+					{Key: "key", Value: primitive.D{{Key: consts.DocumentFieldID, Value: int32(1)}}},
+					{Key: "name", Value: "_id_"},
+				}},
+			)
+		}
+
+		data, err := bson.Marshal(info)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, data)
+	}
+
+	return s.cursorReply(req.namespace("$cmd.listCollections"), docs, -1, false), nil
+}
+
+// wireDrop drops a collection.
+func wireDrop(s *WireServer, req *wireRequest) (primitive.D, error) {
+	ns, err := s.writeNamespace(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	indexes := len(visibleIndexes(ns.coll.IndexManager.List()))
+
+	err = ns.as(req.conn.user).DropContext(req.conn.ctx)
+	s.forgetNamespace(req.db, ns.coll.collname)
+
+	if errors.Is(err, ErrCollectionNotFound) {
+		return nil, newWireError(wireCodeNamespaceNotFound, "ns not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return primitive.D{
+		{Key: "nIndexesWas", Value: int32(indexes)},
+		{Key: "ns", Value: req.namespace(ns.coll.collname)},
+	}, nil
+}
+
+// wireDropDatabase drops every collection of a database and its metadata.
+func wireDropDatabase(s *WireServer, req *wireRequest) (primitive.D, error) {
+	err := s.catalog.dropDatabase(req.db, req.conn.user, func(collname string) {
+		s.forgetNamespace(req.db, collname)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return primitive.D{{Key: "dropped", Value: req.db}}, nil
+}
+
+// wireRenameCollection renames a collection. It runs on the admin database with full namespaces;
+// both must be in the same database.
+func wireRenameCollection(s *WireServer, req *wireRequest) (primitive.D, error) {
+	from, to, err := req.renameNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	if from.Database != to.Database {
+		return nil, newWireError(wireCodeBadValue, "renaming a collection across databases is not supported")
+	}
+
+	if from == to {
+		return nil, newWireError(wireCodeIllegalOperation, "cannot rename a collection to itself")
+	}
+
+	err = s.catalog.renameCollection(from.Database, from.Collection, to.Collection, req.conn.user,
+		req.boolean("dropTarget", false), func(collname string) { s.forgetNamespace(from.Database, collname) })
+	if err != nil {
+		return nil, err
+	}
+
+	return primitive.D{}, nil
+}
+
+// renameNamespaces returns the source and target namespaces of a renameCollection command.
+func (r *wireRequest) renameNamespaces() (Namespace, Namespace, error) {
+	source, _ := r.cmd[0].Value.(string)
+	target, _ := r.lookup("to")
+	to, _ := target.(string)
+
+	from, ok := parseWireNamespace(source)
+	if !ok {
+		return Namespace{}, Namespace{}, newWireError(wireCodeInvalidNamespace, fmt.Sprintf("invalid source namespace: %s", source))
+	}
+
+	dest, ok := parseWireNamespace(to)
+	if !ok {
+		return Namespace{}, Namespace{}, newWireError(wireCodeInvalidNamespace, fmt.Sprintf("invalid target namespace: %s", to))
+	}
+
+	return from, dest, nil
+}
+
+// parseWireNamespace splits a db.collection namespace.
+func parseWireNamespace(ns string) (Namespace, bool) {
+	db, coll, ok := strings.Cut(ns, ".")
+	if !ok || db == "" || coll == "" {
+		return Namespace{}, false
+	}
+
+	return Namespace{Database: db, Collection: coll}, true
+}
+
+// wireInt converts a numeric BSON value to an int64.
+func wireInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// wireTruthy reports whether a BSON value counts as true in a command.
+func wireTruthy(v any) bool {
+	switch b := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return b
+	default:
+		if n, ok := wireInt(v); ok {
+			return n != 0
+		}
+
+		return true
+	}
+}
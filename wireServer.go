@@ -0,0 +1,399 @@
+package gopherdb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wirvii/gopherdb/internal/bson"
+	"github.com/wirvii/gopherdb/internal/wire"
+	"github.com/wirvii/gopherdb/options"
+	"github.com/xdg-go/scram"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// defaultCursorTimeout is how long an idle server-side cursor lives by default.
+	defaultCursorTimeout = 10 * time.Minute
+	// defaultBatchSize is the number of documents of a first batch when the client sets none.
+	defaultBatchSize = 101
+	// maxBatchBytes is the largest size of the documents of a reply batch.
+	maxBatchBytes = wire.MaxDocumentSize - 64*1024
+)
+
+// WireServer serves the databases of a data directory over the MongoDB wire protocol, so
+// MongoDB drivers and tools can use gopherdb as a standalone server. Every database of the
+// directory shares the same storage engine; a handle is opened the first time a command names it.
+type WireServer struct {
+	catalog       *catalog
+	users         *Users
+	cursorTimeout time.Duration
+
+	mu      sync.Mutex
+	cursors map[int64]*wireCursor
+
+	cursorID atomic.Int64
+	connID   atomic.Int64
+}
+
+// wireConn is the state of a client connection.
+type wireConn struct {
+	id int64
+	// ctx is done when the connection is closed, cancelling the commands it runs.
+	ctx  context.Context
+	user *User
+	conv *scram.ServerConversation
+}
+
+// wireCursor is a server-side cursor holding the documents a client has not fetched yet.
+type wireCursor struct {
+	id      int64
+	ns      string
+	docs    []bson.Raw
+	lastUse time.Time
+}
+
+// NewWireServer opens the data directory at path to serve it over the MongoDB wire protocol.
+func NewWireServer(path string, opts ...*options.WireServerOptions) (*WireServer, error) {
+	opt := options.WireServer()
+	if len(opts) > 0 {
+		opt = opt.Merge(opts...)
+	}
+
+	dbOpt := options.Database()
+	if opt.Database != nil {
+		dbOpt = dbOpt.Merge(opt.Database)
+	}
+
+	cat, err := openCatalog(path, dbOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &WireServer{
+		catalog:       cat,
+		cursorTimeout: defaultCursorTimeout,
+		cursors:       make(map[int64]*wireCursor),
+	}
+
+	if opt.CursorTimeout != nil && *opt.CursorTimeout > 0 {
+		s.cursorTimeout = *opt.CursorTimeout
+	}
+
+	if opt.Auth != nil && *opt.Auth {
+		s.users = &Users{catalog: cat}
+	}
+
+	s.cursorID.Store(rand.Int64N(1 << 40))
+
+	return s, nil
+}
+
+// Serve accepts wire protocol connections from the listener. It blocks until the context is done
+// or the listener fails.
+func (s *WireServer) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		s.reapCursors(ctx)
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// Close closes the databases and the storage engine. It must be called after Serve returns.
+func (s *WireServer) Close() error {
+	s.mu.Lock()
+	s.cursors = make(map[int64]*wireCursor)
+	s.mu.Unlock()
+
+	return s.catalog.close()
+}
+
+// serveConn serves the commands of a connection until it is closed or the context is done.
+func (s *WireServer) serveConn(ctx context.Context, conn net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	wc := &wireConn{id: s.connID.Add(1), ctx: ctx}
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	var requestID int32
+
+	for {
+		msg, err := wire.ReadMessage(r)
+		if msg == nil {
+			return
+		}
+
+		var reply primitive.D
+		if err != nil {
+			reply = wireErrorReply(err)
+		} else {
+			reply = s.handle(msg, wc)
+		}
+
+		requestID++
+
+		if msg.OpCode == wire.OpQuery {
+			err = wire.WriteReply(w, requestID, msg.RequestID, reply)
+		} else if msg.Flags&wire.FlagMoreToCome == 0 {
+			err = wire.WriteMsg(w, requestID, msg.RequestID, reply)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle runs the command of a message and returns its reply.
+func (s *WireServer) handle(msg *wire.Message, conn *wireConn) primitive.D {
+	cmd, err := msg.Command()
+	if err != nil {
+		return wireErrorReply(err)
+	}
+
+	if len(cmd) == 0 {
+		return wireErrorReply(newWireError(wireCodeBadValue, "empty command"))
+	}
+
+	req := &wireRequest{
+		name: cmd[0].Key,
+		db:   msg.Database(),
+		cmd:  cmd,
+		conn: conn,
+	}
+
+	run, ok := wireCommands[strings.ToLower(req.name)]
+	if !ok {
+		return wireErrorReply(newWireError(wireCodeCommandNotFound, fmt.Sprintf("no such command: '%s'", req.name)))
+	}
+
+	if err := s.authorize(req); err != nil {
+		return wireErrorReply(err)
+	}
+
+	reply, err := run(s, req)
+	if err != nil {
+		return wireErrorReply(err)
+	}
+
+	return append(reply, primitive.E{Key: "ok", Value: 1.0})
+}
+
+// authorize checks that the connection may run a command when the server requires
+// authentication.
+func (s *WireServer) authorize(req *wireRequest) error {
+	name := strings.ToLower(req.name)
+	if s.users == nil || wirePublicCommands[name] {
+		return nil
+	}
+
+	if req.conn.user == nil {
+		return fmt.Errorf("%w: command %s requires authentication", ErrAuthenticationRequired, req.name)
+	}
+
+	act, ok := wireCommandActions[name]
+	if !ok {
+		return nil
+	}
+
+	if name == "renamecollection" {
+		from, to, err := req.renameNamespaces()
+		if err != nil {
+			return err
+		}
+
+		for _, ns := range []Namespace{from, to} {
+			if !req.conn.user.can(act, ns.Database, ns.Collection) {
+				return fmt.Errorf("%w: %s on %s to execute command %s", ErrUnauthorized, req.conn.user.Name, ns.Database, req.name)
+			}
+		}
+
+		return nil
+	}
+
+	collname, _ := req.cmd[0].Value.(string)
+	if name == "getmore" {
+		v, _ := req.lookup("collection")
+		collname, _ = v.(string)
+	}
+
+	if !req.conn.user.can(act, req.db, collname) {
+		return fmt.Errorf("%w: %s on %s to execute command %s", ErrUnauthorized, req.conn.user.Name, req.db, req.name)
+	}
+
+	return nil
+}
+
+// forgetNamespace drops the cached handle of a collection and its cursors.
+func (s *WireServer) forgetNamespace(dbname, collname string) {
+	s.catalog.forget(dbname, collname)
+
+	key := dbname + "." + collname
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, c := range s.cursors {
+		if c.ns == key {
+			delete(s.cursors, id)
+		}
+	}
+}
+
+// cursorReply builds the cursor document of a reply with the first batch of the documents. A
+// server-side cursor keeps the rest unless the client asked for a single batch.
+func (s *WireServer) cursorReply(ns string, docs []bson.Raw, batchSize int64, singleBatch bool) primitive.D {
+	if batchSize < 0 {
+		batchSize = defaultBatchSize
+	}
+
+	batch, rest := splitBatch(docs, batchSize)
+
+	var id int64
+
+	if len(rest) > 0 && !singleBatch {
+		id = s.cursorID.Add(1)
+
+		s.mu.Lock()
+		s.cursors[id] = &wireCursor{id: id, ns: ns, docs: rest, lastUse: time.Now()}
+		s.mu.Unlock()
+	}
+
+	return primitive.D{
+		{Key: "cursor", Value: primitive.D{
+			{Key: "firstBatch", Value: batch},
+			{Key: "id", Value: id},
+			{Key: "ns", Value: ns},
+		}},
+	}
+}
+
+// nextBatch returns the next batch of a cursor. Exhausted cursors are removed.
+func (s *WireServer) nextBatch(id int64, ns string, batchSize int64) (primitive.A, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.cursors[id]
+	if !ok || c.ns != ns {
+		return nil, 0, newWireError(wireCodeCursorNotFound, fmt.Sprintf("cursor id %d not found", id))
+	}
+
+	if batchSize <= 0 {
+		batchSize = int64(len(c.docs))
+	}
+
+	batch, rest := splitBatch(c.docs, batchSize)
+	c.docs = rest
+	c.lastUse = time.Now()
+
+	if len(rest) == 0 {
+		delete(s.cursors, id)
+
+		return batch, 0, nil
+	}
+
+	return batch, id, nil
+}
+
+// killCursor removes a cursor of a namespace. It reports whether the cursor existed; cursors of
+// other namespaces are not found, as in nextBatch.
+func (s *WireServer) killCursor(id int64, ns string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.cursors[id]
+	if !ok || c.ns != ns {
+		return false
+	}
+
+	delete(s.cursors, id)
+
+	return true
+}
+
+// reapCursors removes the cursors idle for longer than the cursor timeout until the context is
+// done.
+func (s *WireServer) reapCursors(ctx context.Context) {
+	ticker := time.NewTicker(max(s.cursorTimeout/4, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+
+			for id, c := range s.cursors {
+				if now.Sub(c.lastUse) > s.cursorTimeout {
+					delete(s.cursors, id)
+				}
+			}
+
+			s.mu.Unlock()
+		}
+	}
+}
+
+// splitBatch splits the documents into a batch of at most n documents that fits in a reply and
+// the rest.
+func splitBatch(docs []bson.Raw, n int64) (primitive.A, []bson.Raw) {
+	batch := make(primitive.A, 0, min(int64(len(docs)), n))
+	size := 0
+
+	for i, doc := range docs {
+		if int64(i) >= n || (i > 0 && size+len(doc) > maxBatchBytes) {
+			return batch, docs[i:]
+		}
+
+		batch = append(batch, doc)
+		size += len(doc)
+	}
+
+	return batch, nil
+}
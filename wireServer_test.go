@@ -0,0 +1,110 @@
+package gopherdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/wirvii/gopherdb/options"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// runTestWireCommand authorizes and runs a command as the user, like handle does for a message.
+func runTestWireCommand(t *testing.T, s *WireServer, user *User, db string, cmd primitive.D) (primitive.D, error) {
+	t.Helper()
+
+	req := &wireRequest{name: cmd[0].Key, db: db, cmd: cmd, conn: &wireConn{ctx: context.Background(), user: user}}
+
+	if err := s.authorize(req); err != nil {
+		return nil, err
+	}
+
+	return wireCommands[strings.ToLower(req.name)](s, req)
+}
+
+// testWireUser creates a user of the server with the grants.
+func testWireUser(t *testing.T, s *WireServer, name string, grants ...RoleGrant) *User {
+	t.Helper()
+
+	if err := s.users.Create(name, "secret-"+name, grants...); err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+
+	user, err := s.users.Get(name)
+	if err != nil {
+		t.Fatalf("get user %s: %v", name, err)
+	}
+
+	return user
+}
+
+func TestWireKillCursorsOfOtherNamespace(t *testing.T) {
+	s, err := NewWireServer(t.TempDir(), options.WireServer().SetAuth(true))
+	if err != nil {
+		t.Fatalf("open server: %v", err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+
+	alice := testWireUser(t, s, "alice", RoleGrant{Role: RoleReadWrite, Database: "app", Collection: "orders"})
+	bob := testWireUser(t, s, "bob", RoleGrant{Role: RoleRead, Database: "app", Collection: "notes"})
+
+	docs := primitive.A{primitive.D{{Key: "_id", Value: 1}}, primitive.D{{Key: "_id", Value: 2}}}
+	if _, err := runTestWireCommand(t, s, alice, "app", primitive.D{
+		{Key: "insert", Value: "orders"},
+		{Key: "documents", Value: docs},
+	}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	reply, err := runTestWireCommand(t, s, alice, "app", primitive.D{
+		{Key: "find", Value: "orders"},
+		{Key: "batchSize", Value: 1},
+	})
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+
+	cursor, _ := reply.Map()["cursor"].(primitive.D)
+	id, _ := cursor.Map()["id"].(int64)
+
+	if id == 0 {
+		t.Fatalf("find returned no open cursor: %v", reply)
+	}
+
+	reply, err = runTestWireCommand(t, s, bob, "app", primitive.D{
+		{Key: "killCursors", Value: "notes"},
+		{Key: "cursors", Value: primitive.A{id}},
+	})
+	if err != nil {
+		t.Fatalf("kill cursors as bob: %v", err)
+	}
+
+	if killed := reply.Map()["cursorsKilled"].(primitive.A); len(killed) != 0 {
+		t.Fatalf("bob killed the cursors %v of another namespace", killed)
+	}
+
+	if notFound := reply.Map()["cursorsNotFound"].(primitive.A); len(notFound) != 1 || notFound[0] != id {
+		t.Fatalf("cursors not found %v, want [%d]", notFound, id)
+	}
+
+	if _, err := runTestWireCommand(t, s, bob, "app", primitive.D{
+		{Key: "killCursors", Value: "orders"},
+		{Key: "cursors", Value: primitive.A{id}},
+	}); err == nil {
+		t.Fatal("bob killed cursors of a collection without read access")
+	}
+
+	reply, err = runTestWireCommand(t, s, alice, "app", primitive.D{
+		{Key: "getMore", Value: id},
+		{Key: "collection", Value: "orders"},
+	})
+	if err != nil {
+		t.Fatalf("get more after the kill attempts: %v", err)
+	}
+
+	cursor, _ = reply.Map()["cursor"].(primitive.D)
+	if batch, _ := cursor.Map()["nextBatch"].(primitive.A); len(batch) != 1 {
+		t.Fatalf("next batch %v, want the second document", batch)
+	}
+}